@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordPrivateAccess is the composite key prefix for private data access log entries
+const RecordPrivateAccess = "privateAccess~deliveryId~txId"
+
+// EventPrivateDetailsAccessed is emitted when an org logs a read of private details
+const EventPrivateDetailsAccessed = "DeliveryPrivateDetailsAccessed"
+
+// PrivateAccessRecord is a public record of an org reading a recipient's private details
+// DataHash identifies the version that was read without revealing it.
+type PrivateAccessRecord struct {
+	DeliveryID    string `json:"deliveryId"`
+	TxID          string `json:"txId"`
+	Collection    string `json:"collection"`
+	DataHash      string `json:"dataHash"`
+	Purpose       string `json:"purpose"`
+	AccessedBy    string `json:"accessedBy"`
+	AccessedByMSP string `json:"accessedByMsp"`
+	AccessedAt    string `json:"accessedAt"`
+	CorrelationID string `json:"correlationId,omitempty" metadata:",optional"`
+}
+
+// LogPrivateAccess records on the public ledger that the caller read a delivery's private details
+// Submit it alongside each GetDeliveryPrivateDetails evaluation; the details themselves are
+// never returned from a submitted transaction, since results are stored in the block.
+func (c *DeliveryContract) LogPrivateAccess(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	purpose string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	purpose = sanitizeText(purpose)
+	if err := validateRequiredText(purpose, "purpose", 200); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, participantRoles...); err != nil {
+		return err
+	}
+
+	// Same orgs as GetDeliveryPrivateDetails
+	if caller.MSP != MSPPlatform && caller.MSP != MSPSellers && caller.MSP != MSPLogistics {
+		return fmt.Errorf("only PlatformOrg, SellersOrg, and LogisticsOrg can read delivery private details")
+	}
+
+	// The hash is readable by every peer, so endorsers outside the collection agree on it
+	dataHash, err := ctx.GetStub().GetPrivateDataHash(CollectionDeliveryPrivate, deliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to get private details hash: %v", err)
+	}
+	if dataHash == nil {
+		return fmt.Errorf("private details not found for delivery %s", deliveryID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	txID := ctx.GetStub().GetTxID()
+
+	record := PrivateAccessRecord{
+		DeliveryID:    deliveryID,
+		TxID:          txID,
+		Collection:    CollectionDeliveryPrivate,
+		DataHash:      fmt.Sprintf("%x", dataHash),
+		Purpose:       purpose,
+		AccessedBy:    caller.ID,
+		AccessedByMSP: caller.MSP,
+		AccessedAt:    currentTime,
+		CorrelationID: correlationID(ctx),
+	}
+
+	recordKey, err := ctx.GetStub().CreateCompositeKey(RecordPrivateAccess, []string{deliveryID, txID})
+	if err != nil {
+		return fmt.Errorf("failed to create access log composite key: %v", err)
+	}
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal access log record: %v", err)
+	}
+	if err := ctx.GetStub().PutState(recordKey, recordJSON); err != nil {
+		return fmt.Errorf("failed to put access log record: %v", err)
+	}
+
+	return emitEvent(ctx, EventPrivateDetailsAccessed, record)
+}
+
+// GetPrivateAccessLog returns every logged read of a delivery's private details
+// Only ADMIN and AUDITOR can audit access
+func (c *DeliveryContract) GetPrivateAccessLog(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*PrivateAccessRecord, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN and AUDITOR can audit access
+	if err := validateRole(ctx, caller, RoleAdmin, RoleAuditor); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordPrivateAccess, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access log: %v", err)
+	}
+	defer iterator.Close()
+
+	records := []*PrivateAccessRecord{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate access log: %v", err)
+		}
+
+		var record PrivateAccessRecord
+		if err := json.Unmarshal(response.Value, &record); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal access log record: %v", err)
+		}
+		records = append(records, &record)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// iso3166Alpha2 lists the officially assigned ISO 3166-1 alpha-2 country codes
+var iso3166Alpha2 = stringSet(`
+AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS BT BV
+BW BY BZ CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE EG EH ER ES
+ET FI FJ FK FM FO FR GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM HN HR HT HU ID IE
+IL IM IN IO IQ IR IS IT JE JM JO JP KE KG KH KI KM KN KP KR KW KY KZ LA LB LC LI LK LR LS LT LU LV LY
+MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ NA NC NE NF NG NI NL NO NP NR NU
+NZ OM PA PE PF PG PH PK PL PM PN PR PS PT PW PY QA RE RO RS RU RW SA SB SC SD SE SG SH SI SJ SK SL SM
+SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ UA UG UM US UY UZ VA VC VE
+VG VI VN VU WF WS YE YT ZA ZM ZW`)
+
+// postalCodePatterns are the postal code formats of countries with a well-defined one
+// Codes are matched after normalizePostalCode; other countries only get the generic check.
+var postalCodePatterns = map[string]*regexp.Regexp{
+	"US": regexp.MustCompile(`^[0-9]{5}(-[0-9]{4})?$`),
+	"CA": regexp.MustCompile(`^[A-Z][0-9][A-Z] [0-9][A-Z][0-9]$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}[0-9][A-Z0-9]? [0-9][A-Z]{2}$`),
+	"BR": regexp.MustCompile(`^[0-9]{5}-?[0-9]{3}$`),
+	"DE": regexp.MustCompile(`^[0-9]{5}$`),
+	"FR": regexp.MustCompile(`^[0-9]{5}$`),
+	"ES": regexp.MustCompile(`^[0-9]{5}$`),
+	"IT": regexp.MustCompile(`^[0-9]{5}$`),
+	"MX": regexp.MustCompile(`^[0-9]{5}$`),
+	"NL": regexp.MustCompile(`^[0-9]{4} ?[A-Z]{2}$`),
+	"PT": regexp.MustCompile(`^[0-9]{4}-[0-9]{3}$`),
+	"AU": regexp.MustCompile(`^[0-9]{4}$`),
+	"JP": regexp.MustCompile(`^[0-9]{3}-?[0-9]{4}$`),
+	"IN": regexp.MustCompile(`^[0-9]{6}$`),
+	"CN": regexp.MustCompile(`^[0-9]{6}$`),
+}
+
+// genericPostalCodePattern accepts the letters, digits, spaces and dashes postal codes are made of
+var genericPostalCodePattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{0,9}$`)
+
+// stringSet builds a lookup set from whitespace-separated values
+func stringSet(values string) map[string]bool {
+	set := map[string]bool{}
+	for _, value := range strings.Fields(values) {
+		set[value] = true
+	}
+	return set
+}
+
+// collapseSpaces sanitizes a value and reduces internal runs of whitespace to one space
+func collapseSpaces(value string) string {
+	return strings.Join(strings.Fields(sanitizeText(value)), " ")
+}
+
+// normalizeLocation trims and cases location fields so equal places are stored identically
+// Countries and short state codes ("ny") are upper-cased; city and state names keep their case.
+func normalizeLocation(city, state, country string) (string, string, string) {
+	city = collapseSpaces(city)
+	state = collapseSpaces(state)
+	if len(state) <= 3 {
+		state = strings.ToUpper(state)
+	}
+	return city, state, strings.ToUpper(collapseSpaces(country))
+}
+
+// validateCountryCode checks a country is an ISO 3166-1 alpha-2 code
+func validateCountryCode(country string) error {
+	if !iso3166Alpha2[country] {
+		return &ValidationError{Field: "country", Message: "must be an ISO 3166-1 alpha-2 code (e.g. US)"}
+	}
+	return nil
+}
+
+// normalizePostalCode trims and upper-cases a postal code
+func normalizePostalCode(postalCode string) string {
+	return strings.ToUpper(collapseSpaces(postalCode))
+}
+
+// validatePostalCode checks a normalized postal code against its country's format
+func validatePostalCode(postalCode string, country string) error {
+	if pattern, ok := postalCodePatterns[country]; ok {
+		if !pattern.MatchString(postalCode) {
+			return &ValidationError{Field: "deliveryPostalCode", Message: "is not a valid " + country + " postal code"}
+		}
+		return nil
+	}
+	if !genericPostalCodePattern.MatchString(postalCode) {
+		return &ValidationError{Field: "deliveryPostalCode", Message: "must be 1-10 letters, digits, spaces or dashes"}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Certificate attributes scoping staff to part of the marketplace
+const (
+	RegionAttribute     = "region"
+	DepartmentAttribute = "department"
+)
+
+// configAdminRegionsName is the config record holding the AdminRegions
+const configAdminRegionsName = "adminRegions"
+
+// scopeNamePattern restricts region and department names (e.g. "BR-South")
+var scopeNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// AdminRegions maps admin region names to the areas they cover
+// A regional ADMIN (certificate attribute region=BR-South) only acts on deliveries whose
+// destination is covered by the region's areas.
+type AdminRegions map[string][]ServiceArea
+
+// callerScope reads the optional region and department attributes of a certificate
+func callerScope(clientIdentity cid.ClientIdentity) (string, string, error) {
+	values := []string{}
+	for _, attribute := range []string{RegionAttribute, DepartmentAttribute} {
+		value, _, err := clientIdentity.GetAttributeValue(attribute)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get %s attribute: %v", attribute, err)
+		}
+		if value != "" && !scopeNamePattern.MatchString(value) {
+			return "", "", &ValidationError{Field: attribute, Message: "must be 1-64 characters of letters, digits, '-' or '_'"}
+		}
+		values = append(values, value)
+	}
+	return values[0], values[1], nil
+}
+
+// deliveryDestination returns where a delivery is headed
+// Deliveries created before destinations were kept (or from templates) use their last location.
+func deliveryDestination(delivery *Delivery) Location {
+	if delivery.Destination != nil {
+		return *delivery.Destination
+	}
+	return delivery.LastLocation
+}
+
+// getAdminRegions reads the configured admin regions (empty if none)
+func getAdminRegions(ctx contractapi.TransactionContextInterface) (AdminRegions, error) {
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configAdminRegionsName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	regionsJSON, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admin regions: %v", err)
+	}
+
+	regions := AdminRegions{}
+	if regionsJSON != nil {
+		if err := json.Unmarshal(regionsJSON, &regions); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal admin regions: %v", err)
+		}
+	}
+	return regions, nil
+}
+
+// markInheritedAdmin records that the caller acts as ADMIN through the permission model
+func markInheritedAdmin(ctx contractapi.TransactionContextInterface) {
+	if tenantCtx, ok := ctx.(*TenantTransactionContext); ok {
+		tenantCtx.inheritedAdmin = true
+	}
+}
+
+// actsAsAdmin reports whether the caller's certificate is ADMIN or validateRole let it act as ADMIN
+func actsAsAdmin(ctx contractapi.TransactionContextInterface, clientIdentity cid.ClientIdentity) bool {
+	if tenantCtx, ok := ctx.(*TenantTransactionContext); ok && tenantCtx.inheritedAdmin {
+		return true
+	}
+	role, err := callerRole(clientIdentity)
+	return err == nil && role == RoleAdmin
+}
+
+// validateAdminScope rejects a regional ADMIN acting on a delivery headed outside their region
+// Only the certificate is inspected, so it is cheap enough to run on every delivery write;
+// other roles and admins without a region attribute are not restricted. Roles acting as ADMIN
+// through the permission model are scoped like ADMIN certificates.
+// Reads are deliberately not scoped: a package passes through hubs of other regions before it
+// reaches its destination, and the dispute, fraud and stalled queues are triaged across the
+// marketplace. Tenant isolation still applies to every read.
+func validateAdminScope(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
+	clientIdentity := ctx.GetClientIdentity()
+	if delivery == nil || clientIdentity == nil {
+		return nil
+	}
+	if !actsAsAdmin(ctx, clientIdentity) {
+		return nil
+	}
+	region, _, err := callerScope(clientIdentity)
+	if err != nil {
+		return err
+	}
+	if region == "" {
+		return nil
+	}
+
+	regions, err := getAdminRegions(ctx)
+	if err != nil {
+		return err
+	}
+	areas, ok := regions[region]
+	if !ok {
+		return fmt.Errorf("admin region %s is not configured", region)
+	}
+	destination := deliveryDestination(delivery)
+	for _, area := range areas {
+		if area.covers(destination) {
+			return nil
+		}
+	}
+	return fmt.Errorf("admins of region %s cannot act on delivery %s headed for %s, %s, %s", region,
+		delivery.DeliveryID, destination.City, destination.State, destination.Country)
+}
+
+// validateUnscopedAdmin checks the caller is an ADMIN of the whole marketplace
+// Used by functions whose effect isn't tied to one delivery's destination, such as
+// configuration, the organization registry and device registration.
+func validateUnscopedAdmin(ctx contractapi.TransactionContextInterface, caller *CallerIdentity) error {
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
+		return err
+	}
+	if caller.Region != "" {
+		return fmt.Errorf("admins scoped to region %s cannot change marketplace-wide configuration", caller.Region)
+	}
+	return nil
+}
+
+// SetAdminRegions replaces the admin regions of the caller's tenant
+// Only an ADMIN without a region can set them. regionsJSON maps region names to JSON arrays of
+// {city, state, country} areas, as in SetServiceCoverage.
+func (c *DeliveryContract) SetAdminRegions(
+	ctx contractapi.TransactionContextInterface,
+	regionsJSON string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	var regions AdminRegions
+	if err := json.Unmarshal([]byte(regionsJSON), &regions); err != nil {
+		return fmt.Errorf("failed to parse admin regions: %v", err)
+	}
+	for region, areas := range regions {
+		if !scopeNamePattern.MatchString(region) {
+			return &ValidationError{Field: "region", Message: "must be 1-64 characters of letters, digits, '-' or '_'"}
+		}
+		if len(areas) == 0 || len(areas) > maxServiceAreas {
+			return &ValidationError{Field: region, Message: fmt.Sprintf("must list between 1 and %d areas", maxServiceAreas)}
+		}
+		for i, area := range areas {
+			area.City, area.State, area.Country = normalizeLocation(area.City, area.State, area.Country)
+			if err := validateCountryCode(area.Country); err != nil {
+				return err
+			}
+			if err := validateText(area.State, "state", 100, false); err != nil {
+				return err
+			}
+			if err := validateText(area.City, "city", 100, false); err != nil {
+				return err
+			}
+			if area.City != "" && area.State == "" {
+				return &ValidationError{Field: region, Message: fmt.Sprintf("city %s needs a state", area.City)}
+			}
+			areas[i] = area
+		}
+	}
+	if regions == nil {
+		regions = AdminRegions{}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - regional admins can't redraw their own region
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configAdminRegionsName})
+	if err != nil {
+		return fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	updatedJSON, err := json.Marshal(regions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal admin regions: %v", err)
+	}
+
+	return ctx.GetStub().PutState(configKey, updatedJSON)
+}
+
+// GetAdminRegions returns the admin regions of the caller's tenant
+func (c *DeliveryContract) GetAdminRegions(
+	ctx contractapi.TransactionContextInterface,
+) (AdminRegions, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	return getAdminRegions(ctx)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegionalAdminCannotCallMarketplaceWideFunctions(t *testing.T) {
+	regionalAdmin := testIdentity{mspID: MSPPlatform, id: "admin2", role: string(RoleAdmin),
+		attrs: map[string]string{RegionAttribute: "North"}}
+
+	tests := []struct {
+		function string
+		args     []string
+	}{
+		{"RegisterOrganization", []string{"CarrierMSP", "Carrier", `["DELIVERY_PERSON"]`, "true"}},
+		{"SetOrganizationActive", []string{MSPLogistics, "false"}},
+		{"MigrateEndorsementPolicies", []string{MSPLogistics, MSPSellers, "10", ""}},
+		{"CommitMilestoneRoot", nil},
+		{"AnchorDigest", []string{testDeliveryID, "digest", "tx1", "ethereum", "0xabc"}},
+		{"SetCourierCapacity", []string{testCourier.id, "5"}},
+		{"RegisterWeighingStation", []string{"station1", MSPLogistics, "CERT-1", "2030-01-01T00:00:00Z"}},
+		{"RegisterSensorGateway", []string{"gateway1", MSPLogistics, testCourier.id}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.function, func(t *testing.T) {
+			network := newTestNetwork(t)
+			_, err := network.invoke(regionalAdmin, tt.function, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), "admins scoped to region North") {
+				t.Fatalf("expected the regional admin to be rejected, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRegionalAdminCannotWriteDeliveriesOutsideRegion(t *testing.T) {
+	network := newTestNetwork(t)
+	if _, err := network.invoke(testAdmin, "SetAdminRegions", `{"North":[{"country":"BR"}]}`); err != nil {
+		t.Fatalf("failed to set admin regions: %v", err)
+	}
+	regionalAdmin := testIdentity{mspID: MSPPlatform, id: "admin2", role: string(RoleAdmin),
+		attrs: map[string]string{RegionAttribute: "North"}}
+	inRegion := func(id string) *Delivery {
+		delivery := testDelivery(id, StatusInTransit)
+		delivery.Destination = &Location{City: "Recife", State: "PE", Country: "BR"}
+		return delivery
+	}
+	write := func(delivery *Delivery) error {
+		return network.transaction(regionalAdmin, func(ctx *TenantTransactionContext) error {
+			return applyDeliveryUpdate(ctx, delivery)
+		})
+	}
+
+	// A new delivery headed for Lisbon, outside the North region
+	outside := testDelivery(testDeliveryID, StatusInTransit)
+	outside.Destination = &Location{City: "Lisbon", State: "Lisbon", Country: "PT"}
+	if err := write(outside); err == nil || !strings.Contains(err.Error(), "admins of region North cannot act") {
+		t.Fatalf("expected the regional admin to be rejected creating a delivery outside the region, got %v", err)
+	}
+	if network.stub.State[testDeliveryID] != nil {
+		t.Errorf("delivery outside the region was written")
+	}
+
+	if err := write(inRegion("DEL-20260101-0000BR01")); err != nil {
+		t.Fatalf("regional admin could not write a delivery in its region: %v", err)
+	}
+
+	// Redirecting a delivery of the region elsewhere takes it out of the admin's reach
+	network.putDelivery(inRegion("DEL-20260101-0000BR02"))
+	redirected := network.getDelivery("DEL-20260101-0000BR02")
+	redirected.Destination = &Location{City: "Lisbon", State: "Lisbon", Country: "PT"}
+	if err := write(redirected); err == nil || !strings.Contains(err.Error(), "admins of region North cannot act") {
+		t.Fatalf("expected the regional admin to be rejected redirecting out of the region, got %v", err)
+	}
+	if got := network.getDelivery("DEL-20260101-0000BR02").Destination; got == nil || got.City != "Recife" {
+		t.Errorf("delivery was redirected to %+v", got)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// CollectionAgeVerification holds ID-check attestations for age-restricted deliveries
+// Collection: deliveryAgeVerification (PlatformOrg and LogisticsOrg only)
+const CollectionAgeVerification = "deliveryAgeVerification"
+
+// TransientAgeVerification is the transient map key for the courier's ID-check attestation
+const TransientAgeVerification = "ageVerification"
+
+// AgeAttestationType is the kind of document the courier checked
+type AgeAttestationType string
+
+const (
+	AttestationIDCard        AgeAttestationType = "ID_CARD"
+	AttestationPassport      AgeAttestationType = "PASSPORT"
+	AttestationDriverLicense AgeAttestationType = "DRIVER_LICENSE"
+)
+
+// AgeVerificationAttestation is the courier's statement that the recipient's ID was checked
+// Only a hash of the document is kept, never the document itself
+type AgeVerificationAttestation struct {
+	DeliveryID      string             `json:"deliveryId"`
+	AttestationType AgeAttestationType `json:"attestationType"`
+	DocumentHash    string             `json:"documentHash"`
+	CourierID       string             `json:"courierId"`
+	RecipientID     string             `json:"recipientId"`
+	VerifiedAt      string             `json:"verifiedAt"`
+}
+
+// storeAgeVerification validates and stores the attestation supplied with a final handoff
+// Required when confirming delivery of an age-restricted package to the customer
+func storeAgeVerification(ctx contractapi.TransactionContextInterface, delivery *Delivery, currentTime string) error {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient data: %v", err)
+	}
+
+	attestationJSON, exists := transientMap[TransientAgeVerification]
+	if !exists || len(attestationJSON) == 0 {
+		return fmt.Errorf("age-restricted delivery requires an ID-check attestation (%s)", TransientAgeVerification)
+	}
+
+	var attestation AgeVerificationAttestation
+	if err := json.Unmarshal(attestationJSON, &attestation); err != nil {
+		return fmt.Errorf("failed to parse age verification attestation: %v", err)
+	}
+	switch attestation.AttestationType {
+	case AttestationIDCard, AttestationPassport, AttestationDriverLicense:
+	default:
+		return &ValidationError{Field: "attestationType", Message: fmt.Sprintf("unknown attestation type: %s", attestation.AttestationType)}
+	}
+	if len(attestation.DocumentHash) == 0 || len(attestation.DocumentHash) > 128 {
+		return &ValidationError{Field: "documentHash", Message: "must be between 1 and 128 characters"}
+	}
+
+	// The attesting courier is the one handing over the package
+	attestation.DeliveryID = delivery.DeliveryID
+	attestation.CourierID = delivery.PendingHandoff.FromUserID
+	attestation.RecipientID = delivery.PendingHandoff.ToUserID
+	attestation.VerifiedAt = currentTime
+
+	attestationBytes, err := json.Marshal(attestation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal age verification attestation: %v", err)
+	}
+	if err := ctx.GetStub().PutPrivateData(CollectionAgeVerification, delivery.DeliveryID, attestationBytes); err != nil {
+		return fmt.Errorf("failed to store age verification attestation: %v", err)
+	}
+
+	return nil
+}
+
+// SetAgeRestricted marks a delivery as age-restricted (alcohol, tobacco, ...)
+// Only the SELLER can set the flag, and only before pickup
+func (c *DeliveryContract) SetAgeRestricted(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	ageRestricted bool,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER can flag deliveries
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	if delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller can change age restriction")
+	}
+	if delivery.DeliveryStatus != StatusPendingPickup {
+		return fmt.Errorf("age restriction can only be changed before pickup")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	delivery.AgeRestricted = ageRestricted
+	delivery.UpdatedAt = currentTime
+
+	return applyDeliveryUpdate(ctx, delivery)
+}
+
+// GetAgeVerification returns the ID-check attestation of a delivered age-restricted package
+// Only PlatformOrg and LogisticsOrg members can read it
+func (c *DeliveryContract) GetAgeVerification(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) (*AgeVerificationAttestation, error) {
+	// Extract caller identity
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse, RoleCustomer, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if caller.MSP != MSPPlatform && caller.MSP != MSPLogistics {
+		return nil, fmt.Errorf("only PlatformOrg and LogisticsOrg can read age verification attestations")
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	attestationBytes, err := ctx.GetStub().GetPrivateData(CollectionAgeVerification, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get age verification attestation: %v", err)
+	}
+	if attestationBytes == nil {
+		return nil, fmt.Errorf("age verification attestation not found for delivery %s", deliveryID)
+	}
+
+	var attestation AgeVerificationAttestation
+	if err := json.Unmarshal(attestationBytes, &attestation); err != nil {
+		return nil, fmt.Errorf("failed to parse age verification attestation: %v", err)
+	}
+
+	return &attestation, nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EventPackageAmended is emitted when a seller amends package details before pickup
+const EventPackageAmended = "PackageAmended"
+
+// RecordPackageAmendment is the composite key prefix for amendment records
+const RecordPackageAmendment = "amendment~deliveryId~txId"
+
+// AmendmentRecord keeps the before/after values of a package detail amendment
+// Amendments are append-only so the original seller-entered values are never lost
+type AmendmentRecord struct {
+	DeliveryID           string            `json:"deliveryId"`
+	TxID                 string            `json:"txId"`
+	OldPackageWeight     float64           `json:"oldPackageWeight"`
+	OldPackageDimensions PackageDimensions `json:"oldPackageDimensions"`
+	NewPackageWeight     float64           `json:"newPackageWeight"`
+	NewPackageDimensions PackageDimensions `json:"newPackageDimensions"`
+	Reason               string            `json:"reason"`
+	AmendedBy            string            `json:"amendedBy"`
+	AmendedAt            string            `json:"amendedAt"`
+	CorrelationID        string            `json:"correlationId,omitempty" metadata:",optional"`
+}
+
+// AmendPackageDetails corrects the weight/dimensions of a package before pickup
+// Only the SELLER of the delivery can amend, and only while PENDING_PICKUP
+func (c *DeliveryContract) AmendPackageDetails(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	packageWeight float64,
+	dimensionLength float64,
+	dimensionWidth float64,
+	dimensionHeight float64,
+	reason string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	units, err := getMeasurementUnits(ctx)
+	if err != nil {
+		return err
+	}
+	packageWeight = units.toKilograms(packageWeight)
+	dimensionLength = units.toCentimeters(dimensionLength)
+	dimensionWidth = units.toCentimeters(dimensionWidth)
+	dimensionHeight = units.toCentimeters(dimensionHeight)
+	if err := validatePackageWeight(packageWeight); err != nil {
+		return err
+	}
+	if err := validateDimension(dimensionLength, "dimensionLength"); err != nil {
+		return err
+	}
+	if err := validateDimension(dimensionWidth, "dimensionWidth"); err != nil {
+		return err
+	}
+	if err := validateDimension(dimensionHeight, "dimensionHeight"); err != nil {
+		return err
+	}
+	reason = sanitizeText(reason)
+	if err := validateReason(reason); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER can amend package details
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	// Verify caller is the seller for this delivery
+	if delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller can amend this delivery")
+	}
+
+	// Package details are frozen once a courier is involved
+	if delivery.DeliveryStatus != StatusPendingPickup {
+		return fmt.Errorf("package details can only be amended before pickup")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	txID := ctx.GetStub().GetTxID()
+
+	amendment := AmendmentRecord{
+		DeliveryID:           deliveryID,
+		TxID:                 txID,
+		OldPackageWeight:     delivery.PackageWeight,
+		OldPackageDimensions: delivery.PackageDimensions,
+		NewPackageWeight:     packageWeight,
+		NewPackageDimensions: PackageDimensions{
+			Length: dimensionLength,
+			Width:  dimensionWidth,
+			Height: dimensionHeight,
+		},
+		Reason:        reason,
+		AmendedBy:     caller.ID,
+		AmendedAt:     currentTime,
+		CorrelationID: correlationID(ctx),
+	}
+
+	amendmentKey, err := ctx.GetStub().CreateCompositeKey(RecordPackageAmendment, []string{deliveryID, txID})
+	if err != nil {
+		return fmt.Errorf("failed to create amendment composite key: %v", err)
+	}
+	amendmentJSON, err := json.Marshal(amendment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal amendment: %v", err)
+	}
+	if err := ctx.GetStub().PutState(amendmentKey, amendmentJSON); err != nil {
+		return fmt.Errorf("failed to put amendment record: %v", err)
+	}
+
+	before := *delivery
+	delivery.PackageWeight = amendment.NewPackageWeight
+	delivery.DeclaredWeight = amendment.NewPackageWeight
+	delivery.PackageDimensions = amendment.NewPackageDimensions
+	delivery.UpdatedAt = currentTime
+
+	if err := recordCorrection(ctx, "AmendPackageDetails", reason, &before, delivery, caller); err != nil {
+		return err
+	}
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventPackageAmended, amendment)
+}
+
+// GetPackageAmendments returns all amendment records for a delivery
+// Any party involved in the delivery (or admin) can read them
+func (c *DeliveryContract) GetPackageAmendments(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*AmendmentRecord, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordPackageAmendment, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get amendments: %v", err)
+	}
+	defer iterator.Close()
+
+	amendments := []*AmendmentRecord{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate amendments: %v", err)
+		}
+
+		var amendment AmendmentRecord
+		if err := json.Unmarshal(response.Value, &amendment); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal amendment: %v", err)
+		}
+		amendments = append(amendments, &amendment)
+	}
+
+	return amendments, nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// DigestAlgorithm identifies how ComputeStateDigest chains the history entries
+const DigestAlgorithm = "sha256-history-chain-v1"
+
+// RecordDigestAnchor is the composite key prefix for external anchor records
+const RecordDigestAnchor = "anchor~deliveryId~txId"
+
+// EventDigestAnchored is emitted when a state digest is anchored on an external chain
+const EventDigestAnchored = "DigestAnchored"
+
+// StateDigest is a canonical commitment to a delivery and its custody history
+type StateDigest struct {
+	DeliveryID string `json:"deliveryId"`
+	Algorithm  string `json:"algorithm"`
+	Digest     string `json:"digest"`
+	AsOfTxID   string `json:"asOfTxId"`
+	Entries    int    `json:"entries"`
+}
+
+// DigestAnchor records where a state digest was published outside Fabric
+type DigestAnchor struct {
+	DeliveryID    string `json:"deliveryId"`
+	TxID          string `json:"txId"`
+	Digest        string `json:"digest"`
+	AsOfTxID      string `json:"asOfTxId"`
+	Chain         string `json:"chain"`
+	AnchorRef     string `json:"anchorRef"`
+	AnchoredBy    string `json:"anchoredBy"`
+	AnchoredAt    string `json:"anchoredAt"`
+	DigestVersion string `json:"digestVersion"`
+}
+
+// historyEntry is one committed version of a delivery key
+type historyEntry struct {
+	txID      string
+	timestamp time.Time
+	isDelete  bool
+	value     []byte
+}
+
+// readHistoryEntries returns every committed version of a delivery key
+// Entries are ordered by commit timestamp then txID, so every peer sees the same order.
+func readHistoryEntries(ctx contractapi.TransactionContextInterface, deliveryID string) ([]historyEntry, error) {
+	iterator, err := ctx.GetStub().GetHistoryForKey(deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history for delivery: %v", err)
+	}
+	defer iterator.Close()
+
+	var entries []historyEntry
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate history: %v", err)
+		}
+		entry := historyEntry{
+			txID:     response.TxId,
+			isDelete: response.IsDelete,
+			value:    response.Value,
+		}
+		if response.Timestamp != nil {
+			entry.timestamp = response.Timestamp.AsTime()
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].timestamp.Equal(entries[j].timestamp) {
+			return entries[i].timestamp.Before(entries[j].timestamp)
+		}
+		return entries[i].txID < entries[j].txID
+	})
+	return entries, nil
+}
+
+// computeStateDigest chains the history of a delivery key up to and including asOfTxID
+// Each link is sha256(prev || txId || timestamp || isDelete || sha256(value)) over the stored bytes.
+// An empty asOfTxID digests the full history.
+func computeStateDigest(ctx contractapi.TransactionContextInterface, deliveryID, asOfTxID string) (*StateDigest, error) {
+	entries, err := readHistoryEntries(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("delivery %s has no history", deliveryID)
+	}
+
+	chain := sha256.Sum256(nil)
+	count := 0
+	found := asOfTxID == ""
+	for _, entry := range entries {
+		valueHash := sha256.Sum256(entry.value)
+		h := sha256.New()
+		h.Write(chain[:])
+		h.Write([]byte(entry.txID))
+		h.Write([]byte(entry.timestamp.UTC().Format(time.RFC3339Nano)))
+		h.Write([]byte(strconv.FormatBool(entry.isDelete)))
+		h.Write(valueHash[:])
+		copy(chain[:], h.Sum(nil))
+		count++
+
+		if entry.txID == asOfTxID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("transaction %s is not part of the history of delivery %s", asOfTxID, deliveryID)
+	}
+
+	return &StateDigest{
+		DeliveryID: deliveryID,
+		Algorithm:  DigestAlgorithm,
+		Digest:     fmt.Sprintf("%x", chain),
+		AsOfTxID:   entries[count-1].txID,
+		Entries:    count,
+	}, nil
+}
+
+// ComputeStateDigest returns the canonical digest of a delivery and its custody history
+// Any participant can compute it; pass asOfTxID to reproduce the digest of an earlier anchor
+func (c *DeliveryContract) ComputeStateDigest(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	asOfTxID string,
+) (*StateDigest, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	return computeStateDigest(ctx, deliveryID, asOfTxID)
+}
+
+// AnchorDigest records that a delivery's state digest was published on an external chain
+// Only ADMIN can anchor; the digest must match the Fabric history as of asOfTxID
+func (c *DeliveryContract) AnchorDigest(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	digest string,
+	asOfTxID string,
+	chain string,
+	anchorRef string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	if len(asOfTxID) == 0 {
+		return &ValidationError{Field: "asOfTxID", Message: "is required"}
+	}
+	if len(chain) == 0 || len(chain) > 64 {
+		return &ValidationError{Field: "chain", Message: "must be between 1 and 64 characters"}
+	}
+	if len(anchorRef) == 0 || len(anchorRef) > 256 {
+		return &ValidationError{Field: "anchorRef", Message: "must be between 1 and 256 characters"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - digests cover every region, so only an unscoped ADMIN anchors them
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	// History up to an already-committed transaction is immutable, so the recomputation
+	// is stable between endorsement and commit
+	computed, err := computeStateDigest(ctx, deliveryID, asOfTxID)
+	if err != nil {
+		return err
+	}
+	if computed.Digest != digest {
+		return fmt.Errorf("digest does not match delivery %s as of transaction %s", deliveryID, asOfTxID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	txID := ctx.GetStub().GetTxID()
+
+	anchor := DigestAnchor{
+		DeliveryID:    deliveryID,
+		TxID:          txID,
+		Digest:        digest,
+		AsOfTxID:      asOfTxID,
+		Chain:         chain,
+		AnchorRef:     anchorRef,
+		AnchoredBy:    caller.ID,
+		AnchoredAt:    currentTime,
+		DigestVersion: DigestAlgorithm,
+	}
+
+	anchorKey, err := ctx.GetStub().CreateCompositeKey(RecordDigestAnchor, []string{deliveryID, txID})
+	if err != nil {
+		return fmt.Errorf("failed to create anchor composite key: %v", err)
+	}
+	anchorJSON, err := json.Marshal(anchor)
+	if err != nil {
+		return fmt.Errorf("failed to marshal anchor: %v", err)
+	}
+	if err := ctx.GetStub().PutState(anchorKey, anchorJSON); err != nil {
+		return fmt.Errorf("failed to put anchor record: %v", err)
+	}
+
+	return emitEvent(ctx, EventDigestAnchored, anchor)
+}
+
+// GetDigestAnchors returns the external anchors recorded for a delivery
+func (c *DeliveryContract) GetDigestAnchors(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*DigestAnchor, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordDigestAnchor, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get anchors: %v", err)
+	}
+	defer iterator.Close()
+
+	anchors := []*DigestAnchor{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate anchors: %v", err)
+		}
+
+		var anchor DigestAnchor
+		if err := json.Unmarshal(response.Value, &anchor); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal anchor: %v", err)
+		}
+		anchors = append(anchors, &anchor)
+	}
+
+	return anchors, nil
+}
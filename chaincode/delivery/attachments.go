@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordAttachment is the composite key prefix for attachments registered on a delivery
+const RecordAttachment = "attachment~deliveryId~sha256"
+
+// EventAttachmentRegistered is emitted when an attachment is registered on a delivery
+const EventAttachmentRegistered = "AttachmentRegistered"
+
+// AttachmentType is the kind of file an attachment references
+type AttachmentType string
+
+const (
+	AttachmentPhoto     AttachmentType = "PHOTO"
+	AttachmentSignature AttachmentType = "SIGNATURE"
+	AttachmentDocument  AttachmentType = "DOCUMENT"
+)
+
+// Attachment is a content-addressed reference to a file kept off-chain
+// The file is verified by hashing it and comparing with SHA256; the storage URI is only
+// stored as a hash so the ledger doesn't leak where the file lives.
+type Attachment struct {
+	DeliveryID     string         `json:"deliveryId"`
+	Type           AttachmentType `json:"type"`
+	SHA256         string         `json:"sha256"`
+	SizeBytes      int64          `json:"sizeBytes"`
+	StorageURIHash string         `json:"storageUriHash"`
+	RegisteredBy   string         `json:"registeredBy"`
+	RegisteredAt   string         `json:"registeredAt"`
+}
+
+// evidenceReferences reports whether a hash was captured as photo or signature evidence of any
+// handoff of the delivery, current or past
+func evidenceReferences(ctx contractapi.TransactionContextInterface, delivery *Delivery, hash string) (bool, error) {
+	matches := func(version *Delivery) bool {
+		evidence := append(append([]HandoffEvidence{}, version.PickupEvidence...), version.DeliveryEvidence...)
+		if version.PendingHandoff != nil {
+			evidence = append(evidence, version.PendingHandoff.Evidence...)
+		}
+		for _, e := range evidence {
+			if strings.EqualFold(e.PhotoHash, hash) || strings.EqualFold(e.SignatureHash, hash) {
+				return true
+			}
+		}
+		return false
+	}
+	if matches(delivery) {
+		return true, nil
+	}
+
+	// Evidence of intermediate handoffs only survives in the history of the delivery key
+	entries, err := readHistoryEntries(ctx, delivery.DeliveryID)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.isDelete || len(entry.value) == 0 {
+			continue
+		}
+		var version Delivery
+		if err := json.Unmarshal(entry.value, &version); err != nil {
+			return false, fmt.Errorf("failed to unmarshal delivery: %v", err)
+		}
+		if matches(&version) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RegisterAttachment records a content-addressed reference to a photo, signature or document
+// Parties of the delivery can register attachments; the hash must have been captured as
+// evidence of one of its handoffs. Each file (by hash) is registered once per delivery.
+func (c *DeliveryContract) RegisterAttachment(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	attachmentType string,
+	sha256Hash string,
+	sizeBytes int64,
+	storageURIHash string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	switch AttachmentType(attachmentType) {
+	case AttachmentPhoto, AttachmentSignature, AttachmentDocument:
+	default:
+		return &ValidationError{Field: "type", Message: "must be PHOTO, SIGNATURE or DOCUMENT"}
+	}
+	if !identityHashPattern.MatchString(sha256Hash) {
+		return &ValidationError{Field: "sha256", Message: "must be a hex-encoded SHA-256 digest"}
+	}
+	if sizeBytes <= 0 {
+		return &ValidationError{Field: "sizeBytes", Message: "must be positive"}
+	}
+	if !identityHashPattern.MatchString(storageURIHash) {
+		return &ValidationError{Field: "storageUriHash", Message: "must be a hex-encoded SHA-256 digest"}
+	}
+	sha256Hash = strings.ToLower(sha256Hash)
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, participantRoles...); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if err := validateParty(delivery, caller); err != nil {
+		return err
+	}
+
+	referenced, err := evidenceReferences(ctx, delivery, sha256Hash)
+	if err != nil {
+		return err
+	}
+	if !referenced {
+		return fmt.Errorf("hash %s is not part of any handoff evidence of delivery %s", sha256Hash, deliveryID)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(RecordAttachment, []string{deliveryID, sha256Hash})
+	if err != nil {
+		return fmt.Errorf("failed to create attachment composite key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read attachment: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("attachment %s is already registered", sha256Hash)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	attachment := Attachment{
+		DeliveryID:     deliveryID,
+		Type:           AttachmentType(attachmentType),
+		SHA256:         sha256Hash,
+		SizeBytes:      sizeBytes,
+		StorageURIHash: strings.ToLower(storageURIHash),
+		RegisteredBy:   caller.ID,
+		RegisteredAt:   currentTime,
+	}
+	attachmentJSON, err := json.Marshal(attachment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attachment: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, attachmentJSON); err != nil {
+		return fmt.Errorf("failed to put attachment: %v", err)
+	}
+
+	return emitEvent(ctx, EventAttachmentRegistered, attachment)
+}
+
+// ListAttachments returns the attachments registered on a delivery
+// Parties of the delivery and FULL-scope grantees can list them.
+func (c *DeliveryContract) ListAttachments(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*Attachment, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordAttachment, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments: %v", err)
+	}
+	defer iterator.Close()
+
+	attachments := []*Attachment{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate attachments: %v", err)
+		}
+
+		var attachment Attachment
+		if err := json.Unmarshal(response.Value, &attachment); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal attachment: %v", err)
+		}
+		attachments = append(attachments, &attachment)
+	}
+
+	return attachments, nil
+}
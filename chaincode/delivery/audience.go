@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EventAudience tells off-chain event routers who an event concerns
+// It is added to event payloads as "audience", so notifications can be fanned out without
+// re-reading and re-authorizing the delivery for every event.
+type EventAudience struct {
+	TenantID string   `json:"tenantId,omitempty"`
+	UserIDs  []string `json:"userIds"`
+	MSPs     []string `json:"msps"`
+}
+
+// audienceSet collects the audience of the deliveries written by a transaction
+type audienceSet struct {
+	tenantID string
+	userIDs  map[string]bool
+	msps     map[string]bool
+}
+
+// newAudienceSet returns an empty audience
+func newAudienceSet() *audienceSet {
+	return &audienceSet{userIDs: map[string]bool{}, msps: map[string]bool{}}
+}
+
+// addDelivery adds a delivery's parties: seller, customer, custodian, pending handoff and
+// reassignment counterparts and grantees, with the orgs they belong to
+func (a *audienceSet) addDelivery(delivery *Delivery) {
+	if delivery == nil || delivery.Tombstone != nil {
+		return
+	}
+	a.tenantID = delivery.TenantID
+
+	userIDs := []string{delivery.SellerID, delivery.CustomerID, delivery.CurrentCustodianID}
+	msps := []string{roleToMSP[RoleSeller], roleToMSP[RoleCustomer], custodianMSPOf(delivery), delivery.CarrierOfRecord}
+	if handoff := delivery.PendingHandoff; handoff != nil {
+		userIDs = append(userIDs, handoff.FromUserID, handoff.ToUserID)
+		msps = append(msps, roleToMSP[handoff.ToRole], handoff.ToCarrierMSP)
+	}
+	if hasPendingReassignment(delivery) {
+		userIDs = append(userIDs, delivery.Reassignment.ToUserID)
+	}
+	for _, grant := range delivery.AccessGrants {
+		userIDs = append(userIDs, grant.GranteeID)
+	}
+
+	for _, userID := range userIDs {
+		if userID != "" {
+			a.userIDs[userID] = true
+		}
+	}
+	for _, msp := range msps {
+		if msp != "" {
+			a.msps[msp] = true
+		}
+	}
+}
+
+// audience returns the collected audience with sorted, deduplicated members
+func (a *audienceSet) audience() *EventAudience {
+	audience := &EventAudience{TenantID: a.tenantID, UserIDs: []string{}, MSPs: []string{}}
+	for userID := range a.userIDs {
+		audience.UserIDs = append(audience.UserIDs, userID)
+	}
+	for msp := range a.msps {
+		audience.MSPs = append(audience.MSPs, msp)
+	}
+	sort.Strings(audience.UserIDs)
+	sort.Strings(audience.MSPs)
+	return audience
+}
+
+// addEventAudience records the parties of a delivery version for the transaction's event
+// Called by applyDeliveryUpdate with both the previous and the new version, so parties that
+// just lost custody or were corrected away still hear about it.
+func addEventAudience(ctx contractapi.TransactionContextInterface, deliveries ...*Delivery) {
+	tenantCtx, ok := ctx.(*TenantTransactionContext)
+	if !ok {
+		return
+	}
+	if tenantCtx.audience == nil {
+		tenantCtx.audience = newAudienceSet()
+	}
+	for _, delivery := range deliveries {
+		tenantCtx.audience.addDelivery(delivery)
+	}
+}
+
+// withEventAudience adds the transaction's audience to a JSON object payload
+// Payloads of transactions that wrote no delivery are returned unchanged.
+func withEventAudience(ctx contractapi.TransactionContextInterface, payloadBytes []byte) []byte {
+	tenantCtx, ok := ctx.(*TenantTransactionContext)
+	if !ok || tenantCtx.audience == nil {
+		return payloadBytes
+	}
+	return attachAudience(payloadBytes, tenantCtx.audience)
+}
+
+// attachAudience adds an audience to a JSON object payload that doesn't have one yet
+func attachAudience(payloadBytes []byte, audience *audienceSet) []byte {
+	if len(audience.userIDs) == 0 {
+		return payloadBytes
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payloadBytes, &fields); err != nil || fields == nil {
+		return payloadBytes
+	}
+	if _, exists := fields["audience"]; exists {
+		return payloadBytes
+	}
+	audienceBytes, err := json.Marshal(audience.audience())
+	if err != nil {
+		return payloadBytes
+	}
+	fields["audience"] = audienceBytes
+
+	withAudience, err := json.Marshal(fields)
+	if err != nil {
+		return payloadBytes
+	}
+	return withAudience
+}
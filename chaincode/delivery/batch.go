@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Event names for batch handoffs
+// Fabric keeps one event per transaction, so a batch emits a single summary event
+const (
+	EventBatchHandoffInitiated = "BatchHandoffInitiated"
+	EventBatchHandoffConfirmed = "BatchHandoffConfirmed"
+)
+
+// maxBatchSize bounds the number of deliveries handled in one batch transaction
+const maxBatchSize = 100
+
+// BatchHandoffResult is the outcome of a batch handoff for a single delivery
+type BatchHandoffResult struct {
+	DeliveryID string         `json:"deliveryId"`
+	OldStatus  DeliveryStatus `json:"oldStatus"`
+	NewStatus  DeliveryStatus `json:"newStatus"`
+}
+
+// BatchMeasurement is an optional per-delivery re-measurement at batch confirmation
+type BatchMeasurement struct {
+	PackageWeight     float64           `json:"packageWeight"`
+	PackageDimensions PackageDimensions `json:"packageDimensions"`
+}
+
+// parseBatchDeliveryIDs parses and validates a JSON array of delivery IDs for a batch
+func parseBatchDeliveryIDs(deliveryIDsJSON string) ([]string, error) {
+	var deliveryIDs []string
+	if err := json.Unmarshal([]byte(deliveryIDsJSON), &deliveryIDs); err != nil {
+		return nil, fmt.Errorf("failed to parse delivery IDs: %v", err)
+	}
+	if len(deliveryIDs) == 0 {
+		return nil, &ValidationError{Field: "deliveryIDs", Message: "at least one delivery ID is required"}
+	}
+	if len(deliveryIDs) > maxBatchSize {
+		return nil, &ValidationError{Field: "deliveryIDs", Message: fmt.Sprintf("exceeds maximum batch size of %d", maxBatchSize)}
+	}
+
+	seen := make(map[string]bool)
+	for _, deliveryID := range deliveryIDs {
+		if err := validateDeliveryID(deliveryID); err != nil {
+			return nil, err
+		}
+		if seen[deliveryID] {
+			return nil, &ValidationError{Field: "deliveryIDs", Message: fmt.Sprintf("duplicate delivery ID %s", deliveryID)}
+		}
+		seen[deliveryID] = true
+	}
+	return deliveryIDs, nil
+}
+
+// batchError aggregates the per-delivery failures of a batch into a single error
+// Returning it aborts the transaction, so either every delivery is handed off or none is
+func batchError(failures []string) error {
+	return fmt.Errorf("batch rejected, %d deliveries failed: %s", len(failures), strings.Join(failures, "; "))
+}
+
+// InitiateBatchHandoff starts custody transfers of many deliveries to one logistics recipient
+// SELLER, DELIVERY_PERSON, or WAREHOUSE custodians can initiate batch handoffs
+func (c *DeliveryContract) InitiateBatchHandoff(
+	ctx contractapi.TransactionContextInterface,
+	deliveryIDsJSON string,
+	toUserID string,
+	toRole string,
+) ([]*BatchHandoffResult, error) {
+	// ========== INPUT VALIDATION ==========
+	deliveryIDs, err := parseBatchDeliveryIDs(deliveryIDsJSON)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateUserID(toUserID, "toUserID"); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate caller role
+	if err := validateRole(ctx, caller, RoleSeller, RoleDeliveryPerson, RoleWarehouse); err != nil {
+		return nil, err
+	}
+
+	// Batches are for hub transfers; final delivery to customers stays per-parcel
+	targetRole := UserRole(toRole)
+	if targetRole != RoleDeliveryPerson && targetRole != RoleWarehouse {
+		return nil, fmt.Errorf("batch handoffs can only target DELIVERY_PERSON or WAREHOUSE")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*BatchHandoffResult, 0, len(deliveryIDs))
+	var failures []string
+	for _, deliveryID := range deliveryIDs {
+		delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", deliveryID, err))
+			continue
+		}
+
+		oldStatus, err := initiateHandoffInternal(ctx, caller, delivery, toUserID, targetRole, "", nil, currentTime)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", deliveryID, err))
+			continue
+		}
+
+		results = append(results, &BatchHandoffResult{
+			DeliveryID: deliveryID,
+			OldStatus:  oldStatus,
+			NewStatus:  delivery.DeliveryStatus,
+		})
+	}
+	if len(failures) > 0 {
+		return nil, batchError(failures)
+	}
+
+	err = emitEvent(ctx, EventBatchHandoffInitiated, map[string]interface{}{
+		"fromUserId":  caller.ID,
+		"toUserId":    toUserID,
+		"deliveryIds": deliveryIDs,
+		"timestamp":   currentTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ConfirmBatchHandoff confirms many pending handoffs addressed to the caller at one location
+// DELIVERY_PERSON or WAREHOUSE recipients can confirm batch handoffs
+// Re-measured parcels can be passed as a JSON object of deliveryID -> measurement;
+// parcels not listed keep their recorded weight and dimensions.
+func (c *DeliveryContract) ConfirmBatchHandoff(
+	ctx contractapi.TransactionContextInterface,
+	deliveryIDsJSON string,
+	city string,
+	state string,
+	country string,
+	measurementsJSON string,
+) ([]*BatchHandoffResult, error) {
+	// ========== INPUT VALIDATION ==========
+	deliveryIDs, err := parseBatchDeliveryIDs(deliveryIDsJSON)
+	if err != nil {
+		return nil, err
+	}
+	city, state, country = normalizeLocation(city, state, country)
+	if err := validateLocation(city, state, country); err != nil {
+		return nil, err
+	}
+
+	measurements := make(map[string]BatchMeasurement)
+	if measurementsJSON != "" {
+		if err := json.Unmarshal([]byte(measurementsJSON), &measurements); err != nil {
+			return nil, fmt.Errorf("failed to parse measurements: %v", err)
+		}
+	}
+	units, err := getMeasurementUnits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for deliveryID, m := range measurements {
+		m.PackageWeight = units.toKilograms(m.PackageWeight)
+		m.PackageDimensions = units.toMetricDimensions(m.PackageDimensions)
+		measurements[deliveryID] = m
+		if err := validatePackageWeight(m.PackageWeight); err != nil {
+			return nil, fmt.Errorf("%s: %v", deliveryID, err)
+		}
+		if err := validateDimension(m.PackageDimensions.Length, "dimensionLength"); err != nil {
+			return nil, fmt.Errorf("%s: %v", deliveryID, err)
+		}
+		if err := validateDimension(m.PackageDimensions.Width, "dimensionWidth"); err != nil {
+			return nil, fmt.Errorf("%s: %v", deliveryID, err)
+		}
+		if err := validateDimension(m.PackageDimensions.Height, "dimensionHeight"); err != nil {
+			return nil, fmt.Errorf("%s: %v", deliveryID, err)
+		}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse); err != nil {
+		return nil, err
+	}
+
+	// Capacity covers the whole batch, since index writes in this transaction aren't visible to reads
+	if caller.Role == RoleDeliveryPerson {
+		if err := validateCourierCapacity(ctx, caller.ID, len(deliveryIDs)); err != nil {
+			return nil, err
+		}
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	location := Location{
+		City:    city,
+		State:   state,
+		Country: country,
+	}
+
+	results := make([]*BatchHandoffResult, 0, len(deliveryIDs))
+	var failures []string
+	for _, deliveryID := range deliveryIDs {
+		delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", deliveryID, err))
+			continue
+		}
+
+		packageWeight := delivery.PackageWeight
+		dimensions := delivery.PackageDimensions
+		if m, ok := measurements[deliveryID]; ok {
+			packageWeight = m.PackageWeight
+			dimensions = m.PackageDimensions
+		}
+
+		oldStatus, err := confirmHandoffInternal(ctx, caller, delivery, location, packageWeight, dimensions, nil, currentTime)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", deliveryID, err))
+			continue
+		}
+
+		results = append(results, &BatchHandoffResult{
+			DeliveryID: deliveryID,
+			OldStatus:  oldStatus,
+			NewStatus:  delivery.DeliveryStatus,
+		})
+	}
+	if len(failures) > 0 {
+		return nil, batchError(failures)
+	}
+
+	err = emitEvent(ctx, EventBatchHandoffConfirmed, map[string]interface{}{
+		"toUserId":    caller.ID,
+		"deliveryIds": deliveryIDs,
+		"city":        city,
+		"timestamp":   currentTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
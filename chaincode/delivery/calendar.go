@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// configCalendarName is the config record holding the business calendar
+const configCalendarName = "calendar"
+
+// cutoffPattern matches a 24-hour HH:MM local time
+var cutoffPattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// weekdayNames maps calendar day names to weekdays
+var weekdayNames = map[string]time.Weekday{
+	"SUN": time.Sunday,
+	"MON": time.Monday,
+	"TUE": time.Tuesday,
+	"WED": time.Wednesday,
+	"THU": time.Thursday,
+	"FRI": time.Friday,
+	"SAT": time.Saturday,
+}
+
+// expeditedTiers are the service tiers whose promise depends on the order making the day's cutoff
+var expeditedTiers = map[ServiceTier]bool{
+	ServiceTierExpress:   true,
+	ServiceTierOvernight: true,
+}
+
+// RegionCalendar is the working calendar of one region
+// Times are local to the region, given as a fixed UTC offset so every peer computes the same day.
+type RegionCalendar struct {
+	// Working days, e.g. ["MON","TUE","WED","THU","FRI"]
+	WorkingDays []string `json:"workingDays"`
+	// Latest local time (HH:MM) an expedited order is accepted for the same day
+	CutoffTime       string `json:"cutoffTime"`
+	UTCOffsetMinutes int    `json:"utcOffsetMinutes"`
+	// Non-working dates (YYYY-MM-DD)
+	Holidays []string `json:"holidays,omitempty" metadata:",optional"`
+}
+
+// BusinessCalendar maps regions to their calendars
+// A region is a country ("US") or a country and state ("US/CA"); the state entry wins.
+type BusinessCalendar map[string]RegionCalendar
+
+// validate checks a region calendar
+func (rc RegionCalendar) validate(region string) error {
+	if len(rc.WorkingDays) == 0 {
+		return &ValidationError{Field: region, Message: "workingDays must list at least one day"}
+	}
+	for _, day := range rc.WorkingDays {
+		if _, ok := weekdayNames[day]; !ok {
+			return &ValidationError{Field: region, Message: fmt.Sprintf("unknown working day %q (use MON..SUN)", day)}
+		}
+	}
+	if !cutoffPattern.MatchString(rc.CutoffTime) {
+		return &ValidationError{Field: region, Message: "cutoffTime must be HH:MM"}
+	}
+	if rc.UTCOffsetMinutes < -12*60 || rc.UTCOffsetMinutes > 14*60 {
+		return &ValidationError{Field: region, Message: "utcOffsetMinutes must be between -720 and 840"}
+	}
+	for _, holiday := range rc.Holidays {
+		if _, err := time.Parse("2006-01-02", holiday); err != nil {
+			return &ValidationError{Field: region, Message: fmt.Sprintf("holiday %q must be YYYY-MM-DD", holiday)}
+		}
+	}
+	return nil
+}
+
+// local converts a time to the region's local time
+func (rc RegionCalendar) local(t time.Time) time.Time {
+	return t.In(time.FixedZone("", rc.UTCOffsetMinutes*60))
+}
+
+// isWorkingDay reports whether a local date is a working day and not a holiday
+func (rc RegionCalendar) isWorkingDay(local time.Time) bool {
+	date := local.Format("2006-01-02")
+	for _, holiday := range rc.Holidays {
+		if holiday == date {
+			return false
+		}
+	}
+	for _, day := range rc.WorkingDays {
+		if weekdayNames[day] == local.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// beforeCutoff reports whether a local time is before the region's cutoff
+func (rc RegionCalendar) beforeCutoff(local time.Time) bool {
+	return local.Format("15:04") < rc.CutoffTime
+}
+
+// getBusinessCalendar reads the configured business calendar (empty if none)
+func getBusinessCalendar(ctx contractapi.TransactionContextInterface) (BusinessCalendar, error) {
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configCalendarName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	calendarJSON, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read business calendar: %v", err)
+	}
+
+	calendar := BusinessCalendar{}
+	if calendarJSON != nil {
+		if err := json.Unmarshal(calendarJSON, &calendar); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal business calendar: %v", err)
+		}
+	}
+	return calendar, nil
+}
+
+// regionCalendar returns the calendar of a location's region (nil when the region has none)
+func regionCalendar(ctx contractapi.TransactionContextInterface, location Location) (*RegionCalendar, string, error) {
+	calendar, err := getBusinessCalendar(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	country := strings.ToUpper(location.Country)
+	for _, region := range []string{country + "/" + strings.ToUpper(location.State), country} {
+		if rc, ok := calendar[region]; ok {
+			return &rc, region, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// validateServicePromise rejects a new delivery whose tier promises what the origin region can't keep
+// Expedited tiers must be booked on a working day before the regional cutoff and be promised for a
+// working day. STANDARD deliveries are best effort and regions without a calendar are not checked.
+func validateServicePromise(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
+	if !expeditedTiers[delivery.ServiceTier] {
+		return nil
+	}
+	rc, region, err := regionCalendar(ctx, delivery.LastLocation)
+	if err != nil || rc == nil {
+		return err
+	}
+
+	bookedAt, err := time.Parse(time.RFC3339, delivery.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("invalid delivery timestamp: %v", err)
+	}
+	booked := rc.local(bookedAt)
+	if !rc.isWorkingDay(booked) {
+		return fmt.Errorf("%s deliveries cannot be booked on %s, a non-working day in %s", delivery.ServiceTier, booked.Format("2006-01-02"), region)
+	}
+	if !rc.beforeCutoff(booked) {
+		return fmt.Errorf("%s deliveries must be booked before the %s cutoff in %s", delivery.ServiceTier, rc.CutoffTime, region)
+	}
+
+	for _, expectation := range milestoneTemplate(delivery) {
+		if expectation.Milestone != ProgressDelivered {
+			continue
+		}
+		promised := rc.local(bookedAt.Add(time.Duration(expectation.Hours) * time.Hour))
+		if !rc.isWorkingDay(promised) {
+			return fmt.Errorf("%s delivery would be promised for %s, a non-working day in %s", delivery.ServiceTier, promised.Format("2006-01-02"), region)
+		}
+	}
+	return nil
+}
+
+// validateWorkingDay rejects last-mile work scheduled on a non-working day of the location's region
+func validateWorkingDay(ctx contractapi.TransactionContextInterface, location Location, at time.Time) error {
+	rc, region, err := regionCalendar(ctx, location)
+	if err != nil || rc == nil {
+		return err
+	}
+	local := rc.local(at)
+	if !rc.isWorkingDay(local) {
+		return fmt.Errorf("%s is not a working day in %s", local.Format("2006-01-02"), region)
+	}
+	return nil
+}
+
+// SetBusinessCalendar replaces the business calendar of the caller's tenant
+// Only ADMIN can set it. calendarJSON maps regions ("US", "US/CA") to working days, the local
+// cutoff time for expedited bookings, the region's UTC offset and its holidays.
+func (c *DeliveryContract) SetBusinessCalendar(
+	ctx contractapi.TransactionContextInterface,
+	calendarJSON string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	var calendar BusinessCalendar
+	if err := json.Unmarshal([]byte(calendarJSON), &calendar); err != nil {
+		return fmt.Errorf("failed to parse business calendar: %v", err)
+	}
+	normalized := BusinessCalendar{}
+	for region, rc := range calendar {
+		if region == "" || len(region) > 64 {
+			return &ValidationError{Field: "region", Message: "must be between 1 and 64 characters"}
+		}
+		if err := rc.validate(region); err != nil {
+			return err
+		}
+		normalized[strings.ToUpper(region)] = rc
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can change the calendar
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configCalendarName})
+	if err != nil {
+		return fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	updatedJSON, err := json.Marshal(normalized)
+	if err != nil {
+		return fmt.Errorf("failed to marshal business calendar: %v", err)
+	}
+
+	return ctx.GetStub().PutState(configKey, updatedJSON)
+}
+
+// GetBusinessCalendar returns the business calendar of the caller's tenant
+func (c *DeliveryContract) GetBusinessCalendar(
+	ctx contractapi.TransactionContextInterface,
+) (BusinessCalendar, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	return getBusinessCalendar(ctx)
+}
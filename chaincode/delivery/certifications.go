@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// certificationNamePattern restricts certification names to safe attribute suffixes
+var certificationNamePattern = regexp.MustCompile(`^[A-Z0-9_]{1,32}$`)
+
+// certificationAttribute returns the certificate attribute that proves a certification
+// e.g. HAZMAT -> "cert_hazmat" which must be set to "true" by the issuing CA
+func certificationAttribute(certification string) string {
+	return "cert_" + strings.ToLower(certification)
+}
+
+// validateHandlerCertifications checks the caller's certificate carries every required certification
+// Only custodial handlers are checked; the customer receiving the package is not a handler
+func validateHandlerCertifications(ctx contractapi.TransactionContextInterface, delivery *Delivery, recipientRole UserRole) error {
+	if recipientRole == RoleCustomer {
+		return nil
+	}
+	for _, certification := range delivery.RequiredCertifications {
+		if err := assertAttribute(ctx, certificationAttribute(certification), "true"); err != nil {
+			return fmt.Errorf("recipient is not certified for %s handling: %v", certification, err)
+		}
+	}
+	return nil
+}
+
+// SetRequiredCertifications sets the handler certifications required for a delivery
+// Only the SELLER can set them, and only before pickup
+func (c *DeliveryContract) SetRequiredCertifications(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	certificationsJSON string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+
+	var certifications []string
+	if err := json.Unmarshal([]byte(certificationsJSON), &certifications); err != nil {
+		return fmt.Errorf("failed to parse certifications: %v", err)
+	}
+	if len(certifications) > 10 {
+		return &ValidationError{Field: "certifications", Message: "exceeds maximum of 10 certifications"}
+	}
+	normalized := make([]string, 0, len(certifications))
+	seen := make(map[string]bool)
+	for _, certification := range certifications {
+		certification = strings.ToUpper(strings.TrimSpace(certification))
+		if !certificationNamePattern.MatchString(certification) {
+			return &ValidationError{Field: "certifications", Message: fmt.Sprintf("invalid certification name: %s", certification)}
+		}
+		if seen[certification] {
+			continue
+		}
+		seen[certification] = true
+		normalized = append(normalized, certification)
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER can set required certifications
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	if delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller can set required certifications")
+	}
+	if delivery.DeliveryStatus != StatusPendingPickup {
+		return fmt.Errorf("required certifications can only be set before pickup")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	delivery.RequiredCertifications = normalized
+	delivery.UpdatedAt = currentTime
+
+	return applyDeliveryUpdate(ctx, delivery)
+}
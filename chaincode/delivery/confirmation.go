@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EventDeliveryAutoConfirmed is emitted when a delivery is confirmed after the grace period
+const EventDeliveryAutoConfirmed = "DeliveryAutoConfirmed"
+
+// AutoConfirmExpired completes a delivery the customer never confirmed
+// The DELIVERY_PERSON who initiated the final handoff (or ADMIN) can call this
+// once the configured grace period has passed
+func (c *DeliveryContract) AutoConfirmExpired(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleAdmin); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	if delivery.DeliveryStatus != StatusPendingDeliveryConfirmation || delivery.PendingHandoff == nil {
+		return fmt.Errorf("delivery %s is not awaiting customer confirmation", deliveryID)
+	}
+	if caller.Role == RoleDeliveryPerson && delivery.PendingHandoff.FromUserID != caller.ID {
+		return fmt.Errorf("only the handoff initiator can auto-confirm this delivery")
+	}
+
+	// Nobody checks the recipient's age on an automatic confirmation
+	if delivery.AgeRestricted {
+		return fmt.Errorf("age-restricted deliveries must be confirmed by the customer with an ID check")
+	}
+	// Cash on delivery must have been collected before the parcel counts as delivered
+	if err := validateCODSettled(delivery); err != nil {
+		return err
+	}
+
+	// A courier auto-confirming must be at the destination; ADMIN acts remotely
+	if caller.Role == RoleDeliveryPerson {
+		if err := checkGeofence(ctx, delivery); err != nil {
+			return err
+		}
+	}
+
+	settings, err := getContractSettings(ctx)
+	if err != nil {
+		return err
+	}
+	initiatedAt, err := time.Parse(time.RFC3339, delivery.PendingHandoff.InitiatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to parse handoff initiation time: %v", err)
+	}
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	deadline := initiatedAt.Add(time.Duration(settings.DeliveryConfirmationGraceHours) * time.Hour)
+	if txTime.Before(deadline) {
+		return fmt.Errorf("confirmation grace period has not expired (expires %s)", deadline.Format(time.RFC3339))
+	}
+
+	currentTime := txTime.Format(time.RFC3339)
+	handoff := delivery.PendingHandoff
+	oldStatus := delivery.DeliveryStatus
+	keepHandoffEvidence(delivery, handoff, oldStatus)
+
+	delivery.CurrentCustodianID = handoff.ToUserID
+	delivery.CurrentCustodianRole = handoff.ToRole
+	delivery.CustodianMSP = ""
+	delivery.PendingHandoff = nil
+	delivery.DeliveryStatus = StatusConfirmedDelivery
+	delivery.AutoConfirmed = true
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	// Custody moves to the customer, same as a manual confirmation
+	if err := setDeliveryEndorsementPolicy(ctx, delivery); err != nil {
+		return fmt.Errorf("failed to update endorsement policy: %v", err)
+	}
+
+	// Fabric keeps one event per transaction, so the auto-confirmation carries the status change
+	return emitEvent(ctx, EventDeliveryAutoConfirmed, map[string]string{
+		"deliveryId":  deliveryID,
+		"orderId":     delivery.OrderID,
+		"oldStatus":   string(oldStatus),
+		"newStatus":   string(delivery.DeliveryStatus),
+		"customerId":  delivery.CustomerID,
+		"confirmedBy": caller.ID,
+		"timestamp":   currentTime,
+	})
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Event names for hub consolidation
+const (
+	EventDeliverySplit    = "DeliverySplit"
+	EventDeliveriesMerged = "DeliveriesMerged"
+)
+
+// SplitChild describes one onward parcel produced by SplitDelivery
+type SplitChild struct {
+	DeliveryID      string  `json:"deliveryId"`
+	PackageWeight   float64 `json:"packageWeight"`
+	DimensionLength float64 `json:"dimensionLength"`
+	DimensionWidth  float64 `json:"dimensionWidth"`
+	DimensionHeight float64 `json:"dimensionHeight"`
+}
+
+// SplitDelivery splits one consignment at a hub into multiple child deliveries
+// The WAREHOUSE custodian (or ADMIN) can split; the parent is closed as SPLIT. Children keep the
+// destination, customs and compliance data of the parent and divide its declared value and
+// unsettled cash on delivery between them.
+func (c *DeliveryContract) SplitDelivery(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	childrenJSON string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+
+	var children []SplitChild
+	if err := json.Unmarshal([]byte(childrenJSON), &children); err != nil {
+		return fmt.Errorf("failed to parse children: %v", err)
+	}
+	if len(children) < 2 {
+		return &ValidationError{Field: "children", Message: "a split must produce at least 2 child deliveries"}
+	}
+
+	units, err := getMeasurementUnits(ctx)
+	if err != nil {
+		return err
+	}
+	totalWeight := 0.0
+	seen := make(map[string]bool)
+	for i := range children {
+		child := &children[i]
+		child.PackageWeight = units.toKilograms(child.PackageWeight)
+		child.DimensionLength = units.toCentimeters(child.DimensionLength)
+		child.DimensionWidth = units.toCentimeters(child.DimensionWidth)
+		child.DimensionHeight = units.toCentimeters(child.DimensionHeight)
+
+		if err := validateDeliveryID(child.DeliveryID); err != nil {
+			return err
+		}
+		if child.DeliveryID == deliveryID || seen[child.DeliveryID] {
+			return &ValidationError{Field: "children", Message: fmt.Sprintf("duplicate child delivery ID %s", child.DeliveryID)}
+		}
+		seen[child.DeliveryID] = true
+		if err := validatePackageWeight(child.PackageWeight); err != nil {
+			return err
+		}
+		if err := validateDimension(child.DimensionLength, "dimensionLength"); err != nil {
+			return err
+		}
+		if err := validateDimension(child.DimensionWidth, "dimensionWidth"); err != nil {
+			return err
+		}
+		if err := validateDimension(child.DimensionHeight, "dimensionHeight"); err != nil {
+			return err
+		}
+		totalWeight += child.PackageWeight
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only WAREHOUSE or ADMIN can split
+	if err := validateRole(ctx, caller, RoleWarehouse, RoleAdmin); err != nil {
+		return err
+	}
+
+	parent, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	// Warehouses can only split parcels they currently hold
+	if caller.Role == RoleWarehouse && parent.CurrentCustodianID != caller.ID {
+		return fmt.Errorf("only the current custodian can split this delivery")
+	}
+	if parent.DeliveryStatus != StatusInTransit {
+		return fmt.Errorf("can only split a delivery that is in transit")
+	}
+
+	// Children can't weigh more than the consignment they came from
+	if totalWeight > parent.PackageWeight {
+		return &ValidationError{Field: "children", Message: fmt.Sprintf("total child weight %.2f exceeds parent weight %.2f", totalWeight, parent.PackageWeight)}
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Children share the declared value and any outstanding COD of the consignment, so the COD is
+	// collected share by share as each child reaches the customer
+	declaredValues := splitMoney(parent.DeclaredValue, len(children))
+	codAmounts := make([]*Money, len(children))
+	if parent.CODSettlement == nil {
+		codAmounts = splitMoney(parent.CODAmount, len(children))
+	}
+
+	childIDs := make([]string, 0, len(children))
+	for i, spec := range children {
+		exists, err := c.DeliveryExists(ctx, spec.DeliveryID)
+		if err != nil {
+			return fmt.Errorf("failed to check if delivery exists: %v", err)
+		}
+		if exists {
+			return fmt.Errorf("delivery %s already exists", spec.DeliveryID)
+		}
+
+		child := Delivery{
+			TenantID:      parent.TenantID,
+			DeliveryID:    spec.DeliveryID,
+			OrderID:       parent.OrderID,
+			SellerID:      parent.SellerID,
+			CustomerID:    parent.CustomerID,
+			PackageWeight: spec.PackageWeight,
+			PackageDimensions: PackageDimensions{
+				Length: spec.DimensionLength,
+				Width:  spec.DimensionWidth,
+				Height: spec.DimensionHeight,
+			},
+			DeliveryStatus:         StatusInTransit,
+			LastLocation:           parent.LastLocation,
+			Destination:            parent.Destination,
+			CurrentCustodianID:     parent.CurrentCustodianID,
+			CurrentCustodianRole:   parent.CurrentCustodianRole,
+			CustodianMSP:           parent.CustodianMSP,
+			CarrierOfRecord:        parent.CarrierOfRecord,
+			Customs:                parent.Customs,
+			JurisdictionCheck:      parent.JurisdictionCheck,
+			RequiredCertifications: parent.RequiredCertifications,
+			AgeRestricted:          parent.AgeRestricted,
+			ProductExpiry:          parent.ProductExpiry,
+			Insurance:              parent.Insurance,
+			ServiceTier:            parent.ServiceTier,
+			EstimatedArrival:       parent.EstimatedArrival,
+			DeclaredValue:          declaredValues[i],
+			CODAmount:              codAmounts[i],
+			ParentDeliveryID:       parent.DeliveryID,
+			UpdatedAt:              currentTime,
+		}
+
+		if err := applyDeliveryUpdate(ctx, &child); err != nil {
+			return fmt.Errorf("failed to put delivery to world state: %v", err)
+		}
+		if err := setDeliveryEndorsementPolicy(ctx, &child); err != nil {
+			return fmt.Errorf("failed to set endorsement policy: %v", err)
+		}
+
+		childIDs = append(childIDs, child.DeliveryID)
+	}
+
+	oldStatus := parent.DeliveryStatus
+	parent.DeliveryStatus = StatusSplit
+	parent.ChildDeliveryIDs = childIDs
+	parent.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, parent); err != nil {
+		return err
+	}
+
+	// Fabric keeps one event per transaction, so the split event carries the status change
+	return emitEvent(ctx, EventDeliverySplit, map[string]interface{}{
+		"deliveryId":       deliveryID,
+		"orderId":          parent.OrderID,
+		"oldStatus":        oldStatus,
+		"newStatus":        parent.DeliveryStatus,
+		"childDeliveryIds": childIDs,
+		"splitBy":          caller.ID,
+		"timestamp":        currentTime,
+	})
+}
+
+// MergeDeliveries consolidates inbound deliveries for the same customer into one outbound parcel
+// Only the WAREHOUSE holding every source delivery can merge. Sources are closed as MERGED and
+// the merged parcel starts IN_TRANSIT at the warehouse, going through the normal handoff flow.
+// The merged parcel is indexed under the first source's order; sources keep their own links.
+func (c *DeliveryContract) MergeDeliveries(
+	ctx contractapi.TransactionContextInterface,
+	mergedDeliveryID string,
+	sourceIDsJSON string,
+	packageWeight float64,
+	dimensionLength float64,
+	dimensionWidth float64,
+	dimensionHeight float64,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(mergedDeliveryID); err != nil {
+		return err
+	}
+	units, err := getMeasurementUnits(ctx)
+	if err != nil {
+		return err
+	}
+	packageWeight = units.toKilograms(packageWeight)
+	dimensionLength = units.toCentimeters(dimensionLength)
+	dimensionWidth = units.toCentimeters(dimensionWidth)
+	dimensionHeight = units.toCentimeters(dimensionHeight)
+	if err := validatePackageWeight(packageWeight); err != nil {
+		return err
+	}
+	if err := validateDimension(dimensionLength, "dimensionLength"); err != nil {
+		return err
+	}
+	if err := validateDimension(dimensionWidth, "dimensionWidth"); err != nil {
+		return err
+	}
+	if err := validateDimension(dimensionHeight, "dimensionHeight"); err != nil {
+		return err
+	}
+
+	var sourceIDs []string
+	if err := json.Unmarshal([]byte(sourceIDsJSON), &sourceIDs); err != nil {
+		return fmt.Errorf("failed to parse source delivery IDs: %v", err)
+	}
+	if len(sourceIDs) < 2 {
+		return &ValidationError{Field: "sourceIDs", Message: "a merge needs at least 2 source deliveries"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only WAREHOUSE can merge
+	if err := validateRole(ctx, caller, RoleWarehouse); err != nil {
+		return err
+	}
+
+	exists, err := c.DeliveryExists(ctx, mergedDeliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to check if delivery exists: %v", err)
+	}
+	if exists {
+		return fmt.Errorf("delivery %s already exists", mergedDeliveryID)
+	}
+
+	sources := make([]*Delivery, 0, len(sourceIDs))
+	seen := make(map[string]bool)
+	for _, sourceID := range sourceIDs {
+		if err := validateDeliveryID(sourceID); err != nil {
+			return err
+		}
+		if seen[sourceID] {
+			return &ValidationError{Field: "sourceIDs", Message: fmt.Sprintf("duplicate source delivery ID %s", sourceID)}
+		}
+		seen[sourceID] = true
+
+		source, err := c.readDeliveryInternal(ctx, sourceID)
+		if err != nil {
+			return err
+		}
+		if source.CurrentCustodianID != caller.ID {
+			return fmt.Errorf("delivery %s is not held by this warehouse", sourceID)
+		}
+		if source.DeliveryStatus != StatusInTransit {
+			return fmt.Errorf("delivery %s is not in transit", sourceID)
+		}
+		if len(sources) > 0 && (source.CustomerID != sources[0].CustomerID || source.SellerID != sources[0].SellerID) {
+			return fmt.Errorf("delivery %s has a different seller or customer", sourceID)
+		}
+		sources = append(sources, source)
+	}
+
+	// Merged parcels carry the combined declared value and outstanding COD of their sources
+	var declaredValue, codAmount *Money
+	for _, source := range sources {
+		if declaredValue, err = addMoney(declaredValue, source.DeclaredValue); err != nil {
+			return fmt.Errorf("cannot merge declared values: %v", err)
+		}
+		if source.CODSettlement != nil {
+			continue
+		}
+		if codAmount, err = addMoney(codAmount, source.CODAmount); err != nil {
+			return fmt.Errorf("cannot merge cash on delivery amounts: %v", err)
+		}
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	first := sources[0]
+	merged := Delivery{
+		TenantID:      first.TenantID,
+		DeliveryID:    mergedDeliveryID,
+		OrderID:       first.OrderID,
+		SellerID:      first.SellerID,
+		CustomerID:    first.CustomerID,
+		PackageWeight: packageWeight,
+		PackageDimensions: PackageDimensions{
+			Length: dimensionLength,
+			Width:  dimensionWidth,
+			Height: dimensionHeight,
+		},
+		DeliveryStatus:       StatusInTransit,
+		LastLocation:         first.LastLocation,
+		CurrentCustodianID:   caller.ID,
+		CurrentCustodianRole: caller.Role,
+		CustodianMSP:         caller.MSP,
+		CarrierOfRecord:      caller.MSP,
+		MergedFromIDs:        sourceIDs,
+		DeclaredValue:        declaredValue,
+		CODAmount:            codAmount,
+		UpdatedAt:            currentTime,
+	}
+
+	if err := applyDeliveryUpdate(ctx, &merged); err != nil {
+		return fmt.Errorf("failed to put delivery to world state: %v", err)
+	}
+	if err := setDeliveryEndorsementPolicy(ctx, &merged); err != nil {
+		return fmt.Errorf("failed to set endorsement policy: %v", err)
+	}
+
+	for _, source := range sources {
+		source.DeliveryStatus = StatusMerged
+		source.MergedIntoID = mergedDeliveryID
+		source.UpdatedAt = currentTime
+
+		if err := applyDeliveryUpdate(ctx, source); err != nil {
+			return err
+		}
+	}
+
+	return emitEvent(ctx, EventDeliveriesMerged, map[string]interface{}{
+		"deliveryId":    mergedDeliveryID,
+		"orderId":       merged.OrderID,
+		"mergedFromIds": sourceIDs,
+		"mergedBy":      caller.ID,
+		"timestamp":     currentTime,
+	})
+}
+
+// QueryDeliveryChain returns every delivery linked to the given one by splits or merges
+// Links are followed in both directions, breadth-first from the requested delivery
+func (c *DeliveryContract) QueryDeliveryChain(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*Delivery, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	chain := []*Delivery{delivery}
+	seen := map[string]bool{delivery.DeliveryID: true}
+	for i := 0; i < len(chain); i++ {
+		current := chain[i]
+		linked := append([]string{current.ParentDeliveryID, current.MergedIntoID}, current.ChildDeliveryIDs...)
+		linked = append(linked, current.MergedFromIDs...)
+
+		for _, linkedID := range linked {
+			if linkedID == "" || seen[linkedID] {
+				continue
+			}
+			next, err := c.readDeliveryInternal(ctx, linkedID)
+			if err != nil {
+				return nil, err
+			}
+			seen[linkedID] = true
+			chain = append(chain, next)
+		}
+	}
+
+	return chain, nil
+}
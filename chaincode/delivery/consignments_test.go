@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitDeliveryKeepsCashOnDelivery(t *testing.T) {
+	network := newTestNetwork(t)
+	warehouse := testIdentity{mspID: MSPLogistics, id: "warehouse1", role: string(RoleWarehouse)}
+
+	parent := testDelivery(testDeliveryID, StatusInTransit)
+	parent.PackageWeight = 4
+	parent.CurrentCustodianID = warehouse.id
+	parent.CurrentCustodianRole = RoleWarehouse
+	parent.Destination = &Location{City: "Porto", State: "Porto", Country: "PT"}
+	parent.DeclaredValue = &Money{AmountMinor: 5000, Currency: "EUR", MinorUnits: 2}
+	parent.CODAmount = &Money{AmountMinor: 1001, Currency: "EUR", MinorUnits: 2}
+	parent.AgeRestricted = true
+	network.putDelivery(parent)
+
+	children := `[{"deliveryId":"DEL-20260101-000CHLD1","packageWeight":2,"dimensionLength":10,"dimensionWidth":10,"dimensionHeight":10},` +
+		`{"deliveryId":"DEL-20260101-000CHLD2","packageWeight":2,"dimensionLength":10,"dimensionWidth":10,"dimensionHeight":10}]`
+	if _, err := network.invoke(warehouse, "SplitDelivery", testDeliveryID, children); err != nil {
+		t.Fatalf("failed to split delivery: %v", err)
+	}
+
+	for i, want := range []struct{ declared, cod int64 }{{2500, 501}, {2500, 500}} {
+		child := network.getDelivery([]string{"DEL-20260101-000CHLD1", "DEL-20260101-000CHLD2"}[i])
+		if child.Destination == nil || child.Destination.City != "Porto" {
+			t.Errorf("%s lost the destination: %+v", child.DeliveryID, child.Destination)
+		}
+		if !child.AgeRestricted {
+			t.Errorf("%s lost the age restriction", child.DeliveryID)
+		}
+		if child.DeclaredValue == nil || child.DeclaredValue.AmountMinor != want.declared {
+			t.Errorf("%s declared value is %+v, want %d", child.DeliveryID, child.DeclaredValue, want.declared)
+		}
+		if child.CODAmount == nil || child.CODAmount.AmountMinor != want.cod || child.CODAmount.Currency != "EUR" {
+			t.Errorf("%s COD is %+v, want %d EUR", child.DeliveryID, child.CODAmount, want.cod)
+		}
+	}
+
+	// The courier takes the first child to the door without collecting the cash
+	child := network.getDelivery("DEL-20260101-000CHLD1")
+	child.AgeRestricted = false
+	child.DeliveryStatus = StatusPendingDeliveryConfirmation
+	child.CurrentCustodianID = testCourier.id
+	child.CurrentCustodianRole = RoleDeliveryPerson
+	child.PendingHandoff = &PendingHandoff{FromUserID: testCourier.id, FromRole: RoleDeliveryPerson,
+		ToUserID: testCustomer.id, ToRole: RoleCustomer, InitiatedAt: "2026-01-01T00:00:00Z"}
+	network.putDelivery(child)
+
+	confirm := []string{child.DeliveryID, "Porto", "Porto", "PT", "2", "10", "10", "10"}
+	_, err := network.invoke(testCustomer, "ConfirmHandoff", confirm...)
+	if err == nil || !strings.Contains(err.Error(), "has not been settled") {
+		t.Fatalf("expected the unsettled COD to block the final handoff, got %v", err)
+	}
+	_, err = network.invoke(testAdmin, "AutoConfirmExpired", child.DeliveryID)
+	if err == nil || !strings.Contains(err.Error(), "has not been settled") {
+		t.Fatalf("expected the unsettled COD to block the auto-confirmation, got %v", err)
+	}
+
+	if _, err := network.invoke(testCourier, "SettleCashOnDelivery", child.DeliveryID, "EUR", "501"); err != nil {
+		t.Fatalf("failed to settle the child's COD: %v", err)
+	}
+	if _, err := network.invoke(testCustomer, "ConfirmHandoff", confirm...); err != nil {
+		t.Fatalf("failed to confirm the settled child: %v", err)
+	}
+	if got := network.getDelivery(child.DeliveryID).DeliveryStatus; got != StatusConfirmedDelivery {
+		t.Errorf("child is %s, want %s", got, StatusConfirmedDelivery)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// CollectionContentsManifest holds the detailed package contents manifest
+// Collection: deliveryContentsManifest (PlatformOrg and SellersOrg only)
+const CollectionContentsManifest = "deliveryContentsManifest"
+
+// TransientContentsManifest is the transient map key for the optional manifest at creation
+const TransientContentsManifest = "contentsManifest"
+
+// storeContentsManifest stores the optional contents manifest from the transient map
+// Returns the hex SHA-256 of the manifest bytes (empty if no manifest was supplied).
+// The hash equals the private data hash Fabric records on-chain for the collection key,
+// so anyone holding the manifest can verify it against the public commitment.
+func storeContentsManifest(ctx contractapi.TransactionContextInterface, deliveryID string) (string, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("failed to get transient data: %v", err)
+	}
+
+	manifestJSON, exists := transientMap[TransientContentsManifest]
+	if !exists || len(manifestJSON) == 0 {
+		return "", nil
+	}
+
+	if !json.Valid(manifestJSON) {
+		return "", &ValidationError{Field: TransientContentsManifest, Message: "must be valid JSON"}
+	}
+	if len(manifestJSON) > 64*1024 {
+		return "", &ValidationError{Field: TransientContentsManifest, Message: "exceeds maximum size of 64 KB"}
+	}
+
+	if err := ctx.GetStub().PutPrivateData(CollectionContentsManifest, deliveryID, manifestJSON); err != nil {
+		return "", fmt.Errorf("failed to store contents manifest: %v", err)
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(manifestJSON)), nil
+}
+
+// GetContentsManifest returns the detailed contents manifest of a delivery
+// Only PlatformOrg and SellersOrg members can read it
+func (c *DeliveryContract) GetContentsManifest(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) (string, error) {
+	// Extract caller identity
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleSeller, RoleCustomer, RoleAdmin); err != nil {
+		return "", err
+	}
+
+	if caller.MSP != MSPPlatform && caller.MSP != MSPSellers {
+		return "", fmt.Errorf("only PlatformOrg and SellersOrg can read contents manifests")
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return "", err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return "", err
+	}
+
+	manifestJSON, err := ctx.GetStub().GetPrivateData(CollectionContentsManifest, deliveryID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get contents manifest: %v", err)
+	}
+	if manifestJSON == nil {
+		return "", fmt.Errorf("contents manifest not found for delivery %s", deliveryID)
+	}
+
+	return string(manifestJSON), nil
+}
+
+// VerifyContentsManifest checks a manifest hash against the delivery's public commitment
+// Any org can verify (customs, insurers, arbitrators) without seeing the item list
+func (c *DeliveryContract) VerifyContentsManifest(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	manifestHash string,
+) (bool, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return false, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return false, err
+	}
+	if delivery.ContentsManifestHash == "" {
+		return false, fmt.Errorf("delivery %s has no contents manifest", deliveryID)
+	}
+
+	// The public commitment must still match the private data hash recorded by Fabric
+	hashBytes, err := ctx.GetStub().GetPrivateDataHash(CollectionContentsManifest, deliveryID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get contents manifest hash: %v", err)
+	}
+	if hashBytes == nil || fmt.Sprintf("%x", hashBytes) != delivery.ContentsManifestHash {
+		return false, fmt.Errorf("contents manifest commitment does not match private data for delivery %s", deliveryID)
+	}
+
+	return delivery.ContentsManifestHash == manifestHash, nil
+}
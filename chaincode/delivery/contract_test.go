@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/attrmgr"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// testIdentity is the certificate a test transaction is submitted with
+type testIdentity struct {
+	mspID string
+	id    string
+	role  string
+	attrs map[string]string
+}
+
+// testNetwork is a mock peer running the delivery chaincode
+type testNetwork struct {
+	t     *testing.T
+	stub  *shimtest.MockStub
+	txSeq int
+}
+
+// The chaincode holds no state of its own, so every test network runs the same one; describing
+// its metadata takes seconds
+var (
+	testChaincodeOnce sync.Once
+	testChaincode     *describedChaincode
+	testChaincodeErr  error
+)
+
+// newTestNetwork starts the delivery chaincode on a fresh mock peer
+func newTestNetwork(t *testing.T) *testNetwork {
+	t.Helper()
+	testChaincodeOnce.Do(func() {
+		testChaincode, testChaincodeErr = newDescribedChaincode(newDeliveryContract())
+	})
+	if testChaincodeErr != nil {
+		t.Fatalf("failed to create chaincode: %v", testChaincodeErr)
+	}
+	return &testNetwork{t: t, stub: shimtest.NewMockStub("delivery", testChaincode)}
+}
+
+// nextTxID returns a transaction ID not used before on this network
+func (n *testNetwork) nextTxID() string {
+	n.txSeq++
+	return fmt.Sprintf("tx%d", n.txSeq)
+}
+
+// setCaller makes the identity the creator of the following transactions
+func (n *testNetwork) setCaller(identity testIdentity) {
+	n.t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		n.t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: identity.id, OrganizationalUnit: []string{identity.role}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if len(identity.attrs) > 0 {
+		value, err := json.Marshal(&attrmgr.Attributes{Attrs: identity.attrs})
+		if err != nil {
+			n.t.Fatalf("failed to marshal attributes: %v", err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: attrmgr.AttrOID, Value: value}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		n.t.Fatalf("failed to create certificate: %v", err)
+	}
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   identity.mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		n.t.Fatalf("failed to marshal creator: %v", err)
+	}
+	n.stub.Creator = creator
+}
+
+// invoke submits a transaction as the identity and returns its response
+func (n *testNetwork) invoke(identity testIdentity, function string, args ...string) (string, error) {
+	n.t.Helper()
+	n.setCaller(identity)
+	invokeArgs := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+	response := n.stub.MockInvoke(n.nextTxID(), invokeArgs)
+	n.drainEvents()
+	if response.Status != shim.OK {
+		return "", fmt.Errorf("%s", response.Message)
+	}
+	return string(response.Payload), nil
+}
+
+// drainEvents discards the events of finished transactions so the mock's event channel never fills
+func (n *testNetwork) drainEvents() {
+	for {
+		select {
+		case <-n.stub.ChaincodeEventsChannel:
+		default:
+			return
+		}
+	}
+}
+
+// transaction runs fn in a transaction context of the identity, as a transaction function would
+func (n *testNetwork) transaction(identity testIdentity, fn func(ctx *TenantTransactionContext) error) error {
+	n.t.Helper()
+	n.setCaller(identity)
+	txID := n.nextTxID()
+	n.stub.MockTransactionStart(txID)
+	defer n.stub.MockTransactionEnd(txID)
+
+	clientIdentity, err := cid.New(n.stub)
+	if err != nil {
+		n.t.Fatalf("failed to read client identity: %v", err)
+	}
+	ctx := new(TenantTransactionContext)
+	ctx.SetStub(n.stub)
+	ctx.SetClientIdentity(clientIdentity)
+	err = fn(ctx)
+	n.drainEvents()
+	return err
+}
+
+// putDelivery stores a delivery and its indexes the way the contract writes them
+func (n *testNetwork) putDelivery(delivery *Delivery) {
+	n.t.Helper()
+	if err := n.transaction(testAdmin, func(ctx *TenantTransactionContext) error {
+		return applyDeliveryUpdate(ctx, delivery)
+	}); err != nil {
+		n.t.Fatalf("failed to store delivery %s: %v", delivery.DeliveryID, err)
+	}
+}
+
+// getDelivery reads a delivery as stored on the ledger
+func (n *testNetwork) getDelivery(deliveryID string) *Delivery {
+	n.t.Helper()
+	deliveryJSON := n.stub.State[deliveryID]
+	if deliveryJSON == nil {
+		n.t.Fatalf("delivery %s does not exist", deliveryID)
+	}
+	delivery := &Delivery{}
+	if err := json.Unmarshal(deliveryJSON, delivery); err != nil {
+		n.t.Fatalf("failed to unmarshal delivery %s: %v", deliveryID, err)
+	}
+	return delivery
+}
+
+// hasIndex reports whether an index entry exists
+func (n *testNetwork) hasIndex(index string, attributes ...string) bool {
+	n.t.Helper()
+	key, err := n.stub.CreateCompositeKey(index, attributes)
+	if err != nil {
+		n.t.Fatalf("failed to create %s composite key: %v", index, err)
+	}
+	return n.stub.State[key] != nil
+}
+
+// Identities of the default organizations
+var (
+	testAdmin    = testIdentity{mspID: "PlatformOrgMSP", id: "admin1", role: string(RoleAdmin)}
+	testCustomer = testIdentity{mspID: "PlatformOrgMSP", id: "customer1", role: string(RoleCustomer)}
+	testSeller   = testIdentity{mspID: "SellersOrgMSP", id: "seller1", role: string(RoleSeller)}
+	testCourier  = testIdentity{mspID: "LogisticsOrgMSP", id: "courier1", role: string(RoleDeliveryPerson)}
+)
+
+// testDeliveryID is the ID of the delivery a test works on
+const testDeliveryID = "DEL-20260101-0000TEST"
+
+// testDelivery returns a delivery of testSeller to testCustomer held by testCourier
+func testDelivery(deliveryID string, status DeliveryStatus) *Delivery {
+	return &Delivery{
+		DeliveryID:           deliveryID,
+		OrderID:              "order-" + deliveryID,
+		SellerID:             testSeller.id,
+		CustomerID:           testCustomer.id,
+		PackageWeight:        1.5,
+		PackageDimensions:    PackageDimensions{Length: 10, Width: 10, Height: 10},
+		DeliveryStatus:       status,
+		LastLocation:         Location{City: "Lisbon", State: "Lisbon", Country: "PT"},
+		CurrentCustodianID:   testCourier.id,
+		CurrentCustodianRole: RoleDeliveryPerson,
+		UpdatedAt:            "2026-01-01T00:00:00Z",
+	}
+}
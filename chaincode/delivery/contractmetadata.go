@@ -0,0 +1,622 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// systemGetMetadata is the transaction SDKs call to read the interface definition of the chaincode
+const systemGetMetadata = "org.hyperledger.fabric:GetMetadata"
+
+// chaincodeInfo describes the chaincode in its metadata
+var chaincodeInfo = metadata.InfoMetadata{
+	Title:       "delivery",
+	Version:     "1.0",
+	Description: "Multi-organization delivery tracking: custody handoffs, disputes, private delivery details and marketplace administration",
+	License:     &metadata.LicenseMetadata{Name: "MIT"},
+}
+
+// contractInfo describes DeliveryContract in the metadata
+var contractInfo = metadata.InfoMetadata{
+	Title:       "DeliveryContract",
+	Version:     "1.0",
+	Description: "Every transaction of the delivery chaincode; transactions tagged evaluate only read the ledger",
+}
+
+// evaluateTransactions lists the transactions that only read the ledger
+// They are tagged "evaluate" in the metadata so gateways send them as queries; every other
+// transaction is tagged "submit".
+var evaluateTransactions = []string{
+	"ComputeStateDigest",
+	"DeliveryExists",
+	"EvaluateStateTransition",
+	"ExportComplianceReport",
+	"ExportDeliveriesDelta",
+	"GetAdminRegions",
+	"GetAgeVerification",
+	"GetBillingAdjustments",
+	"GetBusinessCalendar",
+	"GetCallerInfo",
+	"GetContentsManifest",
+	"GetContractSettings",
+	"GetCorrections",
+	"GetCourierRunSheet",
+	"GetCustodyReport",
+	"GetCustomerDashboard",
+	"GetDeliveriesByStatusForUser",
+	"GetDeliveryExceptions",
+	"GetDeliveryExport",
+	"GetDeliveryHistory",
+	"GetDeliveryItems",
+	"GetDeliveryPrivateDetails",
+	"GetDeliverySettlement",
+	"GetDeliveryTemplates",
+	"GetDigestAnchors",
+	"GetDisputeCases",
+	"GetEventsForDelivery",
+	"GetFeeQuotes",
+	"GetInsuranceClaims",
+	"GetLabelPayload",
+	"GetLocationHistory",
+	"GetManifest",
+	"GetMilestoneProof",
+	"GetMilestoneTimeline",
+	"GetNotes",
+	"GetOrganizations",
+	"GetPackageAmendments",
+	"GetPackageMeasurements",
+	"GetPenalties",
+	"GetPermissionModel",
+	"GetPointsBalance",
+	"GetPrivateAccessLog",
+	"GetReasonCodes",
+	"GetReputation",
+	"GetRestrictedGoodsMatrix",
+	"GetRetentionPolicy",
+	"GetRolePermissions",
+	"GetRouteDeviations",
+	"GetSellerDashboard",
+	"GetServiceCoverage",
+	"GetTelemetry",
+	"GetWeightMeasurements",
+	"ListAttachments",
+	"ListDeliveryGrants",
+	"QueryByExternalTracking",
+	"QueryDeliveriesByCustodian",
+	"QueryDeliveriesByDateRange",
+	"QueryDeliveriesByLocation",
+	"QueryDeliveriesByStatus",
+	"QueryDeliveriesRequiringEndorsementFromMyOrg",
+	"QueryDeliveriesRich",
+	"QueryDeliveriesUpdatedSince",
+	"QueryDeliveryChain",
+	"QueryDisputeCasesByState",
+	"QueryFleetAvailability",
+	"QueryFraudSignals",
+	"QueryOpenDisputes",
+	"QueryStalledDeliveries",
+	"ReadDelivery",
+	"ReadDeliveryIfChanged",
+	"VerifyContentsManifest",
+	"VerifyDeliveryPrivateDataHash",
+	"VerifyRecipient",
+}
+
+// GetEvaluateTransactions tells contractapi which transactions to tag "evaluate"
+func (c *DeliveryContract) GetEvaluateTransactions() []string {
+	return evaluateTransactions
+}
+
+// isEvaluateTransaction reports whether a function is tagged "evaluate"
+// contractapi also accepts function names starting in lower case, so the first letter is ignored
+// for case.
+func isEvaluateTransaction(function string) bool {
+	for _, name := range evaluateTransactions {
+		if len(function) == len(name) && strings.EqualFold(function[:1], name[:1]) && function[1:] == name[1:] {
+			return true
+		}
+	}
+	return false
+}
+
+// validateEvaluateTransactions checks the evaluate transactions against the transition table
+// A function that changes a delivery's status can't be a query.
+func validateEvaluateTransactions() error {
+	for _, name := range evaluateTransactions {
+		if permission := functionPermission(name); permission != nil && len(permission.Transitions) > 0 {
+			return fmt.Errorf("evaluate transaction %s performs status transitions", name)
+		}
+	}
+	return nil
+}
+
+// transactionParameters names the parameters of every transaction, in call order
+// Reflection only sees parameter types, so without this GetMetadata would list param0, param1...
+var transactionParameters = map[string][]string{
+	"AcceptQuote":                                  {"deliveryID", "quoteID"},
+	"AcknowledgeCustody":                           {"deliveryID", "city", "state", "country"},
+	"AcknowledgeRecall":                            {"deliveryID"},
+	"AddDeliveryItem":                              {"deliveryID", "itemID", "skuHash", "quantity"},
+	"AddDeliveryNote":                              {"deliveryID", "text", "visibility"},
+	"AddToManifest":                                {"manifestID", "deliveryID"},
+	"AdminBroadcastRecall":                         {"orderIDPrefix", "sellerID", "reason", "pageSize", "bookmark"},
+	"AdminReassignCustody":                         {"deliveryID", "newCustodianID"},
+	"AmendPackageDetails":                          {"deliveryID", "packageWeight", "dimensionLength", "dimensionWidth", "dimensionHeight", "reason"},
+	"AnchorDigest":                                 {"deliveryID", "digest", "asOfTxID", "chain", "anchorRef"},
+	"ApplyRetention":                               {"pageSize", "bookmark"},
+	"AttachCustomsDocument":                        {"deliveryID", "documentType", "documentHash"},
+	"AttachExternalTracking":                       {"deliveryID", "carrierCode", "trackingNumber"},
+	"AutoConfirmExpired":                           {"deliveryID"},
+	"CancelDelivery":                               {"deliveryID", "reasonCode", "reason"},
+	"CancelHandoff":                                {"deliveryID"},
+	"CommitMilestoneRoot":                          {},
+	"ComputeStateDigest":                           {"deliveryID", "asOfTxID"},
+	"ConfirmBatchHandoff":                          {"deliveryIDsJSON", "city", "state", "country", "measurementsJSON"},
+	"ConfirmHandoff":                               {"deliveryID", "city", "state", "country", "packageWeight", "dimensionLength", "dimensionWidth", "dimensionHeight"},
+	"ConfirmManifestReceipt":                       {"manifestID", "receivedIDsJSON", "city", "state", "country"},
+	"ConfirmReturnReceipt":                         {"deliveryID", "condition", "disposition"},
+	"CorrectDeliveryParties":                       {"deliveryID", "sellerID", "customerID", "reason"},
+	"CreateDelivery":                               {"deliveryID", "orderID", "customerID", "packageWeight", "dimensionLength", "dimensionWidth", "dimensionHeight", "locationCity", "locationState", "locationCountry"},
+	"CreateDeliveryAutoID":                         {"orderID", "customerID", "packageWeight", "dimensionLength", "dimensionWidth", "dimensionHeight", "locationCity", "locationState", "locationCountry"},
+	"CreateDeliveryFromTemplate":                   {"templateID", "orderID", "customerID"},
+	"CreateManifest":                               {"manifestID", "toUserID", "toRole"},
+	"DeclareOutForDelivery":                        {"deliveryID"},
+	"DelegateCustodyAction":                        {"deliveryID", "helperID", "expiry"},
+	"DeliveryExists":                               {"deliveryID"},
+	"DisputeHandoff":                               {"deliveryID", "reasonCode", "reason"},
+	"EvaluateStateTransition":                      {"deliveryID", "proposedAction", "paramsJSON"},
+	"ExportComplianceReport":                       {"deliveryID"},
+	"ExportDeliveriesDelta":                        {"sinceTimestamp", "pageSize", "bookmark"},
+	"ExportDeliveryPackage":                        {"deliveryID", "targetChannel"},
+	"GetAdminRegions":                              {},
+	"GetAgeVerification":                           {"deliveryID"},
+	"GetBillingAdjustments":                        {"deliveryID"},
+	"GetBusinessCalendar":                          {},
+	"GetCallerInfo":                                {},
+	"GetContentsManifest":                          {"deliveryID"},
+	"GetContractSettings":                          {},
+	"GetCorrections":                               {"deliveryID"},
+	"GetCourierRunSheet":                           {},
+	"GetCustodyReport":                             {"deliveryID"},
+	"GetCustomerDashboard":                         {"recentPerBucket"},
+	"GetDeliveriesByStatusForUser":                 {"status", "userID"},
+	"GetDeliveryExceptions":                        {"deliveryID"},
+	"GetDeliveryExport":                            {"deliveryID"},
+	"GetDeliveryHistory":                           {"deliveryID"},
+	"GetDeliveryItems":                             {"deliveryID"},
+	"GetDeliveryPrivateDetails":                    {"deliveryID"},
+	"GetDeliverySettlement":                        {"deliveryID"},
+	"GetDeliveryTemplates":                         {},
+	"GetDigestAnchors":                             {"deliveryID"},
+	"GetDisputeCases":                              {"deliveryID"},
+	"GetEventsForDelivery":                         {"deliveryID", "sinceTimestamp"},
+	"GetFeeQuotes":                                 {"deliveryID"},
+	"GetInsuranceClaims":                           {"deliveryID"},
+	"GetLabelPayload":                              {"deliveryID"},
+	"GetLocationHistory":                           {"deliveryID"},
+	"GetManifest":                                  {"manifestID"},
+	"GetMilestoneProof":                            {"deliveryID", "txID"},
+	"GetMilestoneTimeline":                         {"deliveryID"},
+	"GetNotes":                                     {"deliveryID"},
+	"GetOrganizations":                             {},
+	"GetPackageAmendments":                         {"deliveryID"},
+	"GetPackageMeasurements":                       {"deliveryID", "weightUnit", "dimensionUnit"},
+	"GetPenalties":                                 {"userID"},
+	"GetPermissionModel":                           {},
+	"GetPointsBalance":                             {"userID"},
+	"GetPrivateAccessLog":                          {"deliveryID"},
+	"GetReasonCodes":                               {"category"},
+	"GetReputation":                                {"userID"},
+	"GetRestrictedGoodsMatrix":                     {},
+	"GetRetentionPolicy":                           {},
+	"GetRolePermissions":                           {"role"},
+	"GetRouteDeviations":                           {"deliveryID"},
+	"GetSellerDashboard":                           {"limitPerSection"},
+	"GetServiceCoverage":                           {},
+	"GetTelemetry":                                 {"deliveryID"},
+	"GetWeightMeasurements":                        {"deliveryID"},
+	"GrantDeliveryAccess":                          {"deliveryID", "granteeID", "scope"},
+	"ImportDeliveryPackage":                        {"packageJSON", "sourceChaincode"},
+	"InitLedger":                                   {},
+	"InitiateBatchHandoff":                         {"deliveryIDsJSON", "toUserID", "toRole"},
+	"InitiateHandoff":                              {"deliveryID", "toUserID", "toRole"},
+	"InitiateInterlineHandoff":                     {"deliveryID", "toUserID", "toRole", "toCarrierMSP"},
+	"ListAttachments":                              {"deliveryID"},
+	"ListDeliveryGrants":                           {"deliveryID"},
+	"LogPrivateAccess":                             {"deliveryID", "purpose"},
+	"MarkStalled":                                  {"deliveryID", "thresholdHours"},
+	"MergeDeliveries":                              {"mergedDeliveryID", "sourceIDsJSON", "packageWeight", "dimensionLength", "dimensionWidth", "dimensionHeight"},
+	"MigrateEndorsementPolicies":                   {"oldMSP", "newMSP", "pageSize", "bookmark"},
+	"PlaceCustomsHold":                             {"deliveryID", "reason"},
+	"QueryByExternalTracking":                      {"carrierCode", "trackingNumber"},
+	"QueryDeliveriesByCustodian":                   {"custodianID"},
+	"QueryDeliveriesByDateRange":                   {"startDate", "endDate"},
+	"QueryDeliveriesByLocation":                    {"city", "state"},
+	"QueryDeliveriesByStatus":                      {"status"},
+	"QueryDeliveriesRequiringEndorsementFromMyOrg": {"pageSize", "bookmark"},
+	"QueryDeliveriesRich":                          {"queryString", "pageSize", "bookmark"},
+	"QueryDeliveriesUpdatedSince":                  {"timestamp", "pageSize", "bookmark"},
+	"QueryDeliveryChain":                           {"deliveryID"},
+	"QueryDisputeCasesByState":                     {"state", "pageSize", "bookmark"},
+	"QueryFleetAvailability":                       {},
+	"QueryFraudSignals":                            {"status", "pageSize", "bookmark"},
+	"QueryOpenDisputes":                            {"pageSize", "bookmark"},
+	"QueryStalledDeliveries":                       {"statusThresholdsJSON"},
+	"QuoteDeliveryFee":                             {"deliveryID", "currency", "feeMinor"},
+	"ReadDelivery":                                 {"deliveryID"},
+	"ReadDeliveryIfChanged":                        {"deliveryID", "notModifiedSince"},
+	"RecallDelivery":                               {"deliveryID", "reason"},
+	"RecordCertifiedWeight":                        {"deliveryID", "weight"},
+	"RecordLegMilestone":                           {"deliveryID", "milestone"},
+	"RecordTelemetry":                              {"deliveryID", "telemetryType", "value"},
+	"RegisterAttachment":                           {"deliveryID", "attachmentType", "sha256Hash", "sizeBytes", "storageURIHash"},
+	"RegisterOrganization":                         {"mspID", "name", "allowedRolesJSON", "endorsesCustody"},
+	"RegisterSensorGateway":                        {"gatewayID", "mspID", "custodianID"},
+	"RegisterWeighingStation":                      {"stationID", "mspID", "certificationID", "certifiedUntil"},
+	"ReleaseCustomsHold":                           {"deliveryID", "reason"},
+	"ReleaseQuarantine":                            {"deliveryID", "reason"},
+	"ReofferPickup":                                {"deliveryID", "outcome"},
+	"ReportException":                              {"deliveryID", "exceptionType", "details"},
+	"ReportMissingItem":                            {"deliveryID", "itemID", "reasonCode", "reason"},
+	"ResolveDispute":                               {"deliveryID", "resolution", "outcome"},
+	"RetryDelivery":                                {"deliveryID"},
+	"ReviewFraudSignal":                            {"signalID", "outcome", "notes"},
+	"RevokeDeliveryAccess":                         {"deliveryID", "granteeID"},
+	"RotateHandoffCode":                            {"deliveryID"},
+	"SaveDeliveryTemplate":                         {"templateID", "name", "packageWeight", "dimensionLength", "dimensionWidth", "dimensionHeight", "originCity", "originState", "originCountry", "serviceTier"},
+	"SetAdminRegions":                              {"regionsJSON"},
+	"SetAgeRestricted":                             {"deliveryID", "ageRestricted"},
+	"SetAvailability":                              {"availability"},
+	"SetBusinessCalendar":                          {"calendarJSON"},
+	"SetContractSettings":                          {"settingsJSON"},
+	"SetCourierCapacity":                           {"courierID", "maxConcurrentDeliveries"},
+	"SetCustomsRequirements":                       {"deliveryID", "originCountry", "brokerID", "requiredDocumentsJSON"},
+	"SetDeclaredValue":                             {"deliveryID", "currency", "declaredValueMinor", "codAmountMinor"},
+	"SetDeliveryInsurance":                         {"deliveryID", "insurer", "policyNumber"},
+	"SetDeliveryPrivateDetails":                    {"deliveryID"},
+	"SetOrganizationActive":                        {"mspID", "active"},
+	"SetPermissionModel":                           {"modelJSON"},
+	"SetReasonCodes":                               {"category", "codesJSON"},
+	"SetRequiredCertifications":                    {"deliveryID", "certificationsJSON"},
+	"SetRestrictedGoodsMatrix":                     {"matrixJSON"},
+	"SetRetentionPolicy":                           {"policyJSON"},
+	"SetRoutePlan":                                 {"deliveryID", "stopsJSON", "allowance"},
+	"SetSerialization":                             {"deliveryID"},
+	"SetServiceCoverage":                           {"coverageJSON"},
+	"SetTelemetryThresholds":                       {"deliveryID", "thresholdsJSON"},
+	"SettleCashOnDelivery":                         {"deliveryID", "currency", "amountMinor"},
+	"SplitDelivery":                                {"deliveryID", "childrenJSON"},
+	"StartInternationalLeg":                        {"deliveryID", "legType", "originPort", "destinationPort"},
+	"TombstoneDelivery":                            {"deliveryID", "reasonHash"},
+	"UpdateCustomsStatus":                          {"deliveryID", "status"},
+	"UpdateDeliveryFields":                         {"deliveryID", "patchJSON", "expectedVersion"},
+	"UpdateLocation":                               {"deliveryID", "city", "state", "country"},
+	"VerifyContentsManifest":                       {"deliveryID", "manifestHash"},
+	"VerifyDeliveryPrivateDataHash":                {"deliveryID", "expectedHash"},
+	"VerifyRecipient":                              {"deliveryID", "providedValue", "salt"},
+}
+
+// parameterDescriptions describes transaction parameters by name
+var parameterDescriptions = map[string]string{
+	"active":                  "Whether the organization may take part in deliveries",
+	"ageRestricted":           "Whether the package needs an ID check at the doorstep",
+	"allowance":               "Deviation allowed from the planned stops: NONE, STATE or COUNTRY",
+	"allowedRolesJSON":        "JSON array of the roles the organization's users may hold",
+	"amountMinor":             "Cash collected, in minor units of the currency",
+	"anchorRef":               "Reference of the anchor on the external chain, e.g. a transaction hash",
+	"asOfTxID":                "Transaction ID the digest is computed as of (empty for the latest version)",
+	"attachmentType":          "PHOTO, SIGNATURE or DOCUMENT",
+	"availability":            "Shift status: AVAILABLE, ON_BREAK or OFF_SHIFT",
+	"bookmark":                "Bookmark returned by the previous page (empty for the first page)",
+	"brokerID":                "User ID of the CUSTOMS_BROKER handling the clearance",
+	"calendarJSON":            "JSON object mapping regions (US, US/CA) to workingDays, cutoffTime, utcOffsetMinutes and holidays",
+	"carrierCode":             "Code of the off-network carrier",
+	"category":                "Reason-code category: DISPUTE, CANCELLATION or INCIDENT",
+	"certificationID":         "ID of the station's metrology certification",
+	"certificationsJSON":      "JSON array of certificate attributes handlers must hold (cert_<name>=true)",
+	"certifiedUntil":          "RFC 3339 time the certification expires",
+	"chain":                   "Name of the external chain holding the anchor",
+	"childrenJSON":            "JSON array of the child parcels (ID, weight and dimensions)",
+	"city":                    "City of the caller's current location",
+	"codAmountMinor":          "Cash-on-delivery amount in minor units (0 for none)",
+	"codesJSON":               "JSON array of reason codes replacing the category's catalog",
+	"condition":               "Arrival condition: GOOD, DAMAGED or TAMPERED",
+	"country":                 "Country of the caller's current location",
+	"courierID":               "User ID of the DELIVERY_PERSON",
+	"coverageJSON":            "JSON array of serviced {city, state, country} areas ([] disables the check)",
+	"currency":                "ISO 4217 currency code",
+	"custodianID":             "User ID of the custodian",
+	"customerID":              "User ID of the CUSTOMER",
+	"declaredValueMinor":      "Declared value in minor units of the currency",
+	"deliveryID":              "Delivery ID (DEL-...)",
+	"deliveryIDsJSON":         "JSON array of delivery IDs",
+	"destinationPort":         "UN/LOCODE of the destination port (linehaul legs only)",
+	"details":                 "Free-text details",
+	"digest":                  "State digest returned by ComputeStateDigest",
+	"dimensionHeight":         "Package height",
+	"dimensionLength":         "Package length",
+	"dimensionUnit":           "Unit to return dimensions in: CM or IN",
+	"dimensionWidth":          "Package width",
+	"disposition":             "Optional restocking disposition: RESTOCK, REFURBISH, LIQUIDATE or DISPOSE",
+	"documentHash":            "Hex SHA-256 hash of the document",
+	"documentType":            "Customs document type, e.g. COMMERCIAL_INVOICE",
+	"endDate":                 "End of the creation date range (RFC 3339)",
+	"endorsesCustody":         "Whether the organization endorses deliveries its users hold",
+	"exceptionType":           "INCIDENT reason code",
+	"expectedHash":            "Hex SHA-256 hash to compare with the private data hash",
+	"expectedVersion":         "stateHash of the delivery returned by the caller's last read",
+	"expiry":                  "RFC 3339 time the delegation ends (at most 24 hours ahead)",
+	"feeMinor":                "Proposed fee in minor units of the currency",
+	"gatewayID":               "User ID of the SENSOR gateway identity",
+	"granteeID":               "User ID of the third party",
+	"helperID":                "User ID of the DELIVERY_PERSON or WAREHOUSE helper",
+	"insurer":                 "Name of the insurer",
+	"itemID":                  "Item ID within the delivery",
+	"legType":                 "EXPORT_HUB, LINEHAUL_AIR, LINEHAUL_SEA or IMPORT_HUB",
+	"limitPerSection":         "Deliveries returned per section (0 for the default)",
+	"locationCity":            "City the package is picked up in",
+	"locationCountry":         "Country the package is picked up in",
+	"locationState":           "State the package is picked up in",
+	"manifestHash":            "Hex SHA-256 hash of the contents manifest",
+	"manifestID":              "Transfer manifest ID",
+	"matrixJSON":              "JSON object mapping goods categories to {country, state, allowed} rules",
+	"maxConcurrentDeliveries": "Maximum deliveries the courier can hold at once",
+	"measurementsJSON":        "Optional JSON object mapping delivery IDs to measured weight and dimensions",
+	"mergedDeliveryID":        "ID of the new consolidated delivery",
+	"milestone":               "DEPARTED_ORIGIN_PORT or ARRIVED_DESTINATION_PORT",
+	"modelJSON":               "JSON object mapping roles to {inherits, functions}",
+	"mspID":                   "MSP ID of the organization",
+	"name":                    "Display name",
+	"newCustodianID":          "User ID of the courier or warehouse taking over",
+	"newMSP":                  "MSP ID replacing oldMSP in the policies",
+	"notModifiedSince":        "stateHash of the copy the caller already holds",
+	"notes":                   "Review notes",
+	"oldMSP":                  "MSP ID to replace in key-level endorsement policies",
+	"orderID":                 "Marketplace order ID",
+	"orderIDPrefix":           "Order ID prefix of the recalled deliveries (empty to select by seller)",
+	"originCity":              "City the package is picked up in",
+	"originCountry":           "ISO country code the package ships from",
+	"originPort":              "UN/LOCODE of the origin port (linehaul legs only)",
+	"originState":             "State the package is picked up in",
+	"outcome":                 "Outcome of the review",
+	"packageJSON":             "Delivery package returned by ExportDeliveryPackage on the source channel",
+	"packageWeight":           "Package weight in kg",
+	"pageSize":                "Maximum records to return",
+	"paramsJSON":              "JSON object of the action's arguments by parameter name",
+	"patchJSON":               "JSON merge patch of whitelisted fields (null clears a field)",
+	"policyJSON":              "JSON object mapping terminal statuses to {retentionDays, action}",
+	"policyNumber":            "Policy number at the insurer",
+	"proposedAction":          "Name of the transaction to dry-run",
+	"providedValue":           "Name or document number presented at the doorstep",
+	"purpose":                 "Why the address was read",
+	"quantity":                "Number of units",
+	"queryString":             "CouchDB selector query",
+	"quoteID":                 "ID returned by QuoteDeliveryFee",
+	"reason":                  "Free-text reason",
+	"reasonCode":              "Reason code from the tenant's catalog",
+	"reasonHash":              "Hex SHA-256 hash of the legal reason, kept on the tombstone",
+	"receivedIDsJSON":         "JSON array of the delivery IDs received",
+	"recentPerBucket":         "Deliveries returned per bucket (0 for the default)",
+	"regionsJSON":             "JSON object mapping region names to {city, state, country} areas",
+	"requiredDocumentsJSON":   "JSON array of required customs document types",
+	"resolution":              "Dispute resolution: REDELIVER, RETURN_TO_SELLER or NO_ACTION",
+	"role":                    "Built-in or custom role to describe",
+	"salt":                    "Salt the recipient hash was computed with",
+	"scope":                   "TRACKING or FULL",
+	"sellerID":                "User ID of the SELLER",
+	"serviceTier":             "STANDARD, EXPRESS or OVERNIGHT",
+	"settingsJSON":            "JSON object of the settings to change",
+	"sha256Hash":              "Hex SHA-256 hash of the file",
+	"signalID":                "Fraud signal ID",
+	"sinceTimestamp":          "RFC 3339 time to start from",
+	"sizeBytes":               "File size in bytes",
+	"skuHash":                 "Hex SHA-256 hash of the item's SKU",
+	"sourceChaincode":         "Name of the chaincode that exported the package",
+	"sourceIDsJSON":           "JSON array of the delivery IDs to merge",
+	"startDate":               "Start of the creation date range (RFC 3339)",
+	"state":                   "State of the caller's current location",
+	"stationID":               "User ID of the weighing station's SENSOR identity",
+	"status":                  "Delivery status",
+	"statusThresholdsJSON":    "JSON object mapping statuses to stall thresholds in hours",
+	"stopsJSON":               "JSON array of planned {city, state, country} stops",
+	"storageURIHash":          "Hex SHA-256 hash of the storage URI",
+	"targetChannel":           "Channel the delivery moves to",
+	"telemetryType":           "TEMPERATURE, HUMIDITY, SHOCK or DOOR_OPEN",
+	"templateID":              "Template ID",
+	"text":                    "Note text",
+	"thresholdHours":          "Hours in the current status after which the delivery counts as stalled",
+	"thresholdsJSON":          "JSON object mapping telemetry types to maximum values",
+	"timestamp":               "RFC 3339 time to list changes since",
+	"toCarrierMSP":            "MSP ID of the receiving carrier",
+	"toRole":                  "Role of the recipient",
+	"toUserID":                "User ID of the recipient",
+	"trackingNumber":          "Tracking number at the off-network carrier",
+	"txID":                    "Transaction ID of the status transition",
+	"userID":                  "User ID (empty for the caller)",
+	"value":                   "Reading value",
+	"visibility":              "PUBLIC_TO_PARTIES, LOGISTICS_ONLY or ADMIN_ONLY",
+	"weight":                  "Certified weight in kg",
+	"weightUnit":              "Unit to return the weight in: KG or LB",
+}
+
+// parameterDescriptionOverrides describes parameters whose meaning differs from the shared
+// description, keyed by "Transaction.parameter"
+var parameterDescriptionOverrides = map[string]string{
+	"QueryDeliveriesByLocation.city":    "City to match",
+	"QueryDeliveriesByLocation.state":   "State to match",
+	"QueryDisputeCasesByState.state":    "Dispute case state: OPEN or RESOLVED",
+	"QueryFraudSignals.status":          "Signal status: OPEN, DISMISSED or CONFIRMED",
+	"RegisterOrganization.name":         "Display name of the organization",
+	"RegisterSensorGateway.custodianID": "Courier or warehouse the gateway is installed with",
+	"ReofferPickup.outcome":             "Free-text outcome of the disputed pickup",
+	"ResolveDispute.outcome":            "Free-text outcome of the dispute",
+	"ReviewFraudSignal.outcome":         "DISMISSED or CONFIRMED",
+	"SaveDeliveryTemplate.name":         "Display name of the template",
+	"UpdateCustomsStatus.status":        "Clearance status: PENDING, SUBMITTED, CLEARED or REJECTED",
+}
+
+// describeMetadata adds parameter names and descriptions and the chaincode info to the
+// metadata reflected by contractapi
+// Transactions missing from transactionParameters (or listed with the wrong number of
+// parameters) and parameters without a description are errors, so the chaincode does not start
+// with an incomplete interface definition.
+func describeMetadata(reflected []byte) ([]byte, error) {
+	var ccMetadata metadata.ContractChaincodeMetadata
+	if err := json.Unmarshal(reflected, &ccMetadata); err != nil {
+		return nil, fmt.Errorf("failed to parse reflected metadata: %v", err)
+	}
+	info := chaincodeInfo
+	ccMetadata.Info = &info
+
+	// DeliveryContract is the only, and so the default, contract; the other one is contractapi's system contract
+	name := ""
+	for contractName, contract := range ccMetadata.Contracts {
+		if contract.Default {
+			name = contractName
+		}
+	}
+	contract, ok := ccMetadata.Contracts[name]
+	if !ok {
+		return nil, fmt.Errorf("the default contract is missing from the reflected metadata")
+	}
+	described := map[string]bool{}
+	for i := range contract.Transactions {
+		tx := &contract.Transactions[i]
+		names, ok := transactionParameters[tx.Name]
+		if !ok {
+			return nil, fmt.Errorf("transaction %s has no entry in transactionParameters", tx.Name)
+		}
+		if len(names) != len(tx.Parameters) {
+			return nil, fmt.Errorf("transaction %s takes %d parameters, transactionParameters names %d", tx.Name, len(tx.Parameters), len(names))
+		}
+		for j, parameter := range names {
+			description, ok := parameterDescriptionOverrides[tx.Name+"."+parameter]
+			if !ok {
+				description, ok = parameterDescriptions[parameter]
+			}
+			if !ok {
+				return nil, fmt.Errorf("parameter %s of %s has no description", parameter, tx.Name)
+			}
+			tx.Parameters[j].Name = parameter
+			tx.Parameters[j].Description = description
+		}
+		described[tx.Name] = true
+	}
+	for txName := range transactionParameters {
+		if !described[txName] {
+			return nil, fmt.Errorf("transactionParameters names unknown transaction %s", txName)
+		}
+	}
+	ccMetadata.Contracts[name] = contract
+
+	if err := metadata.ValidateAgainstSchema(ccMetadata); err != nil {
+		return nil, fmt.Errorf("described metadata is invalid: %v", err)
+	}
+	return json.Marshal(ccMetadata)
+}
+
+// metadataStub is the stub the reflected metadata is read with before the chaincode starts
+// GetMetadata needs nothing else from the stub; the creator is only looked up, not required.
+type metadataStub struct {
+	shim.ChaincodeStubInterface
+}
+
+func (metadataStub) GetFunctionAndParameters() (string, []string) {
+	return systemGetMetadata, nil
+}
+
+func (metadataStub) GetCreator() ([]byte, error) {
+	return nil, fmt.Errorf("no creator outside a transaction")
+}
+
+// describedChaincode serves the described metadata from GetMetadata and passes every other
+// call to the contract chaincode
+type describedChaincode struct {
+	*contractapi.ContractChaincode
+	metadata []byte
+}
+
+// newDescribedChaincode creates the chaincode of a contract with its described metadata
+func newDescribedChaincode(contract contractapi.ContractInterface) (*describedChaincode, error) {
+	if err := validateEvaluateTransactions(); err != nil {
+		return nil, err
+	}
+	chaincode, err := contractapi.NewChaincode(contract)
+	if err != nil {
+		return nil, err
+	}
+	chaincode.Info = chaincodeInfo
+
+	response := chaincode.Invoke(metadataStub{})
+	if response.Status != shim.OK {
+		return nil, fmt.Errorf("failed to read reflected metadata: %s", response.Message)
+	}
+	described, err := describeMetadata(response.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return &describedChaincode{ContractChaincode: chaincode, metadata: described}, nil
+}
+
+// Invoke answers GetMetadata with the described metadata
+func (cc *describedChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	if function, _ := stub.GetFunctionAndParameters(); function == systemGetMetadata {
+		return shim.Success(cc.metadata)
+	}
+	return cc.ContractChaincode.Invoke(stub)
+}
+
+// Start runs the chaincode as a service when CHAINCODE_SERVER_ADDRESS and CORE_CHAINCODE_ID_NAME
+// are set, and connects to the peer otherwise
+// The environment is read the way contractapi's own Start reads it, which can't be used here
+// because it would register the undescribed chaincode.
+func (cc *describedChaincode) Start() error {
+	address := os.Getenv("CHAINCODE_SERVER_ADDRESS")
+	ccid := os.Getenv("CORE_CHAINCODE_ID_NAME")
+	if address == "" || ccid == "" {
+		return shim.Start(cc)
+	}
+
+	tlsProps, err := chaincodeServerTLS()
+	if err != nil {
+		return err
+	}
+	server := &shim.ChaincodeServer{
+		CCID:     ccid,
+		Address:  address,
+		CC:       cc,
+		TLSProps: *tlsProps,
+	}
+	return server.Start()
+}
+
+// chaincodeServerTLS loads the TLS settings of the chaincode server from the environment
+func chaincodeServerTLS() (*shim.TLSProperties, error) {
+	if enabled, err := strconv.ParseBool(os.Getenv("CORE_PEER_TLS_ENABLED")); err != nil || !enabled {
+		return &shim.TLSProperties{Disabled: true}, nil
+	}
+
+	key, err := os.ReadFile(os.Getenv("CORE_TLS_CLIENT_KEY_FILE"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS key: %v", err)
+	}
+	cert, err := os.ReadFile(os.Getenv("CORE_TLS_CLIENT_CERT_FILE"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS certificate: %v", err)
+	}
+	var rootCerts []byte
+	if rootFile := os.Getenv("CORE_PEER_TLS_ROOTCERT_FILE"); rootFile != "" {
+		if rootCerts, err = os.ReadFile(rootFile); err != nil {
+			return nil, fmt.Errorf("failed to read TLS root certificate: %v", err)
+		}
+	}
+	return &shim.TLSProperties{Key: key, Cert: cert, ClientCACerts: rootCerts}, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestOmittedFieldsAreOptionalInMetadata checks that no field left out of the JSON when empty is
+// required by the contract metadata, since contractapi would reject every response without it
+func TestOmittedFieldsAreOptionalInMetadata(t *testing.T) {
+	network := newTestNetwork(t)
+	response, err := network.invoke(testAdmin, "org.hyperledger.fabric:GetMetadata")
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	var metadata struct {
+		Components struct {
+			Schemas map[string]struct {
+				Required []string `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal([]byte(response), &metadata); err != nil {
+		t.Fatalf("failed to unmarshal metadata: %v", err)
+	}
+
+	packages, err := parser.ParseDir(token.NewFileSet(), ".", func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("failed to parse the contract: %v", err)
+	}
+	for _, file := range packages["main"].Files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			spec, ok := node.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			structType, ok := spec.Type.(*ast.StructType)
+			schema, described := metadata.Components.Schemas[spec.Name.Name]
+			if !ok || !described {
+				return true
+			}
+			required := map[string]bool{}
+			for _, name := range schema.Required {
+				required[name] = true
+			}
+			for _, field := range structType.Fields.List {
+				if field.Tag == nil {
+					continue
+				}
+				tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
+				name, options, _ := strings.Cut(tag.Get("json"), ",")
+				if options == "omitempty" && required[name] {
+					t.Errorf("%s.%s is omitted when empty but required by the metadata", spec.Name.Name, name)
+				}
+			}
+			return true
+		})
+	}
+}
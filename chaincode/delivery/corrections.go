@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordCorrection is the composite key prefix for correction records
+const RecordCorrection = "correction~deliveryId~txId"
+
+// EventPartiesCorrected is emitted when an admin corrects the seller or customer of a delivery
+const EventPartiesCorrected = "DeliveryPartiesCorrected"
+
+// CorrectionRecord captures a delivery change made outside the normal custody flow
+// The full before/after snapshots keep the audit story intact without relying on key history
+type CorrectionRecord struct {
+	DeliveryID    string   `json:"deliveryId"`
+	TxID          string   `json:"txId"`
+	Operation     string   `json:"operation"`
+	Reason        string   `json:"reason"`
+	Before        Delivery `json:"before"`
+	After         Delivery `json:"after"`
+	ApprovedBy    string   `json:"approvedBy"`
+	ApproverRole  UserRole `json:"approverRole"`
+	ApproverMSP   string   `json:"approverMsp"`
+	CorrectedAt   string   `json:"correctedAt"`
+	CorrelationID string   `json:"correlationId,omitempty" metadata:",optional"`
+}
+
+// recordCorrection stores a correction record for an out-of-flow delivery change
+// Callers pass the delivery as it was before and after the change
+func recordCorrection(
+	ctx contractapi.TransactionContextInterface,
+	operation string,
+	reason string,
+	before *Delivery,
+	after *Delivery,
+	caller *CallerIdentity,
+) error {
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	txID := ctx.GetStub().GetTxID()
+
+	correction := CorrectionRecord{
+		DeliveryID:    after.DeliveryID,
+		TxID:          txID,
+		Operation:     operation,
+		Reason:        reason,
+		Before:        *before,
+		After:         *after,
+		ApprovedBy:    caller.ID,
+		ApproverRole:  caller.Role,
+		ApproverMSP:   caller.MSP,
+		CorrectedAt:   currentTime,
+		CorrelationID: correlationID(ctx),
+	}
+
+	correctionKey, err := ctx.GetStub().CreateCompositeKey(RecordCorrection, []string{after.DeliveryID, txID})
+	if err != nil {
+		return fmt.Errorf("failed to create correction composite key: %v", err)
+	}
+	correctionJSON, err := json.Marshal(correction)
+	if err != nil {
+		return fmt.Errorf("failed to marshal correction: %v", err)
+	}
+	if err := ctx.GetStub().PutState(correctionKey, correctionJSON); err != nil {
+		return fmt.Errorf("failed to put correction record: %v", err)
+	}
+
+	return nil
+}
+
+// GetCorrections returns all correction records for a delivery
+// Any party involved in the delivery (or admin) can read them
+func (c *DeliveryContract) GetCorrections(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*CorrectionRecord, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordCorrection, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get corrections: %v", err)
+	}
+	defer iterator.Close()
+
+	corrections := []*CorrectionRecord{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate corrections: %v", err)
+		}
+
+		var correction CorrectionRecord
+		if err := json.Unmarshal(response.Value, &correction); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal correction: %v", err)
+		}
+		corrections = append(corrections, &correction)
+	}
+
+	return corrections, nil
+}
+
+// CorrectDeliveryParties replaces a mis-entered seller or customer ID on a delivery
+// Only ADMIN can correct parties; an empty ID keeps the current party. Custody and a pending
+// handoff held by a replaced party follow it, and the seller~/customer~ indexes move with the
+// update. The before/after snapshots are kept as a correction record.
+func (c *DeliveryContract) CorrectDeliveryParties(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	sellerID string,
+	customerID string,
+	reason string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	if sellerID == "" && customerID == "" {
+		return &ValidationError{Field: "sellerID", Message: "sellerID or customerID must be provided"}
+	}
+	if sellerID != "" {
+		if err := validateUserID(sellerID, "sellerID"); err != nil {
+			return err
+		}
+	}
+	if customerID != "" {
+		if err := validateUserID(customerID, "customerID"); err != nil {
+			return err
+		}
+	}
+	reason = sanitizeText(reason)
+	if err := validateReason(reason); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can correct parties
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	before := *delivery
+	if before.PendingHandoff != nil {
+		handoff := *before.PendingHandoff
+		delivery.PendingHandoff = &handoff
+	}
+
+	if sellerID != "" && sellerID != delivery.SellerID {
+		replacePartyID(delivery, RoleSeller, delivery.SellerID, sellerID)
+		delivery.SellerID = sellerID
+	}
+	if customerID != "" && customerID != delivery.CustomerID {
+		replacePartyID(delivery, RoleCustomer, delivery.CustomerID, customerID)
+		delivery.CustomerID = customerID
+	}
+	if delivery.SellerID == before.SellerID && delivery.CustomerID == before.CustomerID {
+		return fmt.Errorf("delivery %s already has these parties", deliveryID)
+	}
+	if delivery.SellerID == delivery.CustomerID {
+		return fmt.Errorf("seller and customer must be different users")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	delivery.UpdatedAt = currentTime
+
+	if err := recordCorrection(ctx, "CorrectDeliveryParties", reason, &before, delivery, caller); err != nil {
+		return err
+	}
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventPartiesCorrected, map[string]string{
+		"deliveryId":    deliveryID,
+		"oldSellerId":   before.SellerID,
+		"newSellerId":   delivery.SellerID,
+		"oldCustomerId": before.CustomerID,
+		"newCustomerId": delivery.CustomerID,
+		"correctedBy":   caller.ID,
+		"timestamp":     currentTime,
+	})
+}
+
+// replacePartyID moves custody and pending handoff references from a replaced party to its successor
+func replacePartyID(delivery *Delivery, role UserRole, oldID string, newID string) {
+	if delivery.CurrentCustodianRole == role && delivery.CurrentCustodianID == oldID {
+		delivery.CurrentCustodianID = newID
+	}
+	if handoff := delivery.PendingHandoff; handoff != nil {
+		if handoff.FromRole == role && handoff.FromUserID == oldID {
+			handoff.FromUserID = newID
+		}
+		if handoff.ToRole == role && handoff.ToUserID == oldID {
+			handoff.ToUserID = newID
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// corrections returns the correction records of a delivery as an admin reads them
+func (n *testNetwork) corrections(deliveryID string) []CorrectionRecord {
+	n.t.Helper()
+	response, err := n.invoke(testAdmin, "GetCorrections", deliveryID)
+	if err != nil {
+		n.t.Fatalf("failed to get corrections of %s: %v", deliveryID, err)
+	}
+	var corrections []CorrectionRecord
+	if err := json.Unmarshal([]byte(response), &corrections); err != nil {
+		n.t.Fatalf("failed to unmarshal corrections: %v", err)
+	}
+	return corrections
+}
+
+func TestResolveDisputeRecordsCorrection(t *testing.T) {
+	network := newTestNetwork(t)
+	delivery := testDelivery(testDeliveryID, StatusDisputedDelivery)
+	delivery.LastDispute = &DisputeInfo{DisputedStatus: StatusDisputedDelivery}
+	network.putDelivery(delivery)
+
+	if _, err := network.invoke(testAdmin, "ResolveDispute", testDeliveryID, string(ResolutionNoAction), "checked with both parties"); err != nil {
+		t.Fatalf("failed to resolve dispute: %v", err)
+	}
+
+	corrections := network.corrections(testDeliveryID)
+	if len(corrections) != 1 {
+		t.Fatalf("got %d correction records, want 1", len(corrections))
+	}
+	correction := corrections[0]
+	if correction.Operation != "ResolveDispute" || correction.Reason != "checked with both parties" || correction.ApprovedBy != testAdmin.id {
+		t.Errorf("unexpected correction record: %+v", correction)
+	}
+	if correction.Before.DeliveryStatus != StatusDisputedDelivery || correction.Before.LastDispute.Resolution != "" {
+		t.Errorf("before snapshot shows the resolution: %+v", correction.Before.LastDispute)
+	}
+	if correction.After.DeliveryStatus != StatusInTransit || correction.After.LastDispute.Resolution != ResolutionNoAction {
+		t.Errorf("after snapshot is %s with %+v", correction.After.DeliveryStatus, correction.After.LastDispute)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransientCorrelationID is the transient map key for the caller's request correlation ID
+// Passed as transient data rather than a parameter so every transaction accepts it unchanged.
+const TransientCorrelationID = "correlationId"
+
+// correlationIDPattern accepts UUIDs, trace IDs and similar request identifiers
+var correlationIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// readCorrelationID runs before every transaction and keeps the correlation ID supplied with it
+// Called by beforeTransaction. A malformed ID fails the transaction, so a tracing bug in the
+// caller is noticed rather than recorded.
+func readCorrelationID(ctx contractapi.TransactionContextInterface) error {
+	tenantCtx, ok := ctx.(*TenantTransactionContext)
+	if !ok {
+		return nil
+	}
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient data: %v", err)
+	}
+	value, exists := transientMap[TransientCorrelationID]
+	if !exists || len(value) == 0 {
+		return nil
+	}
+	if !correlationIDPattern.Match(value) {
+		return &ValidationError{Field: TransientCorrelationID, Message: "must be 1 to 128 letters, digits, '.', '_', ':' or '-'"}
+	}
+	tenantCtx.correlationID = string(value)
+	return nil
+}
+
+// correlationID returns the correlation ID supplied with the transaction ("" if none)
+func correlationID(ctx contractapi.TransactionContextInterface) string {
+	if tenantCtx, ok := ctx.(*TenantTransactionContext); ok {
+		return tenantCtx.correlationID
+	}
+	return ""
+}
+
+// withCorrelationID adds the transaction's correlation ID to a JSON object payload
+// Payloads of transactions without one are returned unchanged.
+func withCorrelationID(ctx contractapi.TransactionContextInterface, payloadBytes []byte) []byte {
+	id := correlationID(ctx)
+	if id == "" {
+		return payloadBytes
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payloadBytes, &fields); err != nil || fields == nil {
+		return payloadBytes
+	}
+	idBytes, err := json.Marshal(id)
+	if err != nil {
+		return payloadBytes
+	}
+	fields["correlationId"] = idBytes
+
+	withID, err := json.Marshal(fields)
+	if err != nil {
+		return payloadBytes
+	}
+	return withID
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// configCoverageName is the config record holding the serviced areas
+const configCoverageName = "coverage"
+
+// ErrCodeUnserviceableDestination prefixes errors for destinations outside service coverage
+const ErrCodeUnserviceableDestination = "UNSERVICEABLE_DESTINATION"
+
+// maxServiceAreas bounds the coverage list kept in one config record
+const maxServiceAreas = 5000
+
+// ServiceArea is a serviced country, state or city
+// An empty city covers the whole state; an empty state (and city) covers the whole country.
+type ServiceArea struct {
+	City    string `json:"city,omitempty" metadata:",optional"`
+	State   string `json:"state,omitempty" metadata:",optional"`
+	Country string `json:"country"`
+}
+
+// covers reports whether the area includes a location (case-insensitive)
+func (a ServiceArea) covers(location Location) bool {
+	if !strings.EqualFold(a.Country, location.Country) {
+		return false
+	}
+	if a.State != "" && !strings.EqualFold(a.State, location.State) {
+		return false
+	}
+	return a.City == "" || strings.EqualFold(a.City, location.City)
+}
+
+// UnserviceableDestinationError is returned when a delivery's destination is outside coverage
+// Clients match on the UNSERVICEABLE_DESTINATION prefix of the message.
+type UnserviceableDestinationError struct {
+	Destination Location
+}
+
+func (e *UnserviceableDestinationError) Error() string {
+	return fmt.Sprintf("%s: %s, %s, %s is not in the service coverage", ErrCodeUnserviceableDestination,
+		e.Destination.City, e.Destination.State, e.Destination.Country)
+}
+
+// getServiceCoverage reads the configured service areas (empty if none)
+func getServiceCoverage(ctx contractapi.TransactionContextInterface) ([]ServiceArea, error) {
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configCoverageName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	coverageJSON, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service coverage: %v", err)
+	}
+
+	coverage := []ServiceArea{}
+	if coverageJSON != nil {
+		if err := json.Unmarshal(coverageJSON, &coverage); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal service coverage: %v", err)
+		}
+	}
+	return coverage, nil
+}
+
+// validateServiceable rejects a destination outside the configured coverage
+// Tenants that never configured coverage serve every destination.
+func validateServiceable(ctx contractapi.TransactionContextInterface, destination Location) error {
+	coverage, err := getServiceCoverage(ctx)
+	if err != nil {
+		return err
+	}
+	if len(coverage) == 0 {
+		return nil
+	}
+	for _, area := range coverage {
+		if area.covers(destination) {
+			return nil
+		}
+	}
+	return &UnserviceableDestinationError{Destination: destination}
+}
+
+// SetServiceCoverage replaces the serviced areas of the caller's tenant
+// Only ADMIN can set coverage. coverageJSON is a JSON array of {city, state, country} where
+// city and state may be left empty to cover a whole state or country; an empty array
+// turns the check off.
+func (c *DeliveryContract) SetServiceCoverage(
+	ctx contractapi.TransactionContextInterface,
+	coverageJSON string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	var coverage []ServiceArea
+	if err := json.Unmarshal([]byte(coverageJSON), &coverage); err != nil {
+		return fmt.Errorf("failed to parse service coverage: %v", err)
+	}
+	if len(coverage) > maxServiceAreas {
+		return &ValidationError{Field: "coverage", Message: fmt.Sprintf("cannot list more than %d areas", maxServiceAreas)}
+	}
+	for i, area := range coverage {
+		area.City, area.State, area.Country = normalizeLocation(area.City, area.State, area.Country)
+		if err := validateCountryCode(area.Country); err != nil {
+			return err
+		}
+		if err := validateText(area.State, "state", 100, false); err != nil {
+			return err
+		}
+		if err := validateText(area.City, "city", 100, false); err != nil {
+			return err
+		}
+		if area.City != "" && area.State == "" {
+			return &ValidationError{Field: "coverage", Message: fmt.Sprintf("city %s needs a state", area.City)}
+		}
+		coverage[i] = area
+	}
+	if coverage == nil {
+		coverage = []ServiceArea{}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can change coverage
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configCoverageName})
+	if err != nil {
+		return fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	updatedJSON, err := json.Marshal(coverage)
+	if err != nil {
+		return fmt.Errorf("failed to marshal service coverage: %v", err)
+	}
+
+	return ctx.GetStub().PutState(configKey, updatedJSON)
+}
+
+// GetServiceCoverage returns the serviced areas of the caller's tenant
+func (c *DeliveryContract) GetServiceCoverage(
+	ctx contractapi.TransactionContextInterface,
+) ([]ServiceArea, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	return getServiceCoverage(ctx)
+}
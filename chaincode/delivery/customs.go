@@ -0,0 +1,470 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Event names for customs clearance
+const (
+	EventCustomsDocumentAttached = "CustomsDocumentAttached"
+	EventCustomsStatusChanged    = "CustomsStatusChanged"
+	EventCustomsHoldPlaced       = "CustomsHoldPlaced"
+	EventCustomsHoldReleased     = "CustomsHoldReleased"
+)
+
+// maxCustomsDocuments bounds the required documents and attachments of one delivery
+const maxCustomsDocuments = 20
+
+// CustomsStatus is the clearance status reported by the customs broker
+type CustomsStatus string
+
+const (
+	CustomsPending   CustomsStatus = "PENDING"
+	CustomsSubmitted CustomsStatus = "SUBMITTED"
+	CustomsCleared   CustomsStatus = "CLEARED"
+	CustomsRejected  CustomsStatus = "REJECTED"
+)
+
+// CustomsDocument is the hash of a customs document (invoice, certificate of origin, ...)
+type CustomsDocument struct {
+	Type         string `json:"type"`
+	DocumentHash string `json:"documentHash"`
+	AttachedBy   string `json:"attachedBy"`
+	AttachedAt   string `json:"attachedAt"`
+}
+
+// CustomsHold is a broker's hold on an international delivery
+type CustomsHold struct {
+	Reason   string `json:"reason"`
+	PlacedBy string `json:"placedBy"`
+	PlacedAt string `json:"placedAt"`
+}
+
+// CustomsInfo tracks the customs clearance of an international delivery
+type CustomsInfo struct {
+	OriginCountry     string            `json:"originCountry"`
+	BrokerID          string            `json:"brokerId"`
+	RequiredDocuments []string          `json:"requiredDocuments"`
+	Documents         []CustomsDocument `json:"documents"`
+	Status            CustomsStatus     `json:"status"`
+	Hold              *CustomsHold      `json:"hold,omitempty" metadata:",optional"`
+	UpdatedBy         string            `json:"updatedBy"`
+	UpdatedAt         string            `json:"updatedAt"`
+}
+
+// missingDocuments returns the required document types not attached yet
+func (ci *CustomsInfo) missingDocuments() []string {
+	attached := make(map[string]bool)
+	for _, document := range ci.Documents {
+		attached[document.Type] = true
+	}
+	missing := []string{}
+	for _, documentType := range ci.RequiredDocuments {
+		if !attached[documentType] {
+			missing = append(missing, documentType)
+		}
+	}
+	return missing
+}
+
+// isCustomsBroker reports whether the caller is the customs broker of an international delivery
+func isCustomsBroker(delivery *Delivery, caller *CallerIdentity) bool {
+	return caller.Role == RoleCustomsBroker && delivery.Customs != nil &&
+		delivery.Customs.BrokerID == caller.ID && delivery.TenantID == caller.TenantID
+}
+
+// validateCustomsCleared rejects the last mile of an international delivery that has not cleared
+// customs: every required document attached, status CLEARED and no hold
+func validateCustomsCleared(delivery *Delivery) error {
+	customs := delivery.Customs
+	if customs == nil {
+		return nil
+	}
+	if customs.Hold != nil {
+		return fmt.Errorf("delivery %s is on customs hold: %s", delivery.DeliveryID, customs.Hold.Reason)
+	}
+	if missing := customs.missingDocuments(); len(missing) > 0 {
+		return fmt.Errorf("delivery %s is missing customs documents: %s", delivery.DeliveryID, strings.Join(missing, ", "))
+	}
+	if customs.Status != CustomsCleared {
+		return fmt.Errorf("delivery %s has not cleared customs (status %s)", delivery.DeliveryID, customs.Status)
+	}
+	return nil
+}
+
+// readBrokerDelivery loads a delivery for its customs broker
+func (c *DeliveryContract) readBrokerDelivery(
+	ctx contractapi.TransactionContextInterface,
+	caller *CallerIdentity,
+	deliveryID string,
+) (*Delivery, error) {
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Customs == nil {
+		return nil, fmt.Errorf("delivery %s is not an international delivery", deliveryID)
+	}
+	if !isCustomsBroker(delivery, caller) {
+		return nil, fmt.Errorf("only the customs broker of delivery %s can change its customs clearance", deliveryID)
+	}
+	switch delivery.DeliveryStatus {
+	case StatusConfirmedDelivery, StatusCancelled, StatusSplit, StatusMerged, StatusExported, StatusReturnCompleted, StatusRedacted:
+		return nil, fmt.Errorf("cannot change customs clearance in current status: %s", delivery.DeliveryStatus)
+	}
+	return delivery, nil
+}
+
+// SetCustomsRequirements makes a delivery international, naming its origin country, customs
+// broker and the documents the broker must attach before the last mile
+// Only the SELLER can set them, and only before pickup. requiredDocumentsJSON is a JSON array
+// of document types such as COMMERCIAL_INVOICE or CERTIFICATE_OF_ORIGIN.
+func (c *DeliveryContract) SetCustomsRequirements(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	originCountry string,
+	brokerID string,
+	requiredDocumentsJSON string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	originCountry = strings.ToUpper(strings.TrimSpace(originCountry))
+	if err := validateCountryCode(originCountry); err != nil {
+		return err
+	}
+	if err := validateUserID(brokerID, "brokerID"); err != nil {
+		return err
+	}
+	var requiredDocuments []string
+	if err := json.Unmarshal([]byte(requiredDocumentsJSON), &requiredDocuments); err != nil {
+		return fmt.Errorf("failed to parse required documents: %v", err)
+	}
+	if len(requiredDocuments) > maxCustomsDocuments {
+		return &ValidationError{Field: "requiredDocuments", Message: fmt.Sprintf("exceeds maximum of %d documents", maxCustomsDocuments)}
+	}
+	normalized := make([]string, 0, len(requiredDocuments))
+	seen := make(map[string]bool)
+	for _, documentType := range requiredDocuments {
+		documentType = strings.ToUpper(strings.TrimSpace(documentType))
+		if !certificationNamePattern.MatchString(documentType) {
+			return &ValidationError{Field: "requiredDocuments", Message: fmt.Sprintf("invalid document type: %s", documentType)}
+		}
+		if !seen[documentType] {
+			seen[documentType] = true
+			normalized = append(normalized, documentType)
+		}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER declares international shipments
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller can set customs requirements")
+	}
+	if delivery.DeliveryStatus != StatusPendingPickup {
+		return fmt.Errorf("customs requirements can only be set before pickup")
+	}
+	if delivery.Destination == nil || delivery.Destination.Country == originCountry {
+		return &ValidationError{Field: "originCountry", Message: "must differ from the delivery's destination country"}
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Documents already attached stay; the broker keeps working on the same clearance
+	customs := &CustomsInfo{Documents: []CustomsDocument{}, Status: CustomsPending}
+	if delivery.Customs != nil {
+		customs = delivery.Customs
+	}
+	customs.OriginCountry = originCountry
+	customs.BrokerID = brokerID
+	customs.RequiredDocuments = normalized
+	customs.UpdatedBy = caller.ID
+	customs.UpdatedAt = currentTime
+	delivery.Customs = customs
+	delivery.UpdatedAt = currentTime
+
+	return applyDeliveryUpdate(ctx, delivery)
+}
+
+// AttachCustomsDocument records the hash of a customs document of an international delivery
+// Only the delivery's CUSTOMS_BROKER can attach documents; attaching a type again replaces it.
+func (c *DeliveryContract) AttachCustomsDocument(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	documentType string,
+	documentHash string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	documentType = strings.ToUpper(strings.TrimSpace(documentType))
+	if !certificationNamePattern.MatchString(documentType) {
+		return &ValidationError{Field: "documentType", Message: "must be 1-32 characters of A-Z, 0-9 or '_'"}
+	}
+	if !identityHashPattern.MatchString(documentHash) {
+		return &ValidationError{Field: "documentHash", Message: "must be a hex-encoded SHA-256 digest"}
+	}
+	documentHash = strings.ToLower(documentHash)
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only CUSTOMS_BROKER handles customs paperwork
+	if err := validateRole(ctx, caller, RoleCustomsBroker); err != nil {
+		return err
+	}
+
+	delivery, err := c.readBrokerDelivery(ctx, caller, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	document := CustomsDocument{
+		Type:         documentType,
+		DocumentHash: documentHash,
+		AttachedBy:   caller.ID,
+		AttachedAt:   currentTime,
+	}
+	customs := delivery.Customs
+	replaced := false
+	for i := range customs.Documents {
+		if customs.Documents[i].Type == documentType {
+			customs.Documents[i] = document
+			replaced = true
+		}
+	}
+	if !replaced {
+		if len(customs.Documents) >= maxCustomsDocuments {
+			return fmt.Errorf("delivery %s already has %d customs documents", deliveryID, maxCustomsDocuments)
+		}
+		customs.Documents = append(customs.Documents, document)
+	}
+	customs.UpdatedBy = caller.ID
+	customs.UpdatedAt = currentTime
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventCustomsDocumentAttached, map[string]string{
+		"deliveryId":   deliveryID,
+		"documentType": documentType,
+		"documentHash": documentHash,
+		"attachedBy":   caller.ID,
+		"timestamp":    currentTime,
+	})
+}
+
+// UpdateCustomsStatus records the customs clearance status of an international delivery
+// Only the delivery's CUSTOMS_BROKER can update it; CLEARED needs every required document.
+func (c *DeliveryContract) UpdateCustomsStatus(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	status string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	customsStatus := CustomsStatus(strings.ToUpper(strings.TrimSpace(status)))
+	switch customsStatus {
+	case CustomsPending, CustomsSubmitted, CustomsCleared, CustomsRejected:
+	default:
+		return &ValidationError{Field: "status", Message: fmt.Sprintf("unknown customs status: %s", status)}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only CUSTOMS_BROKER reports clearance
+	if err := validateRole(ctx, caller, RoleCustomsBroker); err != nil {
+		return err
+	}
+
+	delivery, err := c.readBrokerDelivery(ctx, caller, deliveryID)
+	if err != nil {
+		return err
+	}
+	customs := delivery.Customs
+	if customsStatus == CustomsCleared {
+		if missing := customs.missingDocuments(); len(missing) > 0 {
+			return fmt.Errorf("cannot clear customs, missing documents: %s", strings.Join(missing, ", "))
+		}
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	oldStatus := customs.Status
+
+	customs.Status = customsStatus
+	customs.UpdatedBy = caller.ID
+	customs.UpdatedAt = currentTime
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventCustomsStatusChanged, map[string]string{
+		"deliveryId": deliveryID,
+		"oldStatus":  string(oldStatus),
+		"newStatus":  string(customsStatus),
+		"updatedBy":  caller.ID,
+		"timestamp":  currentTime,
+	})
+}
+
+// PlaceCustomsHold holds an international delivery at customs, blocking its last mile
+// Only the delivery's CUSTOMS_BROKER can place a hold
+func (c *DeliveryContract) PlaceCustomsHold(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	reason string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	reason = sanitizeText(reason)
+	if err := validateReason(reason); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only CUSTOMS_BROKER places holds
+	if err := validateRole(ctx, caller, RoleCustomsBroker); err != nil {
+		return err
+	}
+
+	delivery, err := c.readBrokerDelivery(ctx, caller, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.Customs.Hold != nil {
+		return fmt.Errorf("delivery %s is already on customs hold", deliveryID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	delivery.Customs.Hold = &CustomsHold{
+		Reason:   reason,
+		PlacedBy: caller.ID,
+		PlacedAt: currentTime,
+	}
+	delivery.Customs.UpdatedBy = caller.ID
+	delivery.Customs.UpdatedAt = currentTime
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventCustomsHoldPlaced, map[string]string{
+		"deliveryId":  deliveryID,
+		"custodianId": delivery.CurrentCustodianID,
+		"placedBy":    caller.ID,
+		"reason":      reason,
+		"timestamp":   currentTime,
+	})
+}
+
+// ReleaseCustomsHold lifts the customs hold of an international delivery
+// Only the delivery's CUSTOMS_BROKER can release a hold
+func (c *DeliveryContract) ReleaseCustomsHold(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	reason string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	reason = sanitizeText(reason)
+	if err := validateReason(reason); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only CUSTOMS_BROKER releases holds
+	if err := validateRole(ctx, caller, RoleCustomsBroker); err != nil {
+		return err
+	}
+
+	delivery, err := c.readBrokerDelivery(ctx, caller, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.Customs.Hold == nil {
+		return fmt.Errorf("delivery %s is not on customs hold", deliveryID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	delivery.Customs.Hold = nil
+	delivery.Customs.UpdatedBy = caller.ID
+	delivery.Customs.UpdatedAt = currentTime
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventCustomsHoldReleased, map[string]string{
+		"deliveryId": deliveryID,
+		"releasedBy": caller.ID,
+		"reason":     reason,
+		"timestamp":  currentTime,
+	})
+}
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// defaultDashboardRecent is how many summaries a dashboard bucket lists when none is asked for
+const defaultDashboardRecent = 5
+
+// maxDashboardRecent bounds the summaries listed per dashboard bucket
+const maxDashboardRecent = 20
+
+// maxDashboardScan bounds the index entries one dashboard section looks at
+const maxDashboardScan = 100
+
+// DashboardBucket groups deliveries by what they mean to the customer
+type DashboardBucket string
+
+const (
+	BucketAwaitingShipment DashboardBucket = "AWAITING_SHIPMENT"
+	BucketInTransit        DashboardBucket = "IN_TRANSIT"
+	BucketActionRequired   DashboardBucket = "ACTION_REQUIRED"
+	BucketCompleted        DashboardBucket = "COMPLETED"
+)
+
+// customerBuckets maps the statuses a customer sees to their dashboard bucket, in display order
+// Split and merged deliveries are left out: their successors are listed instead.
+var customerBuckets = []struct {
+	bucket   DashboardBucket
+	statuses []DeliveryStatus
+}{
+	{BucketAwaitingShipment, []DeliveryStatus{StatusPendingPickup, StatusPendingPickupHandoff, StatusDisputedPickupHandoff}},
+	{BucketInTransit, []DeliveryStatus{StatusInTransit, StatusPendingTransitHandoff, StatusDisputedTransitHandoff, StatusOffNetworkTransit, StatusOutForDelivery, StatusQuarantined, StatusRecallPending}},
+	{BucketActionRequired, []DeliveryStatus{StatusPendingDeliveryConfirmation, StatusDisputedDelivery}},
+	{BucketCompleted, []DeliveryStatus{StatusConfirmedDelivery, StatusCancelled, StatusReturnInTransit, StatusReturnCompleted, StatusExported}},
+}
+
+// DeliverySummary is the compact view of a delivery shown in app lists
+type DeliverySummary struct {
+	DeliveryID      string         `json:"deliveryId"`
+	OrderID         string         `json:"orderId"`
+	Status          DeliveryStatus `json:"status"`
+	LastCity        string         `json:"lastCity"`
+	DestinationCity string         `json:"destinationCity,omitempty" metadata:",optional"`
+	ServiceTier     ServiceTier    `json:"serviceTier,omitempty" metadata:",optional"`
+	ExpectedBy      string         `json:"expectedBy,omitempty" metadata:",optional"`
+	UpdatedAt       string         `json:"updatedAt"`
+}
+
+// DashboardBucketView is one bucket of a dashboard: its size and most recent deliveries
+type DashboardBucketView struct {
+	Bucket DashboardBucket    `json:"bucket"`
+	Count  int                `json:"count"`
+	Recent []*DeliverySummary `json:"recent"`
+}
+
+// CustomerDashboard is the home screen of the tracking app
+type CustomerDashboard struct {
+	CustomerID string                 `json:"customerId"`
+	Buckets    []*DashboardBucketView `json:"buckets"`
+}
+
+// expectedArrival returns when a delivery is expected: the custodian's estimate if there is one,
+// otherwise its promised delivery time ("" when it can't be determined)
+func expectedArrival(ctx contractapi.TransactionContextInterface, delivery *Delivery) string {
+	if delivery.EstimatedArrival != "" {
+		return delivery.EstimatedArrival
+	}
+	if promisedAt, err := promisedDeliveryAt(ctx, delivery); err == nil {
+		return promisedAt.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// summarizeDelivery returns the compact view of a delivery
+func summarizeDelivery(ctx contractapi.TransactionContextInterface, delivery *Delivery) *DeliverySummary {
+	summary := &DeliverySummary{
+		DeliveryID:  delivery.DeliveryID,
+		OrderID:     delivery.OrderID,
+		Status:      delivery.DeliveryStatus,
+		LastCity:    delivery.LastLocation.City,
+		ServiceTier: delivery.ServiceTier,
+		UpdatedAt:   delivery.UpdatedAt,
+	}
+	if delivery.Destination != nil {
+		summary.DestinationCity = delivery.Destination.City
+	}
+	summary.ExpectedBy = expectedArrival(ctx, delivery)
+	return summary
+}
+
+// userDeliveryIDs returns the IDs of a user's deliveries in a status from the user~status index
+func userDeliveryIDs(ctx contractapi.TransactionContextInterface, userID string, status DeliveryStatus) ([]string, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(IndexUserStatusDelivery, []string{userID, string(status)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries by user and status: %v", err)
+	}
+	defer iterator.Close()
+
+	var deliveryIDs []string
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate user status index: %v", err)
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil || len(attrs) != 3 {
+			continue
+		}
+		deliveryIDs = append(deliveryIDs, attrs[2])
+	}
+	return deliveryIDs, nil
+}
+
+// GetCustomerDashboard returns the caller's deliveries grouped into AWAITING_SHIPMENT,
+// IN_TRANSIT, ACTION_REQUIRED and COMPLETED, with each bucket's count and its most recent
+// deliveries, in one call
+// Counts come from the index alone; only the listed deliveries are read. Recency follows the
+// delivery ID, which starts with the creation date. recentPerBucket defaults to 5 (at most 20).
+func (c *DeliveryContract) GetCustomerDashboard(
+	ctx contractapi.TransactionContextInterface,
+	recentPerBucket int,
+) (*CustomerDashboard, error) {
+	// ========== INPUT VALIDATION ==========
+	if recentPerBucket == 0 {
+		recentPerBucket = defaultDashboardRecent
+	}
+	if recentPerBucket < 0 || recentPerBucket > maxDashboardRecent {
+		return nil, &ValidationError{Field: "recentPerBucket", Message: fmt.Sprintf("must be between 1 and %d", maxDashboardRecent)}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - the tracking app is for customers
+	if err := validateRole(ctx, caller, RoleCustomer); err != nil {
+		return nil, err
+	}
+
+	dashboard := &CustomerDashboard{CustomerID: caller.ID, Buckets: []*DashboardBucketView{}}
+	for _, group := range customerBuckets {
+		var deliveryIDs []string
+		for _, status := range group.statuses {
+			ids, err := userDeliveryIDs(ctx, caller.ID, status)
+			if err != nil {
+				return nil, err
+			}
+			deliveryIDs = append(deliveryIDs, ids...)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(deliveryIDs)))
+
+		view := &DashboardBucketView{Bucket: group.bucket, Count: len(deliveryIDs), Recent: []*DeliverySummary{}}
+		for _, deliveryID := range deliveryIDs {
+			if len(view.Recent) >= recentPerBucket {
+				break
+			}
+			delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+			if err != nil || delivery.CustomerID != caller.ID {
+				continue
+			}
+			view.Recent = append(view.Recent, summarizeDelivery(ctx, delivery))
+		}
+		dashboard.Buckets = append(dashboard.Buckets, view)
+	}
+
+	return dashboard, nil
+}
+
+// Seller dashboard sections
+const (
+	SectionAwaitingPickupToday = "awaitingPickupToday"
+	SectionOpenDisputes        = "openDisputes"
+	SectionRecentDiscrepancies = "recentDiscrepancies"
+	SectionOverdue             = "overdue"
+)
+
+// SellerDashboard is the home screen of the seller portal
+type SellerDashboard struct {
+	SellerID            string               `json:"sellerId"`
+	AwaitingPickupToday []*DeliverySummary   `json:"awaitingPickupToday"`
+	OpenDisputes        []*DeliverySummary   `json:"openDisputes"`
+	RecentDiscrepancies []*WeightDiscrepancy `json:"recentDiscrepancies"`
+	Overdue             []*DeliverySummary   `json:"overdue"`
+	// Sections that were cut at their limit or scan budget and may have more entries
+	Truncated []string `json:"truncated"`
+}
+
+// scanUserDeliveries lists a user's deliveries in the given statuses that match, oldest first
+// At most maxDashboardScan index entries are looked at; truncated reports that the scan or the
+// limit stopped before every entry was seen.
+func (c *DeliveryContract) scanUserDeliveries(
+	ctx contractapi.TransactionContextInterface,
+	userID string,
+	statuses []DeliveryStatus,
+	limit int,
+	match func(*Delivery) bool,
+) ([]*DeliverySummary, bool, error) {
+	stub := ctx.GetStub()
+	summaries := []*DeliverySummary{}
+	budget := maxDashboardScan
+	for _, status := range statuses {
+		if budget == 0 {
+			return summaries, true, nil
+		}
+		iterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(IndexUserStatusDelivery, []string{userID, string(status)}, int32(budget), "")
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get deliveries by user and status: %v", err)
+		}
+		for iterator.HasNext() {
+			response, err := iterator.Next()
+			if err != nil {
+				iterator.Close()
+				return nil, false, fmt.Errorf("failed to iterate user status index: %v", err)
+			}
+			if len(summaries) >= limit {
+				iterator.Close()
+				return summaries, true, nil
+			}
+			budget--
+			_, attrs, err := stub.SplitCompositeKey(response.Key)
+			if err != nil || len(attrs) != 3 {
+				continue
+			}
+			delivery, err := c.readDeliveryInternal(ctx, attrs[2])
+			if err != nil || !match(delivery) {
+				continue
+			}
+			summaries = append(summaries, summarizeDelivery(ctx, delivery))
+		}
+		iterator.Close()
+		if budget == 0 && metadata.Bookmark != "" {
+			return summaries, true, nil
+		}
+	}
+	return summaries, false, nil
+}
+
+// recentWeightDiscrepancies returns the latest weight discrepancies recorded against a user
+func recentWeightDiscrepancies(ctx contractapi.TransactionContextInterface, userID string, limit int) ([]*WeightDiscrepancy, bool, error) {
+	iterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(RecordWeightDiscrepancy, []string{userID}, maxDashboardScan, "")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get weight discrepancies: %v", err)
+	}
+	defer iterator.Close()
+
+	discrepancies := []*WeightDiscrepancy{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to iterate weight discrepancies: %v", err)
+		}
+		var discrepancy WeightDiscrepancy
+		if err := json.Unmarshal(response.Value, &discrepancy); err != nil {
+			continue
+		}
+		discrepancies = append(discrepancies, &discrepancy)
+	}
+	sort.Slice(discrepancies, func(i, j int) bool {
+		return discrepancies[i].RecordedAt > discrepancies[j].RecordedAt
+	})
+
+	truncated := metadata.Bookmark != "" && int(metadata.FetchedRecordsCount) == maxDashboardScan
+	if len(discrepancies) > limit {
+		discrepancies = discrepancies[:limit]
+		truncated = true
+	}
+	return discrepancies, truncated, nil
+}
+
+// GetSellerDashboard returns the seller portal's home screen in one call: deliveries to be
+// picked up by the end of today (UTC), deliveries in an open handoff dispute, the latest weight
+// discrepancies recorded against the seller, and shipments past their promised delivery time
+// Every section reads at most 100 index entries and lists at most limitPerSection (default 5,
+// at most 20) entries; cut sections are named in Truncated.
+func (c *DeliveryContract) GetSellerDashboard(
+	ctx contractapi.TransactionContextInterface,
+	limitPerSection int,
+) (*SellerDashboard, error) {
+	// ========== INPUT VALIDATION ==========
+	if limitPerSection == 0 {
+		limitPerSection = defaultDashboardRecent
+	}
+	if limitPerSection < 0 || limitPerSection > maxDashboardRecent {
+		return nil, &ValidationError{Field: "limitPerSection", Message: fmt.Sprintf("must be between 1 and %d", maxDashboardRecent)}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - the seller portal is for sellers
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	endOfDay := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	ownDelivery := func(delivery *Delivery) bool {
+		return delivery.SellerID == caller.ID
+	}
+
+	dashboard := &SellerDashboard{SellerID: caller.ID, Truncated: []string{}}
+	var truncated bool
+
+	dashboard.AwaitingPickupToday, truncated, err = c.scanUserDeliveries(ctx, caller.ID,
+		[]DeliveryStatus{StatusPendingPickup, StatusPendingPickupHandoff}, limitPerSection,
+		func(delivery *Delivery) bool {
+			if !ownDelivery(delivery) {
+				return false
+			}
+			pickupBy, err := expectedMilestoneAt(ctx, delivery, ProgressPickedUp)
+			return err == nil && pickupBy.Before(endOfDay)
+		})
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		dashboard.Truncated = append(dashboard.Truncated, SectionAwaitingPickupToday)
+	}
+
+	dashboard.OpenDisputes, truncated, err = c.scanUserDeliveries(ctx, caller.ID,
+		[]DeliveryStatus{StatusDisputedPickupHandoff, StatusDisputedTransitHandoff, StatusDisputedDelivery}, limitPerSection,
+		ownDelivery)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		dashboard.Truncated = append(dashboard.Truncated, SectionOpenDisputes)
+	}
+
+	dashboard.RecentDiscrepancies, truncated, err = recentWeightDiscrepancies(ctx, caller.ID, limitPerSection)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		dashboard.Truncated = append(dashboard.Truncated, SectionRecentDiscrepancies)
+	}
+
+	var undelivered []DeliveryStatus
+	for _, group := range customerBuckets {
+		if group.bucket == BucketAwaitingShipment || group.bucket == BucketInTransit {
+			undelivered = append(undelivered, group.statuses...)
+		}
+	}
+	dashboard.Overdue, truncated, err = c.scanUserDeliveries(ctx, caller.ID, undelivered, limitPerSection,
+		func(delivery *Delivery) bool {
+			if !ownDelivery(delivery) {
+				return false
+			}
+			promisedAt, err := promisedDeliveryAt(ctx, delivery)
+			return err == nil && promisedAt.Before(now)
+		})
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		dashboard.Truncated = append(dashboard.Truncated, SectionOverdue)
+	}
+
+	return dashboard, nil
+}
+
+// maxRunSheetEntries bounds each list of a courier run sheet
+const maxRunSheetEntries = 200
+
+// RunSheetEntry is a delivery on a courier's run sheet, with what the driver app needs at the door
+type RunSheetEntry struct {
+	DeliveryID        string         `json:"deliveryId"`
+	Status            DeliveryStatus `json:"status"`
+	City              string         `json:"city"`
+	State             string         `json:"state"`
+	ExpectedBy        string         `json:"expectedBy,omitempty" metadata:",optional"`
+	ServiceTier       ServiceTier    `json:"serviceTier,omitempty" metadata:",optional"`
+	AgeRestricted     bool           `json:"ageRestricted,omitempty" metadata:",optional"`
+	CODAmount         *Money         `json:"codAmount,omitempty" metadata:",optional"`
+	LabelScanRequired bool           `json:"labelScanRequired,omitempty" metadata:",optional"`
+	// Set on pending handoffs: who offers the package and whether a code must be given
+	FromUserID   string   `json:"fromUserId,omitempty" metadata:",optional"`
+	FromRole     UserRole `json:"fromRole,omitempty" metadata:",optional"`
+	OfferedAt    string   `json:"offeredAt,omitempty" metadata:",optional"`
+	CodeRequired bool     `json:"codeRequired,omitempty" metadata:",optional"`
+}
+
+// CourierRunSheet is a courier's work list for the driver app
+type CourierRunSheet struct {
+	CourierID string           `json:"courierId"`
+	Custody   []*RunSheetEntry `json:"custody"`
+	// Handoffs offered to the courier that wait for their confirmation
+	PendingHandoffs []*RunSheetEntry `json:"pendingHandoffs"`
+	Truncated       bool             `json:"truncated"`
+}
+
+// runSheetEntry returns the run sheet view of a delivery
+// Custody entries point at the next planned stop (the destination once the plan is done or
+// when there is none); pending handoffs at where the package currently is.
+func runSheetEntry(ctx contractapi.TransactionContextInterface, delivery *Delivery) *RunSheetEntry {
+	entry := &RunSheetEntry{
+		DeliveryID:        delivery.DeliveryID,
+		Status:            delivery.DeliveryStatus,
+		City:              delivery.LastLocation.City,
+		State:             delivery.LastLocation.State,
+		ServiceTier:       delivery.ServiceTier,
+		AgeRestricted:     delivery.AgeRestricted,
+		CODAmount:         delivery.CODAmount,
+		LabelScanRequired: delivery.LabelHash != "",
+	}
+	entry.ExpectedBy = expectedArrival(ctx, delivery)
+	return entry
+}
+
+// nextStop returns the next place a delivery is headed: the first planned stop after its last
+// reported city, or its destination
+func nextStop(delivery *Delivery) *Location {
+	if plan := delivery.RoutePlan; plan != nil {
+		next := 0
+		for i, stop := range plan.Stops {
+			if stop.City == delivery.LastLocation.City && stop.State == delivery.LastLocation.State && stop.Country == delivery.LastLocation.Country {
+				next = i + 1
+			}
+		}
+		if next < len(plan.Stops) {
+			return &plan.Stops[next]
+		}
+	}
+	return delivery.Destination
+}
+
+// indexedDeliveries reads the deliveries listed under a value of a delivery index
+// At most limit deliveries are read; more reports that the index had further entries.
+func (c *DeliveryContract) indexedDeliveries(ctx contractapi.TransactionContextInterface, indexName string, value string, limit int) ([]*Delivery, bool, error) {
+	stub := ctx.GetStub()
+	iterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(indexName, []string{value}, int32(limit), "")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get state by composite key %s: %v", indexName, err)
+	}
+	defer iterator.Close()
+
+	deliveries := []*Delivery{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to iterate composite key results: %v", err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(response.Key)
+		if err != nil || len(attrs) != 2 {
+			continue
+		}
+		delivery, err := c.readDeliveryInternal(ctx, attrs[1])
+		if err != nil {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	more := metadata.Bookmark != "" && int(metadata.FetchedRecordsCount) == limit
+	return deliveries, more, nil
+}
+
+// GetCourierRunSheet returns the caller's run sheet in one call: the deliveries in their
+// custody, ordered by expected delivery time, and the handoffs waiting for their confirmation
+// Custody entries point at the next stop of the route plan (or the destination); deliveries
+// without an expected time go last. Each list holds at most 200 deliveries. Handoffs offered
+// before the recipient index existed show up once their delivery is next updated.
+func (c *DeliveryContract) GetCourierRunSheet(
+	ctx contractapi.TransactionContextInterface,
+) (*CourierRunSheet, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - the driver app is for couriers
+	if err := validateRole(ctx, caller, RoleDeliveryPerson); err != nil {
+		return nil, err
+	}
+
+	sheet := &CourierRunSheet{CourierID: caller.ID, Custody: []*RunSheetEntry{}, PendingHandoffs: []*RunSheetEntry{}}
+
+	held, more, err := c.indexedDeliveries(ctx, IndexCustodianDelivery, caller.ID, maxRunSheetEntries)
+	if err != nil {
+		return nil, err
+	}
+	sheet.Truncated = more
+	for _, delivery := range held {
+		if delivery.CurrentCustodianID != caller.ID || retainableStatuses[delivery.DeliveryStatus] {
+			continue
+		}
+		entry := runSheetEntry(ctx, delivery)
+		if stop := nextStop(delivery); stop != nil {
+			entry.City, entry.State = stop.City, stop.State
+		}
+		sheet.Custody = append(sheet.Custody, entry)
+	}
+	sort.SliceStable(sheet.Custody, func(i, j int) bool {
+		a, b := sheet.Custody[i].ExpectedBy, sheet.Custody[j].ExpectedBy
+		if a == "" || b == "" {
+			return a != ""
+		}
+		return a < b
+	})
+
+	offered, more, err := c.indexedDeliveries(ctx, IndexHandoffRecipientDelivery, caller.ID, maxRunSheetEntries)
+	if err != nil {
+		return nil, err
+	}
+	sheet.Truncated = sheet.Truncated || more
+	for _, delivery := range offered {
+		handoff := delivery.PendingHandoff
+		if handoff == nil || handoff.ToUserID != caller.ID {
+			continue
+		}
+		entry := runSheetEntry(ctx, delivery)
+		entry.FromUserID = handoff.FromUserID
+		entry.FromRole = handoff.FromRole
+		entry.OfferedAt = handoff.InitiatedAt
+		entry.CodeRequired = handoff.CodeHash != ""
+		sheet.PendingHandoffs = append(sheet.PendingHandoffs, entry)
+	}
+
+	return sheet, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EventCustodyActionDelegated is emitted when a custodian lets a helper act on a delivery
+const EventCustodyActionDelegated = "CustodyActionDelegated"
+
+// maxDelegationHours bounds how long a custodian can delegate for (one shift)
+const maxDelegationHours = 24
+
+// CustodyDelegation lets a helper update the location of and initiate handoffs for a delivery
+// on behalf of its custodian. It lapses when it expires or custody moves to someone else.
+type CustodyDelegation struct {
+	HelperID    string `json:"helperId"`
+	DelegatedBy string `json:"delegatedBy"`
+	GrantedAt   string `json:"grantedAt"`
+	ExpiresAt   string `json:"expiresAt"`
+}
+
+// delegatedCustodian returns the custodian identity a helper acts as, or nil if the caller holds
+// no active delegation on the delivery
+// The returned identity keeps the helper's MSP and tenant, with DelegateID set to the helper.
+func delegatedCustodian(delivery *Delivery, caller *CallerIdentity, now time.Time) *CallerIdentity {
+	delegation := delivery.Delegation
+	if delegation == nil || delegation.HelperID != caller.ID || delegation.DelegatedBy != delivery.CurrentCustodianID {
+		return nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, delegation.ExpiresAt)
+	if err != nil || !now.Before(expiresAt) {
+		return nil
+	}
+	custodian := *caller
+	custodian.ID = delivery.CurrentCustodianID
+	custodian.Role = delivery.CurrentCustodianRole
+	custodian.DelegateID = caller.ID
+	return &custodian
+}
+
+// DelegateCustodyAction lets another registered courier or warehouse identity act for the custodian
+// Only the current DELIVERY_PERSON or WAREHOUSE custodian can delegate, for up to 24 hours.
+// The helper may call UpdateLocation and InitiateHandoff; both record the helper as the actor.
+// Delegating again replaces the previous delegation.
+func (c *DeliveryContract) DelegateCustodyAction(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	helperID string,
+	expiry string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	if err := validateUserID(helperID, "helperID"); err != nil {
+		return err
+	}
+	expiresAt, err := time.Parse(time.RFC3339, expiry)
+	if err != nil {
+		return &ValidationError{Field: "expiry", Message: "must be an RFC 3339 timestamp"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - couriers and warehouses hold packages between handoffs
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.CurrentCustodianID != caller.ID || delivery.CurrentCustodianRole != caller.Role {
+		return fmt.Errorf("only the current custodian can delegate custody actions")
+	}
+	if helperID == caller.ID {
+		return fmt.Errorf("cannot delegate to yourself")
+	}
+
+	// Helpers must be known logistics identities, not arbitrary IDs
+	helper, err := getUserProfile(ctx, helperID)
+	if err != nil {
+		return err
+	}
+	if helper == nil || (helper.Role != RoleDeliveryPerson && helper.Role != RoleWarehouse) {
+		return fmt.Errorf("helper %s is not a registered DELIVERY_PERSON or WAREHOUSE identity", helperID)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if !expiresAt.After(txTime) || expiresAt.After(txTime.Add(maxDelegationHours*time.Hour)) {
+		return &ValidationError{Field: "expiry", Message: fmt.Sprintf("must be in the next %d hours", maxDelegationHours)}
+	}
+	currentTime := txTime.Format(time.RFC3339)
+
+	delivery.Delegation = &CustodyDelegation{
+		HelperID:    helperID,
+		DelegatedBy: caller.ID,
+		GrantedAt:   currentTime,
+		ExpiresAt:   expiresAt.UTC().Format(time.RFC3339),
+	}
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventCustodyActionDelegated, map[string]string{
+		"deliveryId":  deliveryID,
+		"helperId":    helperID,
+		"delegatedBy": caller.ID,
+		"expiresAt":   delivery.Delegation.ExpiresAt,
+		"timestamp":   currentTime,
+	})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -37,7 +38,17 @@ const (
 	RoleCustomer       UserRole = "CUSTOMER"
 	RoleSeller         UserRole = "SELLER"
 	RoleDeliveryPerson UserRole = "DELIVERY_PERSON"
+	RoleWarehouse      UserRole = "WAREHOUSE"
+	RoleSensor         UserRole = "SENSOR"
 	RoleAdmin          UserRole = "ADMIN"
+	// RoleSupport is platform support staff: reads any delivery and disputes on behalf of
+	// customers, but has none of ADMIN's overrides or policy powers
+	RoleSupport UserRole = "SUPPORT"
+	// RoleAuditor is an external compliance reviewer: reads every delivery, writes nothing
+	RoleAuditor UserRole = "AUDITOR"
+	// RoleCustomsBroker clears international deliveries it is named broker of: attaches customs
+	// documents, reports clearance status and places holds
+	RoleCustomsBroker UserRole = "CUSTOMS_BROKER"
 )
 
 // DeliveryStatus represents the current status of a delivery
@@ -54,31 +65,113 @@ const (
 	StatusConfirmedDelivery           DeliveryStatus = "CONFIRMED_DELIVERY"
 	StatusDisputedDelivery            DeliveryStatus = "DISPUTED_DELIVERY"
 	StatusCancelled                   DeliveryStatus = "CANCELLED"
+	StatusRecallPending               DeliveryStatus = "RECALL_PENDING"
+	StatusReturnInTransit             DeliveryStatus = "RETURN_IN_TRANSIT"
+	StatusSplit                       DeliveryStatus = "SPLIT"
+	StatusMerged                      DeliveryStatus = "MERGED"
+	StatusExported                    DeliveryStatus = "EXPORTED"
+	StatusRedacted                    DeliveryStatus = "REDACTED"
+	StatusOffNetworkTransit           DeliveryStatus = "OFF_NETWORK_TRANSIT"
+	StatusOutForDelivery              DeliveryStatus = "OUT_FOR_DELIVERY"
+	StatusReturnCompleted             DeliveryStatus = "RETURN_COMPLETED"
+	StatusQuarantined                 DeliveryStatus = "QUARANTINED"
 )
 
 // PendingHandoff tracks a pending custody transfer
 type PendingHandoff struct {
-	FromUserID  string   `json:"fromUserId"`
-	FromRole    UserRole `json:"fromRole"`
-	ToUserID    string   `json:"toUserId"`
-	ToRole      UserRole `json:"toRole"`
-	InitiatedAt string   `json:"initiatedAt"`
+	FromUserID     string      `json:"fromUserId"`
+	FromRole       UserRole    `json:"fromRole"`
+	ToUserID       string      `json:"toUserId"`
+	ToRole         UserRole    `json:"toRole"`
+	InitiatedAt    string      `json:"initiatedAt"`
+	InitiatedBy    string      `json:"initiatedBy,omitempty" metadata:",optional"`
+	Type           HandoffType `json:"type,omitempty" metadata:",optional"`
+	FromCarrierMSP string      `json:"fromCarrierMsp,omitempty" metadata:",optional"`
+	ToCarrierMSP   string      `json:"toCarrierMsp,omitempty" metadata:",optional"`
+	// Condition evidence from the initiator and, once confirmed, the recipient
+	Evidence []HandoffEvidence `json:"evidence,omitempty" metadata:",optional"`
+	// Salted hash of the optional single-use confirmation code
+	CodeHash      string `json:"codeHash,omitempty" metadata:",optional"`
+	CodeSalt      string `json:"codeSalt,omitempty" metadata:",optional"`
+	CodeExpiresAt string `json:"codeExpiresAt,omitempty" metadata:",optional"`
+	// Correlation ID of the request that initiated the handoff
+	CorrelationID string `json:"correlationId,omitempty" metadata:",optional"`
+}
+
+// CancellationInfo records why and by whom a delivery was cancelled
+type CancellationInfo struct {
+	ReasonCode  string `json:"reasonCode"`
+	Reason      string `json:"reason,omitempty" metadata:",optional"`
+	CancelledBy string `json:"cancelledBy"`
+	CancelledAt string `json:"cancelledAt"`
 }
 
 // Delivery represents a package delivery record on the blockchain
 type Delivery struct {
-	DeliveryID           string            `json:"deliveryId"`
-	OrderID              string            `json:"orderId"`
-	SellerID             string            `json:"sellerId"`
-	CustomerID           string            `json:"customerId"`
-	PackageWeight        float64           `json:"packageWeight"`
-	PackageDimensions    PackageDimensions `json:"packageDimensions"`
-	DeliveryStatus       DeliveryStatus    `json:"deliveryStatus"`
-	LastLocation         Location          `json:"lastLocation"`
-	CurrentCustodianID   string            `json:"currentCustodianId"`
-	CurrentCustodianRole UserRole          `json:"currentCustodianRole"`
-	PendingHandoff       *PendingHandoff   `json:"pendingHandoff,omitempty" metadata:",optional"`
-	UpdatedAt            string            `json:"updatedAt"`
+	TenantID               string                   `json:"tenantId,omitempty" metadata:",optional"`
+	DeliveryID             string                   `json:"deliveryId"`
+	OrderID                string                   `json:"orderId"`
+	SellerID               string                   `json:"sellerId"`
+	CustomerID             string                   `json:"customerId"`
+	PackageWeight          float64                  `json:"packageWeight"`
+	DeclaredWeight         float64                  `json:"declaredWeight,omitempty" metadata:",optional"`
+	PackageDimensions      PackageDimensions        `json:"packageDimensions"`
+	DeliveryStatus         DeliveryStatus           `json:"deliveryStatus"`
+	LastLocation           Location                 `json:"lastLocation"`
+	Destination            *Location                `json:"destination,omitempty" metadata:",optional"`
+	CurrentCustodianID     string                   `json:"currentCustodianId"`
+	CurrentCustodianRole   UserRole                 `json:"currentCustodianRole"`
+	CustodianMSP           string                   `json:"custodianMsp,omitempty" metadata:",optional"`
+	CarrierOfRecord        string                   `json:"carrierOfRecord,omitempty" metadata:",optional"`
+	PendingHandoff         *PendingHandoff          `json:"pendingHandoff,omitempty" metadata:",optional"`
+	Delegation             *CustodyDelegation       `json:"delegation,omitempty" metadata:",optional"`
+	Recall                 *RecallInfo              `json:"recall,omitempty" metadata:",optional"`
+	Quarantine             *QuarantineInfo          `json:"quarantine,omitempty" metadata:",optional"`
+	Customs                *CustomsInfo             `json:"customs,omitempty" metadata:",optional"`
+	Legs                   []InternationalLeg       `json:"legs,omitempty" metadata:",optional"`
+	RoutePlan              *RoutePlan               `json:"routePlan,omitempty" metadata:",optional"`
+	ReturnReceipt          *ReturnReceipt           `json:"returnReceipt,omitempty" metadata:",optional"`
+	PickupEvidence         []HandoffEvidence        `json:"pickupEvidence,omitempty" metadata:",optional"`
+	DeliveryEvidence       []HandoffEvidence        `json:"deliveryEvidence,omitempty" metadata:",optional"`
+	DeclaredValue          *Money                   `json:"declaredValue,omitempty" metadata:",optional"`
+	CODAmount              *Money                   `json:"codAmount,omitempty" metadata:",optional"`
+	CODSettlement          *CODSettlement           `json:"codSettlement,omitempty" metadata:",optional"`
+	AgreedFee              *AgreedFee               `json:"agreedFee,omitempty" metadata:",optional"`
+	Insurance              *DeliveryInsurance       `json:"insurance,omitempty" metadata:",optional"`
+	ContentsManifestHash   string                   `json:"contentsManifestHash,omitempty" metadata:",optional"`
+	SerializationHash      string                   `json:"serializationHash,omitempty" metadata:",optional"`
+	LabelHash              string                   `json:"labelHash,omitempty" metadata:",optional"`
+	ProductExpiry          string                   `json:"productExpiry,omitempty" metadata:",optional"` // YYYY-MM-DD
+	NearExpiryWarnedAt     string                   `json:"nearExpiryWarnedAt,omitempty" metadata:",optional"`
+	StatusSince            string                   `json:"statusSince,omitempty" metadata:",optional"`
+	StatusDurations        map[DeliveryStatus]int64 `json:"statusDurations,omitempty" metadata:",optional"` // seconds
+	StalledAt              string                   `json:"stalledAt,omitempty" metadata:",optional"`
+	JurisdictionCheck      *JurisdictionCheck       `json:"jurisdictionCheck,omitempty" metadata:",optional"`
+	ParentDeliveryID       string                   `json:"parentDeliveryId,omitempty" metadata:",optional"`
+	ChildDeliveryIDs       []string                 `json:"childDeliveryIds,omitempty" metadata:",optional"`
+	MergedIntoID           string                   `json:"mergedIntoId,omitempty" metadata:",optional"`
+	MergedFromIDs          []string                 `json:"mergedFromIds,omitempty" metadata:",optional"`
+	LatestException        *ExceptionSummary        `json:"latestException,omitempty" metadata:",optional"`
+	LastDispute            *DisputeInfo             `json:"lastDispute,omitempty" metadata:",optional"`
+	Cancellation           *CancellationInfo        `json:"cancellation,omitempty" metadata:",optional"`
+	Reassignment           *CustodyReassignment     `json:"reassignment,omitempty" metadata:",optional"`
+	AccessGrants           []AccessGrant            `json:"accessGrants,omitempty" metadata:",optional"`
+	ExternalTracking       []ExternalTracking       `json:"externalTracking,omitempty" metadata:",optional"`
+	MilestoneActuals       []MilestoneActual        `json:"milestoneActuals,omitempty" metadata:",optional"`
+	DeliveryAttempts       int                      `json:"deliveryAttempts,omitempty" metadata:",optional"`
+	AutoConfirmed          bool                     `json:"autoConfirmed,omitempty" metadata:",optional"`
+	RequiredCertifications []string                 `json:"requiredCertifications,omitempty" metadata:",optional"`
+	AgeRestricted          bool                     `json:"ageRestricted,omitempty" metadata:",optional"`
+	GeofenceFlagged        bool                     `json:"geofenceFlagged,omitempty" metadata:",optional"`
+	ServiceTier            ServiceTier              `json:"serviceTier,omitempty" metadata:",optional"`
+	EstimatedArrival       string                   `json:"estimatedArrival,omitempty" metadata:",optional"`
+	Notes                  string                   `json:"notes,omitempty" metadata:",optional"`
+	TemplateID             string                   `json:"templateId,omitempty" metadata:",optional"`
+	Provenance             *ChannelProvenance       `json:"provenance,omitempty" metadata:",optional"`
+	Tombstone              *TombstoneInfo           `json:"tombstone,omitempty" metadata:",optional"`
+	CorrelationID          string                   `json:"correlationId,omitempty" metadata:",optional"`
+	StateHash              string                   `json:"stateHash,omitempty" metadata:",optional"`
+	UpdatedAt              string                   `json:"updatedAt"`
 }
 
 // Event names for chaincode events
@@ -88,6 +181,8 @@ const (
 	EventHandoffInitiated      = "HandoffInitiated"
 	EventHandoffConfirmed      = "HandoffConfirmed"
 	EventHandoffDisputed       = "HandoffDisputed"
+	EventLocationUpdated       = "DeliveryLocationUpdated"
+	EventPrivateDetailsUpdated = "DeliveryPrivateDetailsUpdated"
 )
 
 // DeliveryEvent is emitted when delivery status changes
@@ -109,8 +204,11 @@ type DeliveryPrivateDetails struct {
 	DeliveryID         string `json:"deliveryId"`
 	RecipientName      string `json:"recipientName"`
 	DeliveryStreet     string `json:"deliveryStreet"`
-	DeliveryApartment  string `json:"deliveryApartment,omitempty"`
+	DeliveryApartment  string `json:"deliveryApartment,omitempty" metadata:",optional"`
 	DeliveryPostalCode string `json:"deliveryPostalCode"`
+	DestinationGeohash string `json:"destinationGeohash,omitempty" metadata:",optional"`
+	// Salted hash of the recipient's name or document, checked by VerifyRecipient
+	RecipientIdentityHash string `json:"recipientIdentityHash,omitempty" metadata:",optional"`
 }
 
 // Private Data Collection names
@@ -120,10 +218,15 @@ const (
 
 // CallerIdentity holds the extracted identity from the X.509 certificate
 type CallerIdentity struct {
-	ID          string   // User ID extracted from CN
-	Role        UserRole // Role extracted from OU or attribute
-	MSP         string   // MSP ID (organization)
-	Affiliation string   // Full affiliation path (e.g., "sellers")
+	ID              string   // User ID extracted from CN
+	Role            UserRole // Role the caller acts as (the certificate role until validateRole resolves it)
+	CertificateRole UserRole // Role extracted from OU or attribute
+	MSP             string   // MSP ID (organization)
+	Affiliation     string   // Full affiliation path (e.g., "sellers")
+	TenantID        string   // Marketplace from the "tenant" attribute (empty = default tenant)
+	Region          string   // Admin region from the "region" attribute (empty = unscoped)
+	Department      string   // Department from the "department" attribute (optional)
+	DelegateID      string   // Helper acting under a custody delegation (see delegatedCustodian)
 }
 
 // getCallerIdentity extracts the caller's identity from the X.509 certificate
@@ -151,41 +254,17 @@ func getCallerIdentity(ctx contractapi.TransactionContextInterface) (*CallerIden
 	}
 
 	// Extract role from Organizational Unit (OU) or attribute
-	var role UserRole
-	if len(cert.Subject.OrganizationalUnit) > 0 {
-		ouValue := strings.ToUpper(cert.Subject.OrganizationalUnit[0])
-		switch ouValue {
-		case "CUSTOMER":
-			role = RoleCustomer
-		case "SELLER":
-			role = RoleSeller
-		case "DELIVERY_PERSON", "DELIVERYPERSON", "DELIVERY":
-			role = RoleDeliveryPerson
-		case "ADMIN":
-			role = RoleAdmin
-		default:
-			// OU doesn't match a role, try attribute
-			role = ""
-		}
+	role, err := callerRole(clientIdentity)
+	if err != nil {
+		return nil, err
 	}
-
-	// If OU didn't provide a valid role, check the 'role' attribute
-	if role == "" {
-		roleAttr, found, err := clientIdentity.GetAttributeValue("role")
-		if err != nil || !found {
-			return nil, fmt.Errorf("cannot determine role: no valid OU and no role attribute found")
+	if !isBuiltinRole(role) {
+		model, err := getPermissionModel(ctx)
+		if err != nil {
+			return nil, err
 		}
-		switch strings.ToUpper(roleAttr) {
-		case "CUSTOMER":
-			role = RoleCustomer
-		case "SELLER":
-			role = RoleSeller
-		case "DELIVERY_PERSON", "DELIVERYPERSON", "DELIVERY":
-			role = RoleDeliveryPerson
-		case "ADMIN":
-			role = RoleAdmin
-		default:
-			return nil, fmt.Errorf("invalid role attribute: %s", roleAttr)
+		if _, ok := model[role]; !ok {
+			return nil, fmt.Errorf("invalid role attribute: %s", role)
 		}
 	}
 
@@ -195,22 +274,105 @@ func getCallerIdentity(ctx contractapi.TransactionContextInterface) (*CallerIden
 		affiliation = cert.Subject.Organization[0]
 	}
 
+	// Tenant (marketplace) scoping; the ledger stub is namespaced by the same attribute
+	tenantID, err := callerTenantID(clientIdentity)
+	if err != nil {
+		return nil, err
+	}
+
+	// The organization registry decides which roles each MSP may issue
+	if err := validateOrgRole(ctx, mspID, role); err != nil {
+		return nil, err
+	}
+
+	// Optional scoping attributes of regional and departmental staff
+	region, department, err := callerScope(clientIdentity)
+	if err != nil {
+		return nil, err
+	}
+
 	return &CallerIdentity{
-		ID:          userID,
-		Role:        role,
-		MSP:         mspID,
-		Affiliation: affiliation,
+		ID:              userID,
+		Role:            role,
+		CertificateRole: role,
+		MSP:             mspID,
+		Affiliation:     affiliation,
+		TenantID:        tenantID,
+		Region:          region,
+		Department:      department,
 	}, nil
 }
 
+// parseRole maps an OU or role attribute value to a role ("" if it names none)
+func parseRole(value string) UserRole {
+	switch strings.ToUpper(value) {
+	case "CUSTOMER":
+		return RoleCustomer
+	case "SELLER":
+		return RoleSeller
+	case "DELIVERY_PERSON", "DELIVERYPERSON", "DELIVERY":
+		return RoleDeliveryPerson
+	case "WAREHOUSE":
+		return RoleWarehouse
+	case "SENSOR", "GATEWAY":
+		return RoleSensor
+	case "ADMIN":
+		return RoleAdmin
+	case "SUPPORT":
+		return RoleSupport
+	case "AUDITOR":
+		return RoleAuditor
+	case "CUSTOMS_BROKER", "CUSTOMSBROKER", "BROKER":
+		return RoleCustomsBroker
+	}
+	return ""
+}
+
+// callerRole reads the role from the certificate's first OU, falling back to the 'role' attribute
+func callerRole(clientIdentity cid.ClientIdentity) (UserRole, error) {
+	cert, err := clientIdentity.GetX509Certificate()
+	if err != nil {
+		return "", fmt.Errorf("failed to get X.509 certificate: %v", err)
+	}
+	if len(cert.Subject.OrganizationalUnit) > 0 {
+		if role := parseRole(cert.Subject.OrganizationalUnit[0]); role != "" {
+			return role, nil
+		}
+	}
+
+	// If OU didn't provide a valid role, check the 'role' attribute
+	roleAttr, found, err := clientIdentity.GetAttributeValue("role")
+	if err != nil || !found {
+		return "", fmt.Errorf("cannot determine role: no valid OU and no role attribute found")
+	}
+	role := parseRole(roleAttr)
+	if role == "" {
+		// Possibly a custom role, checked against the permission model by getCallerIdentity
+		role = UserRole(strings.ToUpper(roleAttr))
+		if !customRolePattern.MatchString(string(role)) {
+			return "", fmt.Errorf("invalid role attribute: %s", roleAttr)
+		}
+	}
+	return role, nil
+}
+
 // getTxTimestamp returns the transaction timestamp from the blockchain
 // This is the authoritative timestamp set by the orderer, not manipulable by clients
 func getTxTimestamp(ctx contractapi.TransactionContextInterface) (string, error) {
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	return txTime.Format(time.RFC3339), nil
+}
+
+// getTxTime returns the transaction timestamp as a time.Time (UTC) for time arithmetic
+func getTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
 	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
-		return "", fmt.Errorf("failed to get transaction timestamp: %v", err)
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
 	}
-	return time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)).UTC().Format(time.RFC3339), nil
+	return time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)).UTC(), nil
 }
 
 // ============================================================================
@@ -219,14 +381,50 @@ func getTxTimestamp(ctx contractapi.TransactionContextInterface) (string, error)
 
 // ValidationError represents a validation failure
 type ValidationError struct {
-	Field   string
-	Message string
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 func (e *ValidationError) Error() string {
 	return fmt.Sprintf("validation failed for %s: %s", e.Field, e.Message)
 }
 
+// ErrCodeValidationFailed prefixes errors that aggregate several field failures
+const ErrCodeValidationFailed = "VALIDATION_FAILED"
+
+// ValidationErrors collects every field failure of a transaction's input
+// The message is the VALIDATION_FAILED prefix followed by a JSON array of {field, message},
+// so clients can highlight every invalid field after one round trip.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	fieldsJSON, err := json.Marshal([]*ValidationError(e))
+	if err != nil {
+		return fmt.Sprintf("%s: %d invalid fields", ErrCodeValidationFailed, len(e))
+	}
+	return fmt.Sprintf("%s: %s", ErrCodeValidationFailed, fieldsJSON)
+}
+
+// collect adds the result of a validator to the list (nil results are ignored)
+func (e *ValidationErrors) collect(err error) {
+	if err == nil {
+		return
+	}
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		validationErr = &ValidationError{Field: "input", Message: err.Error()}
+	}
+	*e = append(*e, validationErr)
+}
+
+// err returns the collected failures as an error, or nil when there are none
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
 // validateDeliveryID checks if a delivery ID has the correct format (DEL-YYYYMMDD-XXXXXXXX)
 func validateDeliveryID(deliveryID string) error {
 	if len(deliveryID) == 0 {
@@ -245,26 +443,26 @@ func validateDeliveryID(deliveryID string) error {
 	return nil
 }
 
+// derivedDeliveryID builds a delivery ID from the transaction and order
+// (DEL-YYYYMMDD-<first 8 hex digits of sha256(txID:orderID)>); every endorser derives the same
+// ID, and no two transactions can claim it.
+func derivedDeliveryID(ctx contractapi.TransactionContextInterface, orderID string) (string, error) {
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(ctx.GetStub().GetTxID() + ":" + orderID))
+	return fmt.Sprintf("DEL-%s-%X", txTime.Format("20060102"), digest[:4]), nil
+}
+
 // validateOrderID checks if an order ID is valid
 func validateOrderID(orderID string) error {
-	if len(orderID) == 0 {
-		return &ValidationError{Field: "orderID", Message: "cannot be empty"}
-	}
-	if len(orderID) > 50 {
-		return &ValidationError{Field: "orderID", Message: "exceeds maximum length of 50 characters"}
-	}
-	return nil
+	return validateRequiredText(orderID, "orderID", 50)
 }
 
 // validateUserID checks if a user ID is valid
 func validateUserID(userID string, fieldName string) error {
-	if len(userID) == 0 {
-		return &ValidationError{Field: fieldName, Message: "cannot be empty"}
-	}
-	if len(userID) > 100 {
-		return &ValidationError{Field: fieldName, Message: "exceeds maximum length of 100 characters"}
-	}
-	return nil
+	return validateRequiredText(userID, fieldName, 100)
 }
 
 // validatePackageWeight checks if package weight is valid
@@ -291,25 +489,16 @@ func validateDimension(value float64, fieldName string) error {
 
 // validateLocation checks if location fields are valid
 func validateLocation(city, state, country string) error {
-	if len(city) == 0 {
-		return &ValidationError{Field: "city", Message: "cannot be empty"}
-	}
-	if len(city) > 100 {
-		return &ValidationError{Field: "city", Message: "exceeds maximum length of 100 characters"}
-	}
-	if len(state) == 0 {
-		return &ValidationError{Field: "state", Message: "cannot be empty"}
+	if err := validateRequiredText(city, "city", 100); err != nil {
+		return err
 	}
-	if len(state) > 100 {
-		return &ValidationError{Field: "state", Message: "exceeds maximum length of 100 characters"}
+	if err := validateRequiredText(state, "state", 100); err != nil {
+		return err
 	}
 	if len(country) == 0 {
 		return &ValidationError{Field: "country", Message: "cannot be empty"}
 	}
-	if len(country) > 100 {
-		return &ValidationError{Field: "country", Message: "exceeds maximum length of 100 characters"}
-	}
-	return nil
+	return validateCountryCode(country)
 }
 
 // validateReason checks if a dispute reason is valid
@@ -317,10 +506,7 @@ func validateReason(reason string) error {
 	if len(reason) == 0 {
 		return &ValidationError{Field: "reason", Message: "cannot be empty"}
 	}
-	if len(reason) > 1000 {
-		return &ValidationError{Field: "reason", Message: "exceeds maximum length of 1000 characters"}
-	}
-	return nil
+	return validateText(reason, "reason", 1000, true)
 }
 
 // assertAttribute checks if a specific attribute exists with an expected value
@@ -333,17 +519,57 @@ func assertAttribute(ctx contractapi.TransactionContextInterface, attrName strin
 }
 
 // validateRole checks if the caller role is allowed for the operation
-func validateRole(caller *CallerIdentity, allowedRoles ...UserRole) error {
+// A role not listed is still allowed when the ledger's permission model lets it inherit a
+// listed role or grants it the invoked function. The caller then acts as the listed role that
+// granted the call, so the party checks the function applies to that role apply to them too.
+func validateRole(ctx contractapi.TransactionContextInterface, caller *CallerIdentity, allowedRoles ...UserRole) error {
 	for _, allowed := range allowedRoles {
 		if caller.Role == allowed {
 			return nil
 		}
 	}
-	return fmt.Errorf("role %s is not authorized for this operation", caller.Role)
+	model, err := getPermissionModel(ctx)
+	if err != nil {
+		return err
+	}
+	role, ok := model.grantingRole(caller.CertificateRole, invokedFunction(ctx), allowedRoles)
+	if !ok {
+		return fmt.Errorf("role %s is not authorized for this operation", caller.CertificateRole)
+	}
+	caller.Role = role
+	if role == RoleAdmin {
+		markInheritedAdmin(ctx)
+	}
+	return nil
 }
 
 // validateInvolvement checks if the caller is involved in the delivery
+// Involved means a party (see validateParty) or the holder of a FULL access grant
 func validateInvolvement(delivery *Delivery, caller *CallerIdentity) error {
+	if err := validateParty(delivery, caller); err == nil {
+		return nil
+	}
+	if delivery.TenantID == caller.TenantID && hasAccessGrant(delivery, caller.ID, GrantScopeFull) {
+		return nil
+	}
+	// Support staff and auditors can look at any delivery of their marketplace, but are never a party
+	if delivery.TenantID == caller.TenantID && (caller.Role == RoleSupport || caller.Role == RoleAuditor) {
+		return nil
+	}
+	if isCustomsBroker(delivery, caller) {
+		return nil
+	}
+	return fmt.Errorf("not authorized to access this delivery")
+}
+
+// validateParty checks if the caller takes part in the delivery itself
+// Access grants are not considered, so use this before letting the caller change anything
+func validateParty(delivery *Delivery, caller *CallerIdentity) error {
+	// Deliveries never cross marketplaces, not even for admins
+	if delivery.TenantID != caller.TenantID {
+		return fmt.Errorf("not authorized to access this delivery")
+	}
+
 	// Admin can always read
 	if caller.Role == RoleAdmin {
 		return nil
@@ -364,15 +590,25 @@ func validateInvolvement(delivery *Delivery, caller *CallerIdentity) error {
 		}
 	}
 
+	// The new custodian of a pending reassignment needs the delivery to find it
+	if hasPendingReassignment(delivery) && delivery.Reassignment.ToUserID == caller.ID {
+		return nil
+	}
+
 	return fmt.Errorf("not authorized to access this delivery")
 }
 
 // emitEvent emits a chaincode event
+// The parties of the deliveries written by the transaction are added as "audience"
 func emitEvent(ctx contractapi.TransactionContextInterface, eventName string, payload interface{}) error {
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event payload: %v", err)
 	}
+	payloadBytes = withCorrelationID(ctx, withEventAudience(ctx, payloadBytes))
+	if tenantCtx, ok := ctx.(*TenantTransactionContext); ok {
+		tenantCtx.eventName, tenantCtx.eventPayload = eventName, payloadBytes
+	}
 	return ctx.GetStub().SetEvent(eventName, payloadBytes)
 }
 
@@ -390,19 +626,24 @@ const (
 // roleToMSP maps user roles to their MSP IDs
 var roleToMSP = map[UserRole]string{
 	RoleAdmin:          MSPPlatform,
+	RoleSupport:        MSPPlatform,
+	RoleAuditor:        MSPPlatform,
 	RoleCustomer:       MSPPlatform,
 	RoleSeller:         MSPSellers,
 	RoleDeliveryPerson: MSPLogistics,
+	RoleWarehouse:      MSPLogistics,
+	RoleSensor:         MSPLogistics,
+	RoleCustomsBroker:  MSPLogistics,
 }
 
 // setDeliveryEndorsementPolicy sets a state-based endorsement policy for a delivery
-// The policy requires endorsement from the current custodian's organization
+// The policy requires endorsement from the current custodian's organization (per the org registry)
 // This ensures that custody changes must be endorsed by the party releasing custody
-func setDeliveryEndorsementPolicy(ctx contractapi.TransactionContextInterface, deliveryID string, custodianRole UserRole) error {
+func setDeliveryEndorsementPolicy(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
 	// Get the MSP for the current custodian
-	custodianMSP, ok := roleToMSP[custodianRole]
-	if !ok {
-		return fmt.Errorf("unknown custodian role: %s", custodianRole)
+	custodianMSP, err := custodianEndorsingMSP(ctx, delivery)
+	if err != nil {
+		return err
 	}
 
 	// Create a state-based endorsement policy
@@ -426,11 +667,37 @@ func setDeliveryEndorsementPolicy(ctx contractapi.TransactionContextInterface, d
 	}
 
 	// Set the state validation parameter (endorsement policy) for this key
-	err = ctx.GetStub().SetStateValidationParameter(deliveryID, policyBytes)
+	err = ctx.GetStub().SetStateValidationParameter(delivery.DeliveryID, policyBytes)
 	if err != nil {
 		return fmt.Errorf("failed to set state validation parameter: %v", err)
 	}
 
+	return syncPolicyIndex(ctx, delivery.DeliveryID, []string{custodianMSP})
+}
+
+// setPrivateDetailsEndorsementPolicy requires SellersOrg and PlatformOrg to endorse
+// every later write or purge of a delivery's private details, so no single org's peer
+// can rewrite the shipping address on its own
+func setPrivateDetailsEndorsementPolicy(ctx contractapi.TransactionContextInterface, deliveryID string) error {
+	ep, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create state endorsement policy: %v", err)
+	}
+
+	// Every listed org must endorse
+	if err := ep.AddOrgs(statebased.RoleTypeMember, MSPSellers, MSPPlatform); err != nil {
+		return fmt.Errorf("failed to add orgs to endorsement policy: %v", err)
+	}
+
+	policyBytes, err := ep.Policy()
+	if err != nil {
+		return fmt.Errorf("failed to serialize endorsement policy: %v", err)
+	}
+
+	if err := ctx.GetStub().SetPrivateDataValidationParameter(CollectionDeliveryPrivate, deliveryID, policyBytes); err != nil {
+		return fmt.Errorf("failed to set private data validation parameter: %v", err)
+	}
+
 	return nil
 }
 
@@ -445,108 +712,201 @@ const (
 	IndexCustodianDelivery = "custodian~deliveryId"
 	IndexStatusDelivery    = "status~deliveryId"
 	IndexOrderDelivery     = "order~deliveryId"
+	IndexDisputedDelivery  = "disputed~deliveryId"
+	// Recipient of the pending handoff, if any
+	IndexHandoffRecipientDelivery = "handoffRecipient~deliveryId"
 )
 
-// createDeliveryIndexes creates all composite key indexes for a delivery
-func createDeliveryIndexes(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
-	stub := ctx.GetStub()
+// closedStatuses are terminal statuses in which nobody holds the delivery anymore
+// Closed deliveries keep their last custodian on record but leave the custodian indexes.
+var closedStatuses = map[DeliveryStatus]bool{
+	StatusSplit:    true,
+	StatusMerged:   true,
+	StatusExported: true,
+}
 
-	// Index by seller
-	sellerKey, err := stub.CreateCompositeKey(IndexSellerDelivery, []string{delivery.SellerID, delivery.DeliveryID})
-	if err != nil {
-		return fmt.Errorf("failed to create seller composite key: %v", err)
+// deliveryIndex is one single-valued composite key index entry of a delivery
+type deliveryIndex struct {
+	name  string
+	value string
+}
+
+// deliveryIndexEntries returns the index entries a delivery should currently have
+// Tombstones are not indexed; entries with an empty value are skipped.
+func deliveryIndexEntries(delivery *Delivery) []deliveryIndex {
+	if delivery == nil || delivery.Tombstone != nil {
+		return nil
+	}
+	custodianID := delivery.CurrentCustodianID
+	if closedStatuses[delivery.DeliveryStatus] {
+		custodianID = ""
 	}
-	if err := stub.PutState(sellerKey, []byte{0x00}); err != nil {
-		return fmt.Errorf("failed to put seller index: %v", err)
+	var recipientID string
+	if delivery.PendingHandoff != nil {
+		recipientID = delivery.PendingHandoff.ToUserID
+	}
+
+	var entries []deliveryIndex
+	for _, entry := range []deliveryIndex{
+		{IndexSellerDelivery, delivery.SellerID},
+		{IndexCustomerDelivery, delivery.CustomerID},
+		{IndexCustodianDelivery, custodianID},
+		{IndexStatusDelivery, string(delivery.DeliveryStatus)},
+		{IndexOrderDelivery, delivery.OrderID},
+		{IndexHandoffRecipientDelivery, recipientID},
+	} {
+		if entry.value != "" {
+			entries = append(entries, entry)
+		}
 	}
+	return entries
+}
+
+// applyDeliveryUpdate writes a delivery and brings every index derived from it up to date
+// Every mutator goes through here instead of maintaining indexes itself. The committed version
+// is compared with the new one: moved index entries are rewritten, status changes update the
+// dispute queue and record a milestone, and the user~status, carrier~tracking and updated~bucket
+// indexes follow. Progress milestones reached for the first time are stamped before the write.
+// Fabric reads don't see writes from the same transaction, so write a delivery at most once per tx.
+func applyDeliveryUpdate(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
+	stub := ctx.GetStub()
 
-	// Index by customer
-	customerKey, err := stub.CreateCompositeKey(IndexCustomerDelivery, []string{delivery.CustomerID, delivery.DeliveryID})
+	var previous *Delivery
+	previousJSON, err := stub.GetState(delivery.DeliveryID)
 	if err != nil {
-		return fmt.Errorf("failed to create customer composite key: %v", err)
+		return fmt.Errorf("failed to read delivery from world state: %v", err)
 	}
-	if err := stub.PutState(customerKey, []byte{0x00}); err != nil {
-		return fmt.Errorf("failed to put customer index: %v", err)
+	if previousJSON != nil {
+		// Without the stored version its index entries can't be cleaned up, so don't write over it
+		previous = &Delivery{}
+		if err := json.Unmarshal(previousJSON, previous); err != nil {
+			return fmt.Errorf("failed to unmarshal stored delivery %s: %v", delivery.DeliveryID, err)
+		}
 	}
 
-	// Index by current custodian
-	custodianKey, err := stub.CreateCompositeKey(IndexCustodianDelivery, []string{delivery.CurrentCustodianID, delivery.DeliveryID})
-	if err != nil {
-		return fmt.Errorf("failed to create custodian composite key: %v", err)
+	// The state hash is derived from the stored bytes, never stored itself
+	delivery.StateHash = ""
+	delivery.CorrelationID = correlationID(ctx)
+	// Regional admins only act on deliveries headed for their region, both as stored and as
+	// written: that covers creations, split and merge children and redirects out of the region
+	if err := validateAdminScope(ctx, previous); err != nil {
+		return err
 	}
-	if err := stub.PutState(custodianKey, []byte{0x00}); err != nil {
-		return fmt.Errorf("failed to put custodian index: %v", err)
+	if err := validateAdminScope(ctx, delivery); err != nil {
+		return err
 	}
 
-	// Index by status
-	statusKey, err := stub.CreateCompositeKey(IndexStatusDelivery, []string{string(delivery.DeliveryStatus), delivery.DeliveryID})
+	recordMilestoneActuals(delivery)
+	trackStatusTime(previous, delivery)
+	deliveryJSON, err := json.Marshal(delivery)
 	if err != nil {
-		return fmt.Errorf("failed to create status composite key: %v", err)
+		return fmt.Errorf("failed to marshal delivery: %v", err)
 	}
-	if err := stub.PutState(statusKey, []byte{0x00}); err != nil {
-		return fmt.Errorf("failed to put status index: %v", err)
+	if err := stub.PutState(delivery.DeliveryID, deliveryJSON); err != nil {
+		return fmt.Errorf("failed to put delivery to world state: %v", err)
 	}
+	addEventAudience(ctx, previous, delivery)
+	addDeliveryProjection(ctx, projectDelivery(ctx, delivery))
 
-	// Index by order
-	orderKey, err := stub.CreateCompositeKey(IndexOrderDelivery, []string{delivery.OrderID, delivery.DeliveryID})
-	if err != nil {
-		return fmt.Errorf("failed to create order composite key: %v", err)
+	if err := syncDeliveryIndexes(ctx, previous, delivery); err != nil {
+		return err
+	}
+
+	// Status transitions feed the open-dispute queue, the milestone Merkle root, fee settlements
+	// and courier incentive points
+	var oldStatus DeliveryStatus
+	if previous != nil {
+		oldStatus = previous.DeliveryStatus
 	}
-	if err := stub.PutState(orderKey, []byte{0x00}); err != nil {
-		return fmt.Errorf("failed to put order index: %v", err)
+	if delivery.Tombstone == nil && oldStatus != delivery.DeliveryStatus {
+		if err := updateDisputeIndex(ctx, delivery.DeliveryID, oldStatus, delivery.DeliveryStatus); err != nil {
+			return err
+		}
+		if err := recordMilestone(ctx, delivery.DeliveryID, oldStatus, delivery.DeliveryStatus); err != nil {
+			return err
+		}
+		if delivery.DeliveryStatus == StatusConfirmedDelivery {
+			if err := recordDeliverySettlement(ctx, delivery); err != nil {
+				return err
+			}
+			if err := accrueDeliveryPoints(ctx, previous, delivery); err != nil {
+				return err
+			}
+		}
 	}
 
-	return nil
+	if err := syncUserStatusIndex(ctx, previous, delivery); err != nil {
+		return err
+	}
+	if err := syncExternalTrackingIndex(ctx, previous, delivery); err != nil {
+		return err
+	}
+	return syncUpdatedIndex(ctx, previous, delivery)
 }
 
-// updateCustodianIndex updates the custodian index when custody changes
-func updateCustodianIndex(ctx contractapi.TransactionContextInterface, delivery *Delivery, oldCustodianID, newCustodianID string) error {
+// syncDeliveryIndexes moves the seller, customer, custodian, status and order entries of a delivery
+// from its previous version (nil on creation) to the new one. Party corrections rely on this to move
+// seller~ and customer~ keys; current entries are always rewritten so stale ones heal.
+func syncDeliveryIndexes(ctx contractapi.TransactionContextInterface, previous *Delivery, delivery *Delivery) error {
 	stub := ctx.GetStub()
 
-	// Delete old custodian index
-	oldKey, err := stub.CreateCompositeKey(IndexCustodianDelivery, []string{oldCustodianID, delivery.DeliveryID})
-	if err != nil {
-		return fmt.Errorf("failed to create old custodian composite key: %v", err)
-	}
-	if err := stub.DelState(oldKey); err != nil {
-		return fmt.Errorf("failed to delete old custodian index: %v", err)
+	current := map[string]bool{}
+	for _, entry := range deliveryIndexEntries(delivery) {
+		key, err := stub.CreateCompositeKey(entry.name, []string{entry.value, delivery.DeliveryID})
+		if err != nil {
+			return fmt.Errorf("failed to create %s composite key: %v", entry.name, err)
+		}
+		current[key] = true
+		if err := stub.PutState(key, []byte{0x00}); err != nil {
+			return fmt.Errorf("failed to put %s index: %v", entry.name, err)
+		}
 	}
-
-	// Create new custodian index
-	newKey, err := stub.CreateCompositeKey(IndexCustodianDelivery, []string{newCustodianID, delivery.DeliveryID})
-	if err != nil {
-		return fmt.Errorf("failed to create new custodian composite key: %v", err)
+	if previous == nil {
+		return nil
 	}
-	if err := stub.PutState(newKey, []byte{0x00}); err != nil {
-		return fmt.Errorf("failed to put new custodian index: %v", err)
+	for _, entry := range deliveryIndexEntries(previous) {
+		key, err := stub.CreateCompositeKey(entry.name, []string{entry.value, previous.DeliveryID})
+		if err != nil {
+			return fmt.Errorf("failed to create %s composite key: %v", entry.name, err)
+		}
+		if current[key] {
+			continue
+		}
+		if err := stub.DelState(key); err != nil {
+			return fmt.Errorf("failed to delete %s index: %v", entry.name, err)
+		}
 	}
-
 	return nil
 }
 
-// updateStatusIndex updates the status index when status changes
-func updateStatusIndex(ctx contractapi.TransactionContextInterface, deliveryID string, oldStatus, newStatus DeliveryStatus) error {
+// deleteDeliveryIndexes removes all composite key indexes of a delivery
+func deleteDeliveryIndexes(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
 	stub := ctx.GetStub()
 
-	// Delete old status index
-	oldKey, err := stub.CreateCompositeKey(IndexStatusDelivery, []string{string(oldStatus), deliveryID})
-	if err != nil {
-		return fmt.Errorf("failed to create old status composite key: %v", err)
-	}
-	if err := stub.DelState(oldKey); err != nil {
-		return fmt.Errorf("failed to delete old status index: %v", err)
+	for _, entry := range deliveryIndexEntries(delivery) {
+		key, err := stub.CreateCompositeKey(entry.name, []string{entry.value, delivery.DeliveryID})
+		if err != nil {
+			return fmt.Errorf("failed to create %s composite key: %v", entry.name, err)
+		}
+		if err := stub.DelState(key); err != nil {
+			return fmt.Errorf("failed to delete %s index: %v", entry.name, err)
+		}
 	}
 
-	// Create new status index
-	newKey, err := stub.CreateCompositeKey(IndexStatusDelivery, []string{string(newStatus), deliveryID})
-	if err != nil {
-		return fmt.Errorf("failed to create new status composite key: %v", err)
+	if err := syncUserStatusIndex(ctx, delivery, nil); err != nil {
+		return err
+	}
+	if err := syncUpdatedIndex(ctx, delivery, nil); err != nil {
+		return err
+	}
+	if err := syncExternalTrackingIndex(ctx, delivery, nil); err != nil {
+		return err
 	}
-	if err := stub.PutState(newKey, []byte{0x00}); err != nil {
-		return fmt.Errorf("failed to put new status index: %v", err)
+	if err := syncPolicyIndex(ctx, delivery.DeliveryID, nil); err != nil {
+		return err
 	}
 
-	return nil
+	return removeFromDisputeQueue(ctx, delivery.DeliveryID)
 }
 
 // queryByCompositeKey executes a composite key query and returns matching delivery IDs
@@ -584,9 +944,44 @@ func (c *DeliveryContract) InitLedger(ctx contractapi.TransactionContextInterfac
 	return nil
 }
 
+// DeliveryInput is the caller-supplied part of a new delivery, in kg/cm
+type DeliveryInput struct {
+	DeliveryID        string
+	OrderID           string
+	CustomerID        string
+	PackageWeight     float64
+	PackageDimensions PackageDimensions
+	Destination       Location
+}
+
+// validate normalizes the destination and checks every field
+// All failures are returned together as ValidationErrors rather than only the first one.
+func (in *DeliveryInput) validate() error {
+	var errs ValidationErrors
+	errs.collect(validateDeliveryID(in.DeliveryID))
+	errs.collect(validateOrderID(in.OrderID))
+	errs.collect(validateUserID(in.CustomerID, "customerID"))
+	errs.collect(validatePackageWeight(in.PackageWeight))
+	errs.collect(validateDimension(in.PackageDimensions.Length, "dimensionLength"))
+	errs.collect(validateDimension(in.PackageDimensions.Width, "dimensionWidth"))
+	errs.collect(validateDimension(in.PackageDimensions.Height, "dimensionHeight"))
+
+	d := &in.Destination
+	d.City, d.State, d.Country = normalizeLocation(d.City, d.State, d.Country)
+	errs.collect(validateRequiredText(d.City, "city", 100))
+	errs.collect(validateRequiredText(d.State, "state", 100))
+	errs.collect(validateCountryCode(d.Country))
+	return errs.err()
+}
+
 // CreateDelivery creates a new delivery record on the ledger
 // Only SELLER can create deliveries (when confirming an order)
 // The caller identity is extracted from the X.509 certificate - no parameters needed!
+// An optional contents manifest can be passed in the transient map ("contentsManifest"), and
+// perishables can carry their expiry date ("productExpiry") and restricted goods their
+// category ("goodsCategory"); a label's barcode/QR payload ("labelPayload") binds the label
+// Destinations outside the configured service coverage fail with UNSERVICEABLE_DESTINATION;
+// invalid input fails with VALIDATION_FAILED listing every invalid field
 func (c *DeliveryContract) CreateDelivery(
 	ctx contractapi.TransactionContextInterface,
 	deliveryID string,
@@ -601,28 +996,24 @@ func (c *DeliveryContract) CreateDelivery(
 	locationCountry string,
 ) error {
 	// ========== INPUT VALIDATION ==========
-	if err := validateDeliveryID(deliveryID); err != nil {
-		return err
-	}
-	if err := validateOrderID(orderID); err != nil {
-		return err
-	}
-	if err := validateUserID(customerID, "customerID"); err != nil {
-		return err
-	}
-	if err := validatePackageWeight(packageWeight); err != nil {
-		return err
-	}
-	if err := validateDimension(dimensionLength, "dimensionLength"); err != nil {
-		return err
-	}
-	if err := validateDimension(dimensionWidth, "dimensionWidth"); err != nil {
+	// Weights and dimensions are stored in kg/cm; limits apply after normalization
+	units, err := getMeasurementUnits(ctx)
+	if err != nil {
 		return err
 	}
-	if err := validateDimension(dimensionHeight, "dimensionHeight"); err != nil {
-		return err
+	input := DeliveryInput{
+		DeliveryID:    deliveryID,
+		OrderID:       orderID,
+		CustomerID:    customerID,
+		PackageWeight: units.toKilograms(packageWeight),
+		PackageDimensions: PackageDimensions{
+			Length: units.toCentimeters(dimensionLength),
+			Width:  units.toCentimeters(dimensionWidth),
+			Height: units.toCentimeters(dimensionHeight),
+		},
+		Destination: Location{City: locationCity, State: locationState, Country: locationCountry},
 	}
-	if err := validateLocation(locationCity, locationState, locationCountry); err != nil {
+	if err := input.validate(); err != nil {
 		return err
 	}
 
@@ -633,7 +1024,7 @@ func (c *DeliveryContract) CreateDelivery(
 	}
 
 	// Validate role - only SELLER can create deliveries
-	if err := validateRole(caller, RoleSeller); err != nil {
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
 		return err
 	}
 
@@ -646,67 +1037,117 @@ func (c *DeliveryContract) CreateDelivery(
 		return fmt.Errorf("delivery %s already exists", deliveryID)
 	}
 
+	// Unfulfillable deliveries never enter the ledger
+	if err := validateServiceable(ctx, input.Destination); err != nil {
+		return err
+	}
+
 	currentTime, err := getTxTimestamp(ctx)
 	if err != nil {
 		return err
 	}
 
 	delivery := Delivery{
-		DeliveryID:    deliveryID,
-		OrderID:       orderID,
-		SellerID:      caller.ID, // Seller ID comes from the certificate!
-		CustomerID:    customerID,
-		PackageWeight: packageWeight,
-		PackageDimensions: PackageDimensions{
-			Length: dimensionLength,
-			Width:  dimensionWidth,
-			Height: dimensionHeight,
-		},
-		DeliveryStatus: StatusPendingPickup,
-		LastLocation: Location{
-			City:    locationCity,
-			State:   locationState,
-			Country: locationCountry,
-		},
+		TenantID:             caller.TenantID,
+		DeliveryID:           deliveryID,
+		OrderID:              orderID,
+		SellerID:             caller.ID, // Seller ID comes from the certificate!
+		CustomerID:           customerID,
+		PackageWeight:        input.PackageWeight,
+		DeclaredWeight:       input.PackageWeight,
+		PackageDimensions:    input.PackageDimensions,
+		DeliveryStatus:       StatusPendingPickup,
+		LastLocation:         input.Destination,
+		Destination:          &input.Destination,
 		CurrentCustodianID:   caller.ID,
 		CurrentCustodianRole: RoleSeller,
+		CustodianMSP:         caller.MSP,
 		UpdatedAt:            currentTime,
 	}
 
-	deliveryJSON, err := json.Marshal(delivery)
+	return createDeliveryInternal(ctx, &delivery)
+}
+
+// CreateDeliveryAutoID creates a delivery like CreateDelivery, deriving its ID from the
+// transaction ID and orderID instead of trusting the client to pick an unused one
+// Returns the generated delivery ID.
+func (c *DeliveryContract) CreateDeliveryAutoID(
+	ctx contractapi.TransactionContextInterface,
+	orderID string,
+	customerID string,
+	packageWeight float64,
+	dimensionLength float64,
+	dimensionWidth float64,
+	dimensionHeight float64,
+	locationCity string,
+	locationState string,
+	locationCountry string,
+) (string, error) {
+	deliveryID, err := derivedDeliveryID(ctx, orderID)
 	if err != nil {
-		return fmt.Errorf("failed to marshal delivery: %v", err)
+		return "", err
+	}
+	if err := c.CreateDelivery(ctx, deliveryID, orderID, customerID, packageWeight, dimensionLength, dimensionWidth, dimensionHeight, locationCity, locationState, locationCountry); err != nil {
+		return "", err
+	}
+	return deliveryID, nil
+}
+
+// createDeliveryInternal stores a new seller-held delivery with its indexes and endorsement policy
+// Shared by CreateDelivery and CreateDeliveryFromTemplate
+func createDeliveryInternal(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
+	deliveryID := delivery.DeliveryID
+
+	// Expedited tiers must be deliverable under the origin region's business calendar
+	if err := validateServicePromise(ctx, delivery); err != nil {
+		return err
+	}
+
+	// Perishables must be promised for before they expire
+	if err := applyProductExpiry(ctx, delivery); err != nil {
+		return err
+	}
+
+	// Restricted goods only go where the jurisdiction matrix allows them
+	if err := applyGoodsCategory(ctx, delivery); err != nil {
+		return err
 	}
 
-	err = ctx.GetStub().PutState(deliveryID, deliveryJSON)
+	// Optional contents manifest: only its hash is public, the list stays in the PDC
+	manifestHash, err := storeContentsManifest(ctx, deliveryID)
 	if err != nil {
+		return err
+	}
+	delivery.ContentsManifestHash = manifestHash
+
+	// Optional barcode/QR label payload, checked at every handoff confirmation
+	if err := applyLabelPayload(ctx, delivery); err != nil {
+		return err
+	}
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
 		return fmt.Errorf("failed to put delivery to world state: %v", err)
 	}
 
 	// Set state-based endorsement policy
 	// The seller's org (SellersOrgMSP) must endorse any state changes
 	// This ensures custody changes require the current custodian's endorsement
-	if err := setDeliveryEndorsementPolicy(ctx, deliveryID, RoleSeller); err != nil {
+	if err := setDeliveryEndorsementPolicy(ctx, delivery); err != nil {
 		return fmt.Errorf("failed to set endorsement policy: %v", err)
 	}
 
-	// Create composite key indexes for efficient queries
-	if err := createDeliveryIndexes(ctx, &delivery); err != nil {
-		return fmt.Errorf("failed to create delivery indexes: %v", err)
-	}
-
 	// Emit event
 	event := DeliveryEvent{
 		DeliveryID: deliveryID,
-		OrderID:    orderID,
+		OrderID:    delivery.OrderID,
 		NewStatus:  StatusPendingPickup,
-		Timestamp:  currentTime,
+		Timestamp:  delivery.UpdatedAt,
 	}
 	return emitEvent(ctx, EventDeliveryCreated, event)
 }
 
 // ReadDelivery retrieves a delivery from the ledger
-// All roles can read deliveries they are involved with; admin can read any
+// All roles can read deliveries they are involved with; admin, support and auditors can read any
 func (c *DeliveryContract) ReadDelivery(
 	ctx contractapi.TransactionContextInterface,
 	deliveryID string,
@@ -718,10 +1159,15 @@ func (c *DeliveryContract) ReadDelivery(
 	}
 
 	// Validate role - all roles can read
-	if err := validateRole(caller, RoleSeller, RoleCustomer, RoleDeliveryPerson, RoleAdmin); err != nil {
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
 		return nil, err
 	}
 
+	return readDeliveryForCaller(ctx, caller, deliveryID)
+}
+
+// readDeliveryForCaller reads a delivery the caller may see, with its state hash set
+func readDeliveryForCaller(ctx contractapi.TransactionContextInterface, caller *CallerIdentity, deliveryID string) (*Delivery, error) {
 	deliveryJSON, err := ctx.GetStub().GetState(deliveryID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read delivery from world state: %v", err)
@@ -735,17 +1181,28 @@ func (c *DeliveryContract) ReadDelivery(
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal delivery: %v", err)
 	}
+	delivery.StateHash = deliveryStateHash(deliveryJSON)
+
+	// Redacted deliveries keep only a tombstone, which anyone in the tenant may see
+	if delivery.Tombstone != nil && delivery.TenantID == caller.TenantID {
+		return &delivery, nil
+	}
 
-	// Validate involvement (admin bypasses this check)
-	if err := validateInvolvement(&delivery, caller); err != nil {
+	// Validate involvement (admin bypasses this check); TRACKING grants are enough here
+	if err := validateTrackingAccess(&delivery, caller); err != nil {
 		return nil, err
 	}
 
+	// Grantees don't learn who else was granted access
+	if validateParty(&delivery, caller) != nil {
+		delivery.AccessGrants = nil
+	}
+
 	return &delivery, nil
 }
 
-// UpdateLocation updates the last known location of a delivery
-// Only the current DELIVERY_PERSON custodian can update location
+// UpdateLocation records the current location of a delivery and appends it to its history
+// The current DELIVERY_PERSON custodian reports positions; a WAREHOUSE custodian records hub arrival
 func (c *DeliveryContract) UpdateLocation(
 	ctx contractapi.TransactionContextInterface,
 	deliveryID string,
@@ -757,6 +1214,7 @@ func (c *DeliveryContract) UpdateLocation(
 	if err := validateDeliveryID(deliveryID); err != nil {
 		return err
 	}
+	city, state, country = normalizeLocation(city, state, country)
 	if err := validateLocation(city, state, country); err != nil {
 		return err
 	}
@@ -767,8 +1225,8 @@ func (c *DeliveryContract) UpdateLocation(
 		return fmt.Errorf("failed to get caller identity: %v", err)
 	}
 
-	// Validate role - only DELIVERY_PERSON can update location
-	if err := validateRole(caller, RoleDeliveryPerson); err != nil {
+	// Validate role - couriers and warehouses hold packages between handoffs
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse); err != nil {
 		return err
 	}
 
@@ -777,14 +1235,28 @@ func (c *DeliveryContract) UpdateLocation(
 		return err
 	}
 
-	// Must be current custodian
-	if delivery.CurrentCustodianID != caller.ID {
-		return fmt.Errorf("only the current custodian can update location")
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
 	}
+	currentTime := txTime.Format(time.RFC3339)
 
-	// Must be in transit
-	if delivery.DeliveryStatus != StatusInTransit {
-		return fmt.Errorf("can only update location when in transit")
+	// Must be current custodian, in the role it took custody with, or its delegated helper
+	custodian, onBehalfOf := caller, ""
+	if delivery.CurrentCustodianID != caller.ID || delivery.CurrentCustodianRole != caller.Role {
+		if custodian = delegatedCustodian(delivery, caller, txTime); custodian == nil {
+			return fmt.Errorf("only the current custodian can update location")
+		}
+		onBehalfOf = custodian.ID
+	}
+
+	if !locationUpdateStatuses[delivery.DeliveryStatus] {
+		return fmt.Errorf("cannot update location in status %s", delivery.DeliveryStatus)
+	}
+
+	kind := LocationUpdateCourier
+	if custodian.Role == RoleWarehouse {
+		kind = LocationUpdateHubArrival
 	}
 
 	delivery.LastLocation = Location{
@@ -792,22 +1264,83 @@ func (c *DeliveryContract) UpdateLocation(
 		State:   state,
 		Country: country,
 	}
-	currentTime, err := getTxTimestamp(ctx)
+	delivery.UpdatedAt = currentTime
+
+	// Perishables are warned about once, the first time transit eats into the expiry margin
+	warnNearExpiry := false
+	var expectedAt time.Time
+	if delivery.NearExpiryWarnedAt == "" {
+		warnNearExpiry, expectedAt, err = nearExpiry(ctx, delivery, txTime)
+		if err != nil {
+			return err
+		}
+		if warnNearExpiry {
+			delivery.NearExpiryWarnedAt = currentTime
+		}
+	}
+	deviation, err := checkRouteDeviation(ctx, delivery, caller, currentTime)
 	if err != nil {
 		return err
 	}
-	delivery.UpdatedAt = currentTime
 
-	deliveryJSON, err := json.Marshal(delivery)
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	err = appendLocationHistory(ctx, &LocationUpdate{
+		DeliveryID:     deliveryID,
+		TxID:           ctx.GetStub().GetTxID(),
+		Kind:           kind,
+		Location:       delivery.LastLocation,
+		DeliveryStatus: delivery.DeliveryStatus,
+		RecordedBy:     caller.ID,
+		RecordedByRole: caller.Role,
+		OnBehalfOf:     onBehalfOf,
+		RecordedAt:     currentTime,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to marshal delivery: %v", err)
+		return err
 	}
 
-	return ctx.GetStub().PutState(deliveryID, deliveryJSON)
+	// City-level only - finer positions never reach the public ledger
+	eventPayload := map[string]string{
+		"deliveryId": deliveryID,
+		"orderId":    delivery.OrderID,
+		"kind":       string(kind),
+		"city":       city,
+		"state":      state,
+		"country":    country,
+		"txId":       ctx.GetStub().GetTxID(),
+		"updatedBy":  caller.ID,
+		"timestamp":  currentTime,
+	}
+	if onBehalfOf != "" {
+		eventPayload["onBehalfOf"] = onBehalfOf
+	}
+
+	// Fabric keeps one event per transaction, so alerts carry the location update; a route
+	// deviation also carries a near-expiry warning raised by the same update
+	if warnNearExpiry {
+		eventPayload["productExpiry"] = delivery.ProductExpiry
+		eventPayload["expectedBy"] = expectedAt.Format(time.RFC3339)
+		eventPayload["sellerId"] = delivery.SellerID
+	}
+	if deviation != nil {
+		eventPayload["sellerId"] = delivery.SellerID
+		eventPayload["allowance"] = string(deviation.Allowance)
+		eventPayload["deviations"] = fmt.Sprintf("%d", delivery.RoutePlan.Deviations)
+		return emitEvent(ctx, EventRouteDeviation, eventPayload)
+	}
+	if warnNearExpiry {
+		return emitEvent(ctx, EventNearExpiry, eventPayload)
+	}
+	return emitEvent(ctx, EventLocationUpdated, eventPayload)
 }
 
 // InitiateHandoff starts a custody transfer (current custodian initiates)
-// SELLER or DELIVERY_PERSON can initiate handoffs
+// SELLER, DELIVERY_PERSON, or WAREHOUSE can initiate handoffs; condition evidence can be
+// attached via the transient map ("handoffEvidence"), and a confirmation code the recipient
+// must present via "handoffCode"
 func (c *DeliveryContract) InitiateHandoff(
 	ctx contractapi.TransactionContextInterface,
 	deliveryID string,
@@ -829,14 +1362,14 @@ func (c *DeliveryContract) InitiateHandoff(
 	}
 
 	// Validate caller role
-	if err := validateRole(caller, RoleSeller, RoleDeliveryPerson); err != nil {
+	if err := validateRole(ctx, caller, RoleSeller, RoleDeliveryPerson, RoleWarehouse); err != nil {
 		return err
 	}
 
 	// Validate target role
 	targetRole := UserRole(toRole)
-	if targetRole != RoleDeliveryPerson && targetRole != RoleCustomer {
-		return fmt.Errorf("can only hand off to DELIVERY_PERSON or CUSTOMER")
+	if targetRole != RoleDeliveryPerson && targetRole != RoleWarehouse && targetRole != RoleCustomer {
+		return fmt.Errorf("can only hand off to DELIVERY_PERSON, WAREHOUSE, or CUSTOMER")
 	}
 
 	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
@@ -844,95 +1377,161 @@ func (c *DeliveryContract) InitiateHandoff(
 		return err
 	}
 
-	// Sellers can only hand off to delivery persons (not directly to customers)
-	if caller.Role == RoleSeller && targetRole == RoleCustomer {
-		return fmt.Errorf("sellers can only hand off to delivery persons")
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	currentTime := txTime.Format(time.RFC3339)
+
+	// A delegated helper initiates as the custodian; the handoff records who actually did
+	custodian := caller
+	if delivery.CurrentCustodianID != caller.ID {
+		if delegate := delegatedCustodian(delivery, caller, txTime); delegate != nil {
+			custodian = delegate
+		}
+	}
+
+	evidence, err := getHandoffEvidence(ctx, caller.ID, currentTime)
+	if err != nil {
+		return err
+	}
+
+	oldStatus, err := initiateHandoffInternal(ctx, custodian, delivery, toUserID, targetRole, "", evidence, currentTime)
+	if err != nil {
+		return err
+	}
+
+	// Emit status change event if status changed
+	if oldStatus != delivery.DeliveryStatus {
+		event := DeliveryEvent{
+			DeliveryID: deliveryID,
+			OrderID:    delivery.OrderID,
+			OldStatus:  oldStatus,
+			NewStatus:  delivery.DeliveryStatus,
+			Timestamp:  currentTime,
+		}
+		return emitEvent(ctx, EventDeliveryStatusChanged, event)
+	}
+
+	// Emit handoff initiated event
+	eventPayload := map[string]string{
+		"deliveryId": deliveryID,
+		"fromUserId": custodian.ID,
+		"toUserId":   toUserID,
+		"timestamp":  currentTime,
+	}
+	if custodian.DelegateID != "" {
+		eventPayload["initiatedBy"] = custodian.DelegateID
+	}
+	return emitEvent(ctx, EventHandoffInitiated, eventPayload)
+}
+
+// initiateHandoffInternal validates and records a pending handoff on an already-loaded delivery
+// Shared by InitiateHandoff, InitiateInterlineHandoff and the batch/manifest flows; events are left
+// to the caller. A non-empty toCarrierMSP makes it an INTERLINE handoff to that carrier; evidence,
+// if any, is what the initiator captured for this package.
+// Returns the status the delivery had before the handoff.
+func initiateHandoffInternal(
+	ctx contractapi.TransactionContextInterface,
+	caller *CallerIdentity,
+	delivery *Delivery,
+	toUserID string,
+	targetRole UserRole,
+	toCarrierMSP string,
+	evidence *HandoffEvidence,
+	currentTime string,
+) (DeliveryStatus, error) {
+	// Sellers and warehouses can only hand off to logistics (not directly to customers)
+	if caller.Role != RoleDeliveryPerson && targetRole == RoleCustomer {
+		return "", fmt.Errorf("only delivery persons can hand off to customers")
 	}
 
 	// Verify caller is current custodian
 	if delivery.CurrentCustodianID != caller.ID {
-		return fmt.Errorf("only the current custodian can initiate a handoff")
+		return "", fmt.Errorf("only the current custodian can initiate a handoff")
 	}
 
 	// Check if there's already a pending handoff
 	if delivery.PendingHandoff != nil {
-		return fmt.Errorf("there is already a pending handoff for this delivery")
+		return "", fmt.Errorf("there is already a pending handoff for this delivery")
+	}
+	if hasPendingReassignment(delivery) {
+		return "", fmt.Errorf("custody of this delivery is being reassigned")
+	}
+
+	// Couriers on break or off shift can't be assigned new packages
+	if targetRole == RoleDeliveryPerson {
+		if err := validateCourierAvailable(ctx, toUserID); err != nil {
+			return "", err
+		}
+		if err := validatePrivateDetailsSet(ctx, delivery.DeliveryID); err != nil {
+			return "", err
+		}
 	}
 
 	// Validate status allows handoff
 	validStatuses := map[DeliveryStatus]bool{
-		StatusPendingPickup: true,
-		StatusInTransit:     true,
+		StatusPendingPickup:     true,
+		StatusInTransit:         true,
+		StatusOffNetworkTransit: true,
+		StatusOutForDelivery:    true,
 	}
 	if !validStatuses[delivery.DeliveryStatus] {
-		return fmt.Errorf("cannot initiate handoff in current status: %s", delivery.DeliveryStatus)
-	}
-
-	currentTime, err := getTxTimestamp(ctx)
-	if err != nil {
-		return err
+		return "", fmt.Errorf("cannot initiate handoff in current status: %s", delivery.DeliveryStatus)
 	}
 
 	// Create pending handoff
 	delivery.PendingHandoff = &PendingHandoff{
-		FromUserID:  caller.ID,
-		FromRole:    caller.Role,
-		ToUserID:    toUserID,
-		ToRole:      targetRole,
-		InitiatedAt: currentTime,
+		FromUserID:    caller.ID,
+		FromRole:      caller.Role,
+		ToUserID:      toUserID,
+		ToRole:        targetRole,
+		InitiatedAt:   currentTime,
+		InitiatedBy:   caller.DelegateID,
+		CorrelationID: correlationID(ctx),
+	}
+	if toCarrierMSP != "" {
+		delivery.PendingHandoff.Type = HandoffTypeInterline
+		delivery.PendingHandoff.FromCarrierMSP = caller.MSP
+		delivery.PendingHandoff.ToCarrierMSP = toCarrierMSP
+	}
+	if evidence != nil {
+		delivery.PendingHandoff.Evidence = []HandoffEvidence{*evidence}
+	}
+	if err := setHandoffCode(ctx, delivery, currentTime); err != nil {
+		return "", err
 	}
 
 	// Update delivery status based on handoff type
 	oldStatus := delivery.DeliveryStatus
 	switch targetRole {
-	case RoleDeliveryPerson:
+	case RoleDeliveryPerson, RoleWarehouse:
 		if delivery.DeliveryStatus == StatusPendingPickup {
 			delivery.DeliveryStatus = StatusPendingPickupHandoff
 		} else {
 			delivery.DeliveryStatus = StatusPendingTransitHandoff
 		}
-	case RoleCustomer:
-		delivery.DeliveryStatus = StatusPendingDeliveryConfirmation
-	}
-
-	delivery.UpdatedAt = currentTime
-
-	deliveryJSON, err := json.Marshal(delivery)
-	if err != nil {
-		return fmt.Errorf("failed to marshal delivery: %v", err)
+	case RoleCustomer:
+		delivery.DeliveryStatus = StatusPendingDeliveryConfirmation
 	}
 
-	err = ctx.GetStub().PutState(deliveryID, deliveryJSON)
-	if err != nil {
-		return err
-	}
+	delivery.UpdatedAt = currentTime
 
-	// Update status index and emit event if status changed
-	if oldStatus != delivery.DeliveryStatus {
-		if err := updateStatusIndex(ctx, deliveryID, oldStatus, delivery.DeliveryStatus); err != nil {
-			return fmt.Errorf("failed to update status index: %v", err)
-		}
-		event := DeliveryEvent{
-			DeliveryID: deliveryID,
-			OrderID:    delivery.OrderID,
-			OldStatus:  oldStatus,
-			NewStatus:  delivery.DeliveryStatus,
-			Timestamp:  currentTime,
-		}
-		return emitEvent(ctx, EventDeliveryStatusChanged, event)
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return "", err
 	}
 
-	// Emit handoff initiated event
-	return emitEvent(ctx, EventHandoffInitiated, map[string]string{
-		"deliveryId": deliveryID,
-		"fromUserId": caller.ID,
-		"toUserId":   toUserID,
-		"timestamp":  currentTime,
-	})
+	return oldStatus, nil
 }
 
 // ConfirmHandoff confirms a pending custody transfer (receiver confirms)
-// DELIVERY_PERSON or CUSTOMER can confirm handoffs
+// DELIVERY_PERSON, WAREHOUSE, or CUSTOMER can confirm handoffs
+// Per-item conditions can be reported via the transient map ("itemConditions")
+// Age-restricted final handoffs require an ID-check attestation ("ageVerification")
+// Geofenced final handoffs require the courier's position ("courierCoordinates")
+// Photo, seal and signature evidence can be attached via the transient map ("handoffEvidence")
+// Code-protected handoffs require the initiator's code ("handoffCode")
+// Labelled deliveries require the scanned label payload ("labelScan")
 func (c *DeliveryContract) ConfirmHandoff(
 	ctx contractapi.TransactionContextInterface,
 	deliveryID string,
@@ -948,9 +1547,18 @@ func (c *DeliveryContract) ConfirmHandoff(
 	if err := validateDeliveryID(deliveryID); err != nil {
 		return err
 	}
+	city, state, country = normalizeLocation(city, state, country)
 	if err := validateLocation(city, state, country); err != nil {
 		return err
 	}
+	units, err := getMeasurementUnits(ctx)
+	if err != nil {
+		return err
+	}
+	packageWeight = units.toKilograms(packageWeight)
+	dimensionLength = units.toCentimeters(dimensionLength)
+	dimensionWidth = units.toCentimeters(dimensionWidth)
+	dimensionHeight = units.toCentimeters(dimensionHeight)
 	if err := validatePackageWeight(packageWeight); err != nil {
 		return err
 	}
@@ -971,7 +1579,7 @@ func (c *DeliveryContract) ConfirmHandoff(
 	}
 
 	// Validate role
-	if err := validateRole(caller, RoleDeliveryPerson, RoleCustomer); err != nil {
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse, RoleCustomer); err != nil {
 		return err
 	}
 
@@ -980,50 +1588,153 @@ func (c *DeliveryContract) ConfirmHandoff(
 		return err
 	}
 
+	// Couriers can't accept more packages than their configured capacity
+	if caller.Role == RoleDeliveryPerson {
+		if err := validateCourierCapacity(ctx, caller.ID, 1); err != nil {
+			return err
+		}
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	location := Location{
+		City:    city,
+		State:   state,
+		Country: country,
+	}
+	dimensions := PackageDimensions{
+		Length: dimensionLength,
+		Width:  dimensionWidth,
+		Height: dimensionHeight,
+	}
+	evidence, err := getHandoffEvidence(ctx, caller.ID, currentTime)
+	if err != nil {
+		return err
+	}
+	oldStatus, err := confirmHandoffInternal(ctx, caller, delivery, location, packageWeight, dimensions, evidence, currentTime)
+	if err != nil {
+		return err
+	}
+
+	// Optional per-item condition report supplied with the confirmation
+	if err := recordItemConditions(ctx, deliveryID, caller.ID, currentTime); err != nil {
+		return err
+	}
+
+	// Emit status change event
+	event := DeliveryEvent{
+		DeliveryID: deliveryID,
+		OrderID:    delivery.OrderID,
+		OldStatus:  oldStatus,
+		NewStatus:  delivery.DeliveryStatus,
+		Timestamp:  currentTime,
+	}
+	return emitEvent(ctx, EventDeliveryStatusChanged, event)
+}
+
+// confirmHandoffInternal validates and completes a pending handoff on an already-loaded delivery
+// Shared by ConfirmHandoff and the batch/manifest flows; events are left to the caller.
+// evidence, if any, is what the recipient captured and is kept with the initiator's.
+// Returns the status the delivery had before the confirmation.
+func confirmHandoffInternal(
+	ctx contractapi.TransactionContextInterface,
+	caller *CallerIdentity,
+	delivery *Delivery,
+	location Location,
+	packageWeight float64,
+	dimensions PackageDimensions,
+	evidence *HandoffEvidence,
+	currentTime string,
+) (DeliveryStatus, error) {
 	// Verify there's a pending handoff
 	if delivery.PendingHandoff == nil {
-		return fmt.Errorf("no pending handoff for this delivery")
+		return "", fmt.Errorf("no pending handoff for this delivery")
 	}
 
 	// Verify caller is the intended recipient
 	if delivery.PendingHandoff.ToUserID != caller.ID {
-		return fmt.Errorf("only the intended recipient can confirm the handoff")
+		return "", fmt.Errorf("only the intended recipient can confirm the handoff")
 	}
 
-	currentTime, err := getTxTimestamp(ctx)
-	if err != nil {
-		return err
+	// Code-protected handoffs need the code the initiator gave the recipient
+	if err := verifyHandoffCode(ctx, delivery, currentTime); err != nil {
+		return "", err
+	}
+
+	// Certified handling (hazmat, pharma, ...) requires matching certificate attributes
+	if err := validateHandlerCertifications(ctx, delivery, delivery.PendingHandoff.ToRole); err != nil {
+		return "", err
+	}
+
+	// Age-restricted packages need the courier's ID-check attestation on final delivery
+	if delivery.AgeRestricted && delivery.PendingHandoff.ToRole == RoleCustomer {
+		if err := storeAgeVerification(ctx, delivery, currentTime); err != nil {
+			return "", err
+		}
+	}
+
+	// Labelled packages are scanned by every recipient, catching swapped labels
+	if err := verifyLabelScan(ctx, delivery); err != nil {
+		return "", err
+	}
+
+	// Serialized (pharma) packages are scanned by every recipient
+	if err := verifySerializationScan(ctx, delivery, currentTime); err != nil {
+		return "", err
+	}
+
+	// Final delivery must happen near the destination (courier coordinates in transient data),
+	// with any cash on delivery collected
+	if delivery.PendingHandoff.ToRole == RoleCustomer {
+		if err := checkGeofence(ctx, delivery); err != nil {
+			return "", err
+		}
+		if err := validateCODSettled(delivery); err != nil {
+			return "", err
+		}
+	}
+
+	// Packages only change carrier through an INTERLINE handoff
+	if err := validateCarrierTransfer(delivery, caller); err != nil {
+		return "", err
+	}
+
+	// Re-measured weights that don't match count against whoever handed the package over
+	if err := checkWeightDiscrepancy(ctx, caller, delivery, packageWeight, currentTime); err != nil {
+		return "", err
 	}
 
 	// Update custody
 	handoff := delivery.PendingHandoff
 	oldStatus := delivery.DeliveryStatus
-	oldCustodian := delivery.CurrentCustodianID
+	if evidence != nil {
+		handoff.Evidence = append(handoff.Evidence, *evidence)
+	}
+	keepHandoffEvidence(delivery, handoff, oldStatus)
 
 	delivery.CurrentCustodianID = handoff.ToUserID
 	delivery.CurrentCustodianRole = handoff.ToRole
+	delivery.CustodianMSP = caller.MSP
+	if isLogisticsRole(handoff.ToRole) {
+		delivery.CarrierOfRecord = caller.MSP
+	}
 
 	// Clear pending handoff
 	delivery.PendingHandoff = nil
 
 	// Update location
-	delivery.LastLocation = Location{
-		City:    city,
-		State:   state,
-		Country: country,
-	}
+	delivery.LastLocation = location
 
 	// Update package dimensions and weight
 	delivery.PackageWeight = packageWeight
-	delivery.PackageDimensions = PackageDimensions{
-		Length: dimensionLength,
-		Width:  dimensionWidth,
-		Height: dimensionHeight,
-	}
+	delivery.PackageDimensions = dimensions
 
 	// Update delivery status based on new holder
 	switch handoff.ToRole {
-	case RoleDeliveryPerson:
+	case RoleDeliveryPerson, RoleWarehouse:
 		delivery.DeliveryStatus = StatusInTransit
 	case RoleCustomer:
 		delivery.DeliveryStatus = StatusConfirmedDelivery
@@ -1031,55 +1742,37 @@ func (c *DeliveryContract) ConfirmHandoff(
 
 	delivery.UpdatedAt = currentTime
 
-	deliveryJSON, err := json.Marshal(delivery)
-	if err != nil {
-		return fmt.Errorf("failed to marshal delivery: %v", err)
-	}
-
-	err = ctx.GetStub().PutState(deliveryID, deliveryJSON)
-	if err != nil {
-		return err
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return "", err
 	}
 
 	// Update state-based endorsement policy to reflect new custodian
 	// The new custodian's org must endorse any future state changes
-	if err := setDeliveryEndorsementPolicy(ctx, deliveryID, delivery.CurrentCustodianRole); err != nil {
-		return fmt.Errorf("failed to update endorsement policy: %v", err)
+	if err := setDeliveryEndorsementPolicy(ctx, delivery); err != nil {
+		return "", fmt.Errorf("failed to update endorsement policy: %v", err)
 	}
 
-	// Update composite key indexes
-	if err := updateCustodianIndex(ctx, delivery, oldCustodian, delivery.CurrentCustodianID); err != nil {
-		return fmt.Errorf("failed to update custodian index: %v", err)
-	}
-	if oldStatus != delivery.DeliveryStatus {
-		if err := updateStatusIndex(ctx, deliveryID, oldStatus, delivery.DeliveryStatus); err != nil {
-			return fmt.Errorf("failed to update status index: %v", err)
-		}
-	}
-
-	// Emit status change event
-	event := DeliveryEvent{
-		DeliveryID: deliveryID,
-		OrderID:    delivery.OrderID,
-		OldStatus:  oldStatus,
-		NewStatus:  delivery.DeliveryStatus,
-		Timestamp:  currentTime,
-	}
-	return emitEvent(ctx, EventDeliveryStatusChanged, event)
+	return oldStatus, nil
 }
 
 // DisputeHandoff disputes a pending custody transfer
-// The intended recipient (DELIVERY_PERSON or CUSTOMER) can dispute
+// The intended recipient (DELIVERY_PERSON, WAREHOUSE, or CUSTOMER) can dispute
+// with a DISPUTE reason code and optional free text
 func (c *DeliveryContract) DisputeHandoff(
 	ctx contractapi.TransactionContextInterface,
 	deliveryID string,
+	reasonCode string,
 	reason string,
 ) error {
 	// ========== INPUT VALIDATION ==========
 	if err := validateDeliveryID(deliveryID); err != nil {
 		return err
 	}
-	if err := validateReason(reason); err != nil {
+	if err := validateReasonCode(ctx, ReasonCategoryDispute, reasonCode); err != nil {
+		return err
+	}
+	reason = sanitizeText(reason)
+	if err := validateReasonDetails(reason); err != nil {
 		return err
 	}
 
@@ -1090,7 +1783,7 @@ func (c *DeliveryContract) DisputeHandoff(
 	}
 
 	// Validate role
-	if err := validateRole(caller, RoleDeliveryPerson, RoleCustomer); err != nil {
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse, RoleCustomer, RoleSupport); err != nil {
 		return err
 	}
 
@@ -1104,8 +1797,14 @@ func (c *DeliveryContract) DisputeHandoff(
 		return fmt.Errorf("no pending handoff for this delivery")
 	}
 
-	// Verify caller is the intended recipient
-	if delivery.PendingHandoff.ToUserID != caller.ID {
+	// Verify caller is the intended recipient, or SUPPORT acting for the receiving customer
+	onBehalfOf := ""
+	if caller.Role == RoleSupport {
+		if delivery.TenantID != caller.TenantID || delivery.PendingHandoff.ToUserID != delivery.CustomerID {
+			return fmt.Errorf("SUPPORT can only dispute a delivery confirmation on behalf of the customer")
+		}
+		onBehalfOf = delivery.CustomerID
+	} else if delivery.PendingHandoff.ToUserID != caller.ID {
 		return fmt.Errorf("only the intended recipient can dispute the handoff")
 	}
 
@@ -1115,6 +1814,20 @@ func (c *DeliveryContract) DisputeHandoff(
 	}
 	oldStatus := delivery.DeliveryStatus
 
+	interline := delivery.PendingHandoff.Type == HandoffTypeInterline
+
+	// Keep the dispute on the record so it can be resolved later
+	delivery.LastDispute = &DisputeInfo{
+		DisputeID:      ctx.GetStub().GetTxID(),
+		DisputedStatus: oldStatus,
+		FromUserID:     delivery.PendingHandoff.FromUserID,
+		DisputedBy:     caller.ID,
+		OnBehalfOf:     onBehalfOf,
+		ReasonCode:     reasonCode,
+		Reason:         reason,
+		DisputedAt:     currentTime,
+	}
+
 	// Clear pending handoff
 	delivery.PendingHandoff = nil
 
@@ -1130,43 +1843,39 @@ func (c *DeliveryContract) DisputeHandoff(
 
 	delivery.UpdatedAt = currentTime
 
-	deliveryJSON, err := json.Marshal(delivery)
-	if err != nil {
-		return fmt.Errorf("failed to marshal delivery: %v", err)
-	}
-
-	err = ctx.GetStub().PutState(deliveryID, deliveryJSON)
-	if err != nil {
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
 		return err
 	}
 
-	// Update status index
-	if err := updateStatusIndex(ctx, deliveryID, oldStatus, delivery.DeliveryStatus); err != nil {
-		return fmt.Errorf("failed to update status index: %v", err)
+	// The carrier that kept the package endorses alone again
+	if interline {
+		if err := setDeliveryEndorsementPolicy(ctx, delivery); err != nil {
+			return fmt.Errorf("failed to update endorsement policy: %v", err)
+		}
 	}
 
-	// Emit dispute event
-	event := DeliveryEvent{
-		DeliveryID: deliveryID,
-		OrderID:    delivery.OrderID,
-		OldStatus:  oldStatus,
-		NewStatus:  delivery.DeliveryStatus,
-		Timestamp:  currentTime,
-	}
-	if err := emitEvent(ctx, EventDeliveryStatusChanged, event); err != nil {
+	// Open a dispute case so it stays queryable after the delivery moves on
+	if err := openDisputeCase(ctx, delivery, delivery.LastDispute); err != nil {
 		return err
 	}
 
+	// Emit dispute event - Fabric keeps one event per transaction, so it carries the status change
 	return emitEvent(ctx, EventHandoffDisputed, map[string]string{
 		"deliveryId": deliveryID,
+		"orderId":    delivery.OrderID,
+		"oldStatus":  string(oldStatus),
+		"newStatus":  string(delivery.DeliveryStatus),
+		"disputeId":  delivery.LastDispute.DisputeID,
 		"disputedBy": caller.ID,
+		"onBehalfOf": onBehalfOf,
+		"reasonCode": reasonCode,
 		"reason":     reason,
 		"timestamp":  currentTime,
 	})
 }
 
 // CancelHandoff cancels a pending handoff (only initiator can cancel)
-// SELLER or DELIVERY_PERSON can cancel their own handoffs
+// SELLER, DELIVERY_PERSON, or WAREHOUSE can cancel their own handoffs
 func (c *DeliveryContract) CancelHandoff(
 	ctx contractapi.TransactionContextInterface,
 	deliveryID string,
@@ -1183,7 +1892,7 @@ func (c *DeliveryContract) CancelHandoff(
 	}
 
 	// Validate role
-	if err := validateRole(caller, RoleSeller, RoleDeliveryPerson); err != nil {
+	if err := validateRole(ctx, caller, RoleSeller, RoleDeliveryPerson, RoleWarehouse); err != nil {
 		return err
 	}
 
@@ -1208,6 +1917,8 @@ func (c *DeliveryContract) CancelHandoff(
 	}
 	oldStatus := delivery.DeliveryStatus
 
+	interline := delivery.PendingHandoff.Type == HandoffTypeInterline
+
 	// Clear pending handoff
 	delivery.PendingHandoff = nil
 
@@ -1223,21 +1934,19 @@ func (c *DeliveryContract) CancelHandoff(
 
 	delivery.UpdatedAt = currentTime
 
-	deliveryJSON, err := json.Marshal(delivery)
-	if err != nil {
-		return fmt.Errorf("failed to marshal delivery: %v", err)
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
 	}
 
-	err = ctx.GetStub().PutState(deliveryID, deliveryJSON)
-	if err != nil {
-		return err
+	// The carrier that kept the package endorses alone again
+	if interline {
+		if err := setDeliveryEndorsementPolicy(ctx, delivery); err != nil {
+			return fmt.Errorf("failed to update endorsement policy: %v", err)
+		}
 	}
 
 	// Update status index and emit event if status changed
 	if oldStatus != delivery.DeliveryStatus {
-		if err := updateStatusIndex(ctx, deliveryID, oldStatus, delivery.DeliveryStatus); err != nil {
-			return fmt.Errorf("failed to update status index: %v", err)
-		}
 		event := DeliveryEvent{
 			DeliveryID: deliveryID,
 			OrderID:    delivery.OrderID,
@@ -1252,15 +1961,24 @@ func (c *DeliveryContract) CancelHandoff(
 }
 
 // CancelDelivery cancels a delivery (only customer can cancel, before pickup)
-// Only CUSTOMER can cancel their own delivery
+// Only CUSTOMER can cancel their own delivery, with a CANCELLATION reason code and optional free text
 func (c *DeliveryContract) CancelDelivery(
 	ctx contractapi.TransactionContextInterface,
 	deliveryID string,
+	reasonCode string,
+	reason string,
 ) error {
 	// ========== INPUT VALIDATION ==========
 	if err := validateDeliveryID(deliveryID); err != nil {
 		return err
 	}
+	if err := validateReasonCode(ctx, ReasonCategoryCancellation, reasonCode); err != nil {
+		return err
+	}
+	reason = sanitizeText(reason)
+	if err := validateReasonDetails(reason); err != nil {
+		return err
+	}
 
 	// Extract caller identity from X.509 certificate
 	caller, err := getCallerIdentity(ctx)
@@ -1269,7 +1987,7 @@ func (c *DeliveryContract) CancelDelivery(
 	}
 
 	// Validate role - only CUSTOMER can cancel
-	if err := validateRole(caller, RoleCustomer); err != nil {
+	if err := validateRole(ctx, caller, RoleCustomer); err != nil {
 		return err
 	}
 
@@ -1295,21 +2013,19 @@ func (c *DeliveryContract) CancelDelivery(
 	oldStatus := delivery.DeliveryStatus
 
 	delivery.DeliveryStatus = StatusCancelled
-	delivery.UpdatedAt = currentTime
-
-	deliveryJSON, err := json.Marshal(delivery)
-	if err != nil {
-		return fmt.Errorf("failed to marshal delivery: %v", err)
+	delivery.Cancellation = &CancellationInfo{
+		ReasonCode:  reasonCode,
+		Reason:      reason,
+		CancelledBy: caller.ID,
+		CancelledAt: currentTime,
 	}
+	delivery.UpdatedAt = currentTime
 
-	err = ctx.GetStub().PutState(deliveryID, deliveryJSON)
-	if err != nil {
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
 		return err
 	}
-
-	// Update status index
-	if err := updateStatusIndex(ctx, deliveryID, oldStatus, delivery.DeliveryStatus); err != nil {
-		return fmt.Errorf("failed to update status index: %v", err)
+	if err := checkCancellationVelocity(ctx, delivery); err != nil {
+		return err
 	}
 
 	// Emit event
@@ -1336,7 +2052,7 @@ func (c *DeliveryContract) QueryDeliveriesByCustodian(
 	}
 
 	// Validate role
-	if err := validateRole(caller, RoleSeller, RoleDeliveryPerson, RoleCustomer, RoleAdmin); err != nil {
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
 		return nil, err
 	}
 
@@ -1391,6 +2107,7 @@ func (c *DeliveryContract) QueryDeliveriesByCustodian(
 			if err := json.Unmarshal(deliveryBytes, &delivery); err != nil {
 				continue
 			}
+			delivery.StateHash = deliveryStateHash(deliveryBytes)
 			deliveryMap[deliveryID] = &delivery
 		}
 		return nil
@@ -1425,6 +2142,7 @@ func (c *DeliveryContract) QueryDeliveriesByCustodian(
 				if err := json.Unmarshal(response.Value, &delivery); err != nil {
 					continue
 				}
+				delivery.StateHash = deliveryStateHash(response.Value)
 				deliveryMap[delivery.DeliveryID] = &delivery
 			}
 		}
@@ -1441,6 +2159,12 @@ func (c *DeliveryContract) QueryDeliveriesByCustodian(
 			return nil, err
 		}
 
+	case RoleWarehouse:
+		// Warehouses see deliveries currently held at the hub
+		if err := fetchByIndex(IndexCustodianDelivery, caller.ID); err != nil {
+			return nil, err
+		}
+
 	case RoleDeliveryPerson:
 		// Delivery persons see deliveries where they are current custodian
 		if err := fetchByIndex(IndexCustodianDelivery, caller.ID); err != nil {
@@ -1448,11 +2172,10 @@ func (c *DeliveryContract) QueryDeliveriesByCustodian(
 		}
 		// Also fetch deliveries where they are the pending handoff target
 		// Uses CouchDB rich query since we don't have a composite key index for this
-		pendingQuery := fmt.Sprintf(`{
-			"selector": {
-				"pendingHandoff.toUserId": "%s"
-			}
-		}`, caller.ID)
+		pendingQuery, err := newCouchQuery().Eq("pendingHandoff.toUserId", caller.ID).String()
+		if err != nil {
+			return nil, err
+		}
 		pendingIterator, err := ctx.GetStub().GetQueryResult(pendingQuery)
 		if err == nil {
 			defer pendingIterator.Close()
@@ -1494,7 +2217,7 @@ func (c *DeliveryContract) QueryDeliveriesByStatus(
 	}
 
 	// Validate role
-	if err := validateRole(caller, RoleSeller, RoleDeliveryPerson, RoleCustomer, RoleAdmin); err != nil {
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
 		return nil, err
 	}
 
@@ -1537,6 +2260,7 @@ func (c *DeliveryContract) QueryDeliveriesByStatus(
 		if err := json.Unmarshal(deliveryBytes, &delivery); err != nil {
 			continue
 		}
+		delivery.StateHash = deliveryStateHash(deliveryBytes)
 
 		// Admin sees all, others must be involved
 		if isAdmin {
@@ -1560,9 +2284,9 @@ func (c *DeliveryContract) GetDeliveryHistory(
 		return nil, fmt.Errorf("failed to get caller identity: %v", err)
 	}
 
-	// Validate role - only seller, customer, and admin can view history
-	if err := validateRole(caller, RoleSeller, RoleCustomer, RoleAdmin); err != nil {
-		return nil, fmt.Errorf("only seller, customer, or admin can view delivery history")
+	// Validate role - only seller, customer, admin and auditors can view history
+	if err := validateRole(ctx, caller, RoleSeller, RoleCustomer, RoleAdmin, RoleAuditor); err != nil {
+		return nil, fmt.Errorf("only seller, customer, admin or auditor can view delivery history")
 	}
 
 	// First, read current delivery to check involvement
@@ -1571,8 +2295,8 @@ func (c *DeliveryContract) GetDeliveryHistory(
 		return nil, err
 	}
 
-	// Validate caller is the seller, customer, or admin
-	if caller.Role != RoleAdmin {
+	// Validate caller is the seller, customer, admin or auditor
+	if caller.Role != RoleAdmin && caller.Role != RoleAuditor {
 		if delivery.SellerID != caller.ID && delivery.CustomerID != caller.ID {
 			return nil, fmt.Errorf("only the seller or customer of this delivery can view its history")
 		}
@@ -1636,17 +2360,27 @@ func (c *DeliveryContract) readDeliveryInternal(ctx contractapi.TransactionConte
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal delivery: %v", err)
 	}
+	if delivery.Tombstone != nil {
+		return nil, fmt.Errorf("delivery %s has been redacted", deliveryID)
+	}
 
 	return &delivery, nil
 }
 
-// QueryDeliveriesRich performs a CouchDB rich query using a selector
+// QueryDeliveriesRich performs a paginated CouchDB rich query using a selector
 // Only available when using CouchDB as the state database
-// Admin-only function for advanced queries
+// Admin-only function for advanced queries; pageSize is capped by the richQueryMaxResults setting
 func (c *DeliveryContract) QueryDeliveriesRich(
 	ctx contractapi.TransactionContextInterface,
 	queryString string,
-) ([]*Delivery, error) {
+	pageSize int,
+	bookmark string,
+) (*DeliveryQueryPage, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateRichQuery(queryString); err != nil {
+		return nil, err
+	}
+
 	// Extract caller identity from X.509 certificate
 	caller, err := getCallerIdentity(ctx)
 	if err != nil {
@@ -1654,23 +2388,26 @@ func (c *DeliveryContract) QueryDeliveriesRich(
 	}
 
 	// Rich queries are admin-only due to potential performance impact
-	if err := validateRole(caller, RoleAdmin); err != nil {
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
 		return nil, fmt.Errorf("rich queries are admin-only: %v", err)
 	}
 
-	// Validate query string is not empty
-	if queryString == "" {
-		return nil, fmt.Errorf("query string cannot be empty")
+	settings, err := getContractSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pageSize <= 0 || pageSize > settings.RichQueryMaxResults {
+		return nil, &ValidationError{Field: "pageSize", Message: fmt.Sprintf("must be between 1 and %d", settings.RichQueryMaxResults)}
 	}
 
 	// Execute the rich query
-	iterator, err := ctx.GetStub().GetQueryResult(queryString)
+	iterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, int32(pageSize), bookmark)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute rich query: %v", err)
 	}
 	defer iterator.Close()
 
-	var deliveries []*Delivery
+	deliveries := []*Delivery{}
 	for iterator.HasNext() {
 		response, err := iterator.Next()
 		if err != nil {
@@ -1691,7 +2428,11 @@ func (c *DeliveryContract) QueryDeliveriesRich(
 		deliveries = append(deliveries, &delivery)
 	}
 
-	return deliveries, nil
+	return &DeliveryQueryPage{
+		Deliveries:   deliveries,
+		Bookmark:     metadata.Bookmark,
+		FetchedCount: metadata.FetchedRecordsCount,
+	}, nil
 }
 
 // QueryDeliveriesByDateRange queries deliveries created within a date range
@@ -1708,7 +2449,7 @@ func (c *DeliveryContract) QueryDeliveriesByDateRange(
 	}
 
 	// Validate role
-	if err := validateRole(caller, RoleSeller, RoleDeliveryPerson, RoleCustomer, RoleAdmin); err != nil {
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
 		return nil, err
 	}
 
@@ -1718,17 +2459,16 @@ func (c *DeliveryContract) QueryDeliveriesByDateRange(
 	}
 
 	// Build CouchDB selector query
-	queryString := fmt.Sprintf(`{
-		"selector": {
-			"createdAt": {
-				"$gte": "%s",
-				"$lte": "%s"
-			},
-			"deliveryID": {"$gt": null}
-		},
-		"sort": [{"createdAt": "desc"}],
-		"use_index": ["_design/indexCreatedAtDoc", "indexCreatedAt"]
-	}`, startDate, endDate)
+	queryString, err := newCouchQuery().
+		Op("createdAt", "$gte", startDate).
+		Op("createdAt", "$lte", endDate).
+		Op("deliveryID", "$gt", nil).
+		Sort("createdAt", "desc").
+		UseIndex("indexCreatedAtDoc", "indexCreatedAt").
+		String()
+	if err != nil {
+		return nil, err
+	}
 
 	// Execute the query
 	iterator, err := ctx.GetStub().GetQueryResult(queryString)
@@ -1776,31 +2516,28 @@ func (c *DeliveryContract) QueryDeliveriesByLocation(
 	}
 
 	// Only admin and delivery persons can query by location
-	if err := validateRole(caller, RoleDeliveryPerson, RoleAdmin); err != nil {
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleAdmin); err != nil {
 		return nil, fmt.Errorf("only delivery persons and admin can query by location")
 	}
 
-	// Build selector based on provided filters
-	var selectorParts []string
-	selectorParts = append(selectorParts, `"deliveryID": {"$gt": null}`)
+	if city == "" && state == "" {
+		return nil, fmt.Errorf("at least one of city or state is required")
+	}
+	city, state, _ = normalizeLocation(city, state, "")
 
+	// Build selector based on provided filters
+	query := newCouchQuery().Op("deliveryID", "$gt", nil)
 	if city != "" {
-		selectorParts = append(selectorParts, fmt.Sprintf(`"deliveryAddress.city": "%s"`, city))
+		query.Eq("deliveryAddress.city", city)
 	}
 	if state != "" {
-		selectorParts = append(selectorParts, fmt.Sprintf(`"deliveryAddress.state": "%s"`, state))
+		query.Eq("deliveryAddress.state", state)
 	}
-
-	if city == "" && state == "" {
-		return nil, fmt.Errorf("at least one of city or state is required")
+	queryString, err := query.String()
+	if err != nil {
+		return nil, err
 	}
 
-	queryString := fmt.Sprintf(`{
-		"selector": {
-			%s
-		}
-	}`, strings.Join(selectorParts, ", "))
-
 	// Execute the query
 	iterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
@@ -1833,8 +2570,20 @@ func (c *DeliveryContract) QueryDeliveriesByLocation(
 
 // GetCallerInfo returns the caller's identity information (for debugging/verification)
 // This is useful for the API to verify that the identity is being properly extracted
+// Every role may call it; Role is the built-in role the caller acts as.
 func (c *DeliveryContract) GetCallerInfo(ctx contractapi.TransactionContextInterface) (*CallerIdentity, error) {
-	return getCallerIdentity(ctx)
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, allRoles...); err != nil {
+		return nil, err
+	}
+
+	return caller, nil
 }
 
 // =====================================================
@@ -1853,6 +2602,11 @@ func (c *DeliveryContract) SetDeliveryPrivateDetails(
 		return fmt.Errorf("failed to get caller identity: %v", err)
 	}
 
+	// Validate role
+	if err := validateRole(ctx, caller, RoleSeller, RoleCustomer, RoleAdmin); err != nil {
+		return err
+	}
+
 	// Only PlatformOrg and SellersOrg can set private details
 	if caller.MSP != "PlatformOrgMSP" && caller.MSP != "SellersOrgMSP" {
 		return fmt.Errorf("only PlatformOrg and SellersOrg can set delivery private details")
@@ -1866,6 +2620,10 @@ func (c *DeliveryContract) SetDeliveryPrivateDetails(
 	if deliveryBytes == nil {
 		return fmt.Errorf("delivery %s does not exist", deliveryID)
 	}
+	var delivery Delivery
+	if err := json.Unmarshal(deliveryBytes, &delivery); err != nil {
+		return fmt.Errorf("failed to unmarshal delivery: %v", err)
+	}
 
 	// Get private data from transient map
 	transientMap, err := ctx.GetStub().GetTransient()
@@ -1883,6 +2641,14 @@ func (c *DeliveryContract) SetDeliveryPrivateDetails(
 	if err := json.Unmarshal(privateDataJSON, &privateDetails); err != nil {
 		return fmt.Errorf("failed to parse private details: %v", err)
 	}
+	if err := validateRecipientIdentityHash(privateDetails.RecipientIdentityHash); err != nil {
+		return err
+	}
+	// Postal codes follow the format of the destination country
+	privateDetails.DeliveryPostalCode = normalizePostalCode(privateDetails.DeliveryPostalCode)
+	if err := validatePostalCode(privateDetails.DeliveryPostalCode, delivery.LastLocation.Country); err != nil {
+		return err
+	}
 
 	// Set the delivery ID
 	privateDetails.DeliveryID = deliveryID
@@ -1897,7 +2663,28 @@ func (c *DeliveryContract) SetDeliveryPrivateDetails(
 		return fmt.Errorf("failed to store private details: %v", err)
 	}
 
-	return nil
+	// Later rewrites need both the seller's org and the platform
+	if err := setPrivateDetailsEndorsementPolicy(ctx, deliveryID); err != nil {
+		return err
+	}
+	if err := checkAddressReuse(ctx, &delivery, &privateDetails); err != nil {
+		return err
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Only the hash is published; it matches the collection's on-chain private data hash
+	return emitEvent(ctx, EventPrivateDetailsUpdated, map[string]string{
+		"deliveryId":   deliveryID,
+		"dataHash":     fmt.Sprintf("%x", sha256.Sum256(privateDetailsBytes)),
+		"txId":         ctx.GetStub().GetTxID(),
+		"updatedBy":    caller.ID,
+		"updatedByMsp": caller.MSP,
+		"timestamp":    currentTime,
+	})
 }
 
 // GetDeliveryPrivateDetails retrieves sensitive delivery information from private data collection
@@ -1911,6 +2698,12 @@ func (c *DeliveryContract) GetDeliveryPrivateDetails(
 		return nil, fmt.Errorf("failed to get caller identity: %v", err)
 	}
 
+	// Validate role - auditors can't log the access (LogPrivateAccess writes), so they don't get
+	// the address
+	if err := validateRole(ctx, caller, participantRoles...); err != nil {
+		return nil, err
+	}
+
 	// All orgs can read private details (they need delivery address)
 	if caller.MSP != "PlatformOrgMSP" && caller.MSP != "SellersOrgMSP" && caller.MSP != "LogisticsOrgMSP" {
 		return nil, fmt.Errorf("only PlatformOrg, SellersOrg, and LogisticsOrg can read delivery private details")
@@ -1932,6 +2725,27 @@ func (c *DeliveryContract) GetDeliveryPrivateDetails(
 	return &privateDetails, nil
 }
 
+// validatePrivateDetailsSet rejects a courier handoff of a delivery whose address was never set
+// Only enforced when the requirePrivateDetailsForCourier setting is on. The hash is public, so
+// any org's peer can check it without being a member of the collection.
+func validatePrivateDetailsSet(ctx contractapi.TransactionContextInterface, deliveryID string) error {
+	settings, err := getContractSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if !settings.RequirePrivateDetailsForCourier {
+		return nil
+	}
+	hashBytes, err := ctx.GetStub().GetPrivateDataHash(CollectionDeliveryPrivate, deliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to get private data hash: %v", err)
+	}
+	if hashBytes == nil {
+		return fmt.Errorf("private details of delivery %s must be set before handing it to a courier", deliveryID)
+	}
+	return nil
+}
+
 // VerifyDeliveryPrivateDataHash verifies that a hash matches the stored private data
 // This allows LogisticsOrg to verify data without seeing the content
 func (c *DeliveryContract) VerifyDeliveryPrivateDataHash(
@@ -1939,6 +2753,17 @@ func (c *DeliveryContract) VerifyDeliveryPrivateDataHash(
 	deliveryID string,
 	expectedHash string,
 ) (bool, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return false, err
+	}
+
 	hashBytes, err := ctx.GetStub().GetPrivateDataHash(CollectionDeliveryPrivate, deliveryID)
 	if err != nil {
 		return false, fmt.Errorf("failed to get private data hash: %v", err)
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxDeltaPageSize bounds the change records returned by one ExportDeliveriesDelta call
+const maxDeltaPageSize = 500
+
+// DeliveryChange is the compact change record exported to analytics stores
+type DeliveryChange struct {
+	DeliveryID     string         `json:"deliveryId"`
+	OrderID        string         `json:"orderId,omitempty" metadata:",optional"`
+	DeliveryStatus DeliveryStatus `json:"deliveryStatus"`
+	UpdatedAt      string         `json:"updatedAt"`
+	CustodianMSP   string         `json:"custodianMsp,omitempty" metadata:",optional"`
+}
+
+// DeliveryDeltaPage is one page of an ExportDeliveriesDelta sync
+// Checkpoint is the latest updatedAt in the page; store it as the next sinceTimestamp
+// once Bookmark comes back empty.
+type DeliveryDeltaPage struct {
+	Changes    []*DeliveryChange `json:"changes"`
+	Bookmark   string            `json:"bookmark"`
+	Checkpoint string            `json:"checkpoint,omitempty" metadata:",optional"`
+}
+
+// ExportDeliveriesDelta returns deliveries modified at or after sinceTimestamp, oldest first
+// Only ADMIN can export. Uses a CouchDB rich query on updatedAt; deliveries updated exactly at
+// the checkpoint are returned again, so consumers should upsert by deliveryId.
+// Redacted deliveries are included so consumers can drop their copies.
+func (c *DeliveryContract) ExportDeliveriesDelta(
+	ctx contractapi.TransactionContextInterface,
+	sinceTimestamp string,
+	pageSize int,
+	bookmark string,
+) (*DeliveryDeltaPage, error) {
+	// ========== INPUT VALIDATION ==========
+	since, err := time.Parse(time.RFC3339, sinceTimestamp)
+	if err != nil {
+		return nil, &ValidationError{Field: "sinceTimestamp", Message: "must be an RFC 3339 timestamp"}
+	}
+	if pageSize <= 0 || pageSize > maxDeltaPageSize {
+		return nil, &ValidationError{Field: "pageSize", Message: fmt.Sprintf("must be between 1 and %d", maxDeltaPageSize)}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can export
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	// Timestamps are stored as UTC RFC 3339, so string order is time order.
+	// The tenant condition keeps pages full; the tenant stub filters results as well.
+	query := newCouchQuery().
+		Op("updatedAt", "$gte", since.UTC().Format(time.RFC3339)).
+		Op("deliveryId", "$exists", true).
+		Op("deliveryStatus", "$exists", true).
+		Sort("updatedAt", "asc").
+		UseIndex("indexUpdatedAtDoc", "indexUpdatedAt")
+	if caller.TenantID == "" {
+		query.Op("tenantId", "$exists", false)
+	} else {
+		query.Eq("tenantId", caller.TenantID)
+	}
+	queryString, err := query.String()
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute delta query: %v", err)
+	}
+	defer iterator.Close()
+
+	page := &DeliveryDeltaPage{Changes: []*DeliveryChange{}}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate delta query: %v", err)
+		}
+
+		var delivery Delivery
+		if err := json.Unmarshal(response.Value, &delivery); err != nil || delivery.DeliveryID == "" {
+			continue
+		}
+
+		page.Changes = append(page.Changes, &DeliveryChange{
+			DeliveryID:     delivery.DeliveryID,
+			OrderID:        delivery.OrderID,
+			DeliveryStatus: delivery.DeliveryStatus,
+			UpdatedAt:      delivery.UpdatedAt,
+			CustodianMSP:   custodianMSPOf(&delivery),
+		})
+		page.Checkpoint = delivery.UpdatedAt
+	}
+
+	// A short page means the sync has caught up
+	if int(metadata.FetchedRecordsCount) == pageSize {
+		page.Bookmark = metadata.Bookmark
+	}
+
+	return page, nil
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// DisputeCaseState is the lifecycle state of a dispute case
+type DisputeCaseState string
+
+const (
+	DisputeCaseOpen     DisputeCaseState = "OPEN"
+	DisputeCaseResolved DisputeCaseState = "RESOLVED"
+)
+
+// Composite key prefixes for dispute cases and their indexes
+const (
+	RecordDisputeCase    = "disputeCase~disputeId"
+	IndexDeliveryDispute = "deliveryDispute~deliveryId~disputeId"
+	IndexDisputeState    = "disputeState~state~disputeId"
+)
+
+// DisputeCase is one dispute raised against a delivery, kept after it is resolved
+// The dispute ID is the ID of the transaction that opened it.
+type DisputeCase struct {
+	DisputeID      string            `json:"disputeId"`
+	DeliveryID     string            `json:"deliveryId"`
+	DisputedStatus DeliveryStatus    `json:"disputedStatus"`
+	OpenedBy       string            `json:"openedBy"`
+	Respondent     string            `json:"respondent"`
+	ReasonCode     string            `json:"reasonCode,omitempty" metadata:",optional"`
+	Reason         string            `json:"reason"`
+	State          DisputeCaseState  `json:"state"`
+	OpenedAt       string            `json:"openedAt"`
+	Deadline       string            `json:"deadline"`
+	Overdue        bool              `json:"overdue,omitempty" metadata:",optional"`
+	Resolution     DisputeResolution `json:"resolution,omitempty" metadata:",optional"`
+	Outcome        string            `json:"outcome,omitempty" metadata:",optional"`
+	ResolvedBy     string            `json:"resolvedBy,omitempty" metadata:",optional"`
+	ResolvedAt     string            `json:"resolvedAt,omitempty" metadata:",optional"`
+}
+
+// DisputeCasePage is one page of dispute cases in a given state
+type DisputeCasePage struct {
+	Cases    []*DisputeCase `json:"cases"`
+	Bookmark string         `json:"bookmark"`
+}
+
+// getDisputeCase reads a dispute case by ID
+func getDisputeCase(ctx contractapi.TransactionContextInterface, disputeID string) (*DisputeCase, error) {
+	caseKey, err := ctx.GetStub().CreateCompositeKey(RecordDisputeCase, []string{disputeID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dispute case composite key: %v", err)
+	}
+	caseJSON, err := ctx.GetStub().GetState(caseKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dispute case: %v", err)
+	}
+	if caseJSON == nil {
+		return nil, fmt.Errorf("dispute case %s does not exist", disputeID)
+	}
+
+	var disputeCase DisputeCase
+	if err := json.Unmarshal(caseJSON, &disputeCase); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dispute case: %v", err)
+	}
+	return &disputeCase, nil
+}
+
+// putDisputeCase writes a dispute case and moves its state index entry
+func putDisputeCase(ctx contractapi.TransactionContextInterface, disputeCase *DisputeCase, oldState DisputeCaseState) error {
+	stub := ctx.GetStub()
+
+	caseKey, err := stub.CreateCompositeKey(RecordDisputeCase, []string{disputeCase.DisputeID})
+	if err != nil {
+		return fmt.Errorf("failed to create dispute case composite key: %v", err)
+	}
+	caseJSON, err := json.Marshal(disputeCase)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dispute case: %v", err)
+	}
+	if err := stub.PutState(caseKey, caseJSON); err != nil {
+		return fmt.Errorf("failed to put dispute case: %v", err)
+	}
+
+	if oldState == "" {
+		deliveryKey, err := stub.CreateCompositeKey(IndexDeliveryDispute, []string{disputeCase.DeliveryID, disputeCase.DisputeID})
+		if err != nil {
+			return fmt.Errorf("failed to create delivery dispute composite key: %v", err)
+		}
+		if err := stub.PutState(deliveryKey, []byte{0x00}); err != nil {
+			return fmt.Errorf("failed to put delivery dispute index: %v", err)
+		}
+	} else if oldState != disputeCase.State {
+		oldStateKey, err := stub.CreateCompositeKey(IndexDisputeState, []string{string(oldState), disputeCase.DisputeID})
+		if err != nil {
+			return fmt.Errorf("failed to create dispute state composite key: %v", err)
+		}
+		if err := stub.DelState(oldStateKey); err != nil {
+			return fmt.Errorf("failed to delete dispute state index: %v", err)
+		}
+	}
+
+	stateKey, err := stub.CreateCompositeKey(IndexDisputeState, []string{string(disputeCase.State), disputeCase.DisputeID})
+	if err != nil {
+		return fmt.Errorf("failed to create dispute state composite key: %v", err)
+	}
+	return stub.PutState(stateKey, []byte{0x00})
+}
+
+// openDisputeCase creates the case for a dispute just raised on a delivery
+// Its deadline is the configured dispute resolution window after opening.
+func openDisputeCase(ctx contractapi.TransactionContextInterface, delivery *Delivery, dispute *DisputeInfo) error {
+	settings, err := getContractSettings(ctx)
+	if err != nil {
+		return err
+	}
+	openedAt, err := time.Parse(time.RFC3339, dispute.DisputedAt)
+	if err != nil {
+		return fmt.Errorf("failed to parse dispute time: %v", err)
+	}
+
+	disputeCase := &DisputeCase{
+		DisputeID:      dispute.DisputeID,
+		DeliveryID:     delivery.DeliveryID,
+		DisputedStatus: dispute.DisputedStatus,
+		OpenedBy:       dispute.DisputedBy,
+		Respondent:     dispute.FromUserID,
+		ReasonCode:     dispute.ReasonCode,
+		Reason:         dispute.Reason,
+		State:          DisputeCaseOpen,
+		OpenedAt:       dispute.DisputedAt,
+		Deadline:       openedAt.Add(time.Duration(settings.DisputeResolutionHours) * time.Hour).Format(time.RFC3339),
+	}
+	return putDisputeCase(ctx, disputeCase, "")
+}
+
+// resolveDisputeCase closes the case behind a delivery's last dispute
+// Disputes recorded before cases existed have no dispute ID and are skipped.
+func resolveDisputeCase(ctx contractapi.TransactionContextInterface, dispute *DisputeInfo) error {
+	if dispute.DisputeID == "" {
+		return nil
+	}
+	disputeCase, err := getDisputeCase(ctx, dispute.DisputeID)
+	if err != nil {
+		return err
+	}
+
+	disputeCase.State = DisputeCaseResolved
+	disputeCase.Resolution = dispute.Resolution
+	disputeCase.Outcome = dispute.Outcome
+	disputeCase.ResolvedBy = dispute.ResolvedBy
+	disputeCase.ResolvedAt = dispute.ResolvedAt
+	return putDisputeCase(ctx, disputeCase, DisputeCaseOpen)
+}
+
+// deleteDisputeCases removes every dispute case of a delivery along with its indexes
+func deleteDisputeCases(ctx contractapi.TransactionContextInterface, deliveryID string) error {
+	stub := ctx.GetStub()
+	iterator, err := stub.GetStateByPartialCompositeKey(IndexDeliveryDispute, []string{deliveryID})
+	if err != nil {
+		return fmt.Errorf("failed to get dispute cases: %v", err)
+	}
+	var indexKeys, disputeIDs []string
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			iterator.Close()
+			return fmt.Errorf("failed to iterate dispute cases: %v", err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(response.Key)
+		if err != nil || len(attrs) != 2 {
+			continue
+		}
+		indexKeys = append(indexKeys, response.Key)
+		disputeIDs = append(disputeIDs, attrs[1])
+	}
+	iterator.Close()
+
+	for i, disputeID := range disputeIDs {
+		disputeCase, err := getDisputeCase(ctx, disputeID)
+		if err != nil {
+			return err
+		}
+		stateKey, err := stub.CreateCompositeKey(IndexDisputeState, []string{string(disputeCase.State), disputeID})
+		if err != nil {
+			return fmt.Errorf("failed to create dispute state composite key: %v", err)
+		}
+		caseKey, err := stub.CreateCompositeKey(RecordDisputeCase, []string{disputeID})
+		if err != nil {
+			return fmt.Errorf("failed to create dispute case composite key: %v", err)
+		}
+		for _, key := range []string{stateKey, caseKey, indexKeys[i]} {
+			if err := stub.DelState(key); err != nil {
+				return fmt.Errorf("failed to delete dispute case %s: %v", disputeID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// markOverdue flags an open case whose deadline has passed at the given time
+func (d *DisputeCase) markOverdue(now time.Time) {
+	if d.State != DisputeCaseOpen {
+		return
+	}
+	if deadline, err := time.Parse(time.RFC3339, d.Deadline); err == nil && now.After(deadline) {
+		d.Overdue = true
+	}
+}
+
+// GetDisputeCases returns every dispute raised against a delivery, open or resolved
+// Involved parties and ADMIN can read the cases
+func (c *DeliveryContract) GetDisputeCases(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*DisputeCase, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	iterator, err := stub.GetStateByPartialCompositeKey(IndexDeliveryDispute, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dispute cases: %v", err)
+	}
+	defer iterator.Close()
+
+	cases := []*DisputeCase{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate dispute cases: %v", err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(response.Key)
+		if err != nil || len(attrs) != 2 {
+			continue
+		}
+
+		disputeCase, err := getDisputeCase(ctx, attrs[1])
+		if err != nil {
+			return nil, err
+		}
+		disputeCase.markOverdue(txTime)
+		cases = append(cases, disputeCase)
+	}
+
+	return cases, nil
+}
+
+// QueryDisputeCasesByState returns a page of dispute cases in one lifecycle state
+// Only ADMIN can query across deliveries; open cases past their deadline are flagged overdue
+func (c *DeliveryContract) QueryDisputeCasesByState(
+	ctx contractapi.TransactionContextInterface,
+	state string,
+	pageSize int,
+	bookmark string,
+) (*DisputeCasePage, error) {
+	// ========== INPUT VALIDATION ==========
+	switch DisputeCaseState(state) {
+	case DisputeCaseOpen, DisputeCaseResolved:
+	default:
+		return nil, &ValidationError{Field: "state", Message: fmt.Sprintf("unknown dispute case state: %s", state)}
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		return nil, &ValidationError{Field: "pageSize", Message: "must be between 1 and 100"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can query dispute cases across deliveries
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	iterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(IndexDisputeState, []string{state}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dispute cases: %v", err)
+	}
+	defer iterator.Close()
+
+	page := &DisputeCasePage{Cases: []*DisputeCase{}, Bookmark: metadata.Bookmark}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate dispute cases: %v", err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(response.Key)
+		if err != nil || len(attrs) != 2 {
+			continue
+		}
+
+		disputeCase, err := getDisputeCase(ctx, attrs[1])
+		if err != nil {
+			continue
+		}
+		disputeCase.markOverdue(txTime)
+		page.Cases = append(page.Cases, disputeCase)
+	}
+
+	return page, nil
+}
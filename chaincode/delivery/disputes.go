@@ -0,0 +1,450 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Event names for dispute handling
+const (
+	EventPickupReoffered = "PickupReoffered"
+	EventDisputeResolved = "DisputeResolved"
+	EventDeliveryRetried = "DeliveryRetried"
+)
+
+// DisputeResolution is the admin decision on a disputed handoff
+type DisputeResolution string
+
+const (
+	ResolutionRedeliver      DisputeResolution = "REDELIVER"
+	ResolutionReturnToSeller DisputeResolution = "RETURN_TO_SELLER"
+	ResolutionNoAction       DisputeResolution = "NO_ACTION"
+	ResolutionReoffered      DisputeResolution = "REOFFERED"
+)
+
+// disputedStatuses lists the statuses a dispute can leave a delivery in
+var disputedStatuses = map[DeliveryStatus]bool{
+	StatusDisputedPickupHandoff:  true,
+	StatusDisputedTransitHandoff: true,
+	StatusDisputedDelivery:       true,
+}
+
+// DisputeInfo records the most recent handoff dispute and how it was resolved
+type DisputeInfo struct {
+	DisputeID      string            `json:"disputeId,omitempty" metadata:",optional"`
+	DisputedStatus DeliveryStatus    `json:"disputedStatus"`
+	FromUserID     string            `json:"fromUserId"`
+	DisputedBy     string            `json:"disputedBy"`
+	OnBehalfOf     string            `json:"onBehalfOf,omitempty" metadata:",optional"`
+	ReasonCode     string            `json:"reasonCode,omitempty" metadata:",optional"`
+	Reason         string            `json:"reason"`
+	DisputedAt     string            `json:"disputedAt"`
+	Resolution     DisputeResolution `json:"resolution,omitempty" metadata:",optional"`
+	Outcome        string            `json:"outcome,omitempty" metadata:",optional"`
+	ResolvedBy     string            `json:"resolvedBy,omitempty" metadata:",optional"`
+	ResolvedAt     string            `json:"resolvedAt,omitempty" metadata:",optional"`
+}
+
+// DisputeSummary is an open dispute as shown on the support dashboard
+type DisputeSummary struct {
+	DisputeID      string         `json:"disputeId,omitempty" metadata:",optional"`
+	DeliveryID     string         `json:"deliveryId"`
+	OrderID        string         `json:"orderId"`
+	DeliveryStatus DeliveryStatus `json:"deliveryStatus"`
+	SellerID       string         `json:"sellerId"`
+	CustomerID     string         `json:"customerId"`
+	FromUserID     string         `json:"fromUserId"`
+	DisputedBy     string         `json:"disputedBy"`
+	ReasonCode     string         `json:"reasonCode,omitempty" metadata:",optional"`
+	Reason         string         `json:"reason"`
+	DisputedAt     string         `json:"disputedAt"`
+	AgeHours       int            `json:"ageHours"`
+}
+
+// DisputeQueuePage is one page of the open-dispute queue
+type DisputeQueuePage struct {
+	Disputes []*DisputeSummary `json:"disputes"`
+	Bookmark string            `json:"bookmark"`
+}
+
+// updateDisputeIndex adds or removes a delivery from the open-dispute queue on a status transition
+func updateDisputeIndex(ctx contractapi.TransactionContextInterface, deliveryID string, oldStatus, newStatus DeliveryStatus) error {
+	if disputedStatuses[newStatus] {
+		key, err := ctx.GetStub().CreateCompositeKey(IndexDisputedDelivery, []string{deliveryID})
+		if err != nil {
+			return fmt.Errorf("failed to create disputed composite key: %v", err)
+		}
+		if err := ctx.GetStub().PutState(key, []byte{0x00}); err != nil {
+			return fmt.Errorf("failed to put disputed index: %v", err)
+		}
+		return nil
+	}
+	if disputedStatuses[oldStatus] {
+		return removeFromDisputeQueue(ctx, deliveryID)
+	}
+	return nil
+}
+
+// removeFromDisputeQueue deletes a delivery from the open-dispute queue
+func removeFromDisputeQueue(ctx contractapi.TransactionContextInterface, deliveryID string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(IndexDisputedDelivery, []string{deliveryID})
+	if err != nil {
+		return fmt.Errorf("failed to create disputed composite key: %v", err)
+	}
+	if err := ctx.GetStub().DelState(key); err != nil {
+		return fmt.Errorf("failed to delete disputed index: %v", err)
+	}
+	return nil
+}
+
+// ReofferPickup clears a disputed pickup handoff so a new courier can be assigned
+// Only the SELLER of the delivery can re-offer, recording the dispute outcome
+func (c *DeliveryContract) ReofferPickup(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	outcome string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	outcome = sanitizeText(outcome)
+	if err := validateReason(outcome); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER can re-offer a pickup
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	if delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller can re-offer this delivery")
+	}
+	if delivery.DeliveryStatus != StatusDisputedPickupHandoff {
+		return fmt.Errorf("can only re-offer a delivery with a disputed pickup handoff")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	oldStatus := delivery.DeliveryStatus
+
+	// Records created before disputes were tracked have no dispute info
+	if delivery.LastDispute == nil {
+		delivery.LastDispute = &DisputeInfo{DisputedStatus: StatusPendingPickupHandoff}
+	}
+	delivery.LastDispute.Resolution = ResolutionReoffered
+	delivery.LastDispute.Outcome = outcome
+	delivery.LastDispute.ResolvedBy = caller.ID
+	delivery.LastDispute.ResolvedAt = currentTime
+	delivery.DeliveryStatus = StatusPendingPickup
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	if err := resolveDisputeCase(ctx, delivery.LastDispute); err != nil {
+		return err
+	}
+
+	// Fabric keeps one event per transaction, so the re-offer event carries the status change
+	return emitEvent(ctx, EventPickupReoffered, map[string]string{
+		"deliveryId": deliveryID,
+		"orderId":    delivery.OrderID,
+		"oldStatus":  string(oldStatus),
+		"newStatus":  string(delivery.DeliveryStatus),
+		"sellerId":   caller.ID,
+		"outcome":    outcome,
+		"timestamp":  currentTime,
+	})
+}
+
+// ResolveDispute records the admin decision on a disputed handoff
+// Only ADMIN can resolve. A disputed transit handoff or delivery leaves the package with the
+// custodian that offered it: REDELIVER waits for RetryDelivery, NO_ACTION returns the delivery
+// to IN_TRANSIT, and RETURN_TO_SELLER moves it to RECALL_PENDING for the courier to acknowledge.
+// Pickup disputes keep their status until the seller re-offers the pickup.
+func (c *DeliveryContract) ResolveDispute(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	resolution string,
+	outcome string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	switch DisputeResolution(resolution) {
+	case ResolutionRedeliver, ResolutionReturnToSeller, ResolutionNoAction:
+	default:
+		return &ValidationError{Field: "resolution", Message: fmt.Sprintf("unknown dispute resolution: %s", resolution)}
+	}
+	outcome = sanitizeText(outcome)
+	if err := validateReason(outcome); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can resolve disputes
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	if !disputedStatuses[delivery.DeliveryStatus] {
+		return fmt.Errorf("delivery %s is not in a disputed status", deliveryID)
+	}
+
+	// A pickup dispute can't be redelivered - the seller re-offers instead
+	pickupDispute := delivery.DeliveryStatus == StatusDisputedPickupHandoff
+	if pickupDispute && DisputeResolution(resolution) == ResolutionRedeliver {
+		return fmt.Errorf("pickup disputes are resolved by the seller re-offering the pickup")
+	}
+	// Only a courier can acknowledge the recall that carries the package back
+	if !pickupDispute && DisputeResolution(resolution) == ResolutionReturnToSeller && delivery.CurrentCustodianRole != RoleDeliveryPerson {
+		return fmt.Errorf("only a delivery held by a DELIVERY_PERSON can be returned to the seller")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The dispute is resolved in place, so the snapshot gets its own copy
+	before := *delivery
+	if delivery.LastDispute != nil {
+		dispute := *delivery.LastDispute
+		before.LastDispute = &dispute
+	}
+
+	// Records created before disputes were tracked have no dispute info
+	if delivery.LastDispute == nil {
+		delivery.LastDispute = &DisputeInfo{DisputedStatus: delivery.DeliveryStatus}
+	}
+	delivery.LastDispute.Resolution = DisputeResolution(resolution)
+	delivery.LastDispute.Outcome = outcome
+	delivery.LastDispute.ResolvedBy = caller.ID
+	delivery.LastDispute.ResolvedAt = currentTime
+	oldStatus := delivery.DeliveryStatus
+	if !pickupDispute {
+		switch DisputeResolution(resolution) {
+		case ResolutionNoAction:
+			delivery.DeliveryStatus = StatusInTransit
+		case ResolutionReturnToSeller:
+			delivery.Recall = &RecallInfo{
+				RequestedBy: caller.ID,
+				Reason:      outcome,
+				RequestedAt: currentTime,
+			}
+			delivery.DeliveryStatus = StatusRecallPending
+		}
+	}
+	delivery.UpdatedAt = currentTime
+
+	if err := recordCorrection(ctx, "ResolveDispute", outcome, &before, delivery, caller); err != nil {
+		return err
+	}
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	// A resolved dispute is no longer open, even while the follow-up is pending
+	if err := removeFromDisputeQueue(ctx, deliveryID); err != nil {
+		return err
+	}
+	if err := resolveDisputeCase(ctx, delivery.LastDispute); err != nil {
+		return err
+	}
+	if err := recordDisputeFaultPenalty(ctx, delivery); err != nil {
+		return err
+	}
+	claim, err := openInsuranceClaim(ctx, delivery)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]string{
+		"deliveryId": deliveryID,
+		"orderId":    delivery.OrderID,
+		"oldStatus":  string(oldStatus),
+		"newStatus":  string(delivery.DeliveryStatus),
+		"disputeId":  delivery.LastDispute.DisputeID,
+		"resolution": resolution,
+		"outcome":    outcome,
+		"resolvedBy": caller.ID,
+		"timestamp":  currentTime,
+	}
+	if claim != nil {
+		payload["insurer"] = claim.Insurer
+		payload["custodyDigest"] = claim.CustodyDigest.Digest
+	}
+	return emitEvent(ctx, EventDisputeResolved, payload)
+}
+
+// RetryDelivery returns a disputed delivery to IN_TRANSIT for another attempt
+// The current DELIVERY_PERSON custodian (or ADMIN) can retry once the dispute
+// was resolved with REDELIVER. Only a disputed delivery confirmation counts as an attempt;
+// a disputed transit handoff is simply offered again.
+func (c *DeliveryContract) RetryDelivery(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleAdmin); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	if caller.Role == RoleDeliveryPerson && delivery.CurrentCustodianID != caller.ID {
+		return fmt.Errorf("only the current custodian can retry this delivery")
+	}
+	if delivery.DeliveryStatus != StatusDisputedDelivery && delivery.DeliveryStatus != StatusDisputedTransitHandoff {
+		return fmt.Errorf("can only retry a delivery with a disputed transit handoff or delivery confirmation")
+	}
+	if delivery.LastDispute == nil || delivery.LastDispute.Resolution != ResolutionRedeliver {
+		return fmt.Errorf("dispute must be resolved with %s before retrying", ResolutionRedeliver)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	oldStatus := delivery.DeliveryStatus
+
+	if delivery.DeliveryStatus == StatusDisputedDelivery {
+		delivery.DeliveryAttempts++
+	}
+	delivery.DeliveryStatus = StatusInTransit
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	// Fabric keeps one event per transaction, so the retry event carries the status change
+	return emitEvent(ctx, EventDeliveryRetried, map[string]interface{}{
+		"deliveryId": deliveryID,
+		"orderId":    delivery.OrderID,
+		"oldStatus":  oldStatus,
+		"newStatus":  delivery.DeliveryStatus,
+		"retriedBy":  caller.ID,
+		"attempts":   delivery.DeliveryAttempts,
+		"timestamp":  currentTime,
+	})
+}
+
+// QueryOpenDisputes returns a page of unresolved disputes, oldest index entries first
+// Only ADMIN can query the dispute queue; pass the returned bookmark to fetch the next page
+func (c *DeliveryContract) QueryOpenDisputes(
+	ctx contractapi.TransactionContextInterface,
+	pageSize int,
+	bookmark string,
+) (*DisputeQueuePage, error) {
+	// ========== INPUT VALIDATION ==========
+	if pageSize <= 0 || pageSize > 100 {
+		return nil, &ValidationError{Field: "pageSize", Message: "must be between 1 and 100"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can query the dispute queue
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	iterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(IndexDisputedDelivery, []string{}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dispute queue: %v", err)
+	}
+	defer iterator.Close()
+
+	page := &DisputeQueuePage{Disputes: []*DisputeSummary{}, Bookmark: metadata.Bookmark}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate dispute queue: %v", err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(response.Key)
+		if err != nil || len(attrs) != 1 {
+			continue
+		}
+
+		delivery, err := c.readDeliveryInternal(ctx, attrs[0])
+		if err != nil {
+			continue
+		}
+
+		summary := &DisputeSummary{
+			DeliveryID:     delivery.DeliveryID,
+			OrderID:        delivery.OrderID,
+			DeliveryStatus: delivery.DeliveryStatus,
+			SellerID:       delivery.SellerID,
+			CustomerID:     delivery.CustomerID,
+		}
+		if delivery.LastDispute != nil {
+			summary.DisputeID = delivery.LastDispute.DisputeID
+			summary.FromUserID = delivery.LastDispute.FromUserID
+			summary.DisputedBy = delivery.LastDispute.DisputedBy
+			summary.ReasonCode = delivery.LastDispute.ReasonCode
+			summary.Reason = delivery.LastDispute.Reason
+			summary.DisputedAt = delivery.LastDispute.DisputedAt
+			if disputedAt, err := time.Parse(time.RFC3339, summary.DisputedAt); err == nil {
+				summary.AgeHours = int(txTime.Sub(disputedAt).Hours())
+			}
+		}
+		page.Disputes = append(page.Disputes, summary)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite keys tracking which orgs a delivery's key-level endorsement policy names
+const (
+	IndexPolicyMSP          = "policy~msp~deliveryId"
+	RecordEndorsementPolicy = "endorsementPolicy~deliveryId"
+)
+
+// EventEndorsementPoliciesMigrated is emitted for each batch of rewritten key-level policies
+const EventEndorsementPoliciesMigrated = "EndorsementPoliciesMigrated"
+
+// maxPolicyMigrationPageSize bounds the policies rewritten by one MigrateEndorsementPolicies call
+const maxPolicyMigrationPageSize = 200
+
+// EndorsementPolicyRecord mirrors the orgs named in a delivery's current key-level policy
+// Fabric cannot list keys by validation parameter, so the setters keep this copy.
+type EndorsementPolicyRecord struct {
+	DeliveryID    string   `json:"deliveryId"`
+	EndorsingMSPs []string `json:"endorsingMsps"`
+	SetAt         string   `json:"setAt"`
+}
+
+// getEndorsementPolicyRecord reads the tracked policy orgs of a delivery (nil if none)
+func getEndorsementPolicyRecord(ctx contractapi.TransactionContextInterface, deliveryID string) (*EndorsementPolicyRecord, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(RecordEndorsementPolicy, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create endorsement policy composite key: %v", err)
+	}
+	recordJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read endorsement policy record: %v", err)
+	}
+	if recordJSON == nil {
+		return nil, nil
+	}
+	var record EndorsementPolicyRecord
+	if err := json.Unmarshal(recordJSON, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal endorsement policy record: %v", err)
+	}
+	return &record, nil
+}
+
+// syncPolicyIndex moves a delivery's policy~msp~deliveryId entries to the given orgs
+// Called whenever a delivery's key-level policy is set; no orgs removes the delivery from the index.
+func syncPolicyIndex(ctx contractapi.TransactionContextInterface, deliveryID string, mspIDs []string) error {
+	stub := ctx.GetStub()
+	previous, err := getEndorsementPolicyRecord(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool, len(mspIDs))
+	for _, mspID := range mspIDs {
+		keep[mspID] = true
+	}
+	if previous != nil {
+		for _, mspID := range previous.EndorsingMSPs {
+			if keep[mspID] {
+				continue
+			}
+			key, err := stub.CreateCompositeKey(IndexPolicyMSP, []string{mspID, deliveryID})
+			if err != nil {
+				return fmt.Errorf("failed to create policy index key: %v", err)
+			}
+			if err := stub.DelState(key); err != nil {
+				return fmt.Errorf("failed to delete policy index: %v", err)
+			}
+		}
+	}
+
+	recordKey, err := stub.CreateCompositeKey(RecordEndorsementPolicy, []string{deliveryID})
+	if err != nil {
+		return fmt.Errorf("failed to create endorsement policy composite key: %v", err)
+	}
+	if len(mspIDs) == 0 {
+		if err := stub.DelState(recordKey); err != nil {
+			return fmt.Errorf("failed to delete endorsement policy record: %v", err)
+		}
+		return nil
+	}
+
+	for _, mspID := range mspIDs {
+		key, err := stub.CreateCompositeKey(IndexPolicyMSP, []string{mspID, deliveryID})
+		if err != nil {
+			return fmt.Errorf("failed to create policy index key: %v", err)
+		}
+		if err := stub.PutState(key, []byte{0x00}); err != nil {
+			return fmt.Errorf("failed to put policy index: %v", err)
+		}
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	recordJSON, err := json.Marshal(EndorsementPolicyRecord{
+		DeliveryID:    deliveryID,
+		EndorsingMSPs: mspIDs,
+		SetAt:         currentTime,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal endorsement policy record: %v", err)
+	}
+	if err := stub.PutState(recordKey, recordJSON); err != nil {
+		return fmt.Errorf("failed to put endorsement policy record: %v", err)
+	}
+	return nil
+}
+
+// EndorsedDelivery is a delivery whose key-level policy names the caller's org
+type EndorsedDelivery struct {
+	DeliveryID           string         `json:"deliveryId"`
+	OrderID              string         `json:"orderId"`
+	DeliveryStatus       DeliveryStatus `json:"deliveryStatus"`
+	CurrentCustodianID   string         `json:"currentCustodianId"`
+	CurrentCustodianRole UserRole       `json:"currentCustodianRole"`
+	// All orgs the policy requires; more than one means each must endorse
+	EndorsingMSPs []string `json:"endorsingMsps"`
+	PolicySetAt   string   `json:"policySetAt"`
+}
+
+// EndorsedDeliveryPage is a page of deliveries gated by the caller's org
+type EndorsedDeliveryPage struct {
+	MSPID      string              `json:"mspId"`
+	Deliveries []*EndorsedDelivery `json:"deliveries"`
+	Bookmark   string              `json:"bookmark"`
+}
+
+// QueryDeliveriesRequiringEndorsementFromMyOrg lists deliveries whose key-level endorsement
+// policy includes the caller's MSP, i.e. the records the org's peers must endorse changes to.
+// Any org's ADMIN can query, e.g. to plan peer maintenance; results are paginated.
+func (c *DeliveryContract) QueryDeliveriesRequiringEndorsementFromMyOrg(
+	ctx contractapi.TransactionContextInterface,
+	pageSize int,
+	bookmark string,
+) (*EndorsedDeliveryPage, error) {
+	// ========== INPUT VALIDATION ==========
+	if pageSize <= 0 || pageSize > 100 {
+		return nil, &ValidationError{Field: "pageSize", Message: "must be between 1 and 100"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - org admins plan their peers' maintenance
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	iterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(IndexPolicyMSP, []string{caller.MSP}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy index: %v", err)
+	}
+	defer iterator.Close()
+
+	page := &EndorsedDeliveryPage{MSPID: caller.MSP, Deliveries: []*EndorsedDelivery{}, Bookmark: metadata.Bookmark}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate policy index: %v", err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(response.Key)
+		if err != nil || len(attrs) != 2 {
+			continue
+		}
+
+		delivery, err := c.readDeliveryInternal(ctx, attrs[1])
+		if err != nil {
+			continue
+		}
+		record, err := getEndorsementPolicyRecord(ctx, attrs[1])
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &EndorsedDelivery{
+			DeliveryID:           delivery.DeliveryID,
+			OrderID:              delivery.OrderID,
+			DeliveryStatus:       delivery.DeliveryStatus,
+			CurrentCustodianID:   delivery.CurrentCustodianID,
+			CurrentCustodianRole: delivery.CurrentCustodianRole,
+			EndorsingMSPs:        []string{caller.MSP},
+		}
+		if record != nil {
+			entry.EndorsingMSPs = record.EndorsingMSPs
+			entry.PolicySetAt = record.SetAt
+		}
+		page.Deliveries = append(page.Deliveries, entry)
+	}
+
+	return page, nil
+}
+
+// PolicyMigrationResult reports one batch of MigrateEndorsementPolicies
+type PolicyMigrationResult struct {
+	OldMSP   string   `json:"oldMsp"`
+	NewMSP   string   `json:"newMsp"`
+	Migrated []string `json:"migrated"`
+	// Next delivery to migrate; empty once every indexed policy naming oldMSP is rewritten
+	Bookmark string `json:"bookmark"`
+}
+
+// MigrateEndorsementPolicies rewrites up to pageSize key-level endorsement policies that name
+// oldMSP so they name newMSP instead, e.g. when a consortium member is renamed or replaced.
+// Only ADMIN can migrate, and newMSP must be an active organization that endorses custody.
+// Pass the returned bookmark to the next call until it comes back empty. Only policies in the
+// policy~msp~deliveryId index are found; the orgs of multi-org policies are otherwise kept.
+func (c *DeliveryContract) MigrateEndorsementPolicies(
+	ctx contractapi.TransactionContextInterface,
+	oldMSP string,
+	newMSP string,
+	pageSize int,
+	bookmark string,
+) (*PolicyMigrationResult, error) {
+	// ========== INPUT VALIDATION ==========
+	if !mspIDPattern.MatchString(oldMSP) {
+		return nil, &ValidationError{Field: "oldMSP", Message: "must be 1-64 characters of letters, digits, '.', '-' or '_'"}
+	}
+	if !mspIDPattern.MatchString(newMSP) {
+		return nil, &ValidationError{Field: "newMSP", Message: "must be 1-64 characters of letters, digits, '.', '-' or '_'"}
+	}
+	if oldMSP == newMSP {
+		return nil, &ValidationError{Field: "newMSP", Message: "must differ from oldMSP"}
+	}
+	if pageSize <= 0 || pageSize > maxPolicyMigrationPageSize {
+		return nil, &ValidationError{Field: "pageSize", Message: fmt.Sprintf("must be between 1 and %d", maxPolicyMigrationPageSize)}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only an ADMIN of the whole marketplace can rewrite endorsement policies
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return nil, err
+	}
+
+	org, err := getOrganization(ctx, newMSP)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil || !org.Active {
+		return nil, fmt.Errorf("organization %s is not an active member", newMSP)
+	}
+	if !org.EndorsesCustody {
+		return nil, fmt.Errorf("organization %s does not endorse custody", newMSP)
+	}
+
+	// Paginated queries are read-only in Fabric, so the batch is cut from a plain range scan
+	stub := ctx.GetStub()
+	iterator, err := stub.GetStateByPartialCompositeKey(IndexPolicyMSP, []string{oldMSP})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy index: %v", err)
+	}
+	result := &PolicyMigrationResult{OldMSP: oldMSP, NewMSP: newMSP, Migrated: []string{}}
+	var deliveryIDs []string
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			iterator.Close()
+			return nil, fmt.Errorf("failed to iterate policy index: %v", err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(response.Key)
+		if err != nil || len(attrs) != 2 || attrs[1] < bookmark {
+			continue
+		}
+		if len(deliveryIDs) == pageSize {
+			result.Bookmark = attrs[1]
+			break
+		}
+		deliveryIDs = append(deliveryIDs, attrs[1])
+	}
+	iterator.Close()
+
+	for _, deliveryID := range deliveryIDs {
+		record, err := getEndorsementPolicyRecord(ctx, deliveryID)
+		if err != nil {
+			return nil, err
+		}
+		mspIDs := []string{newMSP}
+		if record != nil {
+			for _, mspID := range record.EndorsingMSPs {
+				if mspID != oldMSP && mspID != newMSP {
+					mspIDs = append(mspIDs, mspID)
+				}
+			}
+		}
+
+		ep, err := statebased.NewStateEP(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create state endorsement policy: %v", err)
+		}
+		if err := ep.AddOrgs(statebased.RoleTypeMember, mspIDs...); err != nil {
+			return nil, fmt.Errorf("failed to add orgs to endorsement policy: %v", err)
+		}
+		policyBytes, err := ep.Policy()
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize endorsement policy: %v", err)
+		}
+		if err := stub.SetStateValidationParameter(deliveryID, policyBytes); err != nil {
+			return nil, fmt.Errorf("failed to set state validation parameter: %v", err)
+		}
+		if err := syncPolicyIndex(ctx, deliveryID, mspIDs); err != nil {
+			return nil, err
+		}
+		result.Migrated = append(result.Migrated, deliveryID)
+	}
+
+	err = emitEvent(ctx, EventEndorsementPoliciesMigrated, map[string]interface{}{
+		"oldMsp":     oldMSP,
+		"newMsp":     newMSP,
+		"migrated":   result.Migrated,
+		"bookmark":   result.Bookmark,
+		"migratedBy": caller.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransitionParams are the arguments of an evaluated action, by parameter name
+// Each action reads only the parameters of its own signature.
+type TransitionParams struct {
+	ToUserID        string  `json:"toUserId,omitempty" metadata:",optional"`
+	ToRole          string  `json:"toRole,omitempty" metadata:",optional"`
+	City            string  `json:"city,omitempty" metadata:",optional"`
+	State           string  `json:"state,omitempty" metadata:",optional"`
+	Country         string  `json:"country,omitempty" metadata:",optional"`
+	PackageWeight   float64 `json:"packageWeight,omitempty" metadata:",optional"`
+	DimensionLength float64 `json:"dimensionLength,omitempty" metadata:",optional"`
+	DimensionWidth  float64 `json:"dimensionWidth,omitempty" metadata:",optional"`
+	DimensionHeight float64 `json:"dimensionHeight,omitempty" metadata:",optional"`
+	ReasonCode      string  `json:"reasonCode,omitempty" metadata:",optional"`
+	Reason          string  `json:"reason,omitempty" metadata:",optional"`
+}
+
+// evaluableActions are the delivery transactions EvaluateStateTransition can dry-run
+var evaluableActions = map[string]func(c *DeliveryContract, ctx contractapi.TransactionContextInterface, deliveryID string, p TransitionParams) error{
+	"InitiateHandoff": func(c *DeliveryContract, ctx contractapi.TransactionContextInterface, deliveryID string, p TransitionParams) error {
+		return c.InitiateHandoff(ctx, deliveryID, p.ToUserID, p.ToRole)
+	},
+	"ConfirmHandoff": func(c *DeliveryContract, ctx contractapi.TransactionContextInterface, deliveryID string, p TransitionParams) error {
+		return c.ConfirmHandoff(ctx, deliveryID, p.City, p.State, p.Country, p.PackageWeight, p.DimensionLength, p.DimensionWidth, p.DimensionHeight)
+	},
+	"CancelHandoff": func(c *DeliveryContract, ctx contractapi.TransactionContextInterface, deliveryID string, p TransitionParams) error {
+		return c.CancelHandoff(ctx, deliveryID)
+	},
+	"DisputeHandoff": func(c *DeliveryContract, ctx contractapi.TransactionContextInterface, deliveryID string, p TransitionParams) error {
+		return c.DisputeHandoff(ctx, deliveryID, p.ReasonCode, p.Reason)
+	},
+	"UpdateLocation": func(c *DeliveryContract, ctx contractapi.TransactionContextInterface, deliveryID string, p TransitionParams) error {
+		return c.UpdateLocation(ctx, deliveryID, p.City, p.State, p.Country)
+	},
+	"DeclareOutForDelivery": func(c *DeliveryContract, ctx contractapi.TransactionContextInterface, deliveryID string, p TransitionParams) error {
+		return c.DeclareOutForDelivery(ctx, deliveryID)
+	},
+	"CancelDelivery": func(c *DeliveryContract, ctx contractapi.TransactionContextInterface, deliveryID string, p TransitionParams) error {
+		return c.CancelDelivery(ctx, deliveryID, p.ReasonCode, p.Reason)
+	},
+	"RecallDelivery": func(c *DeliveryContract, ctx contractapi.TransactionContextInterface, deliveryID string, p TransitionParams) error {
+		return c.RecallDelivery(ctx, deliveryID, p.Reason)
+	},
+	"AcknowledgeRecall": func(c *DeliveryContract, ctx contractapi.TransactionContextInterface, deliveryID string, p TransitionParams) error {
+		return c.AcknowledgeRecall(ctx, deliveryID)
+	},
+}
+
+// StateTransitionDiff is what a transaction would change if it were submitted now
+// Index and record keys are rendered as objectType/attribute/... for composite keys.
+type StateTransitionDiff struct {
+	DeliveryID       string         `json:"deliveryId"`
+	Action           string         `json:"action"`
+	OldStatus        DeliveryStatus `json:"oldStatus"`
+	NewStatus        DeliveryStatus `json:"newStatus"`
+	OldCustodianID   string         `json:"oldCustodianId"`
+	NewCustodianID   string         `json:"newCustodianId"`
+	OldCustodianRole UserRole       `json:"oldCustodianRole"`
+	NewCustodianRole UserRole       `json:"newCustodianRole"`
+	IndexesAdded     []string       `json:"indexesAdded"`
+	IndexesRemoved   []string       `json:"indexesRemoved"`
+	RecordsWritten   []string       `json:"recordsWritten"`
+	PolicyChanged    bool           `json:"policyChanged"`
+	OldEndorsingMSPs []string       `json:"oldEndorsingMsps"`
+	NewEndorsingMSPs []string       `json:"newEndorsingMsps"`
+	EventName        string         `json:"eventName,omitempty" metadata:",optional"`
+}
+
+// simulationStub buffers the writes of a transaction instead of sending them to the ledger
+// Reads see the buffered writes; range and composite key queries only see the ledger, as they
+// would within a real transaction. Private data writes are dropped.
+type simulationStub struct {
+	shim.ChaincodeStubInterface
+	function string
+	writes   map[string][]byte
+	deleted  map[string]bool
+	policies map[string][]byte
+	event    string
+}
+
+func (s *simulationStub) GetFunctionAndParameters() (string, []string) {
+	return s.function, nil
+}
+
+func (s *simulationStub) GetState(key string) ([]byte, error) {
+	if s.deleted[key] {
+		return nil, nil
+	}
+	if value, ok := s.writes[key]; ok {
+		return value, nil
+	}
+	return s.ChaincodeStubInterface.GetState(key)
+}
+
+func (s *simulationStub) PutState(key string, value []byte) error {
+	delete(s.deleted, key)
+	s.writes[key] = value
+	return nil
+}
+
+func (s *simulationStub) DelState(key string) error {
+	delete(s.writes, key)
+	s.deleted[key] = true
+	return nil
+}
+
+func (s *simulationStub) SetStateValidationParameter(key string, ep []byte) error {
+	s.policies[key] = ep
+	return nil
+}
+
+func (s *simulationStub) GetStateValidationParameter(key string) ([]byte, error) {
+	if ep, ok := s.policies[key]; ok {
+		return ep, nil
+	}
+	return s.ChaincodeStubInterface.GetStateValidationParameter(key)
+}
+
+func (s *simulationStub) PutPrivateData(collection string, key string, value []byte) error {
+	return nil
+}
+
+func (s *simulationStub) DelPrivateData(collection string, key string) error {
+	return nil
+}
+
+func (s *simulationStub) PurgePrivateData(collection string, key string) error {
+	return nil
+}
+
+func (s *simulationStub) SetPrivateDataValidationParameter(collection string, key string, ep []byte) error {
+	return nil
+}
+
+func (s *simulationStub) SetEvent(name string, payload []byte) error {
+	s.event = name
+	return nil
+}
+
+// simulationContext runs a transaction against a simulationStub
+type simulationContext struct {
+	contractapi.TransactionContextInterface
+	stub *simulationStub
+}
+
+func (s *simulationContext) GetStub() shim.ChaincodeStubInterface {
+	return s.stub
+}
+
+// renderKey returns a readable form of a ledger key
+func renderKey(stub shim.ChaincodeStubInterface, key string) string {
+	if !strings.HasPrefix(key, compositeKeyNamespace) {
+		return key
+	}
+	objectType, attributes, err := stub.SplitCompositeKey(key)
+	if err != nil {
+		return key
+	}
+	return strings.Join(append([]string{objectType}, attributes...), "/")
+}
+
+// endorsingMSPs returns the tracked policy orgs of a delivery (empty if none)
+func endorsingMSPs(ctx contractapi.TransactionContextInterface, deliveryID string) ([]string, error) {
+	record, err := getEndorsementPolicyRecord(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return []string{}, nil
+	}
+	return record.EndorsingMSPs, nil
+}
+
+// EvaluateStateTransition dry-runs a delivery transaction as the caller and returns what it
+// would change, so risk engines can score it before the client submits it
+// proposedAction names the transaction (InitiateHandoff, ConfirmHandoff, CancelHandoff,
+// DisputeHandoff, UpdateLocation, DeclareOutForDelivery, CancelDelivery, RecallDelivery or
+// AcknowledgeRecall); paramsJSON holds its arguments by name. The transaction runs with every
+// authorization and business check, but nothing is written: a rejected action returns its error.
+func (c *DeliveryContract) EvaluateStateTransition(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	proposedAction string,
+	paramsJSON string,
+) (*StateTransitionDiff, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+	action, ok := evaluableActions[proposedAction]
+	if !ok {
+		return nil, &ValidationError{Field: "proposedAction", Message: fmt.Sprintf("cannot evaluate %s", proposedAction)}
+	}
+	var params TransitionParams
+	if strings.TrimSpace(paramsJSON) != "" {
+		if err := json.Unmarshal([]byte(paramsJSON), &params); err != nil {
+			return nil, fmt.Errorf("failed to parse params: %v", err)
+		}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, participantRoles...); err != nil {
+		return nil, err
+	}
+
+	before, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	oldMSPs, err := endorsingMSPs(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := &simulationStub{
+		ChaincodeStubInterface: ctx.GetStub(),
+		function:               proposedAction,
+		writes:                 map[string][]byte{},
+		deleted:                map[string]bool{},
+		policies:               map[string][]byte{},
+	}
+	simulation := &simulationContext{TransactionContextInterface: ctx, stub: stub}
+	if err := action(c, simulation, deliveryID, params); err != nil {
+		return nil, err
+	}
+
+	after, err := c.readDeliveryInternal(simulation, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	newMSPs, err := endorsingMSPs(simulation, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &StateTransitionDiff{
+		DeliveryID:       deliveryID,
+		Action:           proposedAction,
+		OldStatus:        before.DeliveryStatus,
+		NewStatus:        after.DeliveryStatus,
+		OldCustodianID:   before.CurrentCustodianID,
+		NewCustodianID:   after.CurrentCustodianID,
+		OldCustodianRole: before.CurrentCustodianRole,
+		NewCustodianRole: after.CurrentCustodianRole,
+		IndexesAdded:     []string{},
+		IndexesRemoved:   []string{},
+		RecordsWritten:   []string{},
+		PolicyChanged:    len(stub.policies) > 0,
+		OldEndorsingMSPs: oldMSPs,
+		NewEndorsingMSPs: newMSPs,
+		EventName:        stub.event,
+	}
+
+	// Index entries hold a single 0x00 byte; rewriting an existing entry is not a change
+	ledger := ctx.GetStub()
+	for key, value := range stub.writes {
+		if strings.HasPrefix(key, compositeKeyNamespace) && bytes.Equal(value, []byte{0x00}) {
+			existing, err := ledger.GetState(key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read index: %v", err)
+			}
+			if existing == nil {
+				diff.IndexesAdded = append(diff.IndexesAdded, renderKey(ledger, key))
+			}
+			continue
+		}
+		diff.RecordsWritten = append(diff.RecordsWritten, renderKey(ledger, key))
+	}
+	for key := range stub.deleted {
+		existing, err := ledger.GetState(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read index: %v", err)
+		}
+		if existing != nil {
+			diff.IndexesRemoved = append(diff.IndexesRemoved, renderKey(ledger, key))
+		}
+	}
+	sort.Strings(diff.IndexesAdded)
+	sort.Strings(diff.IndexesRemoved)
+	sort.Strings(diff.RecordsWritten)
+
+	return diff, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransientHandoffEvidence is the transient map key for evidence captured at a handoff
+// ({"photoHash":"<sha256 hex>","sealId":"S-1234","signatureHash":"<sha256 hex>"})
+const TransientHandoffEvidence = "handoffEvidence"
+
+// maxSealIDLength bounds the tamper seal identifier
+const maxSealIDLength = 64
+
+// HandoffEvidence is proof of a package's condition captured by one side of a handoff
+// Only hashes are stored; photos and signatures stay off-chain with whoever captured them.
+type HandoffEvidence struct {
+	PhotoHash     string `json:"photoHash,omitempty" metadata:",optional"`
+	SealID        string `json:"sealId,omitempty" metadata:",optional"`
+	SignatureHash string `json:"signatureHash,omitempty" metadata:",optional"`
+	CapturedBy    string `json:"capturedBy"`
+	CapturedAt    string `json:"capturedAt"`
+}
+
+// validate checks the evidence hashes and seal ID
+func (e *HandoffEvidence) validate() error {
+	if e.PhotoHash == "" && e.SealID == "" && e.SignatureHash == "" {
+		return &ValidationError{Field: "handoffEvidence", Message: "must include a photo hash, seal ID or signature hash"}
+	}
+	if e.PhotoHash != "" && !identityHashPattern.MatchString(e.PhotoHash) {
+		return &ValidationError{Field: "photoHash", Message: "must be a hex-encoded SHA-256 digest"}
+	}
+	if e.SignatureHash != "" && !identityHashPattern.MatchString(e.SignatureHash) {
+		return &ValidationError{Field: "signatureHash", Message: "must be a hex-encoded SHA-256 digest"}
+	}
+	if len(e.SealID) > maxSealIDLength {
+		return &ValidationError{Field: "sealId", Message: fmt.Sprintf("must be at most %d characters", maxSealIDLength)}
+	}
+	return nil
+}
+
+// getHandoffEvidence reads the evidence supplied with a handoff transaction (nil if none)
+func getHandoffEvidence(ctx contractapi.TransactionContextInterface, callerID string, currentTime string) (*HandoffEvidence, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transient data: %v", err)
+	}
+	evidenceJSON, exists := transientMap[TransientHandoffEvidence]
+	if !exists || len(evidenceJSON) == 0 {
+		return nil, nil
+	}
+
+	var evidence HandoffEvidence
+	if err := json.Unmarshal(evidenceJSON, &evidence); err != nil {
+		return nil, fmt.Errorf("failed to parse handoff evidence: %v", err)
+	}
+	if err := evidence.validate(); err != nil {
+		return nil, err
+	}
+	evidence.CapturedBy = callerID
+	evidence.CapturedAt = currentTime
+	return &evidence, nil
+}
+
+// keepHandoffEvidence copies the evidence of a completed handoff onto the delivery
+// Pickup and final delivery evidence outlive the handoff, so condition at origin and at the
+// door stay provable when a damage dispute comes up later.
+func keepHandoffEvidence(delivery *Delivery, handoff *PendingHandoff, oldStatus DeliveryStatus) {
+	if len(handoff.Evidence) == 0 {
+		return
+	}
+	switch {
+	case oldStatus == StatusPendingPickupHandoff:
+		delivery.PickupEvidence = handoff.Evidence
+	case handoff.ToRole == RoleCustomer:
+		delivery.DeliveryEvidence = handoff.Evidence
+	}
+}
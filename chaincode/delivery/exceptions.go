@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ExceptionType classifies an operational delivery exception
+// Valid types are the codes of the INCIDENT reason-code catalog; these are its defaults.
+type ExceptionType string
+
+const (
+	ExceptionWeatherDelay         ExceptionType = "WEATHER_DELAY"
+	ExceptionVehicleBreakdown     ExceptionType = "VEHICLE_BREAKDOWN"
+	ExceptionWrongAddress         ExceptionType = "WRONG_ADDRESS"
+	ExceptionRecipientUnavailable ExceptionType = "RECIPIENT_UNAVAILABLE"
+)
+
+// EventDeliveryException is emitted so customers can be notified proactively
+const EventDeliveryException = "DeliveryException"
+
+// RecordDeliveryException is the composite key prefix for exception records
+const RecordDeliveryException = "exception~deliveryId~txId"
+
+// DeliveryException is a typed exception reported against a delivery
+type DeliveryException struct {
+	DeliveryID    string        `json:"deliveryId"`
+	TxID          string        `json:"txId"`
+	ExceptionType ExceptionType `json:"exceptionType"`
+	Details       string        `json:"details,omitempty" metadata:",optional"`
+	Location      Location      `json:"location"`
+	ReportedBy    string        `json:"reportedBy"`
+	ReportedAt    string        `json:"reportedAt"`
+}
+
+// ExceptionSummary is the latest exception, kept on the delivery for quick display
+type ExceptionSummary struct {
+	ExceptionType ExceptionType `json:"exceptionType"`
+	ReportedAt    string        `json:"reportedAt"`
+	Count         int           `json:"count"`
+}
+
+// ReportException records a typed delivery exception
+// Only the current DELIVERY_PERSON custodian can report exceptions; the type must be an INCIDENT reason code
+func (c *DeliveryContract) ReportException(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	exceptionType string,
+	details string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	if err := validateReasonCode(ctx, ReasonCategoryIncident, exceptionType); err != nil {
+		return err
+	}
+	details = sanitizeText(details)
+	if err := validateText(details, "details", 1000, true); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only DELIVERY_PERSON can report exceptions
+	if err := validateRole(ctx, caller, RoleDeliveryPerson); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	// Must be current custodian
+	if delivery.CurrentCustodianID != caller.ID {
+		return fmt.Errorf("only the current custodian can report an exception")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	txID := ctx.GetStub().GetTxID()
+
+	exception := DeliveryException{
+		DeliveryID:    deliveryID,
+		TxID:          txID,
+		ExceptionType: ExceptionType(exceptionType),
+		Details:       details,
+		Location:      delivery.LastLocation,
+		ReportedBy:    caller.ID,
+		ReportedAt:    currentTime,
+	}
+
+	exceptionKey, err := ctx.GetStub().CreateCompositeKey(RecordDeliveryException, []string{deliveryID, txID})
+	if err != nil {
+		return fmt.Errorf("failed to create exception composite key: %v", err)
+	}
+	exceptionJSON, err := json.Marshal(exception)
+	if err != nil {
+		return fmt.Errorf("failed to marshal exception: %v", err)
+	}
+	if err := ctx.GetStub().PutState(exceptionKey, exceptionJSON); err != nil {
+		return fmt.Errorf("failed to put exception record: %v", err)
+	}
+
+	count := 1
+	if delivery.LatestException != nil {
+		count = delivery.LatestException.Count + 1
+	}
+	delivery.LatestException = &ExceptionSummary{
+		ExceptionType: exception.ExceptionType,
+		ReportedAt:    currentTime,
+		Count:         count,
+	}
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventDeliveryException, map[string]string{
+		"deliveryId":    deliveryID,
+		"orderId":       delivery.OrderID,
+		"customerId":    delivery.CustomerID,
+		"exceptionType": exceptionType,
+		"details":       details,
+		"city":          delivery.LastLocation.City,
+		"timestamp":     currentTime,
+	})
+}
+
+// GetDeliveryExceptions returns all exceptions reported for a delivery
+func (c *DeliveryContract) GetDeliveryExceptions(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*DeliveryException, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordDeliveryException, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exceptions: %v", err)
+	}
+	defer iterator.Close()
+
+	exceptions := []*DeliveryException{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate exceptions: %v", err)
+		}
+
+		var exception DeliveryException
+		if err := json.Unmarshal(response.Value, &exception); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal exception: %v", err)
+		}
+		exceptions = append(exceptions, &exception)
+	}
+
+	return exceptions, nil
+}
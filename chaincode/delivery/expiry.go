@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransientProductExpiry is the transient map key for a perishable product's expiry date
+// (YYYY-MM-DD) at creation
+const TransientProductExpiry = "productExpiry"
+
+// EventNearExpiry is emitted when a perishable delivery risks arriving too close to expiry
+const EventNearExpiry = "NearExpiry"
+
+// nearExpiryMarginHours is how long before expiry a perishable delivery should arrive
+const nearExpiryMarginHours = 24
+
+// expiryDeadline returns the end of a product expiry day (UTC)
+func expiryDeadline(expiry string) (time.Time, error) {
+	day, err := time.Parse("2006-01-02", expiry)
+	if err != nil {
+		return time.Time{}, &ValidationError{Field: TransientProductExpiry, Message: "must be a date in YYYY-MM-DD format"}
+	}
+	return day.AddDate(0, 0, 1), nil
+}
+
+// tierDeliveredAt returns when a delivery created at createdAt is promised to arrive
+func tierDeliveredAt(delivery *Delivery, createdAt time.Time) (time.Time, error) {
+	for _, expectation := range milestoneTemplate(delivery) {
+		if expectation.Milestone == ProgressDelivered {
+			return createdAt.Add(time.Duration(expectation.Hours) * time.Hour), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no %s milestone in the %s template", ProgressDelivered, delivery.ServiceTier)
+}
+
+// applyProductExpiry sets the optional product expiry from the transient map on a new delivery
+// Deliveries whose tier promises them for after the expiry day are rejected.
+func applyProductExpiry(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient data: %v", err)
+	}
+	expiryBytes, exists := transientMap[TransientProductExpiry]
+	if !exists || len(expiryBytes) == 0 {
+		return nil
+	}
+
+	expiry := strings.TrimSpace(string(expiryBytes))
+	deadline, err := expiryDeadline(expiry)
+	if err != nil {
+		return err
+	}
+	createdAt, err := time.Parse(time.RFC3339, delivery.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("invalid delivery timestamp: %v", err)
+	}
+	promisedAt, err := tierDeliveredAt(delivery, createdAt)
+	if err != nil {
+		return err
+	}
+	if !promisedAt.Before(deadline) {
+		return &ValidationError{Field: TransientProductExpiry, Message: fmt.Sprintf(
+			"%s delivery would be promised for %s, after the product expires on %s",
+			delivery.ServiceTier, promisedAt.Format("2006-01-02"), expiry)}
+	}
+
+	delivery.ProductExpiry = expiry
+	return nil
+}
+
+// nearExpiry reports whether a perishable delivery is expected to arrive within the margin
+// before its product expires
+// Deliveries running late are expected now at the earliest, so the risk grows with the delay.
+func nearExpiry(ctx contractapi.TransactionContextInterface, delivery *Delivery, now time.Time) (bool, time.Time, error) {
+	if delivery.ProductExpiry == "" {
+		return false, time.Time{}, nil
+	}
+	deadline, err := expiryDeadline(delivery.ProductExpiry)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	expectedAt, err := promisedDeliveryAt(ctx, delivery)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	if expectedAt.Before(now) {
+		expectedAt = now
+	}
+	return deadline.Sub(expectedAt) < nearExpiryMarginHours*time.Hour, expectedAt, nil
+}
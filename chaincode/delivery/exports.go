@@ -0,0 +1,336 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordDeliveryExport is the composite key prefix for cross-channel export records
+const RecordDeliveryExport = "export~deliveryId"
+
+// Event names for cross-channel handovers
+const (
+	EventDeliveryExported = "DeliveryExported"
+	EventDeliveryImported = "DeliveryImported"
+)
+
+// DeliveryPackage is a snapshot of a delivery handed over to another channel
+// PackageHash is the sha256 of the package JSON with PackageHash empty; the same hash
+// is committed on the source channel, which is what ImportDeliveryPackage checks provenance against.
+type DeliveryPackage struct {
+	Delivery      Delivery    `json:"delivery"`
+	HistoryDigest StateDigest `json:"historyDigest"`
+	SourceChannel string      `json:"sourceChannel"`
+	SourceTxID    string      `json:"sourceTxId"`
+	TargetChannel string      `json:"targetChannel"`
+	ExportedBy    string      `json:"exportedBy"`
+	ExportedByMSP string      `json:"exportedByMsp"`
+	ExportedAt    string      `json:"exportedAt"`
+	PackageHash   string      `json:"packageHash"`
+}
+
+// DeliveryExport is the source-channel record of an exported delivery
+type DeliveryExport struct {
+	DeliveryID    string `json:"deliveryId"`
+	PackageHash   string `json:"packageHash"`
+	TargetChannel string `json:"targetChannel"`
+	ExportTxID    string `json:"exportTxId"`
+	ExportedBy    string `json:"exportedBy"`
+	ExportedAt    string `json:"exportedAt"`
+}
+
+// ChannelProvenance links an imported delivery to its record on the source channel
+type ChannelProvenance struct {
+	SourceChannel string `json:"sourceChannel"`
+	SourceTxID    string `json:"sourceTxId"`
+	PackageHash   string `json:"packageHash"`
+	HistoryDigest string `json:"historyDigest"`
+	ImportedBy    string `json:"importedBy"`
+	ImportedAt    string `json:"importedAt"`
+}
+
+// hashDeliveryPackage computes the package hash over the package with PackageHash cleared
+func hashDeliveryPackage(pkg DeliveryPackage) (string, error) {
+	pkg.PackageHash = ""
+	pkgJSON, err := json.Marshal(pkg)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal delivery package: %v", err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(pkgJSON)), nil
+}
+
+// getDeliveryExport reads the export record of a delivery, returning nil if it was not exported
+func getDeliveryExport(ctx contractapi.TransactionContextInterface, deliveryID string) (*DeliveryExport, error) {
+	exportKey, err := ctx.GetStub().CreateCompositeKey(RecordDeliveryExport, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export composite key: %v", err)
+	}
+	exportJSON, err := ctx.GetStub().GetState(exportKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export record: %v", err)
+	}
+	if exportJSON == nil {
+		return nil, nil
+	}
+
+	var export DeliveryExport
+	if err := json.Unmarshal(exportJSON, &export); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal export record: %v", err)
+	}
+	return &export, nil
+}
+
+// ExportDeliveryPackage freezes a delivery on this channel and returns a snapshot for another channel
+// Only the current custodian or ADMIN can export, and only while no handoff is pending
+func (c *DeliveryContract) ExportDeliveryPackage(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	targetChannel string,
+) (*DeliveryPackage, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+	if len(targetChannel) == 0 || len(targetChannel) > 249 {
+		return nil, &ValidationError{Field: "targetChannel", Message: "must be between 1 and 249 characters"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleSeller, RoleDeliveryPerson, RoleWarehouse, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if caller.Role != RoleAdmin && delivery.CurrentCustodianID != caller.ID {
+		return nil, fmt.Errorf("only the current custodian can export a delivery")
+	}
+	if delivery.PendingHandoff != nil {
+		return nil, fmt.Errorf("cannot export a delivery with a pending handoff")
+	}
+	if delivery.DeliveryStatus != StatusPendingPickup && delivery.DeliveryStatus != StatusInTransit {
+		return nil, fmt.Errorf("cannot export delivery in current status: %s", delivery.DeliveryStatus)
+	}
+	if targetChannel == ctx.GetStub().GetChannelID() {
+		return nil, fmt.Errorf("target channel must differ from the current channel")
+	}
+
+	// Digest of the committed history, which the snapshot below extends
+	digest, err := computeStateDigest(ctx, deliveryID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	txID := ctx.GetStub().GetTxID()
+
+	pkg := DeliveryPackage{
+		Delivery:      *delivery,
+		HistoryDigest: *digest,
+		SourceChannel: ctx.GetStub().GetChannelID(),
+		SourceTxID:    txID,
+		TargetChannel: targetChannel,
+		ExportedBy:    caller.ID,
+		ExportedByMSP: caller.MSP,
+		ExportedAt:    currentTime,
+	}
+	pkg.PackageHash, err = hashDeliveryPackage(pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	export := DeliveryExport{
+		DeliveryID:    deliveryID,
+		PackageHash:   pkg.PackageHash,
+		TargetChannel: targetChannel,
+		ExportTxID:    txID,
+		ExportedBy:    caller.ID,
+		ExportedAt:    currentTime,
+	}
+	exportKey, err := ctx.GetStub().CreateCompositeKey(RecordDeliveryExport, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export composite key: %v", err)
+	}
+	exportJSON, err := json.Marshal(export)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal export record: %v", err)
+	}
+	if err := ctx.GetStub().PutState(exportKey, exportJSON); err != nil {
+		return nil, fmt.Errorf("failed to put export record: %v", err)
+	}
+
+	// Freeze the record on this channel; custody continues on the target channel
+	oldStatus := delivery.DeliveryStatus
+	delivery.DeliveryStatus = StatusExported
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	// Fabric keeps one event per transaction, so the export event carries the status change
+	err = emitEvent(ctx, EventDeliveryExported, map[string]string{
+		"deliveryId":    deliveryID,
+		"orderId":       delivery.OrderID,
+		"oldStatus":     string(oldStatus),
+		"newStatus":     string(delivery.DeliveryStatus),
+		"packageHash":   export.PackageHash,
+		"targetChannel": export.TargetChannel,
+		"exportTxId":    export.ExportTxID,
+		"exportedBy":    export.ExportedBy,
+		"exportedAt":    export.ExportedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pkg, nil
+}
+
+// GetDeliveryExport returns the export record of a delivery on this channel
+// Queried cross-channel by ImportDeliveryPackage to verify provenance
+func (c *DeliveryContract) GetDeliveryExport(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) (*DeliveryExport, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	export, err := getDeliveryExport(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if export == nil {
+		return nil, fmt.Errorf("delivery %s has not been exported", deliveryID)
+	}
+	return export, nil
+}
+
+// ImportDeliveryPackage continues custody of a delivery exported from another channel
+// The package hash is recomputed and checked against the export record on the source channel
+// Only the custodian named in the package or ADMIN can import
+func (c *DeliveryContract) ImportDeliveryPackage(
+	ctx contractapi.TransactionContextInterface,
+	packageJSON string,
+	sourceChaincode string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	var pkg DeliveryPackage
+	if err := json.Unmarshal([]byte(packageJSON), &pkg); err != nil {
+		return fmt.Errorf("failed to parse delivery package: %v", err)
+	}
+	delivery := pkg.Delivery
+	if err := validateDeliveryID(delivery.DeliveryID); err != nil {
+		return err
+	}
+	if len(sourceChaincode) == 0 {
+		return &ValidationError{Field: "sourceChaincode", Message: "is required"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleSeller, RoleDeliveryPerson, RoleWarehouse, RoleAdmin); err != nil {
+		return err
+	}
+	if caller.Role != RoleAdmin && delivery.CurrentCustodianID != caller.ID {
+		return fmt.Errorf("only the custodian named in the package can import it")
+	}
+	if delivery.TenantID != caller.TenantID {
+		return fmt.Errorf("package belongs to a different tenant")
+	}
+
+	stub := ctx.GetStub()
+	if pkg.TargetChannel != stub.GetChannelID() {
+		return fmt.Errorf("package was exported to channel %s, not %s", pkg.TargetChannel, stub.GetChannelID())
+	}
+
+	// Digest: the package content must match its own hash
+	computedHash, err := hashDeliveryPackage(pkg)
+	if err != nil {
+		return err
+	}
+	if computedHash != pkg.PackageHash {
+		return fmt.Errorf("delivery package hash mismatch")
+	}
+
+	// Provenance: the source channel must have committed an export with this hash
+	response := stub.InvokeChaincode(sourceChaincode, [][]byte{[]byte("GetDeliveryExport"), []byte(delivery.DeliveryID)}, pkg.SourceChannel)
+	if response.Status != http.StatusOK {
+		return fmt.Errorf("failed to verify export on channel %s: %s", pkg.SourceChannel, response.Message)
+	}
+	var export DeliveryExport
+	if err := json.Unmarshal(response.Payload, &export); err != nil {
+		return fmt.Errorf("failed to parse source export record: %v", err)
+	}
+	if export.PackageHash != pkg.PackageHash || export.ExportTxID != pkg.SourceTxID {
+		return fmt.Errorf("delivery package does not match the export recorded on channel %s", pkg.SourceChannel)
+	}
+
+	exists, err := c.DeliveryExists(ctx, delivery.DeliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to check if delivery exists: %v", err)
+	}
+	if exists {
+		return fmt.Errorf("delivery %s already exists on this channel", delivery.DeliveryID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	delivery.Provenance = &ChannelProvenance{
+		SourceChannel: pkg.SourceChannel,
+		SourceTxID:    pkg.SourceTxID,
+		PackageHash:   pkg.PackageHash,
+		HistoryDigest: pkg.HistoryDigest.Digest,
+		ImportedBy:    caller.ID,
+		ImportedAt:    currentTime,
+	}
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, &delivery); err != nil {
+		return err
+	}
+
+	if err := setDeliveryEndorsementPolicy(ctx, &delivery); err != nil {
+		return fmt.Errorf("failed to set endorsement policy: %v", err)
+	}
+
+	return emitEvent(ctx, EventDeliveryImported, map[string]string{
+		"deliveryId":    delivery.DeliveryID,
+		"sourceChannel": pkg.SourceChannel,
+		"sourceTxId":    pkg.SourceTxID,
+		"packageHash":   pkg.PackageHash,
+		"timestamp":     currentTime,
+	})
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// IndexExternalTracking maps off-network carrier tracking numbers back to deliveries
+const IndexExternalTracking = "carrier~tracking~deliveryId"
+
+// EventExternalTrackingAttached is emitted when a leg is handed to an off-network carrier
+const EventExternalTrackingAttached = "ExternalTrackingAttached"
+
+// maxExternalTracking bounds the off-network legs stored on one delivery record
+const maxExternalTracking = 10
+
+var (
+	// carrierCodePattern matches carrier codes such as UPS, DHL_EXPRESS or POSTNL
+	carrierCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{2,32}$`)
+	// trackingNumberPattern matches third-party tracking numbers
+	trackingNumberPattern = regexp.MustCompile(`^[A-Za-z0-9-]{4,64}$`)
+)
+
+// ExternalTracking records a leg fulfilled by a carrier outside the network
+type ExternalTracking struct {
+	CarrierCode    string `json:"carrierCode"`
+	TrackingNumber string `json:"trackingNumber"`
+	AttachedBy     string `json:"attachedBy"`
+	AttachedAt     string `json:"attachedAt"`
+}
+
+// externalTrackingStatuses are the statuses in which a package can leave the network
+var externalTrackingStatuses = map[DeliveryStatus]bool{
+	StatusInTransit:         true,
+	StatusOffNetworkTransit: true,
+}
+
+// syncExternalTrackingIndex writes the carrier~tracking entries of a delivery's current version
+// and drops entries its previous version had that are gone. Either side may be nil.
+func syncExternalTrackingIndex(ctx contractapi.TransactionContextInterface, previous *Delivery, delivery *Delivery) error {
+	stub := ctx.GetStub()
+
+	current := map[string]bool{}
+	if delivery != nil && delivery.Tombstone == nil {
+		for _, tracking := range delivery.ExternalTracking {
+			key, err := stub.CreateCompositeKey(IndexExternalTracking, []string{tracking.CarrierCode, tracking.TrackingNumber, delivery.DeliveryID})
+			if err != nil {
+				return fmt.Errorf("failed to create external tracking composite key: %v", err)
+			}
+			current[key] = true
+			if err := stub.PutState(key, []byte{0x00}); err != nil {
+				return fmt.Errorf("failed to put external tracking index: %v", err)
+			}
+		}
+	}
+
+	if previous == nil {
+		return nil
+	}
+	for _, tracking := range previous.ExternalTracking {
+		key, err := stub.CreateCompositeKey(IndexExternalTracking, []string{tracking.CarrierCode, tracking.TrackingNumber, previous.DeliveryID})
+		if err != nil {
+			return fmt.Errorf("failed to create external tracking composite key: %v", err)
+		}
+		if current[key] {
+			continue
+		}
+		if err := stub.DelState(key); err != nil {
+			return fmt.Errorf("failed to delete external tracking index: %v", err)
+		}
+	}
+	return nil
+}
+
+// AttachExternalTracking hands the next leg of a delivery to an off-network carrier
+// The DELIVERY_PERSON or WAREHOUSE custodian (or ADMIN) records the carrier's tracking number;
+// the delivery moves to OFF_NETWORK_TRANSIT and stays in the custodian's charge until it is
+// handed off again. A tracking number can only belong to one delivery.
+func (c *DeliveryContract) AttachExternalTracking(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	carrierCode string,
+	trackingNumber string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	carrierCode = strings.ToUpper(carrierCode)
+	if !carrierCodePattern.MatchString(carrierCode) {
+		return &ValidationError{Field: "carrierCode", Message: "must be 2-32 characters of letters, digits, '-' or '_'"}
+	}
+	if !trackingNumberPattern.MatchString(trackingNumber) {
+		return &ValidationError{Field: "trackingNumber", Message: "must be 4-64 characters of letters, digits or '-'"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse, RoleAdmin); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if err := validateParty(delivery, caller); err != nil {
+		return err
+	}
+	if caller.Role != RoleAdmin && delivery.CurrentCustodianID != caller.ID {
+		return fmt.Errorf("only the current custodian can attach external tracking")
+	}
+	if !externalTrackingStatuses[delivery.DeliveryStatus] {
+		return fmt.Errorf("cannot attach external tracking in current status: %s", delivery.DeliveryStatus)
+	}
+	if delivery.PendingHandoff != nil || hasPendingReassignment(delivery) {
+		return fmt.Errorf("custody of this delivery is being transferred")
+	}
+	if len(delivery.ExternalTracking) >= maxExternalTracking {
+		return fmt.Errorf("delivery %s already has %d external tracking numbers", deliveryID, maxExternalTracking)
+	}
+
+	existing, err := queryByCompositeKey(ctx, IndexExternalTracking, []string{carrierCode, trackingNumber})
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("%s tracking number %s is already attached to delivery %s", carrierCode, trackingNumber, existing[0])
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	oldStatus := delivery.DeliveryStatus
+	delivery.ExternalTracking = append(delivery.ExternalTracking, ExternalTracking{
+		CarrierCode:    carrierCode,
+		TrackingNumber: trackingNumber,
+		AttachedBy:     caller.ID,
+		AttachedAt:     currentTime,
+	})
+	delivery.DeliveryStatus = StatusOffNetworkTransit
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventExternalTrackingAttached, map[string]string{
+		"deliveryId":     deliveryID,
+		"oldStatus":      string(oldStatus),
+		"newStatus":      string(delivery.DeliveryStatus),
+		"carrierCode":    carrierCode,
+		"trackingNumber": trackingNumber,
+		"attachedBy":     caller.ID,
+		"timestamp":      currentTime,
+	})
+}
+
+// QueryByExternalTracking finds the deliveries an off-network carrier's tracking number belongs to
+// Used by support to reconcile records coming back from third-party systems. ADMIN sees every
+// match in the tenant; other participants only the deliveries they are involved with.
+func (c *DeliveryContract) QueryByExternalTracking(
+	ctx contractapi.TransactionContextInterface,
+	carrierCode string,
+	trackingNumber string,
+) ([]*Delivery, error) {
+	// ========== INPUT VALIDATION ==========
+	carrierCode = strings.ToUpper(carrierCode)
+	if !carrierCodePattern.MatchString(carrierCode) {
+		return nil, &ValidationError{Field: "carrierCode", Message: "must be 2-32 characters of letters, digits, '-' or '_'"}
+	}
+	if !trackingNumberPattern.MatchString(trackingNumber) {
+		return nil, &ValidationError{Field: "trackingNumber", Message: "must be 4-64 characters of letters, digits or '-'"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	deliveryIDs, err := queryByCompositeKey(ctx, IndexExternalTracking, []string{carrierCode, trackingNumber})
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := []*Delivery{}
+	for _, deliveryID := range deliveryIDs {
+		deliveryBytes, err := ctx.GetStub().GetState(deliveryID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get delivery %s: %v", deliveryID, err)
+		}
+		if deliveryBytes == nil {
+			continue
+		}
+
+		var delivery Delivery
+		if err := json.Unmarshal(deliveryBytes, &delivery); err != nil {
+			continue
+		}
+		if err := validateInvolvement(&delivery, caller); err != nil {
+			continue
+		}
+		delivery.StateHash = deliveryStateHash(deliveryBytes)
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key prefixes for fee quotes and settlements
+const (
+	RecordFeeQuote   = "quote~deliveryId~quoteId"
+	RecordSettlement = "settlement~deliveryId"
+)
+
+// Event names for the fee quote workflow
+const (
+	EventFeeQuoted     = "DeliveryFeeQuoted"
+	EventQuoteAccepted = "DeliveryQuoteAccepted"
+)
+
+// Fee quote states
+const (
+	QuoteProposed = "PROPOSED"
+	QuoteAccepted = "ACCEPTED"
+)
+
+// FeeQuote is a fee a courier or dispatcher proposes for an unassigned delivery
+type FeeQuote struct {
+	DeliveryID   string   `json:"deliveryId"`
+	QuoteID      string   `json:"quoteId"`
+	QuotedBy     string   `json:"quotedBy"`
+	QuotedByRole UserRole `json:"quotedByRole"`
+	Fee          Money    `json:"fee"`
+	State        string   `json:"state"`
+	QuotedAt     string   `json:"quotedAt"`
+	AcceptedAt   string   `json:"acceptedAt,omitempty" metadata:",optional"`
+}
+
+// AgreedFee is the accepted quote recorded on a delivery
+type AgreedFee struct {
+	QuoteID    string `json:"quoteId"`
+	QuotedBy   string `json:"quotedBy"`
+	Fee        Money  `json:"fee"`
+	AcceptedAt string `json:"acceptedAt"`
+}
+
+// DeliverySettlement is written at final delivery for downstream payment systems
+type DeliverySettlement struct {
+	DeliveryID   string `json:"deliveryId"`
+	OrderID      string `json:"orderId"`
+	QuoteID      string `json:"quoteId"`
+	Payer        string `json:"payer"`
+	Payee        string `json:"payee"`
+	Fee          Money  `json:"fee"`
+	CODCollected *Money `json:"codCollected,omitempty" metadata:",optional"`
+	// Certified weight discrepancies the payer is re-billed for
+	BillingAdjustments []BillingAdjustment `json:"billingAdjustments,omitempty" metadata:",optional"`
+	DeliveredAt        string              `json:"deliveredAt"`
+	TxID               string              `json:"txId"`
+}
+
+// getFeeQuote reads a fee quote, returning nil when it doesn't exist
+func getFeeQuote(ctx contractapi.TransactionContextInterface, deliveryID string, quoteID string) (*FeeQuote, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(RecordFeeQuote, []string{deliveryID, quoteID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quote composite key: %v", err)
+	}
+	quoteJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quote: %v", err)
+	}
+	if quoteJSON == nil {
+		return nil, nil
+	}
+	var quote FeeQuote
+	if err := json.Unmarshal(quoteJSON, &quote); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quote: %v", err)
+	}
+	return &quote, nil
+}
+
+// putFeeQuote writes a fee quote
+func putFeeQuote(ctx contractapi.TransactionContextInterface, quote *FeeQuote) error {
+	key, err := ctx.GetStub().CreateCompositeKey(RecordFeeQuote, []string{quote.DeliveryID, quote.QuoteID})
+	if err != nil {
+		return fmt.Errorf("failed to create quote composite key: %v", err)
+	}
+	quoteJSON, err := json.Marshal(quote)
+	if err != nil {
+		return fmt.Errorf("failed to marshal quote: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, quoteJSON); err != nil {
+		return fmt.Errorf("failed to put quote: %v", err)
+	}
+	return nil
+}
+
+// recordDeliverySettlement writes the settlement record of a delivery with an agreed fee
+// Called by applyDeliveryUpdate when a delivery reaches CONFIRMED_DELIVERY.
+func recordDeliverySettlement(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
+	if delivery.AgreedFee == nil {
+		return nil
+	}
+	settlement := DeliverySettlement{
+		DeliveryID:  delivery.DeliveryID,
+		OrderID:     delivery.OrderID,
+		QuoteID:     delivery.AgreedFee.QuoteID,
+		Payer:       delivery.SellerID,
+		Payee:       delivery.AgreedFee.QuotedBy,
+		Fee:         delivery.AgreedFee.Fee,
+		DeliveredAt: delivery.UpdatedAt,
+		TxID:        ctx.GetStub().GetTxID(),
+	}
+	if delivery.CODSettlement != nil {
+		settlement.CODCollected = &delivery.CODSettlement.Amount
+	}
+	adjustments, err := getBillingAdjustments(ctx, delivery.DeliveryID)
+	if err != nil {
+		return err
+	}
+	if len(adjustments) > 0 {
+		settlement.BillingAdjustments = adjustments
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(RecordSettlement, []string{delivery.DeliveryID})
+	if err != nil {
+		return fmt.Errorf("failed to create settlement composite key: %v", err)
+	}
+	settlementJSON, err := json.Marshal(settlement)
+	if err != nil {
+		return fmt.Errorf("failed to marshal settlement: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, settlementJSON); err != nil {
+		return fmt.Errorf("failed to put settlement: %v", err)
+	}
+	return nil
+}
+
+// QuoteDeliveryFee proposes a fee for a delivery still waiting for a courier on the job board
+// A DELIVERY_PERSON (or ADMIN acting as dispatcher) can quote while the delivery is PENDING_PICKUP
+// with no handoff in progress and no fee agreed yet. Returns the quote ID.
+func (c *DeliveryContract) QuoteDeliveryFee(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	currency string,
+	feeMinor int64,
+) (string, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return "", err
+	}
+	fee, err := newMoney(feeMinor, currency, "fee")
+	if err != nil {
+		return "", err
+	}
+	if fee.AmountMinor == 0 {
+		return "", &ValidationError{Field: "fee", Message: "must be greater than 0"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - couriers and dispatchers quote
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleAdmin); err != nil {
+		return "", err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return "", err
+	}
+	if delivery.TenantID != caller.TenantID {
+		return "", fmt.Errorf("not authorized to access this delivery")
+	}
+	if delivery.DeliveryStatus != StatusPendingPickup || delivery.PendingHandoff != nil {
+		return "", fmt.Errorf("can only quote a delivery waiting for pickup")
+	}
+	if delivery.AgreedFee != nil {
+		return "", fmt.Errorf("delivery %s already has an agreed fee", deliveryID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	quote := &FeeQuote{
+		DeliveryID:   deliveryID,
+		QuoteID:      ctx.GetStub().GetTxID(),
+		QuotedBy:     caller.ID,
+		QuotedByRole: caller.Role,
+		Fee:          fee,
+		State:        QuoteProposed,
+		QuotedAt:     currentTime,
+	}
+	if err := putFeeQuote(ctx, quote); err != nil {
+		return "", err
+	}
+
+	if err := emitEvent(ctx, EventFeeQuoted, quote); err != nil {
+		return "", err
+	}
+	return quote.QuoteID, nil
+}
+
+// AcceptQuote accepts a proposed fee and records it on the delivery
+// Only the SELLER of the delivery can accept, while it is still PENDING_PICKUP. The agreed
+// fee is referenced by the settlement record written at final delivery.
+func (c *DeliveryContract) AcceptQuote(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	quoteID string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	if len(quoteID) == 0 {
+		return &ValidationError{Field: "quoteID", Message: "cannot be empty"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER accepts quotes
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller can accept quotes for this delivery")
+	}
+	if delivery.DeliveryStatus != StatusPendingPickup {
+		return fmt.Errorf("can only accept a quote before pickup")
+	}
+	if delivery.AgreedFee != nil {
+		return fmt.Errorf("delivery %s already has an agreed fee", deliveryID)
+	}
+
+	quote, err := getFeeQuote(ctx, deliveryID, quoteID)
+	if err != nil {
+		return err
+	}
+	if quote == nil {
+		return fmt.Errorf("quote %s does not exist for delivery %s", quoteID, deliveryID)
+	}
+	if quote.State != QuoteProposed {
+		return fmt.Errorf("quote %s is %s", quoteID, quote.State)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	quote.State = QuoteAccepted
+	quote.AcceptedAt = currentTime
+	if err := putFeeQuote(ctx, quote); err != nil {
+		return err
+	}
+
+	delivery.AgreedFee = &AgreedFee{
+		QuoteID:    quote.QuoteID,
+		QuotedBy:   quote.QuotedBy,
+		Fee:        quote.Fee,
+		AcceptedAt: currentTime,
+	}
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventQuoteAccepted, map[string]interface{}{
+		"deliveryId": deliveryID,
+		"quoteId":    quote.QuoteID,
+		"quotedBy":   quote.QuotedBy,
+		"currency":   quote.Fee.Currency,
+		"feeMinor":   quote.Fee.AmountMinor,
+		"acceptedBy": caller.ID,
+		"timestamp":  currentTime,
+	})
+}
+
+// GetFeeQuotes lists the fee quotes of a delivery
+// The seller and ADMIN see every quote; couriers see only their own.
+func (c *DeliveryContract) GetFeeQuotes(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*FeeQuote, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleSeller, RoleDeliveryPerson, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.TenantID != caller.TenantID || (caller.Role == RoleSeller && delivery.SellerID != caller.ID) {
+		return nil, fmt.Errorf("not authorized to access this delivery")
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordFeeQuote, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quotes: %v", err)
+	}
+	defer iterator.Close()
+
+	quotes := []*FeeQuote{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate quotes: %v", err)
+		}
+
+		var quote FeeQuote
+		if err := json.Unmarshal(response.Value, &quote); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal quote: %v", err)
+		}
+		if caller.Role == RoleDeliveryPerson && quote.QuotedBy != caller.ID {
+			continue
+		}
+		quotes = append(quotes, &quote)
+	}
+
+	return quotes, nil
+}
+
+// GetDeliverySettlement returns the settlement record written when a delivery with an agreed
+// fee was confirmed. Any party involved in the delivery (or admin) can read it.
+func (c *DeliveryContract) GetDeliverySettlement(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) (*DeliverySettlement, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(RecordSettlement, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create settlement composite key: %v", err)
+	}
+	settlementJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settlement: %v", err)
+	}
+	if settlementJSON == nil {
+		return nil, fmt.Errorf("delivery %s has no settlement", deliveryID)
+	}
+
+	var settlement DeliverySettlement
+	if err := json.Unmarshal(settlementJSON, &settlement); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal settlement: %v", err)
+	}
+	return &settlement, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EventDeliveryFieldsUpdated is emitted when UpdateDeliveryFields changes a delivery
+const EventDeliveryFieldsUpdated = "DeliveryFieldsUpdated"
+
+// maxDeliveryNotesLength bounds the free-text notes kept on a delivery
+const maxDeliveryNotesLength = 500
+
+// patchableField is a delivery attribute UpdateDeliveryFields may change
+// apply validates the JSON value (null clears the field) and sets it on the delivery; it is
+// only called for roles listed in roles.
+type patchableField struct {
+	roles []UserRole
+	apply func(ctx contractapi.TransactionContextInterface, caller *CallerIdentity, delivery *Delivery, value json.RawMessage) error
+}
+
+// isJSONNull reports whether a merge-patch value removes its field
+func isJSONNull(value json.RawMessage) bool {
+	return strings.TrimSpace(string(value)) == "null"
+}
+
+// patchableFields is the whitelist of UpdateDeliveryFields, by JSON field name
+var patchableFields = map[string]patchableField{
+	// The custodian's estimate of when the package reaches the customer
+	"estimatedArrival": {
+		roles: []UserRole{RoleDeliveryPerson, RoleWarehouse, RoleAdmin},
+		apply: func(ctx contractapi.TransactionContextInterface, caller *CallerIdentity, delivery *Delivery, value json.RawMessage) error {
+			if caller.Role != RoleAdmin && delivery.CurrentCustodianID != caller.ID {
+				return &ValidationError{Field: "estimatedArrival", Message: "only the current custodian can estimate the arrival"}
+			}
+			if isJSONNull(value) {
+				delivery.EstimatedArrival = ""
+				return nil
+			}
+			var estimate string
+			if err := json.Unmarshal(value, &estimate); err != nil {
+				return &ValidationError{Field: "estimatedArrival", Message: "must be an RFC 3339 timestamp or null"}
+			}
+			at, err := time.Parse(time.RFC3339, estimate)
+			if err != nil {
+				return &ValidationError{Field: "estimatedArrival", Message: "must be an RFC 3339 timestamp or null"}
+			}
+			delivery.EstimatedArrival = at.UTC().Format(time.RFC3339)
+			return nil
+		},
+	},
+	// Seller or admin notes shown with the delivery
+	"notes": {
+		roles: []UserRole{RoleSeller, RoleAdmin},
+		apply: func(ctx contractapi.TransactionContextInterface, caller *CallerIdentity, delivery *Delivery, value json.RawMessage) error {
+			if caller.Role == RoleSeller && delivery.SellerID != caller.ID {
+				return &ValidationError{Field: "notes", Message: "only the seller can edit the notes of this delivery"}
+			}
+			if isJSONNull(value) {
+				delivery.Notes = ""
+				return nil
+			}
+			var notes string
+			if err := json.Unmarshal(value, &notes); err != nil {
+				return &ValidationError{Field: "notes", Message: "must be a string or null"}
+			}
+			notes = sanitizeText(notes)
+			if err := validateText(notes, "notes", maxDeliveryNotesLength, true); err != nil {
+				return err
+			}
+			delivery.Notes = notes
+			return nil
+		},
+	},
+	// Rebooking the service tier is only possible before pickup; expedited tiers are checked
+	// against the origin region's business calendar as if booked now
+	"serviceTier": {
+		roles: []UserRole{RoleSeller, RoleAdmin},
+		apply: func(ctx contractapi.TransactionContextInterface, caller *CallerIdentity, delivery *Delivery, value json.RawMessage) error {
+			if caller.Role == RoleSeller && delivery.SellerID != caller.ID {
+				return &ValidationError{Field: "serviceTier", Message: "only the seller can rebook this delivery"}
+			}
+			if delivery.DeliveryStatus != StatusPendingPickup {
+				return &ValidationError{Field: "serviceTier", Message: "can only change before pickup"}
+			}
+			var tier ServiceTier
+			if err := json.Unmarshal(value, &tier); err != nil || isJSONNull(value) {
+				return &ValidationError{Field: "serviceTier", Message: "must be STANDARD, EXPRESS or OVERNIGHT"}
+			}
+			if err := validateServiceTier(tier); err != nil {
+				return err
+			}
+			delivery.ServiceTier = tier
+			if err := validateServicePromise(ctx, delivery); err != nil {
+				return &ValidationError{Field: "serviceTier", Message: err.Error()}
+			}
+			return nil
+		},
+	},
+}
+
+// UpdateDeliveryFields applies a JSON merge patch of whitelisted fields to a delivery
+// patchJSON is an object such as {"estimatedArrival":"2025-03-01T12:00:00Z","notes":null};
+// null clears a field. Each field has its own roles and checks: estimatedArrival (current
+// DELIVERY_PERSON or WAREHOUSE custodian, ADMIN), notes (the SELLER, ADMIN) and serviceTier
+// (the SELLER before pickup, ADMIN). expectedVersion is the delivery's stateHash from the
+// caller's last read; the patch is rejected if the delivery changed since.
+// Invalid patches fail with VALIDATION_FAILED listing every rejected field, and applied
+// patches leave a correction record with the delivery before and after.
+func (c *DeliveryContract) UpdateDeliveryFields(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	patchJSON string,
+	expectedVersion string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(patchJSON), &patch); err != nil {
+		return &ValidationError{Field: "patch", Message: "must be a JSON object"}
+	}
+	if len(patch) == 0 {
+		return &ValidationError{Field: "patch", Message: "must change at least one field"}
+	}
+	if !identityHashPattern.MatchString(expectedVersion) {
+		return &ValidationError{Field: "expectedVersion", Message: "must be the stateHash returned by a previous read"}
+	}
+	fields := make([]string, 0, len(patch))
+	for field := range patch {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - each field narrows this down further
+	if err := validateRole(ctx, caller, RoleSeller, RoleDeliveryPerson, RoleWarehouse, RoleAdmin); err != nil {
+		return err
+	}
+
+	deliveryJSON, err := ctx.GetStub().GetState(deliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to read delivery from world state: %v", err)
+	}
+	if deliveryJSON == nil {
+		return fmt.Errorf("delivery %s does not exist", deliveryID)
+	}
+	if deliveryStateHash(deliveryJSON) != expectedVersion {
+		return fmt.Errorf("delivery %s has changed since version %s; read it again and reapply the patch", deliveryID, expectedVersion)
+	}
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.TenantID != caller.TenantID {
+		return fmt.Errorf("not authorized to access this delivery")
+	}
+	if retainableStatuses[delivery.DeliveryStatus] {
+		return fmt.Errorf("cannot update delivery fields in current status: %s", delivery.DeliveryStatus)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	before := *delivery
+	delivery.UpdatedAt = currentTime
+
+	var errs ValidationErrors
+	for _, field := range fields {
+		patchable, ok := patchableFields[field]
+		if !ok {
+			errs.collect(&ValidationError{Field: field, Message: "cannot be updated with a patch"})
+			continue
+		}
+		allowed := false
+		for _, role := range patchable.roles {
+			if caller.Role == role {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			errs.collect(&ValidationError{Field: field, Message: fmt.Sprintf("cannot be updated by role %s", caller.Role)})
+			continue
+		}
+		errs.collect(patchable.apply(ctx, caller, delivery, patch[field]))
+	}
+	if err := errs.err(); err != nil {
+		return err
+	}
+
+	if err := recordCorrection(ctx, "UpdateDeliveryFields", "patched "+strings.Join(fields, ", "), &before, delivery, caller); err != nil {
+		return err
+	}
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventDeliveryFieldsUpdated, map[string]string{
+		"deliveryId": deliveryID,
+		"fields":     strings.Join(fields, ","),
+		"updatedBy":  caller.ID,
+		"timestamp":  currentTime,
+	})
+}
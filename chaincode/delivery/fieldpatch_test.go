@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// version returns the stateHash a client would pass as expectedVersion
+func (n *testNetwork) version(deliveryID string) string {
+	n.t.Helper()
+	return deliveryStateHash(n.stub.State[deliveryID])
+}
+
+func TestUpdateDeliveryFieldsChecksVersion(t *testing.T) {
+	network := newTestNetwork(t)
+	network.putDelivery(testDelivery(testDeliveryID, StatusInTransit))
+	stale := network.version(testDeliveryID)
+
+	if _, err := network.invoke(testSeller, "UpdateDeliveryFields", testDeliveryID, `{"notes":"fragile"}`, stale); err != nil {
+		t.Fatalf("failed to patch the current version: %v", err)
+	}
+	if got := network.getDelivery(testDeliveryID).Notes; got != "fragile" {
+		t.Fatalf("notes are %q, want %q", got, "fragile")
+	}
+
+	// The first patch changed the delivery, so the version it was based on is stale
+	_, err := network.invoke(testSeller, "UpdateDeliveryFields", testDeliveryID, `{"notes":"handle with care"}`, stale)
+	if err == nil || !strings.Contains(err.Error(), "has changed since version") {
+		t.Fatalf("expected the stale version to be rejected, got %v", err)
+	}
+	if got := network.getDelivery(testDeliveryID).Notes; got != "fragile" {
+		t.Errorf("stale patch changed the notes to %q", got)
+	}
+
+	corrections := network.corrections(testDeliveryID)
+	if len(corrections) != 1 {
+		t.Fatalf("got %d correction records, want 1", len(corrections))
+	}
+	correction := corrections[0]
+	if correction.Operation != "UpdateDeliveryFields" || correction.ApprovedBy != testSeller.id {
+		t.Errorf("unexpected correction record: %+v", correction)
+	}
+	if correction.Before.Notes != "" || correction.After.Notes != "fragile" {
+		t.Errorf("correction changes notes from %q to %q", correction.Before.Notes, correction.After.Notes)
+	}
+}
+
+func TestUpdateDeliveryFieldsRejectsFieldsByRole(t *testing.T) {
+	support := testIdentity{mspID: MSPPlatform, id: "support1", role: string(RoleSupport)}
+	tests := []struct {
+		name   string
+		caller testIdentity
+		patch  string
+		want   string
+	}{
+		{"courier edits notes", testCourier, `{"notes":"left at depot"}`, `"field":"notes","message":"cannot be updated by role DELIVERY_PERSON"`},
+		{"seller estimates arrival", testSeller, `{"estimatedArrival":"2026-01-02T12:00:00Z"}`, `"field":"estimatedArrival","message":"cannot be updated by role SELLER"`},
+		{"courier rebooks tier", testCourier, `{"serviceTier":"EXPRESS"}`, `"field":"serviceTier","message":"cannot be updated by role DELIVERY_PERSON"`},
+		{"support edits notes", support, `{"notes":"customer called"}`, "is not authorized"},
+		{"customer edits notes", testCustomer, `{"notes":"ring twice"}`, "is not authorized"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			network := newTestNetwork(t)
+			network.putDelivery(testDelivery(testDeliveryID, StatusInTransit))
+			before := network.stub.State[testDeliveryID]
+
+			_, err := network.invoke(tt.caller, "UpdateDeliveryFields", testDeliveryID, tt.patch, network.version(testDeliveryID))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error containing %s, got %v", tt.want, err)
+			}
+			if string(network.stub.State[testDeliveryID]) != string(before) {
+				t.Errorf("rejected patch changed the delivery")
+			}
+		})
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key prefixes for fraud heuristics
+// Address sightings are kept in the private details collection, so address hashes never reach
+// the public ledger where they could be matched against known addresses.
+const (
+	RecordFraudSignal        = "fraudSignal~signalId"
+	IndexFraudSignalStatus   = "fraudSignalStatus~status~signalId"
+	RecordAddressSightings   = "fraudAddress~addressHash"
+	RecordCustomerCancelRate = "fraudCancellations~customerId"
+)
+
+// FraudSignalType is the heuristic that raised a fraud signal
+type FraudSignalType string
+
+const (
+	FraudAddressReuse         FraudSignalType = "ADDRESS_REUSE"
+	FraudCancellationVelocity FraudSignalType = "CANCELLATION_VELOCITY"
+)
+
+// Fraud signal review states
+const (
+	FraudSignalOpen      = "OPEN"
+	FraudSignalDismissed = "DISMISSED"
+	FraudSignalConfirmed = "CONFIRMED"
+)
+
+// Bounds of the per-address and per-customer tracking records
+const (
+	maxAddressSightings     = 200
+	maxCancellationsTracked = 100
+)
+
+// FraudSignal is a suspicion raised by a heuristic, waiting in the platform's review queue
+// The signal ID is the ID of the transaction that raised it.
+type FraudSignal struct {
+	SignalID           string          `json:"signalId"`
+	Type               FraudSignalType `json:"type"`
+	DeliveryID         string          `json:"deliveryId"`
+	CustomerID         string          `json:"customerId"`
+	Count              int             `json:"count"`
+	Threshold          int             `json:"threshold"`
+	WindowHours        int             `json:"windowHours"`
+	RelatedDeliveryIDs []string        `json:"relatedDeliveryIds"`
+	Status             string          `json:"status"`
+	DetectedAt         string          `json:"detectedAt"`
+	ReviewedBy         string          `json:"reviewedBy,omitempty" metadata:",optional"`
+	ReviewNotes        string          `json:"reviewNotes,omitempty" metadata:",optional"`
+	ReviewedAt         string          `json:"reviewedAt,omitempty" metadata:",optional"`
+}
+
+// FraudSignalPage is one page of fraud signals in a given status
+type FraudSignalPage struct {
+	Signals  []*FraudSignal `json:"signals"`
+	Bookmark string         `json:"bookmark"`
+}
+
+// fraudSighting is a delivery seen by a heuristic: sent to an address, or cancelled
+type fraudSighting struct {
+	CustomerID string `json:"customerId"`
+	DeliveryID string `json:"deliveryId"`
+	SeenAt     string `json:"seenAt"`
+}
+
+// addressSightings are the recent deliveries to one address
+type addressSightings struct {
+	Sightings  []fraudSighting `json:"sightings"`
+	SignaledAt string          `json:"signaledAt,omitempty"`
+}
+
+// customerCancellations are the recent cancellations of one customer
+type customerCancellations struct {
+	Cancellations []fraudSighting `json:"cancellations"`
+	SignaledAt    string          `json:"signaledAt,omitempty"`
+}
+
+// addressHash returns the hash identifying a delivery address, whatever its spacing or case
+func addressHash(details *DeliveryPrivateDetails, country string) string {
+	parts := []string{
+		strings.ToLower(collapseSpaces(details.DeliveryStreet)),
+		strings.ToLower(collapseSpaces(details.DeliveryApartment)),
+		details.DeliveryPostalCode,
+		strings.ToUpper(country),
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(parts, "\n"))))
+}
+
+// withinWindow reports whether a timestamp falls after the window start
+func withinWindow(timestamp string, windowStart time.Time) bool {
+	at, err := time.Parse(time.RFC3339, timestamp)
+	return err == nil && at.After(windowStart)
+}
+
+// raiseFraudSignal writes an OPEN fraud signal into the review queue
+// No event is emitted: the transaction's own event stays the one listeners get.
+func raiseFraudSignal(ctx contractapi.TransactionContextInterface, signal *FraudSignal) error {
+	signal.SignalID = ctx.GetStub().GetTxID()
+	signal.Status = FraudSignalOpen
+	return putFraudSignal(ctx, signal, "")
+}
+
+// putFraudSignal writes a fraud signal and moves its status index entry
+func putFraudSignal(ctx contractapi.TransactionContextInterface, signal *FraudSignal, oldStatus string) error {
+	stub := ctx.GetStub()
+
+	signalKey, err := stub.CreateCompositeKey(RecordFraudSignal, []string{signal.SignalID})
+	if err != nil {
+		return fmt.Errorf("failed to create fraud signal composite key: %v", err)
+	}
+	signalJSON, err := json.Marshal(signal)
+	if err != nil {
+		return fmt.Errorf("failed to marshal fraud signal: %v", err)
+	}
+	if err := stub.PutState(signalKey, signalJSON); err != nil {
+		return fmt.Errorf("failed to put fraud signal: %v", err)
+	}
+
+	if oldStatus != "" && oldStatus != signal.Status {
+		oldStatusKey, err := stub.CreateCompositeKey(IndexFraudSignalStatus, []string{oldStatus, signal.SignalID})
+		if err != nil {
+			return fmt.Errorf("failed to create fraud signal status composite key: %v", err)
+		}
+		if err := stub.DelState(oldStatusKey); err != nil {
+			return fmt.Errorf("failed to delete fraud signal status index: %v", err)
+		}
+	}
+	statusKey, err := stub.CreateCompositeKey(IndexFraudSignalStatus, []string{signal.Status, signal.SignalID})
+	if err != nil {
+		return fmt.Errorf("failed to create fraud signal status composite key: %v", err)
+	}
+	return stub.PutState(statusKey, []byte{0x00})
+}
+
+// checkAddressReuse records a delivery's address and raises an ADDRESS_REUSE signal when
+// fraudAddressCustomerThreshold distinct customers used it within fraudAddressWindowHours
+// Called when private details are set; a delivery whose address changes is counted again.
+func checkAddressReuse(ctx contractapi.TransactionContextInterface, delivery *Delivery, details *DeliveryPrivateDetails) error {
+	settings, err := getContractSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if settings.FraudAddressCustomerThreshold == 0 {
+		return nil
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	windowStart := now.Add(-time.Duration(settings.FraudAddressWindowHours) * time.Hour)
+
+	stub := ctx.GetStub()
+	key, err := stub.CreateCompositeKey(RecordAddressSightings, []string{addressHash(details, delivery.LastLocation.Country)})
+	if err != nil {
+		return fmt.Errorf("failed to create address sightings composite key: %v", err)
+	}
+	recordJSON, err := stub.GetPrivateData(CollectionDeliveryPrivate, key)
+	if err != nil {
+		return fmt.Errorf("failed to read address sightings: %v", err)
+	}
+	var record addressSightings
+	if recordJSON != nil {
+		if err := json.Unmarshal(recordJSON, &record); err != nil {
+			return fmt.Errorf("failed to unmarshal address sightings: %v", err)
+		}
+	}
+
+	// Keep the sightings still in the window, oldest dropped first once the bound is reached
+	kept := []fraudSighting{}
+	for _, sighting := range record.Sightings {
+		if sighting.DeliveryID != delivery.DeliveryID && withinWindow(sighting.SeenAt, windowStart) {
+			kept = append(kept, sighting)
+		}
+	}
+	if len(kept) >= maxAddressSightings {
+		kept = kept[len(kept)-maxAddressSightings+1:]
+	}
+	record.Sightings = append(kept, fraudSighting{
+		CustomerID: delivery.CustomerID,
+		DeliveryID: delivery.DeliveryID,
+		SeenAt:     now.Format(time.RFC3339),
+	})
+
+	customers := map[string]bool{}
+	related := []string{}
+	for _, sighting := range record.Sightings {
+		customers[sighting.CustomerID] = true
+		if sighting.DeliveryID != delivery.DeliveryID {
+			related = append(related, sighting.DeliveryID)
+		}
+	}
+	flag := len(customers) >= settings.FraudAddressCustomerThreshold &&
+		(record.SignaledAt == "" || !withinWindow(record.SignaledAt, windowStart))
+	if flag {
+		record.SignaledAt = now.Format(time.RFC3339)
+	}
+
+	updatedJSON, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal address sightings: %v", err)
+	}
+	if err := stub.PutPrivateData(CollectionDeliveryPrivate, key, updatedJSON); err != nil {
+		return fmt.Errorf("failed to put address sightings: %v", err)
+	}
+	if !flag {
+		return nil
+	}
+
+	return raiseFraudSignal(ctx, &FraudSignal{
+		Type:               FraudAddressReuse,
+		DeliveryID:         delivery.DeliveryID,
+		CustomerID:         delivery.CustomerID,
+		Count:              len(customers),
+		Threshold:          settings.FraudAddressCustomerThreshold,
+		WindowHours:        settings.FraudAddressWindowHours,
+		RelatedDeliveryIDs: related,
+		DetectedAt:         now.Format(time.RFC3339),
+	})
+}
+
+// checkCancellationVelocity records a customer's cancellation and raises a
+// CANCELLATION_VELOCITY signal at fraudCancellationThreshold cancellations within
+// fraudCancellationWindowHours
+func checkCancellationVelocity(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
+	settings, err := getContractSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if settings.FraudCancellationThreshold == 0 {
+		return nil
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	windowStart := now.Add(-time.Duration(settings.FraudCancellationWindowHours) * time.Hour)
+
+	stub := ctx.GetStub()
+	key, err := stub.CreateCompositeKey(RecordCustomerCancelRate, []string{delivery.CustomerID})
+	if err != nil {
+		return fmt.Errorf("failed to create cancellations composite key: %v", err)
+	}
+	recordJSON, err := stub.GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read cancellations: %v", err)
+	}
+	var record customerCancellations
+	if recordJSON != nil {
+		if err := json.Unmarshal(recordJSON, &record); err != nil {
+			return fmt.Errorf("failed to unmarshal cancellations: %v", err)
+		}
+	}
+
+	kept := []fraudSighting{}
+	for _, cancellation := range record.Cancellations {
+		if withinWindow(cancellation.SeenAt, windowStart) {
+			kept = append(kept, cancellation)
+		}
+	}
+	if len(kept) >= maxCancellationsTracked {
+		kept = kept[len(kept)-maxCancellationsTracked+1:]
+	}
+	record.Cancellations = append(kept, fraudSighting{
+		CustomerID: delivery.CustomerID,
+		DeliveryID: delivery.DeliveryID,
+		SeenAt:     now.Format(time.RFC3339),
+	})
+
+	flag := len(record.Cancellations) >= settings.FraudCancellationThreshold &&
+		(record.SignaledAt == "" || !withinWindow(record.SignaledAt, windowStart))
+	if flag {
+		record.SignaledAt = now.Format(time.RFC3339)
+	}
+
+	updatedJSON, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cancellations: %v", err)
+	}
+	if err := stub.PutState(key, updatedJSON); err != nil {
+		return fmt.Errorf("failed to put cancellations: %v", err)
+	}
+	if !flag {
+		return nil
+	}
+
+	related := []string{}
+	for _, cancellation := range record.Cancellations {
+		if cancellation.DeliveryID != delivery.DeliveryID {
+			related = append(related, cancellation.DeliveryID)
+		}
+	}
+	return raiseFraudSignal(ctx, &FraudSignal{
+		Type:               FraudCancellationVelocity,
+		DeliveryID:         delivery.DeliveryID,
+		CustomerID:         delivery.CustomerID,
+		Count:              len(record.Cancellations),
+		Threshold:          settings.FraudCancellationThreshold,
+		WindowHours:        settings.FraudCancellationWindowHours,
+		RelatedDeliveryIDs: related,
+		DetectedAt:         now.Format(time.RFC3339),
+	})
+}
+
+// QueryFraudSignals returns a page of fraud signals in a status (OPEN, DISMISSED or CONFIRMED)
+// Only ADMIN and SUPPORT can read the review queue
+func (c *DeliveryContract) QueryFraudSignals(
+	ctx contractapi.TransactionContextInterface,
+	status string,
+	pageSize int,
+	bookmark string,
+) (*FraudSignalPage, error) {
+	// ========== INPUT VALIDATION ==========
+	switch status {
+	case FraudSignalOpen, FraudSignalDismissed, FraudSignalConfirmed:
+	default:
+		return nil, &ValidationError{Field: "status", Message: fmt.Sprintf("unknown fraud signal status: %s", status)}
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		return nil, &ValidationError{Field: "pageSize", Message: "must be between 1 and 100"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - the review queue belongs to the platform
+	if err := validateRole(ctx, caller, RoleAdmin, RoleSupport); err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	iterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(IndexFraudSignalStatus, []string{status}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fraud signals: %v", err)
+	}
+	defer iterator.Close()
+
+	page := &FraudSignalPage{Signals: []*FraudSignal{}, Bookmark: metadata.Bookmark}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate fraud signals: %v", err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(response.Key)
+		if err != nil || len(attrs) != 2 {
+			continue
+		}
+
+		signal, err := getFraudSignal(ctx, attrs[1])
+		if err != nil {
+			continue
+		}
+		page.Signals = append(page.Signals, signal)
+	}
+
+	return page, nil
+}
+
+// getFraudSignal reads a fraud signal by ID
+func getFraudSignal(ctx contractapi.TransactionContextInterface, signalID string) (*FraudSignal, error) {
+	signalKey, err := ctx.GetStub().CreateCompositeKey(RecordFraudSignal, []string{signalID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fraud signal composite key: %v", err)
+	}
+	signalJSON, err := ctx.GetStub().GetState(signalKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fraud signal: %v", err)
+	}
+	if signalJSON == nil {
+		return nil, fmt.Errorf("fraud signal %s does not exist", signalID)
+	}
+
+	var signal FraudSignal
+	if err := json.Unmarshal(signalJSON, &signal); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal fraud signal: %v", err)
+	}
+	return &signal, nil
+}
+
+// ReviewFraudSignal closes an OPEN fraud signal as DISMISSED or CONFIRMED
+// Only ADMIN can review signals
+func (c *DeliveryContract) ReviewFraudSignal(
+	ctx contractapi.TransactionContextInterface,
+	signalID string,
+	outcome string,
+	notes string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if len(signalID) == 0 {
+		return &ValidationError{Field: "signalID", Message: "cannot be empty"}
+	}
+	if outcome != FraudSignalDismissed && outcome != FraudSignalConfirmed {
+		return &ValidationError{Field: "outcome", Message: "must be DISMISSED or CONFIRMED"}
+	}
+	notes = sanitizeText(notes)
+	if err := validateText(notes, "notes", 1000, false); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can review fraud signals
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
+		return err
+	}
+
+	signal, err := getFraudSignal(ctx, signalID)
+	if err != nil {
+		return err
+	}
+	if signal.Status != FraudSignalOpen {
+		return fmt.Errorf("fraud signal %s was already reviewed", signalID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	signal.Status = outcome
+	signal.ReviewedBy = caller.ID
+	signal.ReviewNotes = notes
+	signal.ReviewedAt = currentTime
+
+	return putFraudSignal(ctx, signal, FraudSignalOpen)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransientCourierCoordinates is the transient map key for the courier's GPS position at final delivery
+const TransientCourierCoordinates = "courierCoordinates"
+
+// GeofenceMode controls what happens to an out-of-zone final confirmation
+type GeofenceMode string
+
+const (
+	GeofenceModeFlag   GeofenceMode = "FLAG"
+	GeofenceModeReject GeofenceMode = "REJECT"
+)
+
+// earthRadiusMeters is the mean Earth radius used for haversine distances
+const earthRadiusMeters = 6371000.0
+
+// geohashAlphabet is the base32 alphabet used by geohashes
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Coordinates is a WGS84 position reported by the courier's device
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// decodeGeohash returns the center of a geohash cell
+func decodeGeohash(geohash string) (float64, float64, error) {
+	if len(geohash) == 0 || len(geohash) > 12 {
+		return 0, 0, &ValidationError{Field: "destinationGeohash", Message: "must be between 1 and 12 characters"}
+	}
+
+	latMin, latMax := -90.0, 90.0
+	lonMin, lonMax := -180.0, 180.0
+	evenBit := true
+	for _, ch := range strings.ToLower(geohash) {
+		idx := strings.IndexRune(geohashAlphabet, ch)
+		if idx < 0 {
+			return 0, 0, &ValidationError{Field: "destinationGeohash", Message: fmt.Sprintf("invalid geohash character %q", ch)}
+		}
+		for bit := 4; bit >= 0; bit-- {
+			set := idx&(1<<uint(bit)) != 0
+			if evenBit {
+				mid := (lonMin + lonMax) / 2
+				if set {
+					lonMin = mid
+				} else {
+					lonMax = mid
+				}
+			} else {
+				mid := (latMin + latMax) / 2
+				if set {
+					latMin = mid
+				} else {
+					latMax = mid
+				}
+			}
+			evenBit = !evenBit
+		}
+	}
+
+	return (latMin + latMax) / 2, (lonMin + lonMax) / 2, nil
+}
+
+// haversineMeters returns the great-circle distance between two positions
+func haversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+}
+
+// checkGeofence validates the courier's coordinates against the delivery's destination geohash
+// Deliveries without a destination geohash in their private details are not geofenced.
+// In FLAG mode an out-of-zone confirmation is marked on the delivery; in REJECT mode it fails.
+func checkGeofence(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
+	privateDetailsJSON, err := ctx.GetStub().GetPrivateData(CollectionDeliveryPrivate, delivery.DeliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to get private details: %v", err)
+	}
+	if privateDetailsJSON == nil {
+		return nil
+	}
+	var privateDetails DeliveryPrivateDetails
+	if err := json.Unmarshal(privateDetailsJSON, &privateDetails); err != nil {
+		return fmt.Errorf("failed to parse private details: %v", err)
+	}
+	if privateDetails.DestinationGeohash == "" {
+		return nil
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient data: %v", err)
+	}
+	coordinatesJSON, exists := transientMap[TransientCourierCoordinates]
+	if !exists || len(coordinatesJSON) == 0 {
+		return fmt.Errorf("final delivery confirmation requires courier coordinates (%s)", TransientCourierCoordinates)
+	}
+	var coordinates Coordinates
+	if err := json.Unmarshal(coordinatesJSON, &coordinates); err != nil {
+		return fmt.Errorf("failed to parse courier coordinates: %v", err)
+	}
+	if coordinates.Latitude < -90 || coordinates.Latitude > 90 || coordinates.Longitude < -180 || coordinates.Longitude > 180 {
+		return &ValidationError{Field: TransientCourierCoordinates, Message: "latitude/longitude out of range"}
+	}
+
+	destLat, destLon, err := decodeGeohash(privateDetails.DestinationGeohash)
+	if err != nil {
+		return err
+	}
+
+	settings, err := getContractSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	distance := haversineMeters(coordinates.Latitude, coordinates.Longitude, destLat, destLon)
+	if distance <= float64(settings.GeofenceRadiusMeters) {
+		delivery.GeofenceFlagged = false
+		return nil
+	}
+
+	if settings.GeofenceMode == GeofenceModeReject {
+		return fmt.Errorf("confirmation is outside the delivery zone (%.0f m from destination, limit %d m)", distance, settings.GeofenceRadiusMeters)
+	}
+	delivery.GeofenceFlagged = true
+	return nil
+}
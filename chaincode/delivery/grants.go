@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Event names for delivery access grants
+const (
+	EventDeliveryAccessGranted = "DeliveryAccessGranted"
+	EventDeliveryAccessRevoked = "DeliveryAccessRevoked"
+)
+
+// maxAccessGrants bounds the grants stored on one delivery record
+const maxAccessGrants = 20
+
+// GrantScope is how much of a delivery a third party may read
+type GrantScope string
+
+const (
+	// GrantScopeTracking covers the delivery record and its location history
+	GrantScopeTracking GrantScope = "TRACKING"
+	// GrantScopeFull covers every read open to involved parties (items, exceptions, telemetry, ...)
+	GrantScopeFull GrantScope = "FULL"
+)
+
+// AccessGrant lets a third party (an insurer, a procurement team) read a delivery
+// Grants are read-only: grantees never pass validateParty, so they can't change anything.
+type AccessGrant struct {
+	GranteeID string     `json:"granteeId"`
+	Scope     GrantScope `json:"scope"`
+	GrantedBy string     `json:"grantedBy"`
+	GrantedAt string     `json:"grantedAt"`
+}
+
+// hasAccessGrant reports whether a user holds a grant covering the scope (FULL covers TRACKING)
+func hasAccessGrant(delivery *Delivery, userID string, scope GrantScope) bool {
+	for _, grant := range delivery.AccessGrants {
+		if grant.GranteeID != userID {
+			continue
+		}
+		if grant.Scope == GrantScopeFull || grant.Scope == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTrackingAccess checks if the caller may follow a delivery (involved or TRACKING grant)
+func validateTrackingAccess(delivery *Delivery, caller *CallerIdentity) error {
+	if err := validateInvolvement(delivery, caller); err == nil {
+		return nil
+	}
+	if delivery.TenantID == caller.TenantID && hasAccessGrant(delivery, caller.ID, GrantScopeTracking) {
+		return nil
+	}
+	return fmt.Errorf("not authorized to access this delivery")
+}
+
+// validateGrantingParty checks the caller is the seller or customer of a delivery, or ADMIN
+func validateGrantingParty(delivery *Delivery, caller *CallerIdentity) error {
+	if delivery.TenantID != caller.TenantID {
+		return fmt.Errorf("not authorized to access this delivery")
+	}
+	if caller.Role == RoleAdmin || delivery.SellerID == caller.ID || delivery.CustomerID == caller.ID {
+		return nil
+	}
+	return fmt.Errorf("only the seller, customer or ADMIN can manage access grants")
+}
+
+// GrantDeliveryAccess lets a third party read a delivery
+// The SELLER or CUSTOMER of the delivery (or ADMIN) can grant; granting again changes the scope
+func (c *DeliveryContract) GrantDeliveryAccess(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	granteeID string,
+	scope string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	if err := validateUserID(granteeID, "granteeID"); err != nil {
+		return err
+	}
+	grantScope := GrantScope(scope)
+	if grantScope != GrantScopeTracking && grantScope != GrantScopeFull {
+		return &ValidationError{Field: "scope", Message: fmt.Sprintf("must be %s or %s", GrantScopeTracking, GrantScopeFull)}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleSeller, RoleCustomer, RoleAdmin); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if err := validateGrantingParty(delivery, caller); err != nil {
+		return err
+	}
+	if validateParty(delivery, &CallerIdentity{ID: granteeID, TenantID: delivery.TenantID}) == nil {
+		return fmt.Errorf("%s is already involved in this delivery", granteeID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	grant := AccessGrant{
+		GranteeID: granteeID,
+		Scope:     grantScope,
+		GrantedBy: caller.ID,
+		GrantedAt: currentTime,
+	}
+	replaced := false
+	for i := range delivery.AccessGrants {
+		if delivery.AccessGrants[i].GranteeID == granteeID {
+			delivery.AccessGrants[i] = grant
+			replaced = true
+		}
+	}
+	if !replaced {
+		if len(delivery.AccessGrants) >= maxAccessGrants {
+			return fmt.Errorf("delivery %s already has %d access grants", deliveryID, maxAccessGrants)
+		}
+		delivery.AccessGrants = append(delivery.AccessGrants, grant)
+	}
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventDeliveryAccessGranted, map[string]string{
+		"deliveryId": deliveryID,
+		"granteeId":  granteeID,
+		"scope":      scope,
+		"grantedBy":  caller.ID,
+		"timestamp":  currentTime,
+	})
+}
+
+// RevokeDeliveryAccess removes a third party's access grant
+// The SELLER or CUSTOMER of the delivery (or ADMIN) can revoke any grant on it
+func (c *DeliveryContract) RevokeDeliveryAccess(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	granteeID string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	if err := validateUserID(granteeID, "granteeID"); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleSeller, RoleCustomer, RoleAdmin); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if err := validateGrantingParty(delivery, caller); err != nil {
+		return err
+	}
+
+	grants := []AccessGrant{}
+	for _, grant := range delivery.AccessGrants {
+		if grant.GranteeID != granteeID {
+			grants = append(grants, grant)
+		}
+	}
+	if len(grants) == len(delivery.AccessGrants) {
+		return fmt.Errorf("%s has no access grant on delivery %s", granteeID, deliveryID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	delivery.AccessGrants = grants
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventDeliveryAccessRevoked, map[string]string{
+		"deliveryId": deliveryID,
+		"granteeId":  granteeID,
+		"revokedBy":  caller.ID,
+		"timestamp":  currentTime,
+	})
+}
+
+// ListDeliveryGrants returns the access grants on a delivery
+// Parties to the delivery (or admin) can list them; grantees can't see each other
+func (c *DeliveryContract) ListDeliveryGrants(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]AccessGrant, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateParty(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	if delivery.AccessGrants == nil {
+		return []AccessGrant{}, nil
+	}
+	return delivery.AccessGrants, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransientHandoffCode is the transient map key for handoff confirmation codes, as a JSON
+// object of delivery ID to code ({"DEL-...":"482913"}) so batch flows can carry one per package
+const TransientHandoffCode = "handoffCode"
+
+// EventHandoffCodeRotated is emitted when the initiator replaces a pending handoff's code
+const EventHandoffCodeRotated = "HandoffCodeRotated"
+
+// handoffCodePattern accepts 4 to 12 letters and digits
+var handoffCodePattern = regexp.MustCompile(`^[A-Za-z0-9]{4,12}$`)
+
+// handoffCodeHash hashes a confirmation code with its handoff's salt
+func handoffCodeHash(code string, salt string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(salt+":"+code)))
+}
+
+// getHandoffCode reads the code supplied for a delivery with the transaction ("" if none)
+func getHandoffCode(ctx contractapi.TransactionContextInterface, deliveryID string) (string, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("failed to get transient data: %v", err)
+	}
+	codesJSON, exists := transientMap[TransientHandoffCode]
+	if !exists || len(codesJSON) == 0 {
+		return "", nil
+	}
+	var codes map[string]string
+	if err := json.Unmarshal(codesJSON, &codes); err != nil {
+		return "", fmt.Errorf("failed to parse handoff codes: %v", err)
+	}
+	code := codes[deliveryID]
+	if code != "" && !handoffCodePattern.MatchString(code) {
+		return "", &ValidationError{Field: TransientHandoffCode, Message: "must be 4 to 12 letters or digits"}
+	}
+	return code, nil
+}
+
+// setHandoffCode protects a pending handoff with the code supplied by its initiator, if any
+// Only the salted hash is kept. The salt is derived from the transaction ID so every endorser
+// computes the same one while each handoff (and each rotation) gets its own.
+func setHandoffCode(ctx contractapi.TransactionContextInterface, delivery *Delivery, currentTime string) error {
+	code, err := getHandoffCode(ctx, delivery.DeliveryID)
+	if err != nil || code == "" {
+		return err
+	}
+
+	settings, err := getContractSettings(ctx)
+	if err != nil {
+		return err
+	}
+	issuedAt, err := time.Parse(time.RFC3339, currentTime)
+	if err != nil {
+		return fmt.Errorf("failed to parse transaction time: %v", err)
+	}
+
+	handoff := delivery.PendingHandoff
+	handoff.CodeSalt = fmt.Sprintf("%x", sha256.Sum256([]byte(ctx.GetStub().GetTxID()+":"+delivery.DeliveryID)))
+	handoff.CodeHash = handoffCodeHash(code, handoff.CodeSalt)
+	handoff.CodeExpiresAt = issuedAt.Add(time.Duration(settings.HandoffCodeTTLHours) * time.Hour).Format(time.RFC3339)
+	return nil
+}
+
+// verifyHandoffCode checks the recipient's code against a code-protected pending handoff
+// Called by confirmHandoffInternal before custody changes. The code lives on the pending
+// handoff, so confirming, cancelling or disputing the handoff consumes it; an expired code
+// must be rotated by the initiator.
+func verifyHandoffCode(ctx contractapi.TransactionContextInterface, delivery *Delivery, currentTime string) error {
+	handoff := delivery.PendingHandoff
+	if handoff.CodeHash == "" {
+		return nil
+	}
+	if currentTime >= handoff.CodeExpiresAt {
+		return fmt.Errorf("the handoff code of delivery %s expired at %s; the initiator must rotate it", delivery.DeliveryID, handoff.CodeExpiresAt)
+	}
+	code, err := getHandoffCode(ctx, delivery.DeliveryID)
+	if err != nil {
+		return err
+	}
+	if code == "" {
+		return fmt.Errorf("delivery %s requires the handoff code (%s)", delivery.DeliveryID, TransientHandoffCode)
+	}
+	if handoffCodeHash(code, handoff.CodeSalt) != handoff.CodeHash {
+		return fmt.Errorf("handoff code does not match for delivery %s", delivery.DeliveryID)
+	}
+	return nil
+}
+
+// RotateHandoffCode replaces the confirmation code of a pending handoff, e.g. when it leaked
+// Only the handoff initiator can rotate; the new code is passed in the transient map
+// ("handoffCode") and gets a fresh salt and expiry. The old code stops working immediately.
+func (c *DeliveryContract) RotateHandoffCode(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - whoever can initiate a handoff
+	if err := validateRole(ctx, caller, RoleSeller, RoleDeliveryPerson, RoleWarehouse); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.PendingHandoff == nil {
+		return fmt.Errorf("no pending handoff for this delivery")
+	}
+	if delivery.PendingHandoff.FromUserID != caller.ID {
+		return fmt.Errorf("only the handoff initiator can rotate its code")
+	}
+
+	code, err := getHandoffCode(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if code == "" {
+		return &ValidationError{Field: TransientHandoffCode, Message: "new code is required in transient data"}
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if err := setHandoffCode(ctx, delivery, currentTime); err != nil {
+		return err
+	}
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventHandoffCodeRotated, map[string]string{
+		"deliveryId": deliveryID,
+		"toUserId":   delivery.PendingHandoff.ToUserID,
+		"expiresAt":  delivery.PendingHandoff.CodeExpiresAt,
+		"rotatedBy":  caller.ID,
+		"timestamp":  currentTime,
+	})
+}
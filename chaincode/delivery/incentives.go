@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordPointsEntry is the composite key prefix for courier incentive point entries
+const RecordPointsEntry = "points~userId~txId"
+
+// Points entry reasons
+const (
+	PointsReasonOnTime = "ON_TIME_DELIVERY"
+	PointsReasonLate   = "LATE_DELIVERY"
+)
+
+// PointsEntry is one accrual in a courier's incentive points ledger
+type PointsEntry struct {
+	UserID     string `json:"userId"`
+	DeliveryID string `json:"deliveryId"`
+	TxID       string `json:"txId"`
+	Points     int64  `json:"points"`
+	Reason     string `json:"reason"`
+	AccruedAt  string `json:"accruedAt"`
+}
+
+// PointsBalance is a courier's points total with the entries it is made of
+type PointsBalance struct {
+	UserID  string         `json:"userId"`
+	Balance int64          `json:"balance"`
+	Entries []*PointsEntry `json:"entries"`
+}
+
+// accrueDeliveryPoints credits the courier who completed a dispute-free delivery
+// Called by applyDeliveryUpdate when a delivery reaches CONFIRMED_DELIVERY. Points follow the
+// onTimeDeliveryPoints and lateDeliveryPoints settings; balances are summed on read, so
+// concurrent confirmations never write the same key.
+func accrueDeliveryPoints(ctx contractapi.TransactionContextInterface, previous *Delivery, delivery *Delivery) error {
+	if previous == nil || previous.CurrentCustodianRole != RoleDeliveryPerson || delivery.LastDispute != nil {
+		return nil
+	}
+
+	deliveredAt, err := time.Parse(time.RFC3339, delivery.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("invalid delivery timestamp: %v", err)
+	}
+	expectedAt, err := expectedMilestoneAt(ctx, delivery, ProgressDelivered)
+	if err != nil {
+		return err
+	}
+	settings, err := getContractSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	entry := &PointsEntry{
+		UserID:     previous.CurrentCustodianID,
+		DeliveryID: delivery.DeliveryID,
+		TxID:       ctx.GetStub().GetTxID(),
+		Points:     int64(settings.OnTimeDeliveryPoints),
+		Reason:     PointsReasonOnTime,
+		AccruedAt:  delivery.UpdatedAt,
+	}
+	if deliveredAt.After(expectedAt) {
+		entry.Points = int64(settings.LateDeliveryPoints)
+		entry.Reason = PointsReasonLate
+	}
+	if entry.Points == 0 {
+		return nil
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(RecordPointsEntry, []string{entry.UserID, entry.TxID})
+	if err != nil {
+		return fmt.Errorf("failed to create points composite key: %v", err)
+	}
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal points entry: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, entryJSON); err != nil {
+		return fmt.Errorf("failed to put points entry: %v", err)
+	}
+	return nil
+}
+
+// GetPointsBalance returns a courier's incentive points balance and accrual entries
+// Couriers read their own balance (pass an empty userID); ADMIN can read any courier's.
+func (c *DeliveryContract) GetPointsBalance(
+	ctx contractapi.TransactionContextInterface,
+	userID string,
+) (*PointsBalance, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if userID == "" {
+		userID = caller.ID
+	}
+	if err := validateUserID(userID, "userID"); err != nil {
+		return nil, err
+	}
+	if caller.Role != RoleAdmin && userID != caller.ID {
+		return nil, fmt.Errorf("couriers can only read their own points balance")
+	}
+
+	return readPointsBalance(ctx, userID)
+}
+
+// readPointsBalance sums a user's incentive point entries
+func readPointsBalance(ctx contractapi.TransactionContextInterface, userID string) (*PointsBalance, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordPointsEntry, []string{userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get points entries: %v", err)
+	}
+	defer iterator.Close()
+
+	balance := &PointsBalance{UserID: userID, Entries: []*PointsEntry{}}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate points entries: %v", err)
+		}
+
+		var entry PointsEntry
+		if err := json.Unmarshal(response.Value, &entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal points entry: %v", err)
+		}
+		balance.Balance += entry.Points
+		balance.Entries = append(balance.Entries, &entry)
+	}
+
+	return balance, nil
+}
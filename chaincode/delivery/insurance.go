@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordInsuranceClaim is the composite key prefix for insurance claims opened on a delivery
+const RecordInsuranceClaim = "claim~deliveryId~disputeId"
+
+// EventDeliveryInsured is emitted when a seller records the insurance of a parcel
+const EventDeliveryInsured = "DeliveryInsured"
+
+// ClaimStateOpen is the state of a claim awaiting the insurer
+const ClaimStateOpen = "OPEN"
+
+// DeliveryInsurance identifies the policy covering a parcel up to its declared value
+type DeliveryInsurance struct {
+	Insurer      string `json:"insurer"`
+	PolicyNumber string `json:"policyNumber"`
+	InsuredBy    string `json:"insuredBy"`
+	InsuredAt    string `json:"insuredAt"`
+}
+
+// InsuranceClaim is the case bundle opened when a dispute on an insured delivery finds the carrier at fault
+type InsuranceClaim struct {
+	DeliveryID   string `json:"deliveryId"`
+	OrderID      string `json:"orderId"`
+	DisputeID    string `json:"disputeId"`
+	Insurer      string `json:"insurer"`
+	PolicyNumber string `json:"policyNumber"`
+	// Declared value of the parcel at the time of the claim
+	ClaimedAmount *Money `json:"claimedAmount,omitempty" metadata:",optional"`
+	// The courier or warehouse whose handoff was disputed, and the carrier org it worked for
+	ResponsibleParty      string            `json:"responsibleParty"`
+	ResponsibleRole       UserRole          `json:"responsibleRole,omitempty" metadata:",optional"`
+	ResponsibleCarrierMSP string            `json:"responsibleCarrierMsp,omitempty" metadata:",optional"`
+	DisputedStatus        DeliveryStatus    `json:"disputedStatus"`
+	ReasonCode            string            `json:"reasonCode,omitempty" metadata:",optional"`
+	Reason                string            `json:"reason"`
+	Resolution            DisputeResolution `json:"resolution"`
+	Outcome               string            `json:"outcome"`
+	// Digest of the delivery and its custody history up to the resolution
+	CustodyDigest *StateDigest `json:"custodyDigest"`
+	State         string       `json:"state"`
+	OpenedBy      string       `json:"openedBy"`
+	OpenedAt      string       `json:"openedAt"`
+}
+
+// carrierAtFault reports whether a resolved dispute upheld a complaint against a courier or warehouse
+// Pickup disputes are about the seller's handoff, so only transit and delivery disputes count.
+func carrierAtFault(dispute *DisputeInfo) bool {
+	if dispute.FromUserID == "" || dispute.Resolution == ResolutionNoAction {
+		return false
+	}
+	return dispute.DisputedStatus == StatusDisputedTransitHandoff || dispute.DisputedStatus == StatusDisputedDelivery
+}
+
+// openInsuranceClaim opens a claim when an insured delivery's dispute is resolved against the carrier
+// Called by ResolveDispute; returns nil when no claim is due.
+func openInsuranceClaim(ctx contractapi.TransactionContextInterface, delivery *Delivery) (*InsuranceClaim, error) {
+	dispute := delivery.LastDispute
+	if delivery.Insurance == nil || !carrierAtFault(dispute) {
+		return nil, nil
+	}
+
+	// The history holds every committed version; the resolution itself is not committed yet
+	digest, err := computeStateDigest(ctx, delivery.DeliveryID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	claim := &InsuranceClaim{
+		DeliveryID:       delivery.DeliveryID,
+		OrderID:          delivery.OrderID,
+		DisputeID:        dispute.DisputeID,
+		Insurer:          delivery.Insurance.Insurer,
+		PolicyNumber:     delivery.Insurance.PolicyNumber,
+		ClaimedAmount:    delivery.DeclaredValue,
+		ResponsibleParty: dispute.FromUserID,
+		DisputedStatus:   dispute.DisputedStatus,
+		ReasonCode:       dispute.ReasonCode,
+		Reason:           dispute.Reason,
+		Resolution:       dispute.Resolution,
+		Outcome:          dispute.Outcome,
+		CustodyDigest:    digest,
+		State:            ClaimStateOpen,
+		OpenedBy:         dispute.ResolvedBy,
+		OpenedAt:         dispute.ResolvedAt,
+	}
+	// A disputed handoff leaves the package with the user who offered it
+	if delivery.CurrentCustodianID == dispute.FromUserID {
+		claim.ResponsibleRole = delivery.CurrentCustodianRole
+		claim.ResponsibleCarrierMSP = currentCarrier(delivery)
+	}
+
+	// Disputes recorded before they had IDs are keyed by the resolving transaction
+	claimKey := dispute.DisputeID
+	if claimKey == "" {
+		claimKey = ctx.GetStub().GetTxID()
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(RecordInsuranceClaim, []string{delivery.DeliveryID, claimKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create insurance claim composite key: %v", err)
+	}
+	claimJSON, err := json.Marshal(claim)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal insurance claim: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, claimJSON); err != nil {
+		return nil, fmt.Errorf("failed to put insurance claim: %v", err)
+	}
+	return claim, nil
+}
+
+// SetDeliveryInsurance records the insurer and policy covering a parcel
+// Only the SELLER of the delivery can insure it, before pickup and once its declared value is
+// set, since the declared value is what a claim is opened for.
+func (c *DeliveryContract) SetDeliveryInsurance(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	insurer string,
+	policyNumber string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	insurer, policyNumber = sanitizeText(insurer), sanitizeText(policyNumber)
+	if err := validateRequiredText(insurer, "insurer", 100); err != nil {
+		return err
+	}
+	if err := validateRequiredText(policyNumber, "policyNumber", 64); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER insures parcels
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller can insure this delivery")
+	}
+	if delivery.DeliveryStatus != StatusPendingPickup {
+		return fmt.Errorf("can only insure a delivery before pickup")
+	}
+	if delivery.DeclaredValue == nil {
+		return fmt.Errorf("the declared value must be set before insuring the delivery")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	delivery.Insurance = &DeliveryInsurance{
+		Insurer:      insurer,
+		PolicyNumber: policyNumber,
+		InsuredBy:    caller.ID,
+		InsuredAt:    currentTime,
+	}
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventDeliveryInsured, map[string]interface{}{
+		"deliveryId":    deliveryID,
+		"insurer":       insurer,
+		"policyNumber":  policyNumber,
+		"declaredValue": delivery.DeclaredValue,
+		"timestamp":     currentTime,
+	})
+}
+
+// GetInsuranceClaims returns the insurance claims opened on a delivery
+// Parties of the delivery and FULL-scope grantees (e.g. the insurer) can read them.
+func (c *DeliveryContract) GetInsuranceClaims(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*InsuranceClaim, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordInsuranceClaim, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get insurance claims: %v", err)
+	}
+	defer iterator.Close()
+
+	claims := []*InsuranceClaim{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate insurance claims: %v", err)
+		}
+
+		var claim InsuranceClaim
+		if err := json.Unmarshal(response.Value, &claim); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal insurance claim: %v", err)
+		}
+		claims = append(claims, &claim)
+	}
+
+	return claims, nil
+}
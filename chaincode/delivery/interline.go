@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// HandoffType distinguishes regular handoffs from transfers between carrier orgs
+type HandoffType string
+
+const (
+	// HandoffTypeStandard is a handoff within one carrier (or to/from a seller or customer)
+	HandoffTypeStandard HandoffType = "STANDARD"
+	// HandoffTypeInterline moves a package from one logistics org to another mid-route
+	HandoffTypeInterline HandoffType = "INTERLINE"
+)
+
+// EventInterlineHandoffInitiated is emitted when a carrier offers a package to another carrier
+const EventInterlineHandoffInitiated = "InterlineHandoffInitiated"
+
+// isLogisticsRole reports whether a role holds custody on behalf of a carrier
+func isLogisticsRole(role UserRole) bool {
+	return role == RoleDeliveryPerson || role == RoleWarehouse
+}
+
+// currentCarrier returns the carrier org responsible for a delivery, or "" before pickup
+// Deliveries stored before carriers were tracked fall back to their logistics custodian's org.
+func currentCarrier(delivery *Delivery) string {
+	if delivery.CarrierOfRecord != "" {
+		return delivery.CarrierOfRecord
+	}
+	if isLogisticsRole(delivery.CurrentCustodianRole) {
+		return custodianMSPOf(delivery)
+	}
+	return ""
+}
+
+// validateCarrierTransfer checks the recipient of a pending handoff may take the package
+// INTERLINE handoffs can only be accepted by the named carrier; standard handoffs can't
+// move the package to a different carrier.
+func validateCarrierTransfer(delivery *Delivery, caller *CallerIdentity) error {
+	handoff := delivery.PendingHandoff
+	if handoff.Type == HandoffTypeInterline {
+		if caller.MSP != handoff.ToCarrierMSP {
+			return fmt.Errorf("only a member of %s can accept this interline handoff", handoff.ToCarrierMSP)
+		}
+		return nil
+	}
+	if !isLogisticsRole(handoff.ToRole) {
+		return nil
+	}
+	if carrier := currentCarrier(delivery); carrier != "" && carrier != caller.MSP {
+		return fmt.Errorf("handoffs from %s to %s must use InitiateInterlineHandoff", carrier, caller.MSP)
+	}
+	return nil
+}
+
+// setInterlineEndorsementPolicy requires both carriers to endorse changes while an interline
+// handoff is pending, so neither carrier can complete or cancel it alone
+func setInterlineEndorsementPolicy(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
+	handoff := delivery.PendingHandoff
+	fromMSP, err := orgEndorsingMSP(ctx, handoff.FromCarrierMSP, handoff.FromRole)
+	if err != nil {
+		return err
+	}
+	toMSP, err := orgEndorsingMSP(ctx, handoff.ToCarrierMSP, handoff.ToRole)
+	if err != nil {
+		return err
+	}
+
+	ep, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create state endorsement policy: %v", err)
+	}
+	if fromMSP == toMSP {
+		err = ep.AddOrgs(statebased.RoleTypeMember, fromMSP)
+	} else {
+		err = ep.AddOrgs(statebased.RoleTypeMember, fromMSP, toMSP)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add orgs to endorsement policy: %v", err)
+	}
+	policyBytes, err := ep.Policy()
+	if err != nil {
+		return fmt.Errorf("failed to serialize endorsement policy: %v", err)
+	}
+	if err := ctx.GetStub().SetStateValidationParameter(delivery.DeliveryID, policyBytes); err != nil {
+		return fmt.Errorf("failed to set state validation parameter: %v", err)
+	}
+	if fromMSP == toMSP {
+		return syncPolicyIndex(ctx, delivery.DeliveryID, []string{fromMSP})
+	}
+	return syncPolicyIndex(ctx, delivery.DeliveryID, []string{fromMSP, toMSP})
+}
+
+// InitiateInterlineHandoff offers a package to a courier or warehouse of another carrier org
+// Only the current DELIVERY_PERSON or WAREHOUSE custodian can initiate. Until the handoff is
+// confirmed, cancelled or disputed, both carriers' orgs must endorse changes to the delivery.
+func (c *DeliveryContract) InitiateInterlineHandoff(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	toUserID string,
+	toRole string,
+	toCarrierMSP string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	if err := validateUserID(toUserID, "toUserID"); err != nil {
+		return err
+	}
+	targetRole := UserRole(toRole)
+	if !isLogisticsRole(targetRole) {
+		return &ValidationError{Field: "toRole", Message: "must be DELIVERY_PERSON or WAREHOUSE"}
+	}
+	if !mspIDPattern.MatchString(toCarrierMSP) {
+		return &ValidationError{Field: "toCarrierMSP", Message: "must be a valid MSP ID"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only carriers hand packages to other carriers
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse); err != nil {
+		return err
+	}
+
+	if toCarrierMSP == caller.MSP {
+		return fmt.Errorf("use InitiateHandoff for handoffs within %s", caller.MSP)
+	}
+	carrier, err := getOrganization(ctx, toCarrierMSP)
+	if err != nil {
+		return err
+	}
+	if carrier == nil || !carrier.Active {
+		return fmt.Errorf("carrier %s is not an active member", toCarrierMSP)
+	}
+	if !carrier.allowsRole(targetRole) {
+		return fmt.Errorf("carrier %s has no %s members", toCarrierMSP, targetRole)
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.DeliveryStatus != StatusInTransit {
+		return fmt.Errorf("cannot initiate interline handoff in current status: %s", delivery.DeliveryStatus)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	evidence, err := getHandoffEvidence(ctx, caller.ID, currentTime)
+	if err != nil {
+		return err
+	}
+
+	oldStatus, err := initiateHandoffInternal(ctx, caller, delivery, toUserID, targetRole, toCarrierMSP, evidence, currentTime)
+	if err != nil {
+		return err
+	}
+
+	if err := setInterlineEndorsementPolicy(ctx, delivery); err != nil {
+		return fmt.Errorf("failed to set interline endorsement policy: %v", err)
+	}
+
+	return emitEvent(ctx, EventInterlineHandoffInitiated, map[string]string{
+		"deliveryId":     deliveryID,
+		"oldStatus":      string(oldStatus),
+		"newStatus":      string(delivery.DeliveryStatus),
+		"fromUserId":     caller.ID,
+		"fromCarrierMsp": caller.MSP,
+		"toUserId":       toUserID,
+		"toCarrierMsp":   toCarrierMSP,
+		"timestamp":      currentTime,
+	})
+}
+
+// CustodyLeg is one uninterrupted stretch of custody in a custody report
+type CustodyLeg struct {
+	CustodianID   string      `json:"custodianId"`
+	CustodianRole UserRole    `json:"custodianRole"`
+	CustodianMSP  string      `json:"custodianMsp"`
+	CarrierMSP    string      `json:"carrierMsp,omitempty" metadata:",optional"`
+	HandoffType   HandoffType `json:"handoffType,omitempty" metadata:",optional"`
+	StartedAt     string      `json:"startedAt"`
+	EndedAt       string      `json:"endedAt,omitempty" metadata:",optional"`
+	StartTxID     string      `json:"startTxId"`
+}
+
+// CarrierSummary totals the legs a carrier org handled
+type CarrierSummary struct {
+	CarrierMSP string `json:"carrierMsp"`
+	Legs       int    `json:"legs"`
+}
+
+// CustodyReport attributes each custody leg of a delivery to its custodian and carrier
+type CustodyReport struct {
+	DeliveryID      string           `json:"deliveryId"`
+	CarrierOfRecord string           `json:"carrierOfRecord,omitempty" metadata:",optional"`
+	Legs            []CustodyLeg     `json:"legs"`
+	Carriers        []CarrierSummary `json:"carriers"`
+}
+
+// GetCustodyReport rebuilds the custody legs of a delivery from its ledger history
+// Logistics legs are attributed to the carrier of record at the time. Any party involved
+// in the delivery (or admin) can read it.
+func (c *DeliveryContract) GetCustodyReport(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) (*CustodyReport, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	entries, err := readHistoryEntries(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CustodyReport{
+		DeliveryID:      deliveryID,
+		CarrierOfRecord: delivery.CarrierOfRecord,
+		Legs:            []CustodyLeg{},
+		Carriers:        []CarrierSummary{},
+	}
+	var previous *Delivery
+	for _, entry := range entries {
+		if entry.isDelete || len(entry.value) == 0 {
+			continue
+		}
+		var version Delivery
+		if err := json.Unmarshal(entry.value, &version); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal delivery: %v", err)
+		}
+
+		custodianChanged := previous == nil ||
+			version.CurrentCustodianID != previous.CurrentCustodianID ||
+			version.CurrentCustodianRole != previous.CurrentCustodianRole
+		if custodianChanged && version.CurrentCustodianID != "" {
+			if n := len(report.Legs); n > 0 {
+				report.Legs[n-1].EndedAt = version.UpdatedAt
+			}
+			leg := CustodyLeg{
+				CustodianID:   version.CurrentCustodianID,
+				CustodianRole: version.CurrentCustodianRole,
+				CustodianMSP:  custodianMSPOf(&version),
+				StartedAt:     version.UpdatedAt,
+				StartTxID:     entry.txID,
+			}
+			if isLogisticsRole(version.CurrentCustodianRole) {
+				leg.CarrierMSP = currentCarrier(&version)
+			}
+			if previous != nil && previous.PendingHandoff != nil {
+				leg.HandoffType = HandoffTypeStandard
+				if previous.PendingHandoff.Type != "" {
+					leg.HandoffType = previous.PendingHandoff.Type
+				}
+			}
+			report.Legs = append(report.Legs, leg)
+		}
+		previous = &version
+	}
+
+	for _, leg := range report.Legs {
+		if leg.CarrierMSP == "" {
+			continue
+		}
+		found := false
+		for i := range report.Carriers {
+			if report.Carriers[i].CarrierMSP == leg.CarrierMSP {
+				report.Carriers[i].Legs++
+				found = true
+			}
+		}
+		if !found {
+			report.Carriers = append(report.Carriers, CarrierSummary{CarrierMSP: leg.CarrierMSP, Legs: 1})
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ItemCondition represents the observed condition of an item within a delivery
+type ItemCondition string
+
+const (
+	ItemConditionGood    ItemCondition = "GOOD"
+	ItemConditionDamaged ItemCondition = "DAMAGED"
+	ItemConditionMissing ItemCondition = "MISSING"
+)
+
+// Composite key prefixes for item-level records
+const (
+	RecordDeliveryItem = "item~deliveryId~itemId"
+	RecordItemDispute  = "itemDispute~deliveryId~itemId~txId"
+)
+
+// Event names for item-level tracking
+const (
+	EventItemAdded         = "DeliveryItemAdded"
+	EventItemDisputeOpened = "ItemDisputeOpened"
+)
+
+// TransientItemConditions is the transient map key for per-item conditions at handoff confirmation
+const TransientItemConditions = "itemConditions"
+
+// ItemRecord is a single item (SKU line) inside a multi-item parcel
+type ItemRecord struct {
+	DeliveryID string        `json:"deliveryId"`
+	ItemID     string        `json:"itemId"`
+	SKUHash    string        `json:"skuHash"`
+	Quantity   int           `json:"quantity"`
+	Condition  ItemCondition `json:"condition"`
+	UpdatedBy  string        `json:"updatedBy"`
+	UpdatedAt  string        `json:"updatedAt"`
+}
+
+// ItemDispute is an item-scoped dispute opened when an item is missing
+type ItemDispute struct {
+	DeliveryID string `json:"deliveryId"`
+	ItemID     string `json:"itemId"`
+	TxID       string `json:"txId"`
+	ReportedBy string `json:"reportedBy"`
+	OnBehalfOf string `json:"onBehalfOf,omitempty"`
+	ReasonCode string `json:"reasonCode,omitempty"`
+	Reason     string `json:"reason"`
+	OpenedAt   string `json:"openedAt"`
+}
+
+// validateItemCondition checks if an item condition is one of the known values
+func validateItemCondition(condition ItemCondition) error {
+	switch condition {
+	case ItemConditionGood, ItemConditionDamaged, ItemConditionMissing:
+		return nil
+	}
+	return &ValidationError{Field: "condition", Message: fmt.Sprintf("unknown item condition: %s", condition)}
+}
+
+// getItemRecord reads an item record, returning nil if it does not exist
+func getItemRecord(ctx contractapi.TransactionContextInterface, deliveryID, itemID string) (*ItemRecord, error) {
+	itemKey, err := ctx.GetStub().CreateCompositeKey(RecordDeliveryItem, []string{deliveryID, itemID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create item composite key: %v", err)
+	}
+	itemJSON, err := ctx.GetStub().GetState(itemKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read item %s: %v", itemID, err)
+	}
+	if itemJSON == nil {
+		return nil, nil
+	}
+
+	var item ItemRecord
+	if err := json.Unmarshal(itemJSON, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal item: %v", err)
+	}
+	return &item, nil
+}
+
+// putItemRecord writes an item record
+func putItemRecord(ctx contractapi.TransactionContextInterface, item *ItemRecord) error {
+	itemKey, err := ctx.GetStub().CreateCompositeKey(RecordDeliveryItem, []string{item.DeliveryID, item.ItemID})
+	if err != nil {
+		return fmt.Errorf("failed to create item composite key: %v", err)
+	}
+	itemJSON, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal item: %v", err)
+	}
+	if err := ctx.GetStub().PutState(itemKey, itemJSON); err != nil {
+		return fmt.Errorf("failed to put item record: %v", err)
+	}
+	return nil
+}
+
+// recordItemConditions applies the optional per-item conditions from the transient map
+// Used by ConfirmHandoff so the recipient can report item condition on receipt
+func recordItemConditions(ctx contractapi.TransactionContextInterface, deliveryID, callerID, currentTime string) error {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient data: %v", err)
+	}
+
+	conditionsJSON, exists := transientMap[TransientItemConditions]
+	if !exists || len(conditionsJSON) == 0 {
+		return nil
+	}
+
+	var conditions map[string]ItemCondition
+	if err := json.Unmarshal(conditionsJSON, &conditions); err != nil {
+		return fmt.Errorf("failed to parse item conditions: %v", err)
+	}
+
+	// Map order is random; every endorsing peer must apply the items in the same order
+	itemIDs := make([]string, 0, len(conditions))
+	for itemID := range conditions {
+		itemIDs = append(itemIDs, itemID)
+	}
+	sort.Strings(itemIDs)
+
+	for _, itemID := range itemIDs {
+		condition := conditions[itemID]
+		if err := validateItemCondition(condition); err != nil {
+			return err
+		}
+		item, err := getItemRecord(ctx, deliveryID, itemID)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			return fmt.Errorf("item %s does not exist in delivery %s", itemID, deliveryID)
+		}
+		item.Condition = condition
+		item.UpdatedBy = callerID
+		item.UpdatedAt = currentTime
+		if err := putItemRecord(ctx, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddDeliveryItem attaches an item (SKU line) to a delivery before pickup
+// Only the SELLER of the delivery can add items
+func (c *DeliveryContract) AddDeliveryItem(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	itemID string,
+	skuHash string,
+	quantity int,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	if err := validateUserID(itemID, "itemID"); err != nil {
+		return err
+	}
+	if len(skuHash) == 0 || len(skuHash) > 128 {
+		return &ValidationError{Field: "skuHash", Message: "must be between 1 and 128 characters"}
+	}
+	if quantity <= 0 {
+		return &ValidationError{Field: "quantity", Message: "must be greater than 0"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER can add items
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	if delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller can add items to this delivery")
+	}
+	if delivery.DeliveryStatus != StatusPendingPickup {
+		return fmt.Errorf("items can only be added before pickup")
+	}
+
+	existing, err := getItemRecord(ctx, deliveryID, itemID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("item %s already exists in delivery %s", itemID, deliveryID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	item := ItemRecord{
+		DeliveryID: deliveryID,
+		ItemID:     itemID,
+		SKUHash:    skuHash,
+		Quantity:   quantity,
+		Condition:  ItemConditionGood,
+		UpdatedBy:  caller.ID,
+		UpdatedAt:  currentTime,
+	}
+	if err := putItemRecord(ctx, &item); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventItemAdded, item)
+}
+
+// GetDeliveryItems returns all items attached to a delivery
+func (c *DeliveryContract) GetDeliveryItems(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*ItemRecord, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordDeliveryItem, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get items: %v", err)
+	}
+	defer iterator.Close()
+
+	items := []*ItemRecord{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate items: %v", err)
+		}
+
+		var item ItemRecord
+		if err := json.Unmarshal(response.Value, &item); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal item: %v", err)
+		}
+		items = append(items, &item)
+	}
+
+	return items, nil
+}
+
+// ReportMissingItem marks an item as missing and opens an item-scoped dispute
+// The customer, current custodian, or pending handoff recipient can report
+// with a DISPUTE reason code and optional free text; SUPPORT reports on behalf of the customer
+func (c *DeliveryContract) ReportMissingItem(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	itemID string,
+	reasonCode string,
+	reason string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	if err := validateUserID(itemID, "itemID"); err != nil {
+		return err
+	}
+	if err := validateReasonCode(ctx, ReasonCategoryDispute, reasonCode); err != nil {
+		return err
+	}
+	reason = sanitizeText(reason)
+	if err := validateReasonDetails(reason); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleCustomer, RoleSupport); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	onBehalfOf := ""
+	if caller.Role == RoleSupport {
+		if err := validateInvolvement(delivery, caller); err != nil {
+			return err
+		}
+		onBehalfOf = delivery.CustomerID
+	} else if err := validateParty(delivery, caller); err != nil {
+		return err
+	}
+
+	item, err := getItemRecord(ctx, deliveryID, itemID)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return fmt.Errorf("item %s does not exist in delivery %s", itemID, deliveryID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	txID := ctx.GetStub().GetTxID()
+
+	item.Condition = ItemConditionMissing
+	item.UpdatedBy = caller.ID
+	item.UpdatedAt = currentTime
+	if err := putItemRecord(ctx, item); err != nil {
+		return err
+	}
+
+	dispute := ItemDispute{
+		DeliveryID: deliveryID,
+		ItemID:     itemID,
+		TxID:       txID,
+		ReportedBy: caller.ID,
+		OnBehalfOf: onBehalfOf,
+		ReasonCode: reasonCode,
+		Reason:     reason,
+		OpenedAt:   currentTime,
+	}
+	disputeKey, err := ctx.GetStub().CreateCompositeKey(RecordItemDispute, []string{deliveryID, itemID, txID})
+	if err != nil {
+		return fmt.Errorf("failed to create item dispute composite key: %v", err)
+	}
+	disputeJSON, err := json.Marshal(dispute)
+	if err != nil {
+		return fmt.Errorf("failed to marshal item dispute: %v", err)
+	}
+	if err := ctx.GetStub().PutState(disputeKey, disputeJSON); err != nil {
+		return fmt.Errorf("failed to put item dispute: %v", err)
+	}
+
+	return emitEvent(ctx, EventItemDisputeOpened, dispute)
+}
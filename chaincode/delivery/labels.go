@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransientLabelPayload is the transient map key for the barcode/QR payload printed on a
+// delivery's label, bound when the delivery is created
+const TransientLabelPayload = "labelPayload"
+
+// TransientLabelScan is the transient map key for the label payloads scanned at a handoff,
+// as a JSON object of delivery ID to scanned payload
+const TransientLabelScan = "labelScan"
+
+// maxLabelPayloadLength bounds a label payload (a dense QR code holds under 3 KB)
+const maxLabelPayloadLength = 4096
+
+// labelFormatVersion is the version of the canonical label content
+const labelFormatVersion = 1
+
+// LabelContent is the machine-readable content of a delivery's shipping label
+type LabelContent struct {
+	Version         int         `json:"version"`
+	DeliveryID      string      `json:"deliveryId"`
+	DestinationCity string      `json:"destinationCity"`
+	ServiceTier     ServiceTier `json:"serviceTier"`
+	BarcodeSeed     string      `json:"barcodeSeed"`
+	// Ledger hash of the private details (address), empty until they are set
+	AddressHash string `json:"addressHash"`
+}
+
+// LabelPayload is the canonical label content, its encoding and hash
+type LabelPayload struct {
+	Content LabelContent `json:"content"`
+	// Canonical JSON encoding of Content, to be printed as the barcode/QR payload
+	Payload     string `json:"payload"`
+	PayloadHash string `json:"payloadHash"`
+}
+
+// labelPayloadHash hashes a scanned or printed label payload exactly as read
+func labelPayloadHash(payload string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(payload)))
+}
+
+// applyLabelPayload binds the label payload supplied with a new delivery, if any
+// Only the hash is stored; the payload itself is on the parcel.
+func applyLabelPayload(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient data: %v", err)
+	}
+	payload, exists := transientMap[TransientLabelPayload]
+	if !exists || len(payload) == 0 {
+		return nil
+	}
+	if len(payload) > maxLabelPayloadLength {
+		return &ValidationError{Field: TransientLabelPayload, Message: fmt.Sprintf("exceeds maximum size of %d bytes", maxLabelPayloadLength)}
+	}
+	delivery.LabelHash = labelPayloadHash(string(payload))
+	return nil
+}
+
+// verifyLabelScan checks the recipient's scan of a labelled delivery at a handoff
+// Called by confirmHandoffInternal before custody changes; deliveries without a bound label
+// are not checked. A mismatch means a swapped label or the wrong parcel, so the confirmation is
+// rejected and the recipient should dispute the handoff.
+func verifyLabelScan(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
+	if delivery.LabelHash == "" {
+		return nil
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient data: %v", err)
+	}
+	scansJSON, exists := transientMap[TransientLabelScan]
+	if !exists || len(scansJSON) == 0 {
+		return fmt.Errorf("labelled delivery %s requires a label scan (%s)", delivery.DeliveryID, TransientLabelScan)
+	}
+	var scans map[string]string
+	if err := json.Unmarshal(scansJSON, &scans); err != nil {
+		return fmt.Errorf("failed to parse label scans: %v", err)
+	}
+	scan, ok := scans[delivery.DeliveryID]
+	if !ok || scan == "" {
+		return fmt.Errorf("labelled delivery %s requires a label scan (%s)", delivery.DeliveryID, TransientLabelScan)
+	}
+	if labelPayloadHash(scan) != delivery.LabelHash {
+		return fmt.Errorf("scanned label does not match delivery %s", delivery.DeliveryID)
+	}
+	return nil
+}
+
+// GetLabelPayload returns the canonical label content of a delivery for label printing
+// The barcode seed is derived from the delivery and order IDs and the address hash is the
+// ledger's hash of the private details, so anyone holding the label can check it against the
+// ledger without seeing the address. Only the delivery's SELLER (and ADMIN) can read it.
+func (c *DeliveryContract) GetLabelPayload(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) (*LabelPayload, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - sellers print labels
+	if err := validateRole(ctx, caller, RoleSeller, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.TenantID != caller.TenantID {
+		return nil, fmt.Errorf("not authorized to access this delivery")
+	}
+	if caller.Role == RoleSeller && delivery.SellerID != caller.ID {
+		return nil, fmt.Errorf("only the seller can print the label of this delivery")
+	}
+
+	addressHash, err := ctx.GetStub().GetPrivateDataHash(CollectionDeliveryPrivate, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get private data hash: %v", err)
+	}
+
+	content := LabelContent{
+		Version:         labelFormatVersion,
+		DeliveryID:      deliveryID,
+		DestinationCity: delivery.LastLocation.City,
+		ServiceTier:     delivery.ServiceTier,
+		BarcodeSeed:     labelPayloadHash(deliveryID + ":" + delivery.OrderID)[:16],
+		AddressHash:     fmt.Sprintf("%x", addressHash),
+	}
+	if delivery.Destination != nil {
+		content.DestinationCity = delivery.Destination.City
+	}
+	if content.ServiceTier == "" {
+		content.ServiceTier = ServiceTierStandard
+	}
+	payload, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal label content: %v", err)
+	}
+
+	return &LabelPayload{
+		Content:     content,
+		Payload:     string(payload),
+		PayloadHash: labelPayloadHash(string(payload)),
+	}, nil
+}
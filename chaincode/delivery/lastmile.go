@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EventOutForDelivery is emitted when a courier starts the last-mile run to the customer
+const EventOutForDelivery = "DeliveryOutForDelivery"
+
+// promisedDeliveryAt returns when a delivery was promised to arrive (its tier's DELIVERED milestone)
+func promisedDeliveryAt(ctx contractapi.TransactionContextInterface, delivery *Delivery) (time.Time, error) {
+	return expectedMilestoneAt(ctx, delivery, ProgressDelivered)
+}
+
+// DeclareOutForDelivery marks a delivery as on the courier's last-mile run
+// Only the DELIVERY_PERSON custodian can declare it, from IN_TRANSIT, on the (UTC) day the
+// delivery was promised for; late packages can still go out on any later day.
+func (c *DeliveryContract) DeclareOutForDelivery(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only couriers make last-mile runs
+	if err := validateRole(ctx, caller, RoleDeliveryPerson); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.CurrentCustodianID != caller.ID {
+		return fmt.Errorf("only the current custodian can declare this delivery out for delivery")
+	}
+	if delivery.DeliveryStatus != StatusInTransit {
+		return fmt.Errorf("cannot declare out for delivery in current status: %s", delivery.DeliveryStatus)
+	}
+	if delivery.PendingHandoff != nil || hasPendingReassignment(delivery) {
+		return fmt.Errorf("custody of this delivery is being transferred")
+	}
+	// International deliveries only reach the customer once customs has cleared them
+	if err := validateCustomsCleared(delivery); err != nil {
+		return err
+	}
+
+	promisedAt, err := promisedDeliveryAt(ctx, delivery)
+	if err != nil {
+		return err
+	}
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	promisedDay := promisedAt.UTC().Format("2006-01-02")
+	if txTime.UTC().Format("2006-01-02") < promisedDay {
+		return fmt.Errorf("delivery %s is promised for %s and cannot go out for delivery before then", deliveryID, promisedDay)
+	}
+	if err := validateWorkingDay(ctx, delivery.LastLocation, txTime); err != nil {
+		return fmt.Errorf("cannot go out for delivery: %v", err)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	oldStatus := delivery.DeliveryStatus
+
+	delivery.DeliveryStatus = StatusOutForDelivery
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventOutForDelivery, map[string]string{
+		"deliveryId": deliveryID,
+		"orderId":    delivery.OrderID,
+		"customerId": delivery.CustomerID,
+		"courierId":  caller.ID,
+		"oldStatus":  string(oldStatus),
+		"newStatus":  string(delivery.DeliveryStatus),
+		"expectedBy": promisedAt.UTC().Format(time.RFC3339),
+		"timestamp":  currentTime,
+	})
+}
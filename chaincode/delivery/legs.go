@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Event names for international legs
+const (
+	EventLegStarted          = "InternationalLegStarted"
+	EventLegMilestoneReached = "InternationalLegMilestone"
+)
+
+// maxInternationalLegs bounds the legs kept on one delivery
+const maxInternationalLegs = 20
+
+// LegType is a stage of an international shipment
+type LegType string
+
+const (
+	LegExportHub   LegType = "EXPORT_HUB"
+	LegLinehaulAir LegType = "LINEHAUL_AIR"
+	LegLinehaulSea LegType = "LINEHAUL_SEA"
+	LegImportHub   LegType = "IMPORT_HUB"
+)
+
+// legStages orders the leg types; a shipment never goes back to an earlier stage
+var legStages = map[LegType]int{
+	LegExportHub:   0,
+	LegLinehaulAir: 1,
+	LegLinehaulSea: 1,
+	LegImportHub:   2,
+}
+
+// LegMilestone is a port milestone of a linehaul leg
+type LegMilestone string
+
+const (
+	LegDepartedOriginPort     LegMilestone = "DEPARTED_ORIGIN_PORT"
+	LegArrivedDestinationPort LegMilestone = "ARRIVED_DESTINATION_PORT"
+)
+
+// unLocodePattern matches UN/LOCODE port codes (e.g. BRSSZ, NLRTM, USJFK)
+var unLocodePattern = regexp.MustCompile(`^[A-Z]{2}[A-Z2-9]{3}$`)
+
+// InternationalLeg is one stage of an international delivery, kept on the delivery with its
+// last-mile custody
+// Hub legs happen at OriginPort; linehaul legs run from OriginPort to DestinationPort.
+type InternationalLeg struct {
+	Type            LegType  `json:"type"`
+	OriginPort      string   `json:"originPort"`
+	DestinationPort string   `json:"destinationPort,omitempty" metadata:",optional"`
+	HandledBy       string   `json:"handledBy"`
+	HandlerRole     UserRole `json:"handlerRole"`
+	StartedAt       string   `json:"startedAt"`
+	DepartedAt      string   `json:"departedAt,omitempty" metadata:",optional"`
+	ArrivedAt       string   `json:"arrivedAt,omitempty" metadata:",optional"`
+}
+
+// isLinehaul reports whether a leg moves between ports
+func (l *InternationalLeg) isLinehaul() bool {
+	return l.Type == LegLinehaulAir || l.Type == LegLinehaulSea
+}
+
+// currentLeg returns the latest international leg of a delivery (nil if none)
+func currentLeg(delivery *Delivery) *InternationalLeg {
+	if len(delivery.Legs) == 0 {
+		return nil
+	}
+	return &delivery.Legs[len(delivery.Legs)-1]
+}
+
+// readLegDelivery loads an in-transit international delivery for its custodian
+func (c *DeliveryContract) readLegDelivery(
+	ctx contractapi.TransactionContextInterface,
+	caller *CallerIdentity,
+	deliveryID string,
+) (*Delivery, error) {
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.CurrentCustodianID != caller.ID || delivery.CurrentCustodianRole != caller.Role {
+		return nil, fmt.Errorf("only the current custodian can record international legs")
+	}
+	if delivery.Customs == nil {
+		return nil, fmt.Errorf("delivery %s is not an international delivery", deliveryID)
+	}
+	if delivery.DeliveryStatus != StatusInTransit {
+		return nil, fmt.Errorf("cannot record international legs in current status: %s", delivery.DeliveryStatus)
+	}
+	return delivery, nil
+}
+
+// StartInternationalLeg starts the next stage of an international delivery
+// Only the current DELIVERY_PERSON or WAREHOUSE custodian can start a leg, while IN_TRANSIT.
+// Legs go EXPORT_HUB, LINEHAUL_AIR/LINEHAUL_SEA, IMPORT_HUB (stages may be skipped or repeated,
+// never reversed), and a linehaul must arrive before the next leg starts. Ports are UN/LOCODEs;
+// destinationPort is required for linehaul legs and must be empty for hub legs.
+func (c *DeliveryContract) StartInternationalLeg(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	legType string,
+	originPort string,
+	destinationPort string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	leg := InternationalLeg{
+		Type:            LegType(strings.ToUpper(strings.TrimSpace(legType))),
+		OriginPort:      strings.ToUpper(strings.TrimSpace(originPort)),
+		DestinationPort: strings.ToUpper(strings.TrimSpace(destinationPort)),
+	}
+	if _, ok := legStages[leg.Type]; !ok {
+		return &ValidationError{Field: "legType", Message: fmt.Sprintf("unknown leg type: %s", legType)}
+	}
+	if !unLocodePattern.MatchString(leg.OriginPort) {
+		return &ValidationError{Field: "originPort", Message: "must be a UN/LOCODE (e.g. BRSSZ)"}
+	}
+	if leg.isLinehaul() {
+		if !unLocodePattern.MatchString(leg.DestinationPort) {
+			return &ValidationError{Field: "destinationPort", Message: "must be a UN/LOCODE (e.g. NLRTM)"}
+		}
+		if leg.DestinationPort == leg.OriginPort {
+			return &ValidationError{Field: "destinationPort", Message: "must differ from originPort"}
+		}
+	} else if leg.DestinationPort != "" {
+		return &ValidationError{Field: "destinationPort", Message: "only linehaul legs have a destination port"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - couriers and warehouses hold packages between handoffs
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse); err != nil {
+		return err
+	}
+
+	delivery, err := c.readLegDelivery(ctx, caller, deliveryID)
+	if err != nil {
+		return err
+	}
+	if previous := currentLeg(delivery); previous != nil {
+		if legStages[leg.Type] < legStages[previous.Type] {
+			return fmt.Errorf("cannot start a %s leg after a %s leg", leg.Type, previous.Type)
+		}
+		if previous.isLinehaul() && previous.ArrivedAt == "" {
+			return fmt.Errorf("the %s leg to %s has not arrived yet", previous.Type, previous.DestinationPort)
+		}
+	}
+	if len(delivery.Legs) >= maxInternationalLegs {
+		return fmt.Errorf("delivery %s already has %d international legs", deliveryID, maxInternationalLegs)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	leg.HandledBy = caller.ID
+	leg.HandlerRole = caller.Role
+	leg.StartedAt = currentTime
+	delivery.Legs = append(delivery.Legs, leg)
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventLegStarted, map[string]string{
+		"deliveryId":      deliveryID,
+		"orderId":         delivery.OrderID,
+		"legType":         string(leg.Type),
+		"originPort":      leg.OriginPort,
+		"destinationPort": leg.DestinationPort,
+		"handledBy":       caller.ID,
+		"timestamp":       currentTime,
+	})
+}
+
+// RecordLegMilestone records a port milestone of the current linehaul leg
+// Only the current DELIVERY_PERSON or WAREHOUSE custodian can record milestones, in order:
+// DEPARTED_ORIGIN_PORT, then ARRIVED_DESTINATION_PORT.
+func (c *DeliveryContract) RecordLegMilestone(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	milestone string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	legMilestone := LegMilestone(strings.ToUpper(strings.TrimSpace(milestone)))
+	if legMilestone != LegDepartedOriginPort && legMilestone != LegArrivedDestinationPort {
+		return &ValidationError{Field: "milestone", Message: fmt.Sprintf("unknown leg milestone: %s", milestone)}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - couriers and warehouses hold packages between handoffs
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse); err != nil {
+		return err
+	}
+
+	delivery, err := c.readLegDelivery(ctx, caller, deliveryID)
+	if err != nil {
+		return err
+	}
+	leg := currentLeg(delivery)
+	if leg == nil || !leg.isLinehaul() {
+		return fmt.Errorf("delivery %s is not on a linehaul leg", deliveryID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	port := leg.OriginPort
+	switch legMilestone {
+	case LegDepartedOriginPort:
+		if leg.DepartedAt != "" {
+			return fmt.Errorf("the %s leg already departed %s", leg.Type, leg.OriginPort)
+		}
+		leg.DepartedAt = currentTime
+	case LegArrivedDestinationPort:
+		if leg.DepartedAt == "" {
+			return fmt.Errorf("the %s leg has not departed %s yet", leg.Type, leg.OriginPort)
+		}
+		if leg.ArrivedAt != "" {
+			return fmt.Errorf("the %s leg already arrived at %s", leg.Type, leg.DestinationPort)
+		}
+		leg.ArrivedAt = currentTime
+		port = leg.DestinationPort
+	}
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventLegMilestoneReached, map[string]string{
+		"deliveryId": deliveryID,
+		"orderId":    delivery.OrderID,
+		"legType":    string(leg.Type),
+		"milestone":  string(legMilestone),
+		"port":       port,
+		"recordedBy": caller.ID,
+		"timestamp":  currentTime,
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordLocationHistory is the composite key prefix for location history entries
+const RecordLocationHistory = "location~deliveryId~txId"
+
+// LocationUpdateKind tells a courier position update apart from a hub arrival scan
+type LocationUpdateKind string
+
+const (
+	LocationUpdateCourier    LocationUpdateKind = "COURIER_UPDATE"
+	LocationUpdateHubArrival LocationUpdateKind = "HUB_ARRIVAL"
+)
+
+// locationUpdateStatuses are the statuses in which the custodian can report its location
+// Return flows are included so recalled packages stay traceable on their way back.
+var locationUpdateStatuses = map[DeliveryStatus]bool{
+	StatusInTransit:         true,
+	StatusRecallPending:     true,
+	StatusReturnInTransit:   true,
+	StatusDisputedDelivery:  true,
+	StatusOffNetworkTransit: true,
+	StatusOutForDelivery:    true,
+	StatusQuarantined:       true,
+}
+
+// LocationUpdate is one entry in a delivery's location history
+type LocationUpdate struct {
+	DeliveryID     string             `json:"deliveryId"`
+	TxID           string             `json:"txId"`
+	Kind           LocationUpdateKind `json:"kind"`
+	Location       Location           `json:"location"`
+	DeliveryStatus DeliveryStatus     `json:"deliveryStatus"`
+	RecordedBy     string             `json:"recordedBy"`
+	RecordedByRole UserRole           `json:"recordedByRole"`
+	OnBehalfOf     string             `json:"onBehalfOf,omitempty" metadata:",optional"`
+	RecordedAt     string             `json:"recordedAt"`
+	CorrelationID  string             `json:"correlationId,omitempty" metadata:",optional"`
+}
+
+// appendLocationHistory stores a location history entry keyed by the current transaction
+func appendLocationHistory(ctx contractapi.TransactionContextInterface, update *LocationUpdate) error {
+	update.CorrelationID = correlationID(ctx)
+	historyKey, err := ctx.GetStub().CreateCompositeKey(RecordLocationHistory, []string{update.DeliveryID, update.TxID})
+	if err != nil {
+		return fmt.Errorf("failed to create location history composite key: %v", err)
+	}
+	updateJSON, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("failed to marshal location update: %v", err)
+	}
+	if err := ctx.GetStub().PutState(historyKey, updateJSON); err != nil {
+		return fmt.Errorf("failed to put location history: %v", err)
+	}
+	return nil
+}
+
+// getLocationUpdate reads the location history entry a transaction recorded, or nil if it recorded none
+func getLocationUpdate(ctx contractapi.TransactionContextInterface, deliveryID, txID string) (*LocationUpdate, error) {
+	historyKey, err := ctx.GetStub().CreateCompositeKey(RecordLocationHistory, []string{deliveryID, txID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create location history composite key: %v", err)
+	}
+	updateJSON, err := ctx.GetStub().GetState(historyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read location history: %v", err)
+	}
+	if updateJSON == nil {
+		return nil, nil
+	}
+
+	var update LocationUpdate
+	if err := json.Unmarshal(updateJSON, &update); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal location update: %v", err)
+	}
+	return &update, nil
+}
+
+// GetLocationHistory returns every recorded location of a delivery
+// Entries are keyed by transaction ID, so clients should order them by recordedAt
+func (c *DeliveryContract) GetLocationHistory(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*LocationUpdate, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTrackingAccess(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordLocationHistory, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get location history: %v", err)
+	}
+	defer iterator.Close()
+
+	updates := []*LocationUpdate{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate location history: %v", err)
+		}
+
+		var update LocationUpdate
+		if err := json.Unmarshal(response.Value, &update); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal location update: %v", err)
+		}
+		updates = append(updates, &update)
+	}
+
+	return updates, nil
+}
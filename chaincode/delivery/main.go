@@ -2,14 +2,10 @@ package main
 
 import (
 	"log"
-
-	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
 func main() {
-	deliveryContract := new(DeliveryContract)
-
-	chaincode, err := contractapi.NewChaincode(deliveryContract)
+	chaincode, err := newDescribedChaincode(newDeliveryContract())
 	if err != nil {
 		log.Panicf("Error creating delivery chaincode: %v", err)
 	}
@@ -18,3 +14,13 @@ func main() {
 		log.Panicf("Error starting delivery chaincode: %v", err)
 	}
 }
+
+// newDeliveryContract creates the delivery contract with its transaction context and hooks
+func newDeliveryContract() *DeliveryContract {
+	deliveryContract := new(DeliveryContract)
+	deliveryContract.TransactionContextHandler = new(TenantTransactionContext)
+	deliveryContract.BeforeTransaction = beforeTransaction
+	deliveryContract.AfterTransaction = emitDeliveryProjections
+	deliveryContract.Info = contractInfo
+	return deliveryContract
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ManifestStatus represents the state of a transfer manifest
+type ManifestStatus string
+
+const (
+	ManifestOpen              ManifestStatus = "OPEN"
+	ManifestReceived          ManifestStatus = "RECEIVED"
+	ManifestPartiallyReceived ManifestStatus = "PARTIALLY_RECEIVED"
+)
+
+// RecordTransferManifest is the composite key prefix for transfer manifests
+const RecordTransferManifest = "manifest~manifestId"
+
+// Event names for manifest transfers
+const (
+	EventManifestCreated  = "ManifestCreated"
+	EventManifestUpdated  = "ManifestUpdated"
+	EventManifestReceived = "ManifestReceived"
+)
+
+// TransferManifest groups the deliveries moved together on a single vehicle trip
+// Adding a delivery initiates its handoff to the manifest recipient;
+// receipt confirms all handed-over parcels at once and records the ones that didn't arrive.
+type TransferManifest struct {
+	ManifestID          string         `json:"manifestId"`
+	FromUserID          string         `json:"fromUserId"`
+	FromRole            UserRole       `json:"fromRole"`
+	ToUserID            string         `json:"toUserId"`
+	ToRole              UserRole       `json:"toRole"`
+	DeliveryIDs         []string       `json:"deliveryIds"`
+	Status              ManifestStatus `json:"status"`
+	ReceivedDeliveryIDs []string       `json:"receivedDeliveryIds,omitempty" metadata:",optional"`
+	MissingDeliveryIDs  []string       `json:"missingDeliveryIds,omitempty" metadata:",optional"`
+	ReceivedLocation    *Location      `json:"receivedLocation,omitempty" metadata:",optional"`
+	CreatedAt           string         `json:"createdAt"`
+	ReceivedAt          string         `json:"receivedAt,omitempty" metadata:",optional"`
+}
+
+// getTransferManifest reads a transfer manifest, returning nil if it does not exist
+func getTransferManifest(ctx contractapi.TransactionContextInterface, manifestID string) (*TransferManifest, error) {
+	manifestKey, err := ctx.GetStub().CreateCompositeKey(RecordTransferManifest, []string{manifestID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create manifest composite key: %v", err)
+	}
+	manifestJSON, err := ctx.GetStub().GetState(manifestKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %v", manifestID, err)
+	}
+	if manifestJSON == nil {
+		return nil, nil
+	}
+
+	var manifest TransferManifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %v", err)
+	}
+	return &manifest, nil
+}
+
+// putTransferManifest writes a transfer manifest
+func putTransferManifest(ctx contractapi.TransactionContextInterface, manifest *TransferManifest) error {
+	manifestKey, err := ctx.GetStub().CreateCompositeKey(RecordTransferManifest, []string{manifest.ManifestID})
+	if err != nil {
+		return fmt.Errorf("failed to create manifest composite key: %v", err)
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %v", err)
+	}
+	if err := ctx.GetStub().PutState(manifestKey, manifestJSON); err != nil {
+		return fmt.Errorf("failed to put manifest: %v", err)
+	}
+	return nil
+}
+
+// CreateManifest opens a transfer manifest for a trip to another logistics party
+// DELIVERY_PERSON or WAREHOUSE can create manifests
+func (c *DeliveryContract) CreateManifest(
+	ctx contractapi.TransactionContextInterface,
+	manifestID string,
+	toUserID string,
+	toRole string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateUserID(manifestID, "manifestID"); err != nil {
+		return err
+	}
+	if err := validateUserID(toUserID, "toUserID"); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - manifests move parcels between logistics parties
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse); err != nil {
+		return err
+	}
+
+	targetRole := UserRole(toRole)
+	if targetRole != RoleDeliveryPerson && targetRole != RoleWarehouse {
+		return fmt.Errorf("manifests can only target DELIVERY_PERSON or WAREHOUSE")
+	}
+	if toUserID == caller.ID {
+		return fmt.Errorf("cannot create a manifest addressed to yourself")
+	}
+
+	existing, err := getTransferManifest(ctx, manifestID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("manifest %s already exists", manifestID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	manifest := TransferManifest{
+		ManifestID:  manifestID,
+		FromUserID:  caller.ID,
+		FromRole:    caller.Role,
+		ToUserID:    toUserID,
+		ToRole:      targetRole,
+		DeliveryIDs: []string{},
+		Status:      ManifestOpen,
+		CreatedAt:   currentTime,
+	}
+	if err := putTransferManifest(ctx, &manifest); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventManifestCreated, manifest)
+}
+
+// AddToManifest loads a delivery onto an open manifest, initiating its handoff to the recipient
+// Only the manifest creator, as current custodian of the delivery, can add to it
+func (c *DeliveryContract) AddToManifest(
+	ctx contractapi.TransactionContextInterface,
+	manifestID string,
+	deliveryID string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateUserID(manifestID, "manifestID"); err != nil {
+		return err
+	}
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse); err != nil {
+		return err
+	}
+
+	manifest, err := getTransferManifest(ctx, manifestID)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		return fmt.Errorf("manifest %s does not exist", manifestID)
+	}
+	if manifest.FromUserID != caller.ID {
+		return fmt.Errorf("only the manifest creator can add deliveries")
+	}
+	if manifest.Status != ManifestOpen {
+		return fmt.Errorf("manifest %s is no longer open", manifestID)
+	}
+	for _, id := range manifest.DeliveryIDs {
+		if id == deliveryID {
+			return fmt.Errorf("delivery %s is already on manifest %s", deliveryID, manifestID)
+		}
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := initiateHandoffInternal(ctx, caller, delivery, manifest.ToUserID, manifest.ToRole, "", nil, currentTime); err != nil {
+		return err
+	}
+
+	manifest.DeliveryIDs = append(manifest.DeliveryIDs, deliveryID)
+	if err := putTransferManifest(ctx, manifest); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventManifestUpdated, map[string]string{
+		"manifestId": manifestID,
+		"deliveryId": deliveryID,
+		"toUserId":   manifest.ToUserID,
+		"timestamp":  currentTime,
+	})
+}
+
+// ConfirmManifestReceipt confirms custody of every received parcel on a manifest in one transaction
+// Only the manifest recipient can confirm; parcels not listed as received are recorded as missing
+// and keep their pending handoff so the sender can cancel or re-offer them
+func (c *DeliveryContract) ConfirmManifestReceipt(
+	ctx contractapi.TransactionContextInterface,
+	manifestID string,
+	receivedIDsJSON string,
+	city string,
+	state string,
+	country string,
+) (*TransferManifest, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateUserID(manifestID, "manifestID"); err != nil {
+		return nil, err
+	}
+	city, state, country = normalizeLocation(city, state, country)
+	if err := validateLocation(city, state, country); err != nil {
+		return nil, err
+	}
+
+	var receivedIDs []string
+	if err := json.Unmarshal([]byte(receivedIDsJSON), &receivedIDs); err != nil {
+		return nil, fmt.Errorf("failed to parse received delivery IDs: %v", err)
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse); err != nil {
+		return nil, err
+	}
+
+	manifest, err := getTransferManifest(ctx, manifestID)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("manifest %s does not exist", manifestID)
+	}
+	if manifest.ToUserID != caller.ID {
+		return nil, fmt.Errorf("only the manifest recipient can confirm receipt")
+	}
+	if manifest.Status != ManifestOpen {
+		return nil, fmt.Errorf("manifest %s has already been received", manifestID)
+	}
+
+	onManifest := make(map[string]bool)
+	for _, id := range manifest.DeliveryIDs {
+		onManifest[id] = true
+	}
+	received := make(map[string]bool)
+	for _, id := range receivedIDs {
+		if !onManifest[id] {
+			return nil, &ValidationError{Field: "receivedIDs", Message: fmt.Sprintf("delivery %s is not on manifest %s", id, manifestID)}
+		}
+		if received[id] {
+			return nil, &ValidationError{Field: "receivedIDs", Message: fmt.Sprintf("duplicate delivery ID %s", id)}
+		}
+		received[id] = true
+	}
+
+	// Couriers can't accept more packages than their configured capacity
+	if caller.Role == RoleDeliveryPerson {
+		if err := validateCourierCapacity(ctx, caller.ID, len(receivedIDs)); err != nil {
+			return nil, err
+		}
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	location := Location{
+		City:    city,
+		State:   state,
+		Country: country,
+	}
+
+	manifest.ReceivedDeliveryIDs = []string{}
+	manifest.MissingDeliveryIDs = []string{}
+	for _, deliveryID := range manifest.DeliveryIDs {
+		if !received[deliveryID] {
+			manifest.MissingDeliveryIDs = append(manifest.MissingDeliveryIDs, deliveryID)
+			continue
+		}
+
+		delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := confirmHandoffInternal(ctx, caller, delivery, location, delivery.PackageWeight, delivery.PackageDimensions, nil, currentTime); err != nil {
+			return nil, fmt.Errorf("%s: %v", deliveryID, err)
+		}
+		manifest.ReceivedDeliveryIDs = append(manifest.ReceivedDeliveryIDs, deliveryID)
+	}
+
+	manifest.Status = ManifestReceived
+	if len(manifest.MissingDeliveryIDs) > 0 {
+		manifest.Status = ManifestPartiallyReceived
+	}
+	manifest.ReceivedLocation = &location
+	manifest.ReceivedAt = currentTime
+	if err := putTransferManifest(ctx, manifest); err != nil {
+		return nil, err
+	}
+
+	// One signature event for the whole manifest
+	if err := emitEvent(ctx, EventManifestReceived, manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// GetManifest returns a transfer manifest with its contents and reconciliation
+// Only the sender, the recipient, or ADMIN can read a manifest
+func (c *DeliveryContract) GetManifest(
+	ctx contractapi.TransactionContextInterface,
+	manifestID string,
+) (*TransferManifest, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	manifest, err := getTransferManifest(ctx, manifestID)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("manifest %s does not exist", manifestID)
+	}
+
+	if caller.Role != RoleAdmin && caller.ID != manifest.FromUserID && caller.ID != manifest.ToUserID {
+		return nil, fmt.Errorf("access denied: you are not a party to this manifest")
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key prefixes for milestone commitments
+const (
+	RecordMilestone        = "milestone~deliveryId~txId"
+	RecordPendingMilestone = "pendingMilestone~deliveryId~txId"
+	RecordMilestoneRoot    = "milestoneRoot~rootId"
+)
+
+// EventMilestoneRootCommitted is emitted when a new Merkle root is stored
+const EventMilestoneRootCommitted = "MilestoneRootCommitted"
+
+// maxMilestonesPerRoot bounds the leaves committed by one CommitMilestoneRoot call
+const maxMilestonesPerRoot = 1000
+
+// Merkle proof sibling positions
+const (
+	ProofSiblingLeft  = "LEFT"
+	ProofSiblingRight = "RIGHT"
+)
+
+// Milestone is a single delivery status transition
+type Milestone struct {
+	DeliveryID string         `json:"deliveryId"`
+	TxID       string         `json:"txId"`
+	FromStatus DeliveryStatus `json:"fromStatus"`
+	ToStatus   DeliveryStatus `json:"toStatus"`
+	Timestamp  string         `json:"timestamp"`
+	RootID     string         `json:"rootId,omitempty" metadata:",optional"`
+}
+
+// MilestoneLeafRef identifies the milestone behind a Merkle leaf
+type MilestoneLeafRef struct {
+	DeliveryID string `json:"deliveryId"`
+	TxID       string `json:"txId"`
+}
+
+// MilestoneRoot is a Merkle root committed over a batch of milestones
+type MilestoneRoot struct {
+	RootID      string             `json:"rootId"`
+	Root        string             `json:"root"`
+	Leaves      []MilestoneLeafRef `json:"leaves"`
+	CommittedBy string             `json:"committedBy"`
+	CommittedAt string             `json:"committedAt"`
+}
+
+// ProofStep is one sibling hash on the path from a leaf to the root
+type ProofStep struct {
+	Hash     string `json:"hash"`
+	Position string `json:"position"`
+}
+
+// MilestoneProof lets an auditor verify a milestone against a committed root
+// Leaf = sha256(0x00 || deliveryId|txId|from|to|timestamp); node = sha256(0x01 || left || right).
+// An unpaired node is carried up to the next level unchanged and adds no step to the path.
+type MilestoneProof struct {
+	Milestone Milestone   `json:"milestone"`
+	Leaf      string      `json:"leaf"`
+	LeafIndex int         `json:"leafIndex"`
+	RootID    string      `json:"rootId"`
+	Root      string      `json:"root"`
+	Path      []ProofStep `json:"path"`
+}
+
+// recordMilestone logs a status transition and queues it for the next Merkle root
+// Called from the status index helpers so every transition is captured
+func recordMilestone(ctx contractapi.TransactionContextInterface, deliveryID string, oldStatus, newStatus DeliveryStatus) error {
+	stub := ctx.GetStub()
+	txID := stub.GetTxID()
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	milestone := Milestone{
+		DeliveryID: deliveryID,
+		TxID:       txID,
+		FromStatus: oldStatus,
+		ToStatus:   newStatus,
+		Timestamp:  currentTime,
+	}
+	if err := putMilestone(ctx, &milestone); err != nil {
+		return err
+	}
+
+	pendingKey, err := stub.CreateCompositeKey(RecordPendingMilestone, []string{deliveryID, txID})
+	if err != nil {
+		return fmt.Errorf("failed to create pending milestone composite key: %v", err)
+	}
+	if err := stub.PutState(pendingKey, []byte{0x00}); err != nil {
+		return fmt.Errorf("failed to put pending milestone: %v", err)
+	}
+
+	return nil
+}
+
+// getMilestone reads a milestone, returning nil if it does not exist
+func getMilestone(ctx contractapi.TransactionContextInterface, deliveryID, txID string) (*Milestone, error) {
+	milestoneKey, err := ctx.GetStub().CreateCompositeKey(RecordMilestone, []string{deliveryID, txID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create milestone composite key: %v", err)
+	}
+	milestoneJSON, err := ctx.GetStub().GetState(milestoneKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read milestone: %v", err)
+	}
+	if milestoneJSON == nil {
+		return nil, nil
+	}
+
+	var milestone Milestone
+	if err := json.Unmarshal(milestoneJSON, &milestone); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal milestone: %v", err)
+	}
+	return &milestone, nil
+}
+
+// putMilestone writes a milestone
+func putMilestone(ctx contractapi.TransactionContextInterface, milestone *Milestone) error {
+	milestoneKey, err := ctx.GetStub().CreateCompositeKey(RecordMilestone, []string{milestone.DeliveryID, milestone.TxID})
+	if err != nil {
+		return fmt.Errorf("failed to create milestone composite key: %v", err)
+	}
+	milestoneJSON, err := json.Marshal(milestone)
+	if err != nil {
+		return fmt.Errorf("failed to marshal milestone: %v", err)
+	}
+	if err := ctx.GetStub().PutState(milestoneKey, milestoneJSON); err != nil {
+		return fmt.Errorf("failed to put milestone: %v", err)
+	}
+	return nil
+}
+
+// milestoneLeaf returns the Merkle leaf hash of a milestone
+func milestoneLeaf(milestone *Milestone) []byte {
+	data := fmt.Sprintf("%s|%s|%s|%s|%s", milestone.DeliveryID, milestone.TxID, milestone.FromStatus, milestone.ToStatus, milestone.Timestamp)
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// merkleNode returns the hash of an inner node
+func merkleNode(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// merkleRootAndPath computes the root over the leaves and the proof path of one leaf
+func merkleRootAndPath(leaves [][]byte, index int) ([]byte, []ProofStep) {
+	path := []ProofStep{}
+	level := leaves
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			if index == i {
+				path = append(path, ProofStep{Hash: fmt.Sprintf("%x", level[i+1]), Position: ProofSiblingRight})
+			} else if index == i+1 {
+				path = append(path, ProofStep{Hash: fmt.Sprintf("%x", level[i]), Position: ProofSiblingLeft})
+			}
+			next = append(next, merkleNode(level[i], level[i+1]))
+		}
+		index /= 2
+		level = next
+	}
+	return level[0], path
+}
+
+// getMilestoneRoot reads a committed Merkle root, returning nil if it does not exist
+func getMilestoneRoot(ctx contractapi.TransactionContextInterface, rootID string) (*MilestoneRoot, error) {
+	rootKey, err := ctx.GetStub().CreateCompositeKey(RecordMilestoneRoot, []string{rootID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create milestone root composite key: %v", err)
+	}
+	rootJSON, err := ctx.GetStub().GetState(rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read milestone root: %v", err)
+	}
+	if rootJSON == nil {
+		return nil, nil
+	}
+
+	var root MilestoneRoot
+	if err := json.Unmarshal(rootJSON, &root); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal milestone root: %v", err)
+	}
+	return &root, nil
+}
+
+// CommitMilestoneRoot builds a Merkle root over the status transitions not yet committed
+// Only ADMIN can commit roots; intended to run periodically (e.g. hourly)
+func (c *DeliveryContract) CommitMilestoneRoot(
+	ctx contractapi.TransactionContextInterface,
+) (*MilestoneRoot, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - milestone roots cover every region, so only an unscoped ADMIN commits them
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	iterator, err := stub.GetStateByPartialCompositeKey(RecordPendingMilestone, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending milestones: %v", err)
+	}
+	defer iterator.Close()
+
+	var refs []MilestoneLeafRef
+	var pendingKeys []string
+	for iterator.HasNext() && len(refs) < maxMilestonesPerRoot {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate pending milestones: %v", err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(response.Key)
+		if err != nil || len(attrs) != 2 {
+			continue
+		}
+		refs = append(refs, MilestoneLeafRef{DeliveryID: attrs[0], TxID: attrs[1]})
+		pendingKeys = append(pendingKeys, response.Key)
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("no pending milestones to commit")
+	}
+
+	// Key order is deterministic, but sort explicitly so the leaf order is part of the spec
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].DeliveryID != refs[j].DeliveryID {
+			return refs[i].DeliveryID < refs[j].DeliveryID
+		}
+		return refs[i].TxID < refs[j].TxID
+	})
+
+	rootID := stub.GetTxID()
+	leaves := make([][]byte, 0, len(refs))
+	for _, ref := range refs {
+		milestone, err := getMilestone(ctx, ref.DeliveryID, ref.TxID)
+		if err != nil {
+			return nil, err
+		}
+		if milestone == nil {
+			return nil, fmt.Errorf("milestone %s/%s not found", ref.DeliveryID, ref.TxID)
+		}
+		leaves = append(leaves, milestoneLeaf(milestone))
+
+		milestone.RootID = rootID
+		if err := putMilestone(ctx, milestone); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range pendingKeys {
+		if err := stub.DelState(key); err != nil {
+			return nil, fmt.Errorf("failed to delete pending milestone: %v", err)
+		}
+	}
+
+	rootHash, _ := merkleRootAndPath(leaves, 0)
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	root := MilestoneRoot{
+		RootID:      rootID,
+		Root:        fmt.Sprintf("%x", rootHash),
+		Leaves:      refs,
+		CommittedBy: caller.ID,
+		CommittedAt: currentTime,
+	}
+	rootKey, err := stub.CreateCompositeKey(RecordMilestoneRoot, []string{rootID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create milestone root composite key: %v", err)
+	}
+	rootJSON, err := json.Marshal(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal milestone root: %v", err)
+	}
+	if err := stub.PutState(rootKey, rootJSON); err != nil {
+		return nil, fmt.Errorf("failed to put milestone root: %v", err)
+	}
+
+	err = emitEvent(ctx, EventMilestoneRootCommitted, map[string]interface{}{
+		"rootId":    rootID,
+		"root":      root.Root,
+		"leafCount": len(refs),
+		"timestamp": currentTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &root, nil
+}
+
+// GetMilestoneProof returns the Merkle inclusion proof of a delivery status transition
+// The transition must already be covered by a committed root
+func (c *DeliveryContract) GetMilestoneProof(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	txID string,
+) (*MilestoneProof, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	milestone, err := getMilestone(ctx, deliveryID, txID)
+	if err != nil {
+		return nil, err
+	}
+	if milestone == nil {
+		return nil, fmt.Errorf("no status transition recorded for delivery %s in transaction %s", deliveryID, txID)
+	}
+	if milestone.RootID == "" {
+		return nil, fmt.Errorf("status transition has not been committed to a milestone root yet")
+	}
+
+	root, err := getMilestoneRoot(ctx, milestone.RootID)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, fmt.Errorf("milestone root %s not found", milestone.RootID)
+	}
+
+	index := -1
+	leaves := make([][]byte, 0, len(root.Leaves))
+	for i, ref := range root.Leaves {
+		leafMilestone, err := getMilestone(ctx, ref.DeliveryID, ref.TxID)
+		if err != nil {
+			return nil, err
+		}
+		if leafMilestone == nil {
+			return nil, fmt.Errorf("milestone %s/%s not found", ref.DeliveryID, ref.TxID)
+		}
+		leaves = append(leaves, milestoneLeaf(leafMilestone))
+		if ref.DeliveryID == deliveryID && ref.TxID == txID {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("status transition is not part of milestone root %s", root.RootID)
+	}
+
+	rootHash, path := merkleRootAndPath(leaves, index)
+	if fmt.Sprintf("%x", rootHash) != root.Root {
+		return nil, fmt.Errorf("recomputed root does not match committed root %s", root.RootID)
+	}
+
+	return &MilestoneProof{
+		Milestone: *milestone,
+		Leaf:      fmt.Sprintf("%x", leaves[index]),
+		LeafIndex: index,
+		RootID:    root.RootID,
+		Root:      root.Root,
+		Path:      path,
+	}, nil
+}
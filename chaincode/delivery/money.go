@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Event names for declared values and cash on delivery
+const (
+	EventDeclaredValueSet      = "DeclaredValueSet"
+	EventCashOnDeliverySettled = "CashOnDeliverySettled"
+)
+
+// maxAmountMinor bounds every stored amount, sums included, well below the int64 range
+const maxAmountMinor = int64(1e15)
+
+// iso4217MinorUnits maps active ISO 4217 currency codes to their number of minor-unit digits
+var iso4217MinorUnits = map[string]int{
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2, "AUD": 2, "AWG": 2, "AZN": 2,
+	"BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BHD": 3, "BIF": 0, "BMD": 2, "BND": 2, "BOB": 2, "BRL": 2,
+	"BSD": 2, "BTN": 2, "BWP": 2, "BYN": 2, "BZD": 2, "CAD": 2, "CDF": 2, "CHF": 2, "CLF": 4, "CLP": 0,
+	"CNY": 2, "COP": 2, "CRC": 2, "CUP": 2, "CVE": 2, "CZK": 2, "DJF": 0, "DKK": 2, "DOP": 2, "DZD": 2,
+	"EGP": 2, "ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2, "GBP": 2, "GEL": 2, "GHS": 2, "GIP": 2,
+	"GMD": 2, "GNF": 0, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2, "HTG": 2, "HUF": 2, "IDR": 2, "ILS": 2,
+	"INR": 2, "IQD": 3, "IRR": 2, "ISK": 0, "JMD": 2, "JOD": 3, "JPY": 0, "KES": 2, "KGS": 2, "KHR": 2,
+	"KMF": 0, "KPW": 2, "KRW": 0, "KWD": 3, "KYD": 2, "KZT": 2, "LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2,
+	"LSL": 2, "LYD": 3, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2, "MNT": 2, "MOP": 2, "MRU": 2,
+	"MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2, "MYR": 2, "MZN": 2, "NAD": 2, "NGN": 2, "NIO": 2, "NOK": 2,
+	"NPR": 2, "NZD": 2, "OMR": 3, "PAB": 2, "PEN": 2, "PGK": 2, "PHP": 2, "PKR": 2, "PLN": 2, "PYG": 0,
+	"QAR": 2, "RON": 2, "RSD": 2, "RUB": 2, "RWF": 0, "SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2,
+	"SGD": 2, "SHP": 2, "SLE": 2, "SOS": 2, "SRD": 2, "SSP": 2, "STN": 2, "SVC": 2, "SYP": 2, "SZL": 2,
+	"THB": 2, "TJS": 2, "TMT": 2, "TND": 3, "TOP": 2, "TRY": 2, "TTD": 2, "TWD": 2, "TZS": 2, "UAH": 2,
+	"UGX": 0, "USD": 2, "UYU": 2, "UYW": 4, "UZS": 2, "VES": 2, "VND": 0, "VUV": 0, "WST": 2, "XAF": 0,
+	"XCD": 2, "XOF": 0, "XPF": 0, "YER": 2, "ZAR": 2, "ZMW": 2, "ZWG": 2,
+}
+
+// Money is an amount in the minor units (cents, pence, yen...) of an ISO 4217 currency
+// Amounts are integers so sums and comparisons never drift the way floats do.
+type Money struct {
+	AmountMinor int64  `json:"amountMinor"`
+	Currency    string `json:"currency"`
+	// Number of minor-unit digits of the currency, for display (2 for USD, 0 for JPY)
+	MinorUnits int `json:"minorUnits"`
+}
+
+// newMoney validates an amount and ISO 4217 currency code
+func newMoney(amountMinor int64, currency string, field string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	minorUnits, ok := iso4217MinorUnits[currency]
+	if !ok {
+		return Money{}, &ValidationError{Field: field + "Currency", Message: "must be an ISO 4217 currency code"}
+	}
+	if amountMinor < 0 || amountMinor > maxAmountMinor {
+		return Money{}, &ValidationError{Field: field, Message: fmt.Sprintf("must be between 0 and %d minor units", maxAmountMinor)}
+	}
+	return Money{AmountMinor: amountMinor, Currency: currency, MinorUnits: minorUnits}, nil
+}
+
+// addMoney sums two amounts of the same currency; a nil side counts as zero
+// Sums above maxAmountMinor are rejected rather than wrapped, so every peer computes the same result.
+func addMoney(a *Money, b *Money) (*Money, error) {
+	if a == nil {
+		return b, nil
+	}
+	if b == nil {
+		return a, nil
+	}
+	if a.Currency != b.Currency {
+		return nil, fmt.Errorf("cannot combine amounts in %s and %s", a.Currency, b.Currency)
+	}
+	if a.AmountMinor > maxAmountMinor-b.AmountMinor {
+		return nil, fmt.Errorf("combined amount exceeds %d %s minor units", maxAmountMinor, a.Currency)
+	}
+	sum := *a
+	sum.AmountMinor += b.AmountMinor
+	return &sum, nil
+}
+
+// splitMoney divides an amount into parts that add up to it, the remainder going to the first part
+// A nil amount or a share of 0 minor units is a nil part.
+func splitMoney(amount *Money, parts int) []*Money {
+	shares := make([]*Money, parts)
+	if amount == nil || parts == 0 {
+		return shares
+	}
+	for i := range shares {
+		share := *amount
+		share.AmountMinor = amount.AmountMinor / int64(parts)
+		if i == 0 {
+			share.AmountMinor += amount.AmountMinor % int64(parts)
+		}
+		if share.AmountMinor > 0 {
+			shares[i] = &share
+		}
+	}
+	return shares
+}
+
+// codSettlementStatuses are the doorstep statuses in which a courier collects cash
+var codSettlementStatuses = map[DeliveryStatus]bool{
+	StatusOutForDelivery:              true,
+	StatusPendingDeliveryConfirmation: true,
+}
+
+// validateCODSettled rejects the final handoff of a cash-on-delivery parcel whose cash has not
+// been collected
+func validateCODSettled(delivery *Delivery) error {
+	if delivery.CODAmount != nil && delivery.CODSettlement == nil {
+		return fmt.Errorf("cash on delivery of %d %s minor units for delivery %s has not been settled",
+			delivery.CODAmount.AmountMinor, delivery.CODAmount.Currency, delivery.DeliveryID)
+	}
+	return nil
+}
+
+// CODSettlement records the cash a courier collected for a cash-on-delivery parcel
+type CODSettlement struct {
+	Amount    Money  `json:"amount"`
+	SettledBy string `json:"settledBy"`
+	SettledAt string `json:"settledAt"`
+}
+
+// SetDeclaredValue records the declared value and optional cash-on-delivery amount of a parcel
+// Only the SELLER of the delivery can set them, before pickup. Both amounts are in minor units
+// of one currency; a COD amount of 0 means the parcel is prepaid.
+func (c *DeliveryContract) SetDeclaredValue(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	currency string,
+	declaredValueMinor int64,
+	codAmountMinor int64,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	declaredValue, err := newMoney(declaredValueMinor, currency, "declaredValue")
+	if err != nil {
+		return err
+	}
+	codAmount, err := newMoney(codAmountMinor, currency, "codAmount")
+	if err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER declares values
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller can set the declared value of this delivery")
+	}
+	if delivery.DeliveryStatus != StatusPendingPickup {
+		return fmt.Errorf("can only set the declared value before pickup")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	delivery.DeclaredValue = &declaredValue
+	delivery.CODAmount = nil
+	if codAmount.AmountMinor > 0 {
+		delivery.CODAmount = &codAmount
+	}
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventDeclaredValueSet, map[string]interface{}{
+		"deliveryId":         deliveryID,
+		"currency":           declaredValue.Currency,
+		"declaredValueMinor": declaredValue.AmountMinor,
+		"codAmountMinor":     codAmount.AmountMinor,
+		"timestamp":          currentTime,
+	})
+}
+
+// SettleCashOnDelivery records the cash collected for a cash-on-delivery parcel
+// Only the current DELIVERY_PERSON custodian can settle, at the doorstep: while out for delivery
+// or with the handoff to the customer pending. The settlement must be in the COD currency and
+// for the full amount.
+func (c *DeliveryContract) SettleCashOnDelivery(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	currency string,
+	amountMinor int64,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	amount, err := newMoney(amountMinor, currency, "amount")
+	if err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only couriers collect cash
+	if err := validateRole(ctx, caller, RoleDeliveryPerson); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	if delivery.CurrentCustodianID != caller.ID {
+		return fmt.Errorf("only the current custodian can settle cash on delivery")
+	}
+	if !codSettlementStatuses[delivery.DeliveryStatus] {
+		return fmt.Errorf("cannot settle cash on delivery in current status: %s", delivery.DeliveryStatus)
+	}
+
+	if delivery.CODAmount == nil {
+		return fmt.Errorf("delivery %s is not cash on delivery", deliveryID)
+	}
+	if delivery.CODSettlement != nil {
+		return fmt.Errorf("cash on delivery was already settled at %s", delivery.CODSettlement.SettledAt)
+	}
+	if amount.Currency != delivery.CODAmount.Currency {
+		return fmt.Errorf("settlement currency %s does not match COD currency %s", amount.Currency, delivery.CODAmount.Currency)
+	}
+	if amount.AmountMinor != delivery.CODAmount.AmountMinor {
+		return fmt.Errorf("settlement of %d does not match COD amount of %d %s minor units", amount.AmountMinor, delivery.CODAmount.AmountMinor, amount.Currency)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	delivery.CODSettlement = &CODSettlement{
+		Amount:    amount,
+		SettledBy: caller.ID,
+		SettledAt: currentTime,
+	}
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventCashOnDeliverySettled, map[string]interface{}{
+		"deliveryId":  deliveryID,
+		"orderId":     delivery.OrderID,
+		"currency":    amount.Currency,
+		"amountMinor": amount.AmountMinor,
+		"settledBy":   caller.ID,
+		"timestamp":   currentTime,
+	})
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordDeliveryNote is the composite key prefix for notes added to a delivery
+const RecordDeliveryNote = "note~deliveryId~txId"
+
+// EventDeliveryNoteAdded is emitted when a note is added to a delivery
+const EventDeliveryNoteAdded = "DeliveryNoteAdded"
+
+// maxNoteLength bounds the text of a single note
+const maxNoteLength = 2000
+
+// NoteVisibility is who can read a delivery note
+type NoteVisibility string
+
+const (
+	// NoteVisibilityParties notes are visible to everyone who can read the delivery
+	NoteVisibilityParties NoteVisibility = "PUBLIC_TO_PARTIES"
+	// NoteVisibilityLogistics notes are visible to couriers, warehouses, support, auditors and admins
+	NoteVisibilityLogistics NoteVisibility = "LOGISTICS_ONLY"
+	// NoteVisibilityAdmin notes are visible to admins only
+	NoteVisibilityAdmin NoteVisibility = "ADMIN_ONLY"
+)
+
+// DeliveryNote is an operational comment on a delivery
+// Notes are append-only records next to the delivery, so they never change the delivery itself.
+type DeliveryNote struct {
+	DeliveryID string         `json:"deliveryId"`
+	TxID       string         `json:"txId"`
+	Text       string         `json:"text"`
+	Visibility NoteVisibility `json:"visibility"`
+	AuthorID   string         `json:"authorId"`
+	AuthorRole UserRole       `json:"authorRole"`
+	CreatedAt  string         `json:"createdAt"`
+}
+
+// noteVisibleTo reports whether a role can read notes of the given visibility
+func noteVisibleTo(visibility NoteVisibility, role UserRole) bool {
+	switch visibility {
+	case NoteVisibilityParties:
+		return true
+	case NoteVisibilityLogistics:
+		return isLogisticsRole(role) || role == RoleSupport || role == RoleAuditor || role == RoleAdmin
+	case NoteVisibilityAdmin:
+		return role == RoleAdmin
+	}
+	return false
+}
+
+// AddDeliveryNote appends a note to a delivery
+// visibility is PUBLIC_TO_PARTIES, LOGISTICS_ONLY or ADMIN_ONLY; callers can only write notes
+// they could read themselves. Parties of the delivery and SUPPORT can add notes. The event
+// carries the note text only for PUBLIC_TO_PARTIES notes.
+func (c *DeliveryContract) AddDeliveryNote(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	text string,
+	visibility string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	text = sanitizeText(text)
+	if text == "" {
+		return &ValidationError{Field: "text", Message: "is required"}
+	}
+	if err := validateText(text, "text", maxNoteLength, true); err != nil {
+		return err
+	}
+	switch NoteVisibility(visibility) {
+	case NoteVisibilityParties, NoteVisibilityLogistics, NoteVisibilityAdmin:
+	default:
+		return &ValidationError{Field: "visibility", Message: "must be PUBLIC_TO_PARTIES, LOGISTICS_ONLY or ADMIN_ONLY"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - people taking part in deliveries
+	if err := validateRole(ctx, caller, participantRoles...); err != nil {
+		return err
+	}
+	if !noteVisibleTo(NoteVisibility(visibility), caller.Role) {
+		return fmt.Errorf("role %s cannot add %s notes", caller.Role, visibility)
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if caller.Role == RoleSupport {
+		if delivery.TenantID != caller.TenantID {
+			return fmt.Errorf("not authorized to access this delivery")
+		}
+	} else if err := validateParty(delivery, caller); err != nil {
+		return err
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	txID := ctx.GetStub().GetTxID()
+
+	note := DeliveryNote{
+		DeliveryID: deliveryID,
+		TxID:       txID,
+		Text:       text,
+		Visibility: NoteVisibility(visibility),
+		AuthorID:   caller.ID,
+		AuthorRole: caller.Role,
+		CreatedAt:  currentTime,
+	}
+
+	noteKey, err := ctx.GetStub().CreateCompositeKey(RecordDeliveryNote, []string{deliveryID, txID})
+	if err != nil {
+		return fmt.Errorf("failed to create note composite key: %v", err)
+	}
+	noteJSON, err := json.Marshal(note)
+	if err != nil {
+		return fmt.Errorf("failed to marshal note: %v", err)
+	}
+	if err := ctx.GetStub().PutState(noteKey, noteJSON); err != nil {
+		return fmt.Errorf("failed to put note: %v", err)
+	}
+
+	payload := map[string]string{
+		"deliveryId": deliveryID,
+		"txId":       txID,
+		"visibility": visibility,
+		"authorId":   caller.ID,
+		"timestamp":  currentTime,
+	}
+	// Restricted notes must not reach the delivery's customer or seller through event routing
+	if note.Visibility == NoteVisibilityParties {
+		payload["text"] = text
+		addEventAudience(ctx, delivery)
+	}
+	return emitEvent(ctx, EventDeliveryNoteAdded, payload)
+}
+
+// GetNotes returns the notes of a delivery the caller is allowed to read, oldest first
+// Anyone who can read the delivery sees PUBLIC_TO_PARTIES notes; LOGISTICS_ONLY and ADMIN_ONLY
+// notes are filtered by the caller's role.
+func (c *DeliveryContract) GetNotes(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*DeliveryNote, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordDeliveryNote, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notes: %v", err)
+	}
+	defer iterator.Close()
+
+	notes := []*DeliveryNote{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate notes: %v", err)
+		}
+
+		var note DeliveryNote
+		if err := json.Unmarshal(response.Value, &note); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal note: %v", err)
+		}
+		if noteVisibleTo(note.Visibility, caller.Role) {
+			notes = append(notes, &note)
+		}
+	}
+
+	// Keys are ordered by transaction ID, not time
+	sort.SliceStable(notes, func(i, j int) bool {
+		return notes[i].CreatedAt < notes[j].CreatedAt
+	})
+	return notes, nil
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordOrganization is the composite key prefix for the organization registry
+// Organizations are network-wide, so the registry is kept outside tenant namespaces.
+const RecordOrganization = "org~mspId"
+
+// EventOrganizationUpdated is emitted when an organization is registered, changed or deactivated
+const EventOrganizationUpdated = "OrganizationUpdated"
+
+// mspIDPattern matches Fabric MSP IDs
+var mspIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Organization is the registry entry of a member organization
+// AllowedRoles limits the roles its certificates may carry; EndorsesCustody says whether
+// its peers endorse deliveries held by its members (otherwise PlatformOrg endorses for it).
+type Organization struct {
+	MSPID           string     `json:"mspId"`
+	Name            string     `json:"name"`
+	AllowedRoles    []UserRole `json:"allowedRoles"`
+	Active          bool       `json:"active"`
+	EndorsesCustody bool       `json:"endorsesCustody"`
+	UpdatedBy       string     `json:"updatedBy,omitempty" metadata:",optional"`
+	UpdatedAt       string     `json:"updatedAt,omitempty" metadata:",optional"`
+}
+
+// defaultOrganizations are the founding organizations, used until the registry overrides them
+var defaultOrganizations = map[string]Organization{
+	MSPPlatform: {
+		MSPID:           MSPPlatform,
+		Name:            "Platform",
+		AllowedRoles:    []UserRole{RoleAdmin, RoleSupport, RoleAuditor, RoleCustomer},
+		Active:          true,
+		EndorsesCustody: true,
+	},
+	MSPSellers: {
+		MSPID:           MSPSellers,
+		Name:            "Sellers",
+		AllowedRoles:    []UserRole{RoleSeller},
+		Active:          true,
+		EndorsesCustody: true,
+	},
+	MSPLogistics: {
+		MSPID:           MSPLogistics,
+		Name:            "Logistics",
+		AllowedRoles:    []UserRole{RoleDeliveryPerson, RoleWarehouse, RoleSensor, RoleCustomsBroker},
+		Active:          true,
+		EndorsesCustody: true,
+	},
+}
+
+// allowsRole reports whether an organization's certificates may carry a role
+func (o *Organization) allowsRole(role UserRole) bool {
+	for _, allowed := range o.AllowedRoles {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+// getOrganization reads a registry entry, falling back to the founding organizations
+// Returns nil if the MSP is not a member.
+func getOrganization(ctx contractapi.TransactionContextInterface, mspID string) (*Organization, error) {
+	stub := networkStub(ctx)
+	orgKey, err := stub.CreateCompositeKey(RecordOrganization, []string{mspID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization composite key: %v", err)
+	}
+	orgJSON, err := stub.GetState(orgKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read organization %s: %v", mspID, err)
+	}
+	if orgJSON == nil {
+		if org, ok := defaultOrganizations[mspID]; ok {
+			return &org, nil
+		}
+		return nil, nil
+	}
+
+	var org Organization
+	if err := json.Unmarshal(orgJSON, &org); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal organization: %v", err)
+	}
+	return &org, nil
+}
+
+// putOrganization writes a registry entry
+func putOrganization(ctx contractapi.TransactionContextInterface, org *Organization) error {
+	stub := networkStub(ctx)
+	orgKey, err := stub.CreateCompositeKey(RecordOrganization, []string{org.MSPID})
+	if err != nil {
+		return fmt.Errorf("failed to create organization composite key: %v", err)
+	}
+	orgJSON, err := json.Marshal(org)
+	if err != nil {
+		return fmt.Errorf("failed to marshal organization: %v", err)
+	}
+	if err := stub.PutState(orgKey, orgJSON); err != nil {
+		return fmt.Errorf("failed to put organization: %v", err)
+	}
+	return nil
+}
+
+// validateOrgRole checks the caller's organization is active and may issue the role
+func validateOrgRole(ctx contractapi.TransactionContextInterface, mspID string, role UserRole) error {
+	org, err := getOrganization(ctx, mspID)
+	if err != nil {
+		return err
+	}
+	if org == nil {
+		return fmt.Errorf("organization %s is not registered", mspID)
+	}
+	if !org.Active {
+		return fmt.Errorf("organization %s is deactivated", mspID)
+	}
+	if !org.allowsRole(role) {
+		return fmt.Errorf("role %s is not allowed for organization %s", role, mspID)
+	}
+	return nil
+}
+
+// custodianMSPOf returns the organization of a delivery's custodian
+// Deliveries stored without one fall back to the founding org for the custodian role.
+func custodianMSPOf(delivery *Delivery) string {
+	if delivery.CustodianMSP != "" {
+		return delivery.CustodianMSP
+	}
+	return roleToMSP[delivery.CurrentCustodianRole]
+}
+
+// custodianEndorsingMSP returns the MSP that must endorse changes to a delivery
+func custodianEndorsingMSP(ctx contractapi.TransactionContextInterface, delivery *Delivery) (string, error) {
+	mspID := custodianMSPOf(delivery)
+	if mspID == "" {
+		return "", fmt.Errorf("unknown custodian role: %s", delivery.CurrentCustodianRole)
+	}
+	return orgEndorsingMSP(ctx, mspID, delivery.CurrentCustodianRole)
+}
+
+// orgEndorsingMSP returns the MSP that endorses for a custodian of the given org and role
+// Orgs that don't endorse custody are covered by PlatformOrg.
+func orgEndorsingMSP(ctx contractapi.TransactionContextInterface, mspID string, role UserRole) (string, error) {
+	org, err := getOrganization(ctx, mspID)
+	if err != nil {
+		return "", err
+	}
+	if org == nil || !org.Active {
+		return "", fmt.Errorf("custodian organization %s is not an active member", mspID)
+	}
+	if !org.allowsRole(role) {
+		return "", fmt.Errorf("organization %s cannot hold %s custody", mspID, role)
+	}
+	if !org.EndorsesCustody {
+		return MSPPlatform, nil
+	}
+	return mspID, nil
+}
+
+// RegisterOrganization adds or updates a member organization, e.g. a new logistics carrier
+// Only a PlatformOrg ADMIN of the default tenant can manage organizations. allowedRolesJSON is
+// a JSON array of roles; registering an organization (re)activates it.
+func (c *DeliveryContract) RegisterOrganization(
+	ctx contractapi.TransactionContextInterface,
+	mspID string,
+	name string,
+	allowedRolesJSON string,
+	endorsesCustody bool,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if !mspIDPattern.MatchString(mspID) {
+		return &ValidationError{Field: "mspID", Message: "must be 1-64 characters of letters, digits, '.', '-' or '_'"}
+	}
+	name = sanitizeText(name)
+	if err := validateRequiredText(name, "name", 100); err != nil {
+		return err
+	}
+	var allowedRoles []UserRole
+	if err := json.Unmarshal([]byte(allowedRolesJSON), &allowedRoles); err != nil {
+		return &ValidationError{Field: "allowedRoles", Message: fmt.Sprintf("must be a JSON array of roles: %v", err)}
+	}
+	if len(allowedRoles) == 0 {
+		return &ValidationError{Field: "allowedRoles", Message: "must list at least one role"}
+	}
+	// Custom roles of the permission model can be issued like built-in ones
+	model, err := getPermissionModel(ctx)
+	if err != nil {
+		return err
+	}
+	seen := map[UserRole]bool{}
+	for _, role := range allowedRoles {
+		if _, ok := model[role]; !ok && !isBuiltinRole(role) {
+			return &ValidationError{Field: "allowedRoles", Message: fmt.Sprintf("unknown role: %s", role)}
+		}
+		if seen[role] {
+			return &ValidationError{Field: "allowedRoles", Message: fmt.Sprintf("duplicate role: %s", role)}
+		}
+		seen[role] = true
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can manage organizations
+	if err := validateOrgAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	// The platform must keep its admins, or nobody could manage the registry again
+	if mspID == MSPPlatform && !seen[RoleAdmin] {
+		return fmt.Errorf("%s must keep the ADMIN role", MSPPlatform)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	org := Organization{
+		MSPID:           mspID,
+		Name:            name,
+		AllowedRoles:    allowedRoles,
+		Active:          true,
+		EndorsesCustody: endorsesCustody,
+		UpdatedBy:       caller.ID,
+		UpdatedAt:       currentTime,
+	}
+	if err := putOrganization(ctx, &org); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventOrganizationUpdated, org)
+}
+
+// SetOrganizationActive activates or deactivates a member organization
+// Members of a deactivated organization are rejected by every function
+func (c *DeliveryContract) SetOrganizationActive(
+	ctx contractapi.TransactionContextInterface,
+	mspID string,
+	active bool,
+) error {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can manage organizations
+	if err := validateOrgAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	if mspID == MSPPlatform && !active {
+		return fmt.Errorf("%s cannot be deactivated", MSPPlatform)
+	}
+
+	org, err := getOrganization(ctx, mspID)
+	if err != nil {
+		return err
+	}
+	if org == nil {
+		return fmt.Errorf("organization %s is not registered", mspID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	org.Active = active
+	org.UpdatedBy = caller.ID
+	org.UpdatedAt = currentTime
+	if err := putOrganization(ctx, org); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventOrganizationUpdated, org)
+}
+
+// GetOrganizations returns all member organizations, ordered by MSP ID
+// Any participant can read the registry
+func (c *DeliveryContract) GetOrganizations(
+	ctx contractapi.TransactionContextInterface,
+) ([]*Organization, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	iterator, err := networkStub(ctx).GetStateByPartialCompositeKey(RecordOrganization, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organizations: %v", err)
+	}
+	defer iterator.Close()
+
+	orgs := map[string]*Organization{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate organizations: %v", err)
+		}
+
+		var org Organization
+		if err := json.Unmarshal(response.Value, &org); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal organization: %v", err)
+		}
+		orgs[org.MSPID] = &org
+	}
+	for mspID, org := range defaultOrganizations {
+		if _, ok := orgs[mspID]; !ok {
+			org := org
+			orgs[mspID] = &org
+		}
+	}
+
+	result := make([]*Organization, 0, len(orgs))
+	for _, org := range orgs {
+		result = append(result, org)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].MSPID < result[j].MSPID })
+	return result, nil
+}
+
+// validateOrgAdmin checks the caller is a platform ADMIN outside any marketplace tenant and region
+// The functions calling it are not delegable through the permission model (see nonDelegableFunctions).
+func validateOrgAdmin(ctx contractapi.TransactionContextInterface, caller *CallerIdentity) error {
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return err
+	}
+	if caller.MSP != MSPPlatform || caller.TenantID != "" {
+		return fmt.Errorf("only %s admins of the default tenant can manage organizations", MSPPlatform)
+	}
+	return nil
+}
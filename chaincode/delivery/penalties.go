@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key prefixes for the penalty ledger
+const (
+	RecordPenaltyEntry      = "penalty~userId~txId"
+	RecordWeightDiscrepancy = "weightDiscrepancy~userId~txId"
+)
+
+// Penalty entry reasons
+const (
+	PenaltyReasonDisputeFault      = "DISPUTE_AT_FAULT"
+	PenaltyReasonWeightDiscrepancy = "WEIGHT_DISCREPANCY"
+)
+
+// PenaltyEntry is one deduction in a user's penalty ledger
+type PenaltyEntry struct {
+	UserID     string `json:"userId"`
+	DeliveryID string `json:"deliveryId"`
+	TxID       string `json:"txId"`
+	Points     int64  `json:"points"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details"`
+	RecordedAt string `json:"recordedAt"`
+}
+
+// WeightDiscrepancy records a package that weighed differently when the next custodian measured it
+type WeightDiscrepancy struct {
+	UserID         string  `json:"userId"`
+	DeliveryID     string  `json:"deliveryId"`
+	TxID           string  `json:"txId"`
+	RecordedWeight float64 `json:"recordedWeight"`
+	MeasuredWeight float64 `json:"measuredWeight"`
+	MeasuredBy     string  `json:"measuredBy"`
+	RecordedAt     string  `json:"recordedAt"`
+}
+
+// PenaltyLedger is a user's penalty total with the entries it is made of
+type PenaltyLedger struct {
+	UserID  string          `json:"userId"`
+	Total   int64           `json:"total"`
+	Entries []*PenaltyEntry `json:"entries"`
+}
+
+// putPenaltyEntry appends an entry to a user's penalty ledger
+func putPenaltyEntry(ctx contractapi.TransactionContextInterface, entry *PenaltyEntry) error {
+	key, err := ctx.GetStub().CreateCompositeKey(RecordPenaltyEntry, []string{entry.UserID, entry.TxID})
+	if err != nil {
+		return fmt.Errorf("failed to create penalty composite key: %v", err)
+	}
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal penalty entry: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, entryJSON); err != nil {
+		return fmt.Errorf("failed to put penalty entry: %v", err)
+	}
+	return nil
+}
+
+// recordDisputeFaultPenalty penalizes the user who offered a handoff whose dispute was upheld
+// REDELIVER and RETURN_TO_SELLER uphold the dispute; NO_ACTION finds no fault.
+func recordDisputeFaultPenalty(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
+	dispute := delivery.LastDispute
+	if dispute.FromUserID == "" || dispute.Resolution == ResolutionNoAction {
+		return nil
+	}
+	settings, err := getContractSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if settings.DisputeFaultPenaltyPoints == 0 {
+		return nil
+	}
+	return putPenaltyEntry(ctx, &PenaltyEntry{
+		UserID:     dispute.FromUserID,
+		DeliveryID: delivery.DeliveryID,
+		TxID:       ctx.GetStub().GetTxID(),
+		Points:     int64(settings.DisputeFaultPenaltyPoints),
+		Reason:     PenaltyReasonDisputeFault,
+		Details:    fmt.Sprintf("dispute %s resolved with %s", dispute.DisputeID, dispute.Resolution),
+		RecordedAt: dispute.ResolvedAt,
+	})
+}
+
+// checkWeightDiscrepancy records a discrepancy against the user handing a package over when the
+// courier or warehouse receiving it weighs it more than the configured tolerance off the recorded
+// weight. Once a user reaches weightDiscrepancyPenaltyThreshold discrepancies, each one is penalized.
+func checkWeightDiscrepancy(ctx contractapi.TransactionContextInterface, caller *CallerIdentity, delivery *Delivery, measuredWeight float64, currentTime string) error {
+	if !isLogisticsRole(caller.Role) || delivery.PackageWeight <= 0 || delivery.PendingHandoff == nil {
+		return nil
+	}
+	settings, err := getContractSettings(ctx)
+	if err != nil {
+		return err
+	}
+	deviation := math.Abs(measuredWeight-delivery.PackageWeight) / delivery.PackageWeight * 100
+	if deviation <= float64(settings.WeightDiscrepancyTolerancePercent) {
+		return nil
+	}
+
+	stub := ctx.GetStub()
+	discrepancy := WeightDiscrepancy{
+		UserID:         delivery.PendingHandoff.FromUserID,
+		DeliveryID:     delivery.DeliveryID,
+		TxID:           stub.GetTxID(),
+		RecordedWeight: delivery.PackageWeight,
+		MeasuredWeight: measuredWeight,
+		MeasuredBy:     caller.ID,
+		RecordedAt:     currentTime,
+	}
+
+	// Count the user's earlier discrepancies before adding this one
+	iterator, err := stub.GetStateByPartialCompositeKey(RecordWeightDiscrepancy, []string{discrepancy.UserID})
+	if err != nil {
+		return fmt.Errorf("failed to get weight discrepancies: %v", err)
+	}
+	count := 1
+	for iterator.HasNext() {
+		if _, err := iterator.Next(); err != nil {
+			iterator.Close()
+			return fmt.Errorf("failed to iterate weight discrepancies: %v", err)
+		}
+		count++
+	}
+	iterator.Close()
+
+	key, err := stub.CreateCompositeKey(RecordWeightDiscrepancy, []string{discrepancy.UserID, discrepancy.TxID})
+	if err != nil {
+		return fmt.Errorf("failed to create weight discrepancy composite key: %v", err)
+	}
+	discrepancyJSON, err := json.Marshal(discrepancy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal weight discrepancy: %v", err)
+	}
+	if err := stub.PutState(key, discrepancyJSON); err != nil {
+		return fmt.Errorf("failed to put weight discrepancy: %v", err)
+	}
+
+	if count < settings.WeightDiscrepancyPenaltyThreshold || settings.WeightDiscrepancyPenaltyPoints == 0 {
+		return nil
+	}
+	return putPenaltyEntry(ctx, &PenaltyEntry{
+		UserID:     discrepancy.UserID,
+		DeliveryID: delivery.DeliveryID,
+		TxID:       discrepancy.TxID,
+		Points:     int64(settings.WeightDiscrepancyPenaltyPoints),
+		Reason:     PenaltyReasonWeightDiscrepancy,
+		Details:    fmt.Sprintf("weight discrepancy #%d: recorded %.3f kg, measured %.3f kg", count, discrepancy.RecordedWeight, measuredWeight),
+		RecordedAt: currentTime,
+	})
+}
+
+// readPenaltyLedger sums a user's penalty entries
+func readPenaltyLedger(ctx contractapi.TransactionContextInterface, userID string) (*PenaltyLedger, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordPenaltyEntry, []string{userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get penalty entries: %v", err)
+	}
+	defer iterator.Close()
+
+	ledger := &PenaltyLedger{UserID: userID, Entries: []*PenaltyEntry{}}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate penalty entries: %v", err)
+		}
+
+		var entry PenaltyEntry
+		if err := json.Unmarshal(response.Value, &entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal penalty entry: %v", err)
+		}
+		ledger.Total += entry.Points
+		ledger.Entries = append(ledger.Entries, &entry)
+	}
+	return ledger, nil
+}
+
+// GetPenalties returns a user's penalty ledger
+// Users read their own ledger (pass an empty userID); ADMIN can read anyone's.
+func (c *DeliveryContract) GetPenalties(
+	ctx contractapi.TransactionContextInterface,
+	userID string,
+) (*PenaltyLedger, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleSeller, RoleDeliveryPerson, RoleWarehouse, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if userID == "" {
+		userID = caller.ID
+	}
+	if err := validateUserID(userID, "userID"); err != nil {
+		return nil, err
+	}
+	if caller.Role != RoleAdmin && userID != caller.ID {
+		return nil, fmt.Errorf("users can only read their own penalties")
+	}
+
+	return readPenaltyLedger(ctx, userID)
+}
+
+// Reputation combines a user's incentive points and penalties for dispatch decisions
+type Reputation struct {
+	UserID          string `json:"userId"`
+	IncentivePoints int64  `json:"incentivePoints"`
+	PenaltyPoints   int64  `json:"penaltyPoints"`
+	Penalties       int    `json:"penalties"`
+	// Incentive points minus penalty points
+	Score int64 `json:"score"`
+}
+
+// GetReputation returns a user's reputation score (incentive points minus penalty points)
+// Dispatchers (ADMIN) can read anyone's; other users only their own.
+func (c *DeliveryContract) GetReputation(
+	ctx contractapi.TransactionContextInterface,
+	userID string,
+) (*Reputation, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleSeller, RoleDeliveryPerson, RoleWarehouse, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if userID == "" {
+		userID = caller.ID
+	}
+	if err := validateUserID(userID, "userID"); err != nil {
+		return nil, err
+	}
+	if caller.Role != RoleAdmin && userID != caller.ID {
+		return nil, fmt.Errorf("users can only read their own reputation")
+	}
+
+	points, err := readPointsBalance(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	penalties, err := readPenaltyLedger(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reputation{
+		UserID:          userID,
+		IncentivePoints: points.Balance,
+		PenaltyPoints:   penalties.Total,
+		Penalties:       len(penalties.Entries),
+		Score:           points.Balance - penalties.Total,
+	}, nil
+}
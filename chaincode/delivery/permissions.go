@@ -0,0 +1,597 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// configPermissionsName is the config record holding the PermissionModel
+const configPermissionsName = "permissions"
+
+// maxModelRoles bounds the roles defined in one permission model
+const maxModelRoles = 50
+
+// customRolePattern restricts role names defined by the permission model
+var customRolePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{1,31}$`)
+
+// nonDelegableFunctions stay with ADMIN whatever the permission model says, so no role can grant
+// itself more rights or take over the organization registry
+var nonDelegableFunctions = map[string]bool{
+	"SetPermissionModel":    true,
+	"RegisterOrganization":  true,
+	"SetOrganizationActive": true,
+}
+
+// StatusTransition is a status change a function can perform
+type StatusTransition struct {
+	From DeliveryStatus `json:"from"`
+	To   DeliveryStatus `json:"to"`
+}
+
+// FunctionPermission describes who may call a function and which transitions it performs
+type FunctionPermission struct {
+	Function    string             `json:"function"`
+	Roles       []UserRole         `json:"roles"`
+	Transitions []StatusTransition `json:"transitions,omitempty" metadata:",optional"`
+}
+
+// RoleTransition is a status transition available to a role through a function
+type RoleTransition struct {
+	Function string         `json:"function"`
+	From     DeliveryStatus `json:"from"`
+	To       DeliveryStatus `json:"to"`
+}
+
+// RolePermissions is the capability matrix of a single role
+type RolePermissions struct {
+	Role        UserRole         `json:"role"`
+	Functions   []string         `json:"functions"`
+	Transitions []RoleTransition `json:"transitions"`
+}
+
+// RoleDefinition is a role's entry in the permission model
+// A role may call every function its inherited roles may call, plus the functions listed.
+type RoleDefinition struct {
+	Inherits  []UserRole `json:"inherits,omitempty" metadata:",optional"`
+	Functions []string   `json:"functions"`
+}
+
+// PermissionModel maps roles to the permissions admins gave them on top of the built-in table
+// Keys are built-in roles (to extend them) or custom roles such as DISPATCHER, which callers
+// carry in their certificate's role attribute.
+type PermissionModel map[UserRole]RoleDefinition
+
+// effectiveRoles returns a role followed by every role it inherits, directly or not
+func (m PermissionModel) effectiveRoles(role UserRole) []UserRole {
+	roles := []UserRole{role}
+	seen := map[UserRole]bool{role: true}
+	for i := 0; i < len(roles); i++ {
+		for _, parent := range m[roles[i]].Inherits {
+			if !seen[parent] {
+				seen[parent] = true
+				roles = append(roles, parent)
+			}
+		}
+	}
+	return roles
+}
+
+// allows reports whether a role may call a function whose built-in roles are allowedRoles
+func (m PermissionModel) allows(role UserRole, function string, allowedRoles []UserRole) bool {
+	_, ok := m.grantingRole(role, function, allowedRoles)
+	return ok
+}
+
+// grantingRole returns the built-in role among allowedRoles a role calls a function as
+// An inherited role is used as is, the closest one first. A role granted the function itself
+// acts as the first allowed role other than ADMIN (ADMIN only for admin-only functions), so the
+// function still checks it is a party to the delivery.
+func (m PermissionModel) grantingRole(role UserRole, function string, allowedRoles []UserRole) (UserRole, bool) {
+	if nonDelegableFunctions[function] || len(allowedRoles) == 0 {
+		return "", false
+	}
+	effective := m.effectiveRoles(role)
+	for _, r := range effective {
+		for _, allowed := range allowedRoles {
+			if r == allowed {
+				return r, true
+			}
+		}
+	}
+	for _, r := range effective {
+		for _, granted := range m[r].Functions {
+			if granted != function {
+				continue
+			}
+			for _, allowed := range allowedRoles {
+				if allowed != RoleAdmin {
+					return allowed, true
+				}
+			}
+			return allowedRoles[0], true
+		}
+	}
+	return "", false
+}
+
+// allRoles lists every role known to the contract
+var allRoles = []UserRole{RoleCustomer, RoleSeller, RoleDeliveryPerson, RoleWarehouse, RoleSensor, RoleSupport, RoleAuditor, RoleCustomsBroker, RoleAdmin}
+
+// participantRoles lists the roles of people taking part in deliveries (every role except device identities)
+var participantRoles = []UserRole{RoleCustomer, RoleSeller, RoleDeliveryPerson, RoleWarehouse, RoleSupport, RoleCustomsBroker, RoleAdmin}
+
+// readerRoles lists the roles allowed to call read-only functions: participants and auditors
+var readerRoles = append(append([]UserRole{}, participantRoles...), RoleAuditor)
+
+// transitionTable is the central authorization model of the contract
+// Each entry mirrors the role checks and status transitions of the function it names.
+// Functions that also restrict by organization (private data) list the roles of the allowed orgs.
+var transitionTable = []FunctionPermission{
+	// Core lifecycle
+	{Function: "CreateDelivery", Roles: []UserRole{RoleSeller}, Transitions: []StatusTransition{
+		{From: "", To: StatusPendingPickup},
+	}},
+	{Function: "CreateDeliveryAutoID", Roles: []UserRole{RoleSeller}, Transitions: []StatusTransition{
+		{From: "", To: StatusPendingPickup},
+	}},
+	{Function: "CreateDeliveryFromTemplate", Roles: []UserRole{RoleSeller}, Transitions: []StatusTransition{
+		{From: "", To: StatusPendingPickup},
+	}},
+	{Function: "SaveDeliveryTemplate", Roles: []UserRole{RoleSeller}},
+	{Function: "GetDeliveryTemplates", Roles: []UserRole{RoleSeller}},
+	{Function: "ReadDelivery", Roles: readerRoles},
+	{Function: "GetPackageMeasurements", Roles: readerRoles},
+	{Function: "ReadDeliveryIfChanged", Roles: readerRoles},
+	{Function: "UpdateLocation", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse}},
+	{Function: "SetRoutePlan", Roles: []UserRole{RoleSeller, RoleDeliveryPerson, RoleWarehouse}},
+	{Function: "DelegateCustodyAction", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse}},
+	{Function: "DeclareOutForDelivery", Roles: []UserRole{RoleDeliveryPerson}, Transitions: []StatusTransition{
+		{From: StatusInTransit, To: StatusOutForDelivery},
+	}},
+	{Function: "InitiateHandoff", Roles: []UserRole{RoleSeller, RoleDeliveryPerson, RoleWarehouse}, Transitions: []StatusTransition{
+		{From: StatusPendingPickup, To: StatusPendingPickupHandoff},
+		{From: StatusInTransit, To: StatusPendingTransitHandoff},
+		{From: StatusInTransit, To: StatusPendingDeliveryConfirmation},
+		{From: StatusOffNetworkTransit, To: StatusPendingTransitHandoff},
+		{From: StatusOffNetworkTransit, To: StatusPendingDeliveryConfirmation},
+		{From: StatusOutForDelivery, To: StatusPendingTransitHandoff},
+		{From: StatusOutForDelivery, To: StatusPendingDeliveryConfirmation},
+	}},
+	{Function: "InitiateInterlineHandoff", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse}, Transitions: []StatusTransition{
+		{From: StatusInTransit, To: StatusPendingTransitHandoff},
+	}},
+	{Function: "ConfirmHandoff", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse, RoleCustomer}, Transitions: []StatusTransition{
+		{From: StatusPendingPickupHandoff, To: StatusInTransit},
+		{From: StatusPendingTransitHandoff, To: StatusInTransit},
+		{From: StatusPendingDeliveryConfirmation, To: StatusConfirmedDelivery},
+	}},
+	{Function: "DisputeHandoff", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse, RoleCustomer, RoleSupport}, Transitions: []StatusTransition{
+		{From: StatusPendingPickupHandoff, To: StatusDisputedPickupHandoff},
+		{From: StatusPendingTransitHandoff, To: StatusDisputedTransitHandoff},
+		{From: StatusPendingDeliveryConfirmation, To: StatusDisputedDelivery},
+	}},
+	{Function: "CancelHandoff", Roles: []UserRole{RoleSeller, RoleDeliveryPerson, RoleWarehouse}, Transitions: []StatusTransition{
+		{From: StatusPendingPickupHandoff, To: StatusPendingPickup},
+		{From: StatusPendingTransitHandoff, To: StatusInTransit},
+		{From: StatusPendingDeliveryConfirmation, To: StatusInTransit},
+	}},
+	{Function: "RotateHandoffCode", Roles: []UserRole{RoleSeller, RoleDeliveryPerson, RoleWarehouse}},
+	{Function: "AttachExternalTracking", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse, RoleAdmin}, Transitions: []StatusTransition{
+		{From: StatusInTransit, To: StatusOffNetworkTransit},
+	}},
+	{Function: "AdminReassignCustody", Roles: []UserRole{RoleAdmin}},
+	{Function: "AcknowledgeCustody", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse}},
+	{Function: "InitiateBatchHandoff", Roles: []UserRole{RoleSeller, RoleDeliveryPerson, RoleWarehouse}, Transitions: []StatusTransition{
+		{From: StatusPendingPickup, To: StatusPendingPickupHandoff},
+		{From: StatusInTransit, To: StatusPendingTransitHandoff},
+	}},
+	{Function: "ConfirmBatchHandoff", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse}, Transitions: []StatusTransition{
+		{From: StatusPendingPickupHandoff, To: StatusInTransit},
+		{From: StatusPendingTransitHandoff, To: StatusInTransit},
+	}},
+	{Function: "CreateManifest", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse}},
+	{Function: "AddToManifest", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse}, Transitions: []StatusTransition{
+		{From: StatusPendingPickup, To: StatusPendingPickupHandoff},
+		{From: StatusInTransit, To: StatusPendingTransitHandoff},
+	}},
+	{Function: "ConfirmManifestReceipt", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse}, Transitions: []StatusTransition{
+		{From: StatusPendingPickupHandoff, To: StatusInTransit},
+		{From: StatusPendingTransitHandoff, To: StatusInTransit},
+	}},
+	{Function: "CancelDelivery", Roles: []UserRole{RoleCustomer}, Transitions: []StatusTransition{
+		{From: StatusPendingPickup, To: StatusCancelled},
+	}},
+	{Function: "AutoConfirmExpired", Roles: []UserRole{RoleDeliveryPerson, RoleAdmin}, Transitions: []StatusTransition{
+		{From: StatusPendingDeliveryConfirmation, To: StatusConfirmedDelivery},
+	}},
+
+	// Recalls and returns
+	{Function: "RecallDelivery", Roles: []UserRole{RoleSeller, RoleAdmin}, Transitions: []StatusTransition{
+		{From: StatusInTransit, To: StatusRecallPending},
+		{From: StatusQuarantined, To: StatusRecallPending},
+	}},
+	{Function: "AdminBroadcastRecall", Roles: []UserRole{RoleAdmin}, Transitions: []StatusTransition{
+		{From: StatusInTransit, To: StatusRecallPending},
+		{From: StatusOutForDelivery, To: StatusRecallPending},
+	}},
+	{Function: "AcknowledgeRecall", Roles: []UserRole{RoleDeliveryPerson}, Transitions: []StatusTransition{
+		{From: StatusRecallPending, To: StatusReturnInTransit},
+	}},
+	{Function: "ConfirmReturnReceipt", Roles: []UserRole{RoleSeller}, Transitions: []StatusTransition{
+		{From: StatusReturnInTransit, To: StatusReturnCompleted},
+	}},
+
+	// Disputes
+	{Function: "ReofferPickup", Roles: []UserRole{RoleSeller}, Transitions: []StatusTransition{
+		{From: StatusDisputedPickupHandoff, To: StatusPendingPickup},
+	}},
+	{Function: "ResolveDispute", Roles: []UserRole{RoleAdmin}, Transitions: []StatusTransition{
+		{From: StatusDisputedTransitHandoff, To: StatusInTransit},
+		{From: StatusDisputedDelivery, To: StatusInTransit},
+		{From: StatusDisputedTransitHandoff, To: StatusRecallPending},
+		{From: StatusDisputedDelivery, To: StatusRecallPending},
+	}},
+	{Function: "QueryOpenDisputes", Roles: []UserRole{RoleAdmin}},
+	{Function: "QueryDisputeCasesByState", Roles: []UserRole{RoleAdmin}},
+	{Function: "RetryDelivery", Roles: []UserRole{RoleDeliveryPerson, RoleAdmin}, Transitions: []StatusTransition{
+		{From: StatusDisputedTransitHandoff, To: StatusInTransit},
+		{From: StatusDisputedDelivery, To: StatusInTransit},
+	}},
+
+	// Hub consolidation
+	{Function: "SplitDelivery", Roles: []UserRole{RoleWarehouse, RoleAdmin}, Transitions: []StatusTransition{
+		{From: StatusInTransit, To: StatusSplit},
+	}},
+	{Function: "MergeDeliveries", Roles: []UserRole{RoleWarehouse}, Transitions: []StatusTransition{
+		{From: StatusInTransit, To: StatusMerged},
+	}},
+	{Function: "QueryDeliveryChain", Roles: readerRoles},
+
+	// Package details, items, and exceptions
+	{Function: "AmendPackageDetails", Roles: []UserRole{RoleSeller}},
+	{Function: "UpdateDeliveryFields", Roles: []UserRole{RoleSeller, RoleDeliveryPerson, RoleWarehouse, RoleAdmin}},
+	{Function: "SetDeclaredValue", Roles: []UserRole{RoleSeller}},
+	{Function: "SetDeliveryInsurance", Roles: []UserRole{RoleSeller}},
+	{Function: "SettleCashOnDelivery", Roles: []UserRole{RoleDeliveryPerson}},
+	{Function: "QuoteDeliveryFee", Roles: []UserRole{RoleDeliveryPerson, RoleAdmin}},
+	{Function: "AcceptQuote", Roles: []UserRole{RoleSeller}},
+	{Function: "GetFeeQuotes", Roles: []UserRole{RoleSeller, RoleDeliveryPerson, RoleAdmin}},
+	{Function: "GetDeliverySettlement", Roles: readerRoles},
+	{Function: "GetInsuranceClaims", Roles: readerRoles},
+	{Function: "RegisterAttachment", Roles: participantRoles},
+	{Function: "ListAttachments", Roles: readerRoles},
+	{Function: "GetPointsBalance", Roles: []UserRole{RoleDeliveryPerson, RoleAdmin}},
+	{Function: "GetPenalties", Roles: []UserRole{RoleSeller, RoleDeliveryPerson, RoleWarehouse, RoleAdmin}},
+	{Function: "GetReputation", Roles: []UserRole{RoleSeller, RoleDeliveryPerson, RoleWarehouse, RoleAdmin}},
+	{Function: "GetPackageAmendments", Roles: readerRoles},
+	{Function: "GetCorrections", Roles: readerRoles},
+	{Function: "CorrectDeliveryParties", Roles: []UserRole{RoleAdmin}},
+	{Function: "GrantDeliveryAccess", Roles: []UserRole{RoleSeller, RoleCustomer, RoleAdmin}},
+	{Function: "RevokeDeliveryAccess", Roles: []UserRole{RoleSeller, RoleCustomer, RoleAdmin}},
+	{Function: "ListDeliveryGrants", Roles: readerRoles},
+	{Function: "SetRequiredCertifications", Roles: []UserRole{RoleSeller}},
+	{Function: "SetAgeRestricted", Roles: []UserRole{RoleSeller}},
+	{Function: "SetSerialization", Roles: []UserRole{RoleSeller}},
+	{Function: "GetLabelPayload", Roles: []UserRole{RoleSeller, RoleAdmin}},
+	{Function: "AddDeliveryItem", Roles: []UserRole{RoleSeller}},
+	{Function: "GetDeliveryItems", Roles: readerRoles},
+	{Function: "ReportMissingItem", Roles: []UserRole{RoleDeliveryPerson, RoleCustomer, RoleSupport}},
+	{Function: "ReportException", Roles: []UserRole{RoleDeliveryPerson}},
+	{Function: "GetDeliveryExceptions", Roles: readerRoles},
+	{Function: "AddDeliveryNote", Roles: participantRoles},
+	{Function: "GetNotes", Roles: readerRoles},
+	{Function: "GetDisputeCases", Roles: readerRoles},
+	{Function: "GetLocationHistory", Roles: readerRoles},
+	{Function: "GetRouteDeviations", Roles: readerRoles},
+	{Function: "GetCustodyReport", Roles: readerRoles},
+	{Function: "GetMilestoneTimeline", Roles: readerRoles},
+	{Function: "EvaluateStateTransition", Roles: participantRoles},
+	{Function: "GetEventsForDelivery", Roles: readerRoles},
+
+	// Customs clearance
+	{Function: "SetCustomsRequirements", Roles: []UserRole{RoleSeller}},
+	{Function: "AttachCustomsDocument", Roles: []UserRole{RoleCustomsBroker}},
+	{Function: "UpdateCustomsStatus", Roles: []UserRole{RoleCustomsBroker}},
+	{Function: "PlaceCustomsHold", Roles: []UserRole{RoleCustomsBroker}},
+	{Function: "ReleaseCustomsHold", Roles: []UserRole{RoleCustomsBroker}},
+	{Function: "StartInternationalLeg", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse}},
+	{Function: "RecordLegMilestone", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse}},
+
+	// Sensors and telemetry
+	{Function: "RegisterSensorGateway", Roles: []UserRole{RoleAdmin}},
+	{Function: "SetTelemetryThresholds", Roles: []UserRole{RoleSeller}},
+	{Function: "RecordTelemetry", Roles: []UserRole{RoleSensor}, Transitions: []StatusTransition{
+		{From: StatusPendingPickup, To: StatusQuarantined},
+		{From: StatusPendingPickupHandoff, To: StatusQuarantined},
+		{From: StatusInTransit, To: StatusQuarantined},
+		{From: StatusPendingTransitHandoff, To: StatusQuarantined},
+		{From: StatusOutForDelivery, To: StatusQuarantined},
+		{From: StatusPendingDeliveryConfirmation, To: StatusQuarantined},
+	}},
+	{Function: "ReleaseQuarantine", Roles: []UserRole{RoleSeller, RoleAdmin}, Transitions: []StatusTransition{
+		{From: StatusQuarantined, To: StatusPendingPickup},
+		{From: StatusQuarantined, To: StatusInTransit},
+		{From: StatusQuarantined, To: StatusOutForDelivery},
+	}},
+	{Function: "GetTelemetry", Roles: readerRoles},
+	{Function: "RegisterWeighingStation", Roles: []UserRole{RoleAdmin}},
+	{Function: "RecordCertifiedWeight", Roles: []UserRole{RoleSensor}},
+	{Function: "GetWeightMeasurements", Roles: readerRoles},
+	{Function: "GetBillingAdjustments", Roles: []UserRole{RoleSeller, RoleAdmin}},
+
+	// User registry
+	{Function: "SetAvailability", Roles: []UserRole{RoleDeliveryPerson}},
+	{Function: "QueryFleetAvailability", Roles: []UserRole{RoleAdmin}},
+	{Function: "SetCourierCapacity", Roles: []UserRole{RoleAdmin}},
+
+	// Queries
+	{Function: "QueryDeliveriesByCustodian", Roles: readerRoles},
+	{Function: "QueryDeliveriesByStatus", Roles: readerRoles},
+	{Function: "GetDeliveriesByStatusForUser", Roles: readerRoles},
+	{Function: "GetCustomerDashboard", Roles: []UserRole{RoleCustomer}},
+	{Function: "GetSellerDashboard", Roles: []UserRole{RoleSeller}},
+	{Function: "GetCourierRunSheet", Roles: []UserRole{RoleDeliveryPerson}},
+	{Function: "QueryByExternalTracking", Roles: readerRoles},
+	{Function: "GetDeliveryHistory", Roles: []UserRole{RoleSeller, RoleCustomer, RoleAdmin, RoleAuditor}},
+	{Function: "QueryDeliveriesRich", Roles: []UserRole{RoleAdmin}},
+	{Function: "ExportDeliveriesDelta", Roles: []UserRole{RoleAdmin}},
+	{Function: "QueryDeliveriesUpdatedSince", Roles: readerRoles},
+	{Function: "QueryDeliveriesByDateRange", Roles: readerRoles},
+	{Function: "QueryDeliveriesByLocation", Roles: []UserRole{RoleDeliveryPerson, RoleAdmin}},
+	{Function: "GetCallerInfo", Roles: allRoles},
+	{Function: "GetRolePermissions", Roles: readerRoles},
+	{Function: "SetPermissionModel", Roles: []UserRole{RoleAdmin}},
+	{Function: "GetPermissionModel", Roles: readerRoles},
+	{Function: "GetManifest", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse, RoleAdmin}},
+
+	// Private data
+	{Function: "SetDeliveryPrivateDetails", Roles: []UserRole{RoleSeller, RoleCustomer, RoleAdmin}},
+	{Function: "GetDeliveryPrivateDetails", Roles: participantRoles},
+	{Function: "LogPrivateAccess", Roles: participantRoles},
+	{Function: "VerifyRecipient", Roles: []UserRole{RoleDeliveryPerson, RoleAdmin}},
+	{Function: "GetPrivateAccessLog", Roles: []UserRole{RoleAdmin, RoleAuditor}},
+	{Function: "VerifyDeliveryPrivateDataHash", Roles: readerRoles},
+	{Function: "GetContentsManifest", Roles: []UserRole{RoleSeller, RoleCustomer, RoleAdmin}},
+	{Function: "VerifyContentsManifest", Roles: readerRoles},
+	{Function: "GetAgeVerification", Roles: []UserRole{RoleDeliveryPerson, RoleWarehouse, RoleCustomer, RoleAdmin}},
+	{Function: "ExportComplianceReport", Roles: []UserRole{RoleAdmin, RoleAuditor}},
+
+	// Anchoring
+	{Function: "ComputeStateDigest", Roles: readerRoles},
+	{Function: "AnchorDigest", Roles: []UserRole{RoleAdmin}},
+	{Function: "GetDigestAnchors", Roles: readerRoles},
+	{Function: "CommitMilestoneRoot", Roles: []UserRole{RoleAdmin}},
+	{Function: "GetMilestoneProof", Roles: readerRoles},
+
+	// Cross-channel handover
+	{Function: "ExportDeliveryPackage", Roles: []UserRole{RoleSeller, RoleDeliveryPerson, RoleWarehouse, RoleAdmin}, Transitions: []StatusTransition{
+		{From: StatusPendingPickup, To: StatusExported},
+		{From: StatusInTransit, To: StatusExported},
+	}},
+	{Function: "GetDeliveryExport", Roles: readerRoles},
+	{Function: "ImportDeliveryPackage", Roles: []UserRole{RoleSeller, RoleDeliveryPerson, RoleWarehouse, RoleAdmin}},
+
+	// Administration
+	{Function: "TombstoneDelivery", Roles: []UserRole{RoleAdmin}},
+	{Function: "SetRetentionPolicy", Roles: []UserRole{RoleAdmin}},
+	{Function: "GetRetentionPolicy", Roles: readerRoles},
+	{Function: "SetBusinessCalendar", Roles: []UserRole{RoleAdmin}},
+	{Function: "GetBusinessCalendar", Roles: readerRoles},
+	{Function: "SetServiceCoverage", Roles: []UserRole{RoleAdmin}},
+	{Function: "GetServiceCoverage", Roles: readerRoles},
+	{Function: "SetAdminRegions", Roles: []UserRole{RoleAdmin}},
+	{Function: "GetAdminRegions", Roles: readerRoles},
+	{Function: "SetRestrictedGoodsMatrix", Roles: []UserRole{RoleAdmin}},
+	{Function: "GetRestrictedGoodsMatrix", Roles: readerRoles},
+	{Function: "ApplyRetention", Roles: []UserRole{RoleAdmin}},
+	{Function: "QueryFraudSignals", Roles: []UserRole{RoleAdmin, RoleSupport}},
+	{Function: "ReviewFraudSignal", Roles: []UserRole{RoleAdmin}},
+	{Function: "QueryStalledDeliveries", Roles: []UserRole{RoleAdmin, RoleSupport}},
+	{Function: "MarkStalled", Roles: []UserRole{RoleAdmin, RoleSupport}},
+	{Function: "SetContractSettings", Roles: []UserRole{RoleAdmin}},
+	{Function: "GetContractSettings", Roles: readerRoles},
+	{Function: "SetReasonCodes", Roles: []UserRole{RoleAdmin}},
+	{Function: "GetReasonCodes", Roles: readerRoles},
+	{Function: "RegisterOrganization", Roles: []UserRole{RoleAdmin}},
+	{Function: "SetOrganizationActive", Roles: []UserRole{RoleAdmin}},
+	{Function: "GetOrganizations", Roles: readerRoles},
+	{Function: "QueryDeliveriesRequiringEndorsementFromMyOrg", Roles: []UserRole{RoleAdmin}},
+	{Function: "MigrateEndorsementPolicies", Roles: []UserRole{RoleAdmin}},
+}
+
+// isBuiltinRole reports whether a role is one the contract itself defines
+func isBuiltinRole(role UserRole) bool {
+	for _, r := range allRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// invokedFunction returns the name of the transaction function being called, without contract prefix
+func invokedFunction(ctx contractapi.TransactionContextInterface) string {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	if i := strings.LastIndex(function, ":"); i >= 0 {
+		function = function[i+1:]
+	}
+	return function
+}
+
+// functionPermission returns the transition table entry of a function (nil if unknown)
+func functionPermission(function string) *FunctionPermission {
+	for i := range transitionTable {
+		if transitionTable[i].Function == function {
+			return &transitionTable[i]
+		}
+	}
+	return nil
+}
+
+// getPermissionModel reads the configured permission model (empty if none)
+func getPermissionModel(ctx contractapi.TransactionContextInterface) (PermissionModel, error) {
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configPermissionsName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	modelJSON, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read permission model: %v", err)
+	}
+
+	model := PermissionModel{}
+	if modelJSON != nil {
+		if err := json.Unmarshal(modelJSON, &model); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal permission model: %v", err)
+		}
+	}
+	return model, nil
+}
+
+// SetPermissionModel replaces the permission model of the caller's tenant
+// Only ADMIN can set it, and this, RegisterOrganization and SetOrganizationActive can't be granted.
+// modelJSON maps roles to {inherits, functions}; inherited roles and functions must exist, and
+// custom roles must also be allowed by an organization before its certificates can carry them.
+func (c *DeliveryContract) SetPermissionModel(
+	ctx contractapi.TransactionContextInterface,
+	modelJSON string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	var model PermissionModel
+	if err := json.Unmarshal([]byte(modelJSON), &model); err != nil {
+		return fmt.Errorf("failed to parse permission model: %v", err)
+	}
+	if len(model) > maxModelRoles {
+		return &ValidationError{Field: "roles", Message: fmt.Sprintf("cannot define more than %d roles", maxModelRoles)}
+	}
+	for role, definition := range model {
+		if definition.Functions == nil {
+			definition.Functions = []string{}
+			model[role] = definition
+		}
+		if !customRolePattern.MatchString(string(role)) {
+			return &ValidationError{Field: string(role), Message: "role must be 2-32 upper-case letters, digits or '_'"}
+		}
+		for _, parent := range definition.Inherits {
+			if _, ok := model[parent]; !ok && !isBuiltinRole(parent) {
+				return &ValidationError{Field: string(role), Message: fmt.Sprintf("inherits unknown role %s", parent)}
+			}
+			if parent == role {
+				return &ValidationError{Field: string(role), Message: "cannot inherit itself"}
+			}
+		}
+		for _, function := range definition.Functions {
+			if functionPermission(function) == nil {
+				return &ValidationError{Field: string(role), Message: fmt.Sprintf("unknown function %s", function)}
+			}
+			if nonDelegableFunctions[function] {
+				return &ValidationError{Field: string(role), Message: fmt.Sprintf("%s can only be called by ADMIN", function)}
+			}
+		}
+	}
+	if model == nil {
+		model = PermissionModel{}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can change permissions
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configPermissionsName})
+	if err != nil {
+		return fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	updatedJSON, err := json.Marshal(model)
+	if err != nil {
+		return fmt.Errorf("failed to marshal permission model: %v", err)
+	}
+
+	return ctx.GetStub().PutState(configKey, updatedJSON)
+}
+
+// GetPermissionModel returns the permission model of the caller's tenant
+func (c *DeliveryContract) GetPermissionModel(
+	ctx contractapi.TransactionContextInterface,
+) (PermissionModel, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	return getPermissionModel(ctx)
+}
+
+// GetRolePermissions returns the functions and status transitions a role may perform
+// Built from the central transition table and the tenant's permission model so front-ends
+// don't hardcode authorization rules
+func (c *DeliveryContract) GetRolePermissions(
+	ctx contractapi.TransactionContextInterface,
+	role string,
+) (*RolePermissions, error) {
+	target := UserRole(strings.ToUpper(role))
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	model, err := getPermissionModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := model[target]; !ok && !isBuiltinRole(target) {
+		return nil, fmt.Errorf("unknown role: %s", role)
+	}
+
+	permissions := &RolePermissions{
+		Role:        target,
+		Functions:   []string{},
+		Transitions: []RoleTransition{},
+	}
+	for _, entry := range transitionTable {
+		allowed := model.allows(target, entry.Function, entry.Roles)
+		for _, r := range entry.Roles {
+			if r == target {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			continue
+		}
+
+		permissions.Functions = append(permissions.Functions, entry.Function)
+		for _, t := range entry.Transitions {
+			permissions.Transitions = append(permissions.Transitions, RoleTransition{
+				Function: entry.Function,
+				From:     t.From,
+				To:       t.To,
+			})
+		}
+	}
+
+	return permissions, nil
+}
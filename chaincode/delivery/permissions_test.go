@@ -0,0 +1,251 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// setupCustomRole adds a role to the permission model and lets an organization issue it
+func setupCustomRole(t *testing.T, network *testNetwork, modelJSON string, mspID string, orgRolesJSON string) {
+	t.Helper()
+	if _, err := network.invoke(testAdmin, "SetPermissionModel", modelJSON); err != nil {
+		t.Fatalf("failed to set permission model: %v", err)
+	}
+	if _, err := network.invoke(testAdmin, "RegisterOrganization", mspID, mspID, orgRolesJSON, "true"); err != nil {
+		t.Fatalf("failed to register organization: %v", err)
+	}
+}
+
+// customIdentity returns an identity whose certificate carries a custom role attribute
+func customIdentity(mspID, id, role string, attrs map[string]string) testIdentity {
+	all := map[string]string{"role": role}
+	for name, value := range attrs {
+		all[name] = value
+	}
+	return testIdentity{mspID: mspID, id: id, role: role, attrs: all}
+}
+
+func TestInheritedRoleCannotActOnAnotherSellersDelivery(t *testing.T) {
+	network := newTestNetwork(t)
+	setupCustomRole(t, network, `{"DISPATCHER":{"inherits":["SELLER"]}}`, MSPSellers, `["SELLER","DISPATCHER"]`)
+	dispatcher := customIdentity(MSPSellers, "dispatcher1", "DISPATCHER", nil)
+
+	network.putDelivery(testDelivery(testDeliveryID, StatusInTransit))
+	_, err := network.invoke(dispatcher, "RecallDelivery", testDeliveryID, "wrong item")
+	if err == nil || !strings.Contains(err.Error(), "only the seller can recall this delivery") {
+		t.Fatalf("expected the seller check to reject the dispatcher, got %v", err)
+	}
+	if got := network.getDelivery(testDeliveryID).DeliveryStatus; got != StatusInTransit {
+		t.Errorf("delivery moved to %s", got)
+	}
+
+	// The same role may act on deliveries it is the seller of
+	own := testDelivery("DEL-20260101-0000OWN1", StatusInTransit)
+	own.SellerID = dispatcher.id
+	network.putDelivery(own)
+	if _, err := network.invoke(dispatcher, "RecallDelivery", own.DeliveryID, "wrong item"); err != nil {
+		t.Fatalf("dispatcher could not recall its own delivery: %v", err)
+	}
+	if got := network.getDelivery(own.DeliveryID).DeliveryStatus; got != StatusRecallPending {
+		t.Errorf("own delivery is %s, want %s", got, StatusRecallPending)
+	}
+}
+
+func TestGrantedFunctionKeepsPartyChecks(t *testing.T) {
+	network := newTestNetwork(t)
+	setupCustomRole(t, network, `{"COORDINATOR":{"functions":["RetryDelivery"]}}`, MSPPlatform,
+		`["ADMIN","SUPPORT","AUDITOR","CUSTOMER","COORDINATOR"]`)
+	coordinator := customIdentity(MSPPlatform, "coordinator1", "COORDINATOR", nil)
+
+	delivery := testDelivery(testDeliveryID, StatusDisputedDelivery)
+	delivery.LastDispute = &DisputeInfo{DisputedStatus: StatusDisputedDelivery, Resolution: ResolutionRedeliver}
+	network.putDelivery(delivery)
+
+	// RetryDelivery is granted, but not the ADMIN override: the coordinator acts as a courier
+	_, err := network.invoke(coordinator, "RetryDelivery", testDeliveryID)
+	if err == nil || !strings.Contains(err.Error(), "only the current custodian can retry this delivery") {
+		t.Fatalf("expected the custodian check to reject the coordinator, got %v", err)
+	}
+	if got := network.getDelivery(testDeliveryID).DeliveryStatus; got != StatusDisputedDelivery {
+		t.Errorf("delivery moved to %s", got)
+	}
+}
+
+func TestInheritedAdminIsRegionScoped(t *testing.T) {
+	network := newTestNetwork(t)
+	if _, err := network.invoke(testAdmin, "SetAdminRegions", `{"North":[{"country":"BR"}]}`); err != nil {
+		t.Fatalf("failed to set admin regions: %v", err)
+	}
+	setupCustomRole(t, network, `{"REGIONAL_OPS":{"inherits":["ADMIN"]}}`, MSPPlatform,
+		`["ADMIN","SUPPORT","AUDITOR","CUSTOMER","REGIONAL_OPS"]`)
+	ops := customIdentity(MSPPlatform, "ops1", "REGIONAL_OPS", map[string]string{RegionAttribute: "North"})
+
+	// Headed for Lisbon, outside the North region
+	network.putDelivery(testDelivery(testDeliveryID, StatusDisputedDelivery))
+	_, err := network.invoke(ops, "ResolveDispute", testDeliveryID, string(ResolutionNoAction), "checked with both parties")
+	if err == nil || !strings.Contains(err.Error(), "admins of region North cannot act") {
+		t.Fatalf("expected the region scope to reject the inherited admin, got %v", err)
+	}
+	if got := network.getDelivery(testDeliveryID).DeliveryStatus; got != StatusDisputedDelivery {
+		t.Errorf("delivery moved to %s", got)
+	}
+
+	inRegion := testDelivery("DEL-20260101-0000BR01", StatusDisputedDelivery)
+	inRegion.LastLocation = Location{City: "Recife", State: "PE", Country: "BR"}
+	network.putDelivery(inRegion)
+	if _, err := network.invoke(ops, "ResolveDispute", inRegion.DeliveryID, string(ResolutionNoAction), "checked with both parties"); err != nil {
+		t.Fatalf("inherited admin could not resolve a dispute in its region: %v", err)
+	}
+
+	// Marketplace-wide configuration stays with unscoped admins
+	if _, err := network.invoke(ops, "SetAdminRegions", `{}`); err == nil {
+		t.Errorf("regional inherited admin changed the admin regions")
+	}
+}
+
+// roleIdentifiers maps the identifiers role checks are written with to the roles they stand for
+var roleIdentifiers = map[string][]UserRole{
+	"RoleCustomer":       {RoleCustomer},
+	"RoleSeller":         {RoleSeller},
+	"RoleDeliveryPerson": {RoleDeliveryPerson},
+	"RoleWarehouse":      {RoleWarehouse},
+	"RoleSensor":         {RoleSensor},
+	"RoleSupport":        {RoleSupport},
+	"RoleAuditor":        {RoleAuditor},
+	"RoleCustomsBroker":  {RoleCustomsBroker},
+	"RoleAdmin":          {RoleAdmin},
+	"allRoles":           allRoles,
+	"participantRoles":   participantRoles,
+	"readerRoles":        readerRoles,
+}
+
+// roleCheckSource finds the roles each contract function passes to validateRole, directly or
+// through the helpers it calls
+type roleCheckSource struct {
+	t         *testing.T
+	functions map[string]*ast.FuncDecl // package functions by name
+	methods   map[string]*ast.FuncDecl // DeliveryContract methods by name
+}
+
+// parseRoleCheckSource parses the contract's source files
+func parseRoleCheckSource(t *testing.T) *roleCheckSource {
+	t.Helper()
+	packages, err := parser.ParseDir(token.NewFileSet(), ".", func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("failed to parse the contract: %v", err)
+	}
+	source := &roleCheckSource{t: t, functions: map[string]*ast.FuncDecl{}, methods: map[string]*ast.FuncDecl{}}
+	for _, file := range packages["main"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			if fn.Recv == nil {
+				source.functions[fn.Name.Name] = fn
+				continue
+			}
+			if star, ok := fn.Recv.List[0].Type.(*ast.StarExpr); ok {
+				if receiver, ok := star.X.(*ast.Ident); ok && receiver.Name == "DeliveryContract" {
+					source.methods[fn.Name.Name] = fn
+				}
+			}
+		}
+	}
+	return source
+}
+
+// roles returns the roles fn and the helpers it calls check for
+func (s *roleCheckSource) roles(fn *ast.FuncDecl, visited map[*ast.FuncDecl]bool, roles map[UserRole]bool) {
+	if visited[fn] {
+		return
+	}
+	visited[fn] = true
+	ast.Inspect(fn.Body, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var callee *ast.FuncDecl
+		switch fun := call.Fun.(type) {
+		case *ast.Ident:
+			if fun.Name == "validateRole" {
+				for _, arg := range call.Args[2:] {
+					ident, ok := arg.(*ast.Ident)
+					if !ok || roleIdentifiers[ident.Name] == nil {
+						s.t.Errorf("%s: validateRole argument %T is not a role identifier", fn.Name.Name, arg)
+						continue
+					}
+					for _, role := range roleIdentifiers[ident.Name] {
+						roles[role] = true
+					}
+				}
+				return true
+			}
+			callee = s.functions[fun.Name]
+		case *ast.SelectorExpr:
+			if receiver, ok := fun.X.(*ast.Ident); ok && fn.Recv != nil && receiver.Name == fn.Recv.List[0].Names[0].Name {
+				callee = s.methods[fun.Sel.Name]
+			}
+		}
+		if callee != nil {
+			s.roles(callee, visited, roles)
+		}
+		return true
+	})
+}
+
+// TestTransitionTableMatchesRoleChecks walks every contract function and compares the roles its
+// role checks allow with its transition table entry, so the two can't drift apart
+func TestTransitionTableMatchesRoleChecks(t *testing.T) {
+	source := parseRoleCheckSource(t)
+
+	for name, fn := range source.methods {
+		if !fn.Name.IsExported() {
+			continue
+		}
+		checked := map[UserRole]bool{}
+		source.roles(fn, map[*ast.FuncDecl]bool{}, checked)
+		permission := functionPermission(name)
+		if permission == nil {
+			if len(checked) > 0 {
+				t.Errorf("%s checks roles but has no transition table entry", name)
+			}
+			continue
+		}
+
+		listed := map[UserRole]bool{}
+		for _, role := range permission.Roles {
+			listed[role] = true
+		}
+		var missing, extra []string
+		for role := range checked {
+			if !listed[role] {
+				missing = append(missing, string(role))
+			}
+		}
+		for role := range listed {
+			if !checked[role] {
+				extra = append(extra, string(role))
+			}
+		}
+		sort.Strings(missing)
+		sort.Strings(extra)
+		if len(missing) > 0 || len(extra) > 0 {
+			t.Errorf("%s: transition table is missing %v and lists %v the code doesn't allow", name, missing, extra)
+		}
+	}
+
+	for _, entry := range transitionTable {
+		if source.methods[entry.Function] == nil {
+			t.Errorf("transition table lists %s, which is not a contract function", entry.Function)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EventDeliveryProjection carries the projections of a transaction that emitted no other event
+const EventDeliveryProjection = "DeliveryProjection"
+
+// projectionVersion is the version of the DeliveryProjection schema
+// Bump it whenever a field changes meaning or is removed; adding fields keeps the version.
+const projectionVersion = 1
+
+// DeliveryProjection is the compact search document of a delivery version
+// Every transaction that writes deliveries adds the latest version of each to its event as
+// "projections", so an off-chain search index can be maintained from events alone. Removed
+// deliveries (tombstoned or purged by retention) are projected with Removed set.
+type DeliveryProjection struct {
+	Version         int            `json:"version"`
+	TenantID        string         `json:"tenantId,omitempty" metadata:",optional"`
+	DeliveryID      string         `json:"deliveryId"`
+	OrderID         string         `json:"orderId,omitempty" metadata:",optional"`
+	Status          DeliveryStatus `json:"status,omitempty" metadata:",optional"`
+	SellerID        string         `json:"sellerId,omitempty" metadata:",optional"`
+	CustomerID      string         `json:"customerId,omitempty" metadata:",optional"`
+	CustodianID     string         `json:"custodianId,omitempty" metadata:",optional"`
+	CustodianRole   UserRole       `json:"custodianRole,omitempty" metadata:",optional"`
+	City            string         `json:"city,omitempty" metadata:",optional"`
+	State           string         `json:"state,omitempty" metadata:",optional"`
+	Country         string         `json:"country,omitempty" metadata:",optional"`
+	DestinationCity string         `json:"destinationCity,omitempty" metadata:",optional"`
+	ServiceTier     ServiceTier    `json:"serviceTier,omitempty" metadata:",optional"`
+	StatusSince     string         `json:"statusSince,omitempty" metadata:",optional"`
+	UpdatedAt       string         `json:"updatedAt,omitempty" metadata:",optional"`
+	TxID            string         `json:"txId"`
+	Removed         bool           `json:"removed,omitempty" metadata:",optional"`
+}
+
+// projectDelivery returns the projection of a delivery version written by the transaction
+func projectDelivery(ctx contractapi.TransactionContextInterface, delivery *Delivery) *DeliveryProjection {
+	projection := &DeliveryProjection{
+		Version:    projectionVersion,
+		TenantID:   delivery.TenantID,
+		DeliveryID: delivery.DeliveryID,
+		TxID:       ctx.GetStub().GetTxID(),
+	}
+	if delivery.Tombstone != nil {
+		projection.Removed = true
+		projection.UpdatedAt = delivery.Tombstone.DeletedAt
+		return projection
+	}
+	projection.OrderID = delivery.OrderID
+	projection.Status = delivery.DeliveryStatus
+	projection.SellerID = delivery.SellerID
+	projection.CustomerID = delivery.CustomerID
+	projection.CustodianID = delivery.CurrentCustodianID
+	projection.CustodianRole = delivery.CurrentCustodianRole
+	projection.City = delivery.LastLocation.City
+	projection.State = delivery.LastLocation.State
+	projection.Country = delivery.LastLocation.Country
+	projection.ServiceTier = delivery.ServiceTier
+	projection.StatusSince = delivery.StatusSince
+	projection.UpdatedAt = delivery.UpdatedAt
+	if delivery.Destination != nil {
+		projection.DestinationCity = delivery.Destination.City
+	}
+	return projection
+}
+
+// addDeliveryProjection records the projection of a delivery written by the transaction
+// Called by applyDeliveryUpdate; a delivery written twice keeps its last version.
+func addDeliveryProjection(ctx contractapi.TransactionContextInterface, projection *DeliveryProjection) {
+	tenantCtx, ok := ctx.(*TenantTransactionContext)
+	if !ok {
+		return
+	}
+	if tenantCtx.projections == nil {
+		tenantCtx.projections = map[string]*DeliveryProjection{}
+	}
+	if _, exists := tenantCtx.projections[projection.DeliveryID]; !exists {
+		tenantCtx.projectionOrder = append(tenantCtx.projectionOrder, projection.DeliveryID)
+	}
+	tenantCtx.projections[projection.DeliveryID] = projection
+}
+
+// attachProjections adds a transaction's projections to a JSON object event payload
+func attachProjections(payloadBytes []byte, projections []*DeliveryProjection) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payloadBytes, &fields); err != nil || fields == nil {
+		fields = map[string]json.RawMessage{}
+	}
+	projectionsBytes, err := json.Marshal(projections)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal delivery projections: %v", err)
+	}
+	fields["projections"] = projectionsBytes
+	return json.Marshal(fields)
+}
+
+// emitDeliveryProjections runs after every successful transaction and re-emits its event with
+// the projections of the deliveries it wrote, or a DeliveryProjection event if it emitted none
+// Transactions that wrote no delivery are left untouched.
+func emitDeliveryProjections(ctx contractapi.TransactionContextInterface) error {
+	tenantCtx, ok := ctx.(*TenantTransactionContext)
+	if !ok || len(tenantCtx.projections) == 0 {
+		return nil
+	}
+	projections := make([]*DeliveryProjection, 0, len(tenantCtx.projectionOrder))
+	for _, deliveryID := range tenantCtx.projectionOrder {
+		projections = append(projections, tenantCtx.projections[deliveryID])
+	}
+
+	eventName, payloadBytes := tenantCtx.eventName, tenantCtx.eventPayload
+	if eventName == "" {
+		eventName = EventDeliveryProjection
+		payloadBytes = withCorrelationID(ctx, withEventAudience(ctx, []byte("{}")))
+	}
+	withProjections, err := attachProjections(payloadBytes, projections)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().SetEvent(eventName, withProjections)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Event names for cold-chain quarantine
+const (
+	EventDeliveryQuarantined = "DeliveryQuarantined"
+	EventQuarantineReleased  = "QuarantineReleased"
+)
+
+// quarantineResumeStatuses maps the statuses a cold-chain breach can quarantine from to the
+// status a released delivery resumes in
+// A pending handoff is cancelled by the quarantine, so the delivery resumes with its custodian.
+var quarantineResumeStatuses = map[DeliveryStatus]DeliveryStatus{
+	StatusPendingPickup:               StatusPendingPickup,
+	StatusPendingPickupHandoff:        StatusPendingPickup,
+	StatusInTransit:                   StatusInTransit,
+	StatusPendingTransitHandoff:       StatusInTransit,
+	StatusOutForDelivery:              StatusOutForDelivery,
+	StatusPendingDeliveryConfirmation: StatusInTransit,
+}
+
+// QuarantineInfo records the cold-chain breach that quarantined a delivery and its release
+type QuarantineInfo struct {
+	GatewayID      string         `json:"gatewayId"`
+	Temperature    float64        `json:"temperature"`
+	Threshold      float64        `json:"threshold"`
+	PreviousStatus DeliveryStatus `json:"previousStatus"`
+	ResumeStatus   DeliveryStatus `json:"resumeStatus"`
+	QuarantinedAt  string         `json:"quarantinedAt"`
+	// Handoff cancelled by the quarantine, if one was pending
+	CancelledHandoff *PendingHandoff `json:"cancelledHandoff,omitempty" metadata:",optional"`
+	ReleasedBy       string          `json:"releasedBy,omitempty" metadata:",optional"`
+	ReleaseReason    string          `json:"releaseReason,omitempty" metadata:",optional"`
+	ReleasedAt       string          `json:"releasedAt,omitempty" metadata:",optional"`
+}
+
+// quarantineDelivery moves a delivery to QUARANTINED after a TEMPERATURE violation
+// Returns false, without writing, if the delivery is not in a status that can be quarantined
+// (e.g. already quarantined or on its way back to the seller).
+func quarantineDelivery(
+	ctx contractapi.TransactionContextInterface,
+	delivery *Delivery,
+	reading *TelemetryReading,
+	threshold float64,
+) (bool, error) {
+	resumeStatus, ok := quarantineResumeStatuses[delivery.DeliveryStatus]
+	if !ok {
+		return false, nil
+	}
+
+	delivery.Quarantine = &QuarantineInfo{
+		GatewayID:        reading.GatewayID,
+		Temperature:      reading.Value,
+		Threshold:        threshold,
+		PreviousStatus:   delivery.DeliveryStatus,
+		ResumeStatus:     resumeStatus,
+		QuarantinedAt:    reading.RecordedAt,
+		CancelledHandoff: delivery.PendingHandoff,
+	}
+	delivery.PendingHandoff = nil
+	delivery.DeliveryStatus = StatusQuarantined
+	delivery.UpdatedAt = reading.RecordedAt
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseQuarantine clears a cold-chain quarantine once the goods are judged safe
+// The SELLER of the delivery or an ADMIN can release; the delivery resumes in IN_TRANSIT,
+// OUT_FOR_DELIVERY or PENDING_PICKUP with its current custodian. To send the goods back
+// instead, use RecallDelivery.
+func (c *DeliveryContract) ReleaseQuarantine(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	reason string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	reason = sanitizeText(reason)
+	if err := validateReason(reason); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - the seller answers for the goods, admins for the marketplace
+	if err := validateRole(ctx, caller, RoleSeller, RoleAdmin); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if caller.Role == RoleSeller && delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller of this delivery can release its quarantine")
+	}
+	if delivery.DeliveryStatus != StatusQuarantined || delivery.Quarantine == nil {
+		return fmt.Errorf("delivery %s is not quarantined", deliveryID)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	oldStatus := delivery.DeliveryStatus
+
+	delivery.Quarantine.ReleasedBy = caller.ID
+	delivery.Quarantine.ReleaseReason = reason
+	delivery.Quarantine.ReleasedAt = currentTime
+	delivery.DeliveryStatus = delivery.Quarantine.ResumeStatus
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventQuarantineReleased, map[string]string{
+		"deliveryId": deliveryID,
+		"orderId":    delivery.OrderID,
+		"oldStatus":  string(oldStatus),
+		"newStatus":  string(delivery.DeliveryStatus),
+		"releasedBy": caller.ID,
+		"reason":     reason,
+		"timestamp":  currentTime,
+	})
+}
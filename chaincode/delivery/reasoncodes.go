@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ReasonCategory groups the reason codes accepted by one kind of transaction
+type ReasonCategory string
+
+const (
+	// ReasonCategoryDispute covers DisputeHandoff and ReportMissingItem
+	ReasonCategoryDispute ReasonCategory = "DISPUTE"
+	// ReasonCategoryCancellation covers CancelDelivery
+	ReasonCategoryCancellation ReasonCategory = "CANCELLATION"
+	// ReasonCategoryIncident covers ReportException
+	ReasonCategoryIncident ReasonCategory = "INCIDENT"
+)
+
+// RecordReasonCodes is the composite key prefix for reason-code catalogs
+const RecordReasonCodes = "reasonCodes~category"
+
+// reasonCodePattern keeps codes short, upper-case identifiers suitable for analytics
+var reasonCodePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{1,49}$`)
+
+// ReasonCode is one entry of a reason-code catalog
+type ReasonCode struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// defaultReasonCodes returns the catalog used for a category until an admin sets one
+func defaultReasonCodes(category ReasonCategory) []ReasonCode {
+	switch category {
+	case ReasonCategoryDispute:
+		return []ReasonCode{
+			{Code: "NOT_RECEIVED", Description: "Package was not handed over"},
+			{Code: "WRONG_PACKAGE", Description: "Package does not match the delivery"},
+			{Code: "DAMAGED", Description: "Package or contents are damaged"},
+			{Code: "MISSING_ITEMS", Description: "Items are missing from the package"},
+			{Code: "OTHER", Description: "Other reason, see details"},
+		}
+	case ReasonCategoryCancellation:
+		return []ReasonCode{
+			{Code: "CHANGED_MIND", Description: "Customer no longer wants the order"},
+			{Code: "DUPLICATE_ORDER", Description: "Order was placed twice"},
+			{Code: "ADDRESS_CHANGE", Description: "Delivery address has to change"},
+			{Code: "OTHER", Description: "Other reason, see details"},
+		}
+	case ReasonCategoryIncident:
+		return []ReasonCode{
+			{Code: string(ExceptionWeatherDelay), Description: "Delayed by weather"},
+			{Code: string(ExceptionVehicleBreakdown), Description: "Courier vehicle broke down"},
+			{Code: string(ExceptionWrongAddress), Description: "Address is wrong or incomplete"},
+			{Code: string(ExceptionRecipientUnavailable), Description: "Recipient could not be reached"},
+		}
+	}
+	return nil
+}
+
+// validateReasonCategory checks if a category is one of the known catalogs
+func validateReasonCategory(category ReasonCategory) error {
+	switch category {
+	case ReasonCategoryDispute, ReasonCategoryCancellation, ReasonCategoryIncident:
+		return nil
+	}
+	return &ValidationError{Field: "category", Message: fmt.Sprintf("unknown reason category: %s", category)}
+}
+
+// validateReasonDetails checks the optional free text accompanying a reason code
+func validateReasonDetails(reason string) error {
+	return validateText(reason, "reason", 1000, true)
+}
+
+// getReasonCodes reads the catalog of a category, falling back to the defaults
+func getReasonCodes(ctx contractapi.TransactionContextInterface, category ReasonCategory) ([]ReasonCode, error) {
+	catalogKey, err := ctx.GetStub().CreateCompositeKey(RecordReasonCodes, []string{string(category)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reason codes composite key: %v", err)
+	}
+	catalogJSON, err := ctx.GetStub().GetState(catalogKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reason codes: %v", err)
+	}
+	if catalogJSON == nil {
+		return defaultReasonCodes(category), nil
+	}
+
+	var codes []ReasonCode
+	if err := json.Unmarshal(catalogJSON, &codes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reason codes: %v", err)
+	}
+	return codes, nil
+}
+
+// validateReasonCode checks that a code is in the catalog of its category
+func validateReasonCode(ctx contractapi.TransactionContextInterface, category ReasonCategory, code string) error {
+	if code == "" {
+		return &ValidationError{Field: "reasonCode", Message: "cannot be empty"}
+	}
+	codes, err := getReasonCodes(ctx, category)
+	if err != nil {
+		return err
+	}
+	for _, reasonCode := range codes {
+		if reasonCode.Code == code {
+			return nil
+		}
+	}
+	return &ValidationError{Field: "reasonCode", Message: fmt.Sprintf("unknown %s reason code: %s", category, code)}
+}
+
+// SetReasonCodes replaces the reason-code catalog of a category
+// Only ADMIN can manage catalogs; codes already stored on records stay as they are
+func (c *DeliveryContract) SetReasonCodes(
+	ctx contractapi.TransactionContextInterface,
+	category string,
+	codesJSON string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateReasonCategory(ReasonCategory(category)); err != nil {
+		return err
+	}
+	var codes []ReasonCode
+	if err := json.Unmarshal([]byte(codesJSON), &codes); err != nil {
+		return fmt.Errorf("failed to parse reason codes: %v", err)
+	}
+	if len(codes) == 0 || len(codes) > 100 {
+		return &ValidationError{Field: "codes", Message: "must contain between 1 and 100 reason codes"}
+	}
+	seen := make(map[string]bool)
+	for i, reasonCode := range codes {
+		if !reasonCodePattern.MatchString(reasonCode.Code) {
+			return &ValidationError{Field: "code", Message: fmt.Sprintf("invalid reason code %q: must be 2-50 upper-case letters, digits or '_'", reasonCode.Code)}
+		}
+		if seen[reasonCode.Code] {
+			return &ValidationError{Field: "code", Message: fmt.Sprintf("duplicate reason code: %s", reasonCode.Code)}
+		}
+		seen[reasonCode.Code] = true
+		codes[i].Description = sanitizeText(reasonCode.Description)
+		if err := validateText(codes[i].Description, "description", 200, false); err != nil {
+			return err
+		}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can manage reason codes
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	catalogKey, err := ctx.GetStub().CreateCompositeKey(RecordReasonCodes, []string{category})
+	if err != nil {
+		return fmt.Errorf("failed to create reason codes composite key: %v", err)
+	}
+	catalogJSON, err := json.Marshal(codes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reason codes: %v", err)
+	}
+
+	return ctx.GetStub().PutState(catalogKey, catalogJSON)
+}
+
+// GetReasonCodes returns the reason-code catalog of a category
+// Any authenticated user can read catalogs to populate client pickers
+func (c *DeliveryContract) GetReasonCodes(
+	ctx contractapi.TransactionContextInterface,
+	category string,
+) ([]ReasonCode, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateReasonCategory(ReasonCategory(category)); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	return getReasonCodes(ctx, ReasonCategory(category))
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Event names for admin custody reassignment
+const (
+	EventCustodyReassignmentRequested = "CustodyReassignmentRequested"
+	EventCustodyReassigned            = "CustodyReassigned"
+)
+
+// reassignableStatuses are the statuses in which a logistics custodian can be replaced
+var reassignableStatuses = map[DeliveryStatus]bool{
+	StatusInTransit:        true,
+	StatusRecallPending:    true,
+	StatusReturnInTransit:  true,
+	StatusDisputedDelivery: true,
+	StatusOutForDelivery:   true,
+}
+
+// CustodyReassignment records an admin-initiated custody transfer away from an unresponsive custodian
+// It is pending until the new custodian acknowledges it (AcknowledgedAt set).
+type CustodyReassignment struct {
+	FromUserID     string   `json:"fromUserId"`
+	ToUserID       string   `json:"toUserId"`
+	ToRole         UserRole `json:"toRole"`
+	RequestedBy    string   `json:"requestedBy"`
+	RequestedByMSP string   `json:"requestedByMsp,omitempty" metadata:",optional"`
+	RequestedAt    string   `json:"requestedAt"`
+	AcknowledgedAt string   `json:"acknowledgedAt,omitempty" metadata:",optional"`
+}
+
+// hasPendingReassignment reports whether a custody reassignment awaits acknowledgement
+func hasPendingReassignment(delivery *Delivery) bool {
+	return delivery.Reassignment != nil && delivery.Reassignment.AcknowledgedAt == ""
+}
+
+// AdminReassignCustody starts moving custody from an unresponsive courier or warehouse to a new one
+// Only ADMIN can reassign. The new custodian takes the current custodian's role and must call
+// AcknowledgeCustody before the transfer finalizes; calling again replaces a pending reassignment.
+// The finalized transfer is recorded as a correction approved by the requesting admin.
+func (c *DeliveryContract) AdminReassignCustody(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	newCustodianID string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	if err := validateUserID(newCustodianID, "newCustodianID"); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can reassign custody
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	// Sellers and customers hold their own parcels; only logistics custody is reassigned
+	if delivery.CurrentCustodianRole != RoleDeliveryPerson && delivery.CurrentCustodianRole != RoleWarehouse {
+		return fmt.Errorf("only DELIVERY_PERSON or WAREHOUSE custody can be reassigned")
+	}
+	if !reassignableStatuses[delivery.DeliveryStatus] {
+		return fmt.Errorf("cannot reassign custody in current status: %s", delivery.DeliveryStatus)
+	}
+	if delivery.PendingHandoff != nil {
+		return fmt.Errorf("cancel the pending handoff before reassigning custody")
+	}
+	if newCustodianID == delivery.CurrentCustodianID {
+		return fmt.Errorf("%s is already the custodian", newCustodianID)
+	}
+
+	// Couriers on break or off shift can't be assigned new packages
+	if delivery.CurrentCustodianRole == RoleDeliveryPerson {
+		if err := validateCourierAvailable(ctx, newCustodianID); err != nil {
+			return err
+		}
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	delivery.Reassignment = &CustodyReassignment{
+		FromUserID:     delivery.CurrentCustodianID,
+		ToUserID:       newCustodianID,
+		ToRole:         delivery.CurrentCustodianRole,
+		RequestedBy:    caller.ID,
+		RequestedByMSP: caller.MSP,
+		RequestedAt:    currentTime,
+	}
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventCustodyReassignmentRequested, map[string]string{
+		"deliveryId":  deliveryID,
+		"fromUserId":  delivery.Reassignment.FromUserID,
+		"toUserId":    newCustodianID,
+		"requestedBy": caller.ID,
+		"timestamp":   currentTime,
+	})
+}
+
+// AcknowledgeCustody finalizes an admin custody reassignment addressed to the caller
+// The new DELIVERY_PERSON or WAREHOUSE custodian confirms they physically hold the parcel
+func (c *DeliveryContract) AcknowledgeCustody(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	city string,
+	state string,
+	country string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	city, state, country = normalizeLocation(city, state, country)
+	if err := validateLocation(city, state, country); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleWarehouse); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	if !hasPendingReassignment(delivery) {
+		return fmt.Errorf("no pending custody reassignment for this delivery")
+	}
+	reassignment := delivery.Reassignment
+	if reassignment.ToUserID != caller.ID || reassignment.ToRole != caller.Role {
+		return fmt.Errorf("only the new custodian can acknowledge the reassignment")
+	}
+
+	// Same checks as a regular handoff to this custodian
+	if err := validateHandlerCertifications(ctx, delivery, reassignment.ToRole); err != nil {
+		return err
+	}
+	if caller.Role == RoleDeliveryPerson {
+		if err := validateCourierCapacity(ctx, caller.ID, 1); err != nil {
+			return err
+		}
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The reassignment is acknowledged in place, so the snapshot gets its own copy
+	before := *delivery
+	pending := *reassignment
+	before.Reassignment = &pending
+
+	delivery.CurrentCustodianID = reassignment.ToUserID
+	delivery.CurrentCustodianRole = reassignment.ToRole
+	delivery.CustodianMSP = caller.MSP
+	delivery.CarrierOfRecord = caller.MSP
+	delivery.LastLocation = Location{
+		City:    city,
+		State:   state,
+		Country: country,
+	}
+	reassignment.AcknowledgedAt = currentTime
+	delivery.UpdatedAt = currentTime
+
+	// The admin who requested the transfer approved it
+	approver := &CallerIdentity{ID: reassignment.RequestedBy, Role: RoleAdmin, MSP: reassignment.RequestedByMSP}
+	reason := fmt.Sprintf("custody reassigned from %s, acknowledged by %s", reassignment.FromUserID, caller.ID)
+	if err := recordCorrection(ctx, "AdminReassignCustody", reason, &before, delivery, approver); err != nil {
+		return err
+	}
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	// The new custodian's org must endorse any future state changes
+	if err := setDeliveryEndorsementPolicy(ctx, delivery); err != nil {
+		return fmt.Errorf("failed to update endorsement policy: %v", err)
+	}
+
+	return emitEvent(ctx, EventCustodyReassigned, map[string]string{
+		"deliveryId":  deliveryID,
+		"fromUserId":  reassignment.FromUserID,
+		"toUserId":    reassignment.ToUserID,
+		"requestedBy": reassignment.RequestedBy,
+		"timestamp":   currentTime,
+	})
+}
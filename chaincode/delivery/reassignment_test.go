@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAcknowledgedReassignmentRecordsCorrection(t *testing.T) {
+	network := newTestNetwork(t)
+	network.putDelivery(testDelivery(testDeliveryID, StatusInTransit))
+	newCourier := testIdentity{mspID: MSPLogistics, id: "courier2", role: string(RoleDeliveryPerson)}
+
+	if _, err := network.invoke(testAdmin, "AdminReassignCustody", testDeliveryID, newCourier.id); err != nil {
+		t.Fatalf("failed to reassign custody: %v", err)
+	}
+	if corrections := network.corrections(testDeliveryID); len(corrections) != 0 {
+		t.Fatalf("pending reassignment recorded %d corrections", len(corrections))
+	}
+	if _, err := network.invoke(newCourier, "AcknowledgeCustody", testDeliveryID, "Porto", "Porto", "PT"); err != nil {
+		t.Fatalf("failed to acknowledge custody: %v", err)
+	}
+
+	corrections := network.corrections(testDeliveryID)
+	if len(corrections) != 1 {
+		t.Fatalf("got %d correction records, want 1", len(corrections))
+	}
+	correction := corrections[0]
+	if correction.Operation != "AdminReassignCustody" || !strings.Contains(correction.Reason, newCourier.id) {
+		t.Errorf("unexpected correction record: %+v", correction)
+	}
+	if correction.ApprovedBy != testAdmin.id || correction.ApproverRole != RoleAdmin || correction.ApproverMSP != testAdmin.mspID {
+		t.Errorf("correction approved by %s (%s, %s), want the requesting admin", correction.ApprovedBy, correction.ApproverRole, correction.ApproverMSP)
+	}
+	if correction.Before.CurrentCustodianID != testCourier.id || correction.Before.Reassignment.AcknowledgedAt != "" {
+		t.Errorf("before snapshot is held by %s with reassignment %+v", correction.Before.CurrentCustodianID, correction.Before.Reassignment)
+	}
+	if correction.After.CurrentCustodianID != newCourier.id || correction.After.Reassignment.AcknowledgedAt == "" {
+		t.Errorf("after snapshot is held by %s with reassignment %+v", correction.After.CurrentCustodianID, correction.After.Reassignment)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// identityHashPattern matches a hex-encoded SHA-256 digest
+var identityHashPattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// validateRecipientIdentityHash checks the optional identity hash stored with private details
+func validateRecipientIdentityHash(hash string) error {
+	if hash != "" && !identityHashPattern.MatchString(hash) {
+		return &ValidationError{Field: "recipientIdentityHash", Message: "must be a hex-encoded SHA-256 digest"}
+	}
+	return nil
+}
+
+// recipientIdentityHash hashes a recipient's name or document number with its salt
+// Values are trimmed and upper-cased so the doorstep check tolerates casing differences.
+// Clients must compute RecipientIdentityHash the same way: hex(sha256(salt + ":" + value)).
+func recipientIdentityHash(value string, salt string) string {
+	normalized := strings.ToUpper(strings.TrimSpace(value))
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(salt+":"+normalized)))
+}
+
+// VerifyRecipient checks the person at the door against the salted identity hash in private details
+// Evaluate only - a submitted call would write the plaintext value into the block.
+// The current DELIVERY_PERSON custodian or ADMIN can verify; only a match flag is returned.
+func (c *DeliveryContract) VerifyRecipient(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	providedValue string,
+	salt string,
+) (bool, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return false, err
+	}
+	if len(strings.TrimSpace(providedValue)) == 0 || len(providedValue) > 200 {
+		return false, &ValidationError{Field: "providedValue", Message: "must be between 1 and 200 characters"}
+	}
+	if len(salt) < 8 || len(salt) > 128 {
+		return false, &ValidationError{Field: "salt", Message: "must be between 8 and 128 characters"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, RoleDeliveryPerson, RoleAdmin); err != nil {
+		return false, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return false, err
+	}
+
+	if caller.Role == RoleDeliveryPerson && delivery.CurrentCustodianID != caller.ID {
+		return false, fmt.Errorf("only the current custodian can verify the recipient")
+	}
+
+	privateDetailsBytes, err := ctx.GetStub().GetPrivateData(CollectionDeliveryPrivate, deliveryID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get private details: %v", err)
+	}
+	if privateDetailsBytes == nil {
+		return false, fmt.Errorf("private details not found for delivery %s", deliveryID)
+	}
+
+	var privateDetails DeliveryPrivateDetails
+	if err := json.Unmarshal(privateDetailsBytes, &privateDetails); err != nil {
+		return false, fmt.Errorf("failed to parse private details: %v", err)
+	}
+	if privateDetails.RecipientIdentityHash == "" {
+		return false, fmt.Errorf("no recipient identity hash stored for delivery %s", deliveryID)
+	}
+
+	provided := recipientIdentityHash(providedValue, salt)
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(strings.ToLower(privateDetails.RecipientIdentityHash))) == 1, nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordUserProfile is the composite key prefix for the user registry
+const RecordUserProfile = "user~userId"
+
+// CourierAvailability is the shift status of a courier
+type CourierAvailability string
+
+const (
+	AvailabilityAvailable CourierAvailability = "AVAILABLE"
+	AvailabilityOnBreak   CourierAvailability = "ON_BREAK"
+	AvailabilityOffShift  CourierAvailability = "OFF_SHIFT"
+)
+
+// EventAvailabilityChanged is emitted when a courier changes shift status
+const EventAvailabilityChanged = "CourierAvailabilityChanged"
+
+// UserProfile is the on-chain registry entry of a user
+// Entries are created on first use; users without an entry keep default behaviour
+type UserProfile struct {
+	UserID       string              `json:"userId"`
+	Role         UserRole            `json:"role"`
+	MSP          string              `json:"msp"`
+	Availability CourierAvailability `json:"availability,omitempty" metadata:",optional"`
+	// Maximum deliveries a courier may hold at once (0 = unlimited)
+	MaxConcurrentDeliveries int `json:"maxConcurrentDeliveries,omitempty" metadata:",optional"`
+	// Courier or warehouse a SENSOR gateway is installed with
+	CustodianID string `json:"custodianId,omitempty" metadata:",optional"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+// getUserProfile reads a user registry entry, returning nil if the user is not registered
+func getUserProfile(ctx contractapi.TransactionContextInterface, userID string) (*UserProfile, error) {
+	profileKey, err := ctx.GetStub().CreateCompositeKey(RecordUserProfile, []string{userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user composite key: %v", err)
+	}
+	profileJSON, err := ctx.GetStub().GetState(profileKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user profile %s: %v", userID, err)
+	}
+	if profileJSON == nil {
+		return nil, nil
+	}
+
+	var profile UserProfile
+	if err := json.Unmarshal(profileJSON, &profile); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user profile: %v", err)
+	}
+	return &profile, nil
+}
+
+// putUserProfile writes a user registry entry
+func putUserProfile(ctx contractapi.TransactionContextInterface, profile *UserProfile) error {
+	profileKey, err := ctx.GetStub().CreateCompositeKey(RecordUserProfile, []string{profile.UserID})
+	if err != nil {
+		return fmt.Errorf("failed to create user composite key: %v", err)
+	}
+	profileJSON, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal user profile: %v", err)
+	}
+	if err := ctx.GetStub().PutState(profileKey, profileJSON); err != nil {
+		return fmt.Errorf("failed to put user profile: %v", err)
+	}
+	return nil
+}
+
+// validateCourierAvailable rejects assignments to couriers who are not on shift
+// Couriers who never declared availability are treated as available
+func validateCourierAvailable(ctx contractapi.TransactionContextInterface, courierID string) error {
+	profile, err := getUserProfile(ctx, courierID)
+	if err != nil {
+		return err
+	}
+	if profile != nil && profile.Availability != "" && profile.Availability != AvailabilityAvailable {
+		return fmt.Errorf("courier %s is not available (%s)", courierID, profile.Availability)
+	}
+	return nil
+}
+
+// validateCourierCapacity rejects accepting incoming packages beyond the courier's configured limit
+// The current load is the number of custodian index entries for the courier
+func validateCourierCapacity(ctx contractapi.TransactionContextInterface, courierID string, incoming int) error {
+	profile, err := getUserProfile(ctx, courierID)
+	if err != nil {
+		return err
+	}
+	if profile == nil || profile.MaxConcurrentDeliveries <= 0 {
+		return nil
+	}
+
+	held, err := queryByCompositeKey(ctx, IndexCustodianDelivery, []string{courierID})
+	if err != nil {
+		return err
+	}
+	if len(held)+incoming > profile.MaxConcurrentDeliveries {
+		return fmt.Errorf("courier %s would exceed capacity (%d held + %d incoming, max %d)", courierID, len(held), incoming, profile.MaxConcurrentDeliveries)
+	}
+	return nil
+}
+
+// SetAvailability updates the caller's shift status in the user registry
+// Only DELIVERY_PERSON can set availability
+func (c *DeliveryContract) SetAvailability(
+	ctx contractapi.TransactionContextInterface,
+	availability string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	switch CourierAvailability(availability) {
+	case AvailabilityAvailable, AvailabilityOnBreak, AvailabilityOffShift:
+	default:
+		return &ValidationError{Field: "availability", Message: fmt.Sprintf("unknown availability: %s", availability)}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only DELIVERY_PERSON has shifts
+	if err := validateRole(ctx, caller, RoleDeliveryPerson); err != nil {
+		return err
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	profile, err := getUserProfile(ctx, caller.ID)
+	if err != nil {
+		return err
+	}
+	if profile == nil {
+		profile = &UserProfile{UserID: caller.ID}
+	}
+	profile.Role = caller.Role
+	profile.MSP = caller.MSP
+	profile.Availability = CourierAvailability(availability)
+	profile.UpdatedAt = currentTime
+
+	if err := putUserProfile(ctx, profile); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventAvailabilityChanged, map[string]string{
+		"userId":       caller.ID,
+		"availability": availability,
+		"timestamp":    currentTime,
+	})
+}
+
+// QueryFleetAvailability returns the registry entries of all couriers
+// Only ADMIN can view fleet availability
+func (c *DeliveryContract) QueryFleetAvailability(
+	ctx contractapi.TransactionContextInterface,
+) ([]*UserProfile, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can view the fleet
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordUserProfile, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user profiles: %v", err)
+	}
+	defer iterator.Close()
+
+	profiles := []*UserProfile{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate user profiles: %v", err)
+		}
+
+		var profile UserProfile
+		if err := json.Unmarshal(response.Value, &profile); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal user profile: %v", err)
+		}
+		if profile.Role != RoleDeliveryPerson {
+			continue
+		}
+		profiles = append(profiles, &profile)
+	}
+
+	return profiles, nil
+}
+
+// SetCourierCapacity sets the maximum number of deliveries a courier may hold at once
+// Only ADMIN can set capacity; 0 removes the limit
+func (c *DeliveryContract) SetCourierCapacity(
+	ctx contractapi.TransactionContextInterface,
+	courierID string,
+	maxConcurrentDeliveries int,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateUserID(courierID, "courierID"); err != nil {
+		return err
+	}
+	if maxConcurrentDeliveries < 0 || maxConcurrentDeliveries > 1000 {
+		return &ValidationError{Field: "maxConcurrentDeliveries", Message: "must be between 0 and 1000"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - couriers work across regions, so only an unscoped ADMIN sets capacity
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	profile, err := getUserProfile(ctx, courierID)
+	if err != nil {
+		return err
+	}
+	if profile == nil {
+		profile = &UserProfile{UserID: courierID, Role: RoleDeliveryPerson, MSP: MSPLogistics}
+	}
+	if profile.Role != RoleDeliveryPerson {
+		return fmt.Errorf("user %s is not a delivery person", courierID)
+	}
+	profile.MaxConcurrentDeliveries = maxConcurrentDeliveries
+	profile.UpdatedAt = currentTime
+
+	return putUserProfile(ctx, profile)
+}
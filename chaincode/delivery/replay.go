@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ReplayedEvent is a lifecycle event rebuilt from a delivery's ledger history
+// Payload has the same schema as the event emitted on-chain, audience included.
+type ReplayedEvent struct {
+	EventName string                 `json:"eventName"`
+	TxID      string                 `json:"txId"`
+	Timestamp string                 `json:"timestamp"`
+	Payload   map[string]interface{} `json:"payload"`
+}
+
+// replayLifecycleEvent rebuilds the lifecycle event a transaction emitted from the delivery
+// versions before and after it. Transactions that emitted no lifecycle event return "".
+func replayLifecycleEvent(ctx contractapi.TransactionContextInterface, previous *Delivery, delivery *Delivery, txID string) (string, interface{}, error) {
+	if previous == nil {
+		return EventDeliveryCreated, DeliveryEvent{
+			DeliveryID: delivery.DeliveryID,
+			OrderID:    delivery.OrderID,
+			NewStatus:  delivery.DeliveryStatus,
+			Timestamp:  delivery.UpdatedAt,
+		}, nil
+	}
+
+	// DisputeHandoff's dispute event supersedes its status change event
+	if dispute := delivery.LastDispute; dispute != nil && (previous.LastDispute == nil || previous.LastDispute.DisputeID != dispute.DisputeID) {
+		return EventHandoffDisputed, map[string]string{
+			"deliveryId": delivery.DeliveryID,
+			"disputeId":  dispute.DisputeID,
+			"disputedBy": dispute.DisputedBy,
+			"reasonCode": dispute.ReasonCode,
+			"reason":     dispute.Reason,
+			"timestamp":  dispute.DisputedAt,
+		}, nil
+	}
+
+	handoff := delivery.PendingHandoff
+	newHandoff := handoff != nil && (previous.PendingHandoff == nil || previous.PendingHandoff.InitiatedAt != handoff.InitiatedAt)
+	if newHandoff && handoff.Type == HandoffTypeInterline {
+		return EventInterlineHandoffInitiated, map[string]string{
+			"deliveryId":     delivery.DeliveryID,
+			"oldStatus":      string(previous.DeliveryStatus),
+			"newStatus":      string(delivery.DeliveryStatus),
+			"fromUserId":     handoff.FromUserID,
+			"fromCarrierMsp": handoff.FromCarrierMSP,
+			"toUserId":       handoff.ToUserID,
+			"toCarrierMsp":   handoff.ToCarrierMSP,
+			"timestamp":      handoff.InitiatedAt,
+		}, nil
+	}
+
+	if previous.DeliveryStatus != delivery.DeliveryStatus {
+		return EventDeliveryStatusChanged, DeliveryEvent{
+			DeliveryID: delivery.DeliveryID,
+			OrderID:    delivery.OrderID,
+			OldStatus:  previous.DeliveryStatus,
+			NewStatus:  delivery.DeliveryStatus,
+			Timestamp:  delivery.UpdatedAt,
+		}, nil
+	}
+
+	if newHandoff {
+		return EventHandoffInitiated, map[string]string{
+			"deliveryId": delivery.DeliveryID,
+			"fromUserId": handoff.FromUserID,
+			"toUserId":   handoff.ToUserID,
+			"timestamp":  handoff.InitiatedAt,
+		}, nil
+	}
+
+	// Location updates left a location history entry under the same transaction
+	if previous.LastLocation != delivery.LastLocation {
+		update, err := getLocationUpdate(ctx, delivery.DeliveryID, txID)
+		if err != nil {
+			return "", nil, err
+		}
+		if update != nil {
+			return EventLocationUpdated, map[string]string{
+				"deliveryId": delivery.DeliveryID,
+				"orderId":    delivery.OrderID,
+				"kind":       string(update.Kind),
+				"city":       update.Location.City,
+				"state":      update.Location.State,
+				"country":    update.Location.Country,
+				"txId":       txID,
+				"updatedBy":  update.RecordedBy,
+				"timestamp":  update.RecordedAt,
+			}, nil
+		}
+	}
+
+	return "", nil, nil
+}
+
+// GetEventsForDelivery rebuilds the ordered lifecycle events of a delivery from its ledger history
+// Recovery path for listeners that missed blocks: creation, status changes, handoff initiations,
+// disputes and location updates since sinceTimestamp (RFC3339; empty for all), in the payload
+// schema emitted on-chain. Any party involved in the delivery (or admin) can replay them.
+func (c *DeliveryContract) GetEventsForDelivery(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	sinceTimestamp string,
+) ([]ReplayedEvent, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+	var since time.Time
+	if sinceTimestamp != "" {
+		parsed, err := time.Parse(time.RFC3339, sinceTimestamp)
+		if err != nil {
+			return nil, &ValidationError{Field: "sinceTimestamp", Message: "must be an RFC3339 timestamp"}
+		}
+		since = parsed
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	entries, err := readHistoryEntries(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []ReplayedEvent{}
+	var previous *Delivery
+	for _, entry := range entries {
+		if entry.isDelete || len(entry.value) == 0 {
+			previous = nil
+			continue
+		}
+		var version Delivery
+		if err := json.Unmarshal(entry.value, &version); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal delivery: %v", err)
+		}
+		if version.Tombstone != nil {
+			break
+		}
+
+		if entry.timestamp.Before(since) {
+			previous = &version
+			continue
+		}
+
+		eventName, payload, err := replayLifecycleEvent(ctx, previous, &version, entry.txID)
+		if err != nil {
+			return nil, err
+		}
+		if eventName != "" {
+			payloadBytes, err := json.Marshal(payload)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal event payload: %v", err)
+			}
+			audience := newAudienceSet()
+			audience.addDelivery(previous)
+			audience.addDelivery(&version)
+
+			var fields map[string]interface{}
+			if err := json.Unmarshal(attachAudience(payloadBytes, audience), &fields); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal event payload: %v", err)
+			}
+			events = append(events, ReplayedEvent{
+				EventName: eventName,
+				TxID:      entry.txID,
+				Timestamp: entry.timestamp.UTC().Format(time.RFC3339),
+				Payload:   fields,
+			})
+		}
+		previous = &version
+	}
+
+	return events, nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// configRestrictedGoodsName is the config record holding the RestrictedGoodsMatrix
+const configRestrictedGoodsName = "restrictedGoods"
+
+// TransientGoodsCategory is the transient map key for a new delivery's restricted goods category
+const TransientGoodsCategory = "goodsCategory"
+
+// ErrCodeRestrictedGoodsProhibited prefixes errors for restricted goods sent where they are prohibited
+const ErrCodeRestrictedGoodsProhibited = "RESTRICTED_GOODS_PROHIBITED"
+
+// Jurisdiction check results
+const (
+	JurisdictionAllowed    = "ALLOWED"
+	JurisdictionProhibited = "PROHIBITED"
+)
+
+// maxJurisdictionRules bounds the rules kept per category
+const maxJurisdictionRules = 1000
+
+// goodsCategoryPattern restricts category names (e.g. ALCOHOL, FIREARMS, CANNABIS)
+var goodsCategoryPattern = regexp.MustCompile(`^[A-Z0-9_]{1,32}$`)
+
+// JurisdictionRule allows or prohibits a category in a state, or a whole country if State is empty
+type JurisdictionRule struct {
+	Country string `json:"country"`
+	State   string `json:"state,omitempty" metadata:",optional"`
+	Allowed bool   `json:"allowed"`
+}
+
+// RestrictedGoodsMatrix maps restricted goods categories to their jurisdiction rules
+// A state rule takes precedence over its country's rule; jurisdictions without a rule are
+// prohibited, so each category is only shipped where it was explicitly allowed.
+type RestrictedGoodsMatrix map[string][]JurisdictionRule
+
+// JurisdictionCheck is the recorded result of checking a delivery against the matrix
+type JurisdictionCheck struct {
+	Category    string   `json:"category"`
+	Destination Location `json:"destination"`
+	Result      string   `json:"result"`
+	// Rule that decided the result; nil when no rule covered the destination
+	Rule      *JurisdictionRule `json:"rule,omitempty" metadata:",optional"`
+	CheckedAt string            `json:"checkedAt"`
+}
+
+// RestrictedGoodsError is returned when restricted goods are sent where they are prohibited
+// Clients match on the RESTRICTED_GOODS_PROHIBITED prefix of the message.
+type RestrictedGoodsError struct {
+	Check *JurisdictionCheck
+}
+
+func (e *RestrictedGoodsError) Error() string {
+	return fmt.Sprintf("%s: %s goods cannot be delivered to %s, %s", ErrCodeRestrictedGoodsProhibited,
+		e.Check.Category, e.Check.Destination.State, e.Check.Destination.Country)
+}
+
+// getRestrictedGoodsMatrix reads the configured restricted goods matrix (empty if none)
+func getRestrictedGoodsMatrix(ctx contractapi.TransactionContextInterface) (RestrictedGoodsMatrix, error) {
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configRestrictedGoodsName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	matrixJSON, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read restricted goods matrix: %v", err)
+	}
+
+	matrix := RestrictedGoodsMatrix{}
+	if matrixJSON != nil {
+		if err := json.Unmarshal(matrixJSON, &matrix); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal restricted goods matrix: %v", err)
+		}
+	}
+	return matrix, nil
+}
+
+// checkJurisdiction evaluates a category against the matrix for a destination
+// Returns an error only for unknown categories; a prohibited destination is a PROHIBITED result.
+func checkJurisdiction(
+	ctx contractapi.TransactionContextInterface,
+	category string,
+	destination Location,
+	currentTime string,
+) (*JurisdictionCheck, error) {
+	matrix, err := getRestrictedGoodsMatrix(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules, ok := matrix[category]
+	if !ok {
+		return nil, &ValidationError{Field: TransientGoodsCategory, Message: fmt.Sprintf("unknown restricted goods category: %s", category)}
+	}
+
+	check := &JurisdictionCheck{
+		Category:    category,
+		Destination: destination,
+		Result:      JurisdictionProhibited,
+		CheckedAt:   currentTime,
+	}
+	for i, rule := range rules {
+		if !strings.EqualFold(rule.Country, destination.Country) {
+			continue
+		}
+		if rule.State != "" && !strings.EqualFold(rule.State, destination.State) {
+			continue
+		}
+		// A state rule beats a country rule
+		if check.Rule == nil || (check.Rule.State == "" && rule.State != "") {
+			check.Rule = &rules[i]
+		}
+	}
+	if check.Rule != nil && check.Rule.Allowed {
+		check.Result = JurisdictionAllowed
+	}
+	return check, nil
+}
+
+// applyGoodsCategory checks a new delivery's optional restricted goods category (transient
+// "goodsCategory") against its destination and records the result on the delivery
+// Deliveries of prohibited goods fail with RESTRICTED_GOODS_PROHIBITED.
+func applyGoodsCategory(ctx contractapi.TransactionContextInterface, delivery *Delivery) error {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient data: %v", err)
+	}
+	categoryBytes, exists := transientMap[TransientGoodsCategory]
+	if !exists || len(categoryBytes) == 0 {
+		return nil
+	}
+
+	category := strings.ToUpper(strings.TrimSpace(string(categoryBytes)))
+	if !goodsCategoryPattern.MatchString(category) {
+		return &ValidationError{Field: TransientGoodsCategory, Message: "must be 1-32 characters of A-Z, 0-9 or '_'"}
+	}
+	// Template deliveries only know their origin
+	if delivery.Destination == nil {
+		return &ValidationError{Field: TransientGoodsCategory, Message: "restricted goods need a delivery with a destination"}
+	}
+	check, err := checkJurisdiction(ctx, category, *delivery.Destination, delivery.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if check.Result != JurisdictionAllowed {
+		return &RestrictedGoodsError{Check: check}
+	}
+
+	delivery.JurisdictionCheck = check
+	return nil
+}
+
+// SetRestrictedGoodsMatrix replaces the restricted goods matrix of the caller's tenant
+// Only an ADMIN without a region can set it. matrixJSON maps categories to JSON arrays of
+// {country, state, allowed} rules; leave state empty for a country-wide rule.
+func (c *DeliveryContract) SetRestrictedGoodsMatrix(
+	ctx contractapi.TransactionContextInterface,
+	matrixJSON string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	var matrix RestrictedGoodsMatrix
+	if err := json.Unmarshal([]byte(matrixJSON), &matrix); err != nil {
+		return fmt.Errorf("failed to parse restricted goods matrix: %v", err)
+	}
+	for category, rules := range matrix {
+		if !goodsCategoryPattern.MatchString(category) {
+			return &ValidationError{Field: "category", Message: fmt.Sprintf("invalid category name: %s", category)}
+		}
+		if len(rules) > maxJurisdictionRules {
+			return &ValidationError{Field: category, Message: fmt.Sprintf("cannot list more than %d rules", maxJurisdictionRules)}
+		}
+		seen := make(map[string]bool)
+		for i, rule := range rules {
+			_, rule.State, rule.Country = normalizeLocation("", rule.State, rule.Country)
+			if err := validateCountryCode(rule.Country); err != nil {
+				return err
+			}
+			if err := validateText(rule.State, "state", 100, false); err != nil {
+				return err
+			}
+			jurisdiction := strings.ToUpper(rule.Country + "/" + rule.State)
+			if seen[jurisdiction] {
+				return &ValidationError{Field: category, Message: fmt.Sprintf("duplicate rule for %s", jurisdiction)}
+			}
+			seen[jurisdiction] = true
+			rules[i] = rule
+		}
+		if rules == nil {
+			matrix[category] = []JurisdictionRule{}
+		}
+	}
+	if matrix == nil {
+		matrix = RestrictedGoodsMatrix{}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - jurisdiction rules apply marketplace-wide
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configRestrictedGoodsName})
+	if err != nil {
+		return fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	updatedJSON, err := json.Marshal(matrix)
+	if err != nil {
+		return fmt.Errorf("failed to marshal restricted goods matrix: %v", err)
+	}
+
+	return ctx.GetStub().PutState(configKey, updatedJSON)
+}
+
+// GetRestrictedGoodsMatrix returns the restricted goods matrix of the caller's tenant
+func (c *DeliveryContract) GetRestrictedGoodsMatrix(
+	ctx contractapi.TransactionContextInterface,
+) (RestrictedGoodsMatrix, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	return getRestrictedGoodsMatrix(ctx)
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RetentionAction is what happens to a delivery past its retention period
+type RetentionAction string
+
+const (
+	// RetentionArchive keeps a compact archive record with the history digest
+	RetentionArchive RetentionAction = "ARCHIVE"
+	// RetentionPurge removes the delivery without a trace in world state
+	RetentionPurge RetentionAction = "PURGE"
+)
+
+// configRetentionName is the config record holding the RetentionPolicy
+const configRetentionName = "retention"
+
+// RecordArchivedDelivery is the composite key prefix for archive records
+const RecordArchivedDelivery = "archive~deliveryId"
+
+// EventRetentionApplied is emitted once per ApplyRetention page
+const EventRetentionApplied = "RetentionApplied"
+
+// maxRetentionPageSize bounds the deliveries examined by one ApplyRetention call
+const maxRetentionPageSize = 200
+
+// retainableStatuses are the terminal statuses a retention rule may target
+var retainableStatuses = map[DeliveryStatus]bool{
+	StatusConfirmedDelivery: true,
+	StatusCancelled:         true,
+	StatusSplit:             true,
+	StatusMerged:            true,
+	StatusExported:          true,
+	StatusRedacted:          true,
+	StatusReturnCompleted:   true,
+}
+
+// deliveryRecordTypes are the per-delivery record families removed with the delivery
+// Anchors, milestones, export, settlement and insurance claim records stay so past commitments remain verifiable.
+var deliveryRecordTypes = []string{
+	RecordPackageAmendment,
+	RecordCorrection,
+	RecordDeliveryItem,
+	RecordItemDispute,
+	RecordDeliveryException,
+	RecordLocationHistory,
+	RecordTelemetry,
+	RecordTelemetryCursor,
+	RecordTelemetryThreshold,
+	RecordFeeQuote,
+	RecordAttachment,
+	RecordDeliveryNote,
+}
+
+// RetentionRule is the retention period and action for one status
+type RetentionRule struct {
+	RetentionDays int             `json:"retentionDays"`
+	Action        RetentionAction `json:"action"`
+}
+
+// RetentionPolicy maps terminal statuses to retention rules
+// Stored per tenant, since the ledger stub is tenant-namespaced
+type RetentionPolicy map[DeliveryStatus]RetentionRule
+
+// ArchivedDelivery is the compact record left behind by the ARCHIVE action
+type ArchivedDelivery struct {
+	DeliveryID    string         `json:"deliveryId"`
+	OrderID       string         `json:"orderId,omitempty"`
+	FinalStatus   DeliveryStatus `json:"finalStatus"`
+	HistoryDigest string         `json:"historyDigest"`
+	LastUpdatedAt string         `json:"lastUpdatedAt"`
+	ArchivedAt    string         `json:"archivedAt"`
+}
+
+// RetentionResult summarizes one ApplyRetention page
+// Pass Bookmark to the next call; an empty bookmark means the sweep is complete
+type RetentionResult struct {
+	Examined int      `json:"examined"`
+	Archived []string `json:"archived"`
+	Purged   []string `json:"purged"`
+	Bookmark string   `json:"bookmark"`
+}
+
+// getRetentionPolicy reads the configured retention policy (empty if none)
+func getRetentionPolicy(ctx contractapi.TransactionContextInterface) (RetentionPolicy, error) {
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configRetentionName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	policyJSON, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read retention policy: %v", err)
+	}
+
+	policy := RetentionPolicy{}
+	if policyJSON != nil {
+		if err := json.Unmarshal(policyJSON, &policy); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal retention policy: %v", err)
+		}
+	}
+	return policy, nil
+}
+
+// deleteDeliveryRecords removes the per-delivery record families of a delivery
+func deleteDeliveryRecords(ctx contractapi.TransactionContextInterface, deliveryID string) error {
+	stub := ctx.GetStub()
+	for _, recordType := range deliveryRecordTypes {
+		iterator, err := stub.GetStateByPartialCompositeKey(recordType, []string{deliveryID})
+		if err != nil {
+			return fmt.Errorf("failed to get %s records: %v", recordType, err)
+		}
+		var keys []string
+		for iterator.HasNext() {
+			response, err := iterator.Next()
+			if err != nil {
+				iterator.Close()
+				return fmt.Errorf("failed to iterate %s records: %v", recordType, err)
+			}
+			keys = append(keys, response.Key)
+		}
+		iterator.Close()
+
+		for _, key := range keys {
+			if err := stub.DelState(key); err != nil {
+				return fmt.Errorf("failed to delete %s record: %v", recordType, err)
+			}
+		}
+	}
+	return deleteDisputeCases(ctx, deliveryID)
+}
+
+// SetRetentionPolicy replaces the retention policy of the caller's tenant
+// Only ADMIN can set the policy; only terminal statuses can be targeted
+func (c *DeliveryContract) SetRetentionPolicy(
+	ctx contractapi.TransactionContextInterface,
+	policyJSON string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	var policy RetentionPolicy
+	if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+		return fmt.Errorf("failed to parse retention policy: %v", err)
+	}
+	for status, rule := range policy {
+		if !retainableStatuses[status] {
+			return &ValidationError{Field: string(status), Message: "retention can only target terminal statuses"}
+		}
+		if rule.RetentionDays <= 0 || rule.RetentionDays > 36500 {
+			return &ValidationError{Field: string(status), Message: "retentionDays must be between 1 and 36500"}
+		}
+		if rule.Action != RetentionArchive && rule.Action != RetentionPurge {
+			return &ValidationError{Field: string(status), Message: "action must be ARCHIVE or PURGE"}
+		}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can change retention
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configRetentionName})
+	if err != nil {
+		return fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	updatedJSON, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal retention policy: %v", err)
+	}
+
+	return ctx.GetStub().PutState(configKey, updatedJSON)
+}
+
+// GetRetentionPolicy returns the retention policy of the caller's tenant
+func (c *DeliveryContract) GetRetentionPolicy(
+	ctx contractapi.TransactionContextInterface,
+) (RetentionPolicy, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	return getRetentionPolicy(ctx)
+}
+
+// ApplyRetention sweeps one page of deliveries, archiving or purging those past retention
+// Only ADMIN can apply retention. Paginated queries are read-only in Fabric, so the page is a
+// range scan starting at bookmark; call again with the returned bookmark until it is empty.
+func (c *DeliveryContract) ApplyRetention(
+	ctx contractapi.TransactionContextInterface,
+	pageSize int,
+	bookmark string,
+) (*RetentionResult, error) {
+	// ========== INPUT VALIDATION ==========
+	if pageSize <= 0 || pageSize > maxRetentionPageSize {
+		return nil, &ValidationError{Field: "pageSize", Message: fmt.Sprintf("must be between 1 and %d", maxRetentionPageSize)}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can apply retention
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	policy, err := getRetentionPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(policy) == 0 {
+		return nil, fmt.Errorf("no retention policy configured")
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	currentTime := txTime.Format(time.RFC3339)
+
+	// Deliveries are the only simple keys; every other record is a composite key
+	iterator, err := ctx.GetStub().GetStateByRange(bookmark, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries: %v", err)
+	}
+
+	result := &RetentionResult{Archived: []string{}, Purged: []string{}}
+	var expired []*Delivery
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			iterator.Close()
+			return nil, fmt.Errorf("failed to iterate deliveries: %v", err)
+		}
+		if result.Examined == pageSize {
+			result.Bookmark = response.Key
+			break
+		}
+		result.Examined++
+
+		var delivery Delivery
+		if err := json.Unmarshal(response.Value, &delivery); err != nil {
+			continue
+		}
+		rule, ok := policy[delivery.DeliveryStatus]
+		if !ok {
+			continue
+		}
+		updatedAt, err := time.Parse(time.RFC3339, delivery.UpdatedAt)
+		if err != nil {
+			continue
+		}
+		if txTime.Before(updatedAt.AddDate(0, 0, rule.RetentionDays)) {
+			continue
+		}
+		// Regional admins only sweep deliveries headed for their region
+		if validateAdminScope(ctx, &delivery) != nil {
+			continue
+		}
+		expired = append(expired, &delivery)
+	}
+	iterator.Close()
+
+	for _, delivery := range expired {
+		action := policy[delivery.DeliveryStatus].Action
+
+		if action == RetentionArchive {
+			digest, err := computeStateDigest(ctx, delivery.DeliveryID, "")
+			if err != nil {
+				return nil, err
+			}
+			archive := ArchivedDelivery{
+				DeliveryID:    delivery.DeliveryID,
+				OrderID:       delivery.OrderID,
+				FinalStatus:   delivery.DeliveryStatus,
+				HistoryDigest: digest.Digest,
+				LastUpdatedAt: delivery.UpdatedAt,
+				ArchivedAt:    currentTime,
+			}
+			archiveKey, err := ctx.GetStub().CreateCompositeKey(RecordArchivedDelivery, []string{delivery.DeliveryID})
+			if err != nil {
+				return nil, fmt.Errorf("failed to create archive composite key: %v", err)
+			}
+			archiveJSON, err := json.Marshal(archive)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal archive record: %v", err)
+			}
+			if err := ctx.GetStub().PutState(archiveKey, archiveJSON); err != nil {
+				return nil, fmt.Errorf("failed to put archive record: %v", err)
+			}
+		}
+
+		if err := deleteDeliveryIndexes(ctx, delivery); err != nil {
+			return nil, err
+		}
+		if err := deleteDeliveryRecords(ctx, delivery.DeliveryID); err != nil {
+			return nil, err
+		}
+		for _, collection := range []string{CollectionDeliveryPrivate, CollectionContentsManifest, CollectionAgeVerification} {
+			if err := ctx.GetStub().PurgePrivateData(collection, delivery.DeliveryID); err != nil {
+				return nil, fmt.Errorf("failed to purge %s: %v", collection, err)
+			}
+		}
+		if err := ctx.GetStub().DelState(delivery.DeliveryID); err != nil {
+			return nil, fmt.Errorf("failed to delete delivery %s: %v", delivery.DeliveryID, err)
+		}
+		addDeliveryProjection(ctx, &DeliveryProjection{
+			Version:    projectionVersion,
+			TenantID:   delivery.TenantID,
+			DeliveryID: delivery.DeliveryID,
+			UpdatedAt:  currentTime,
+			TxID:       ctx.GetStub().GetTxID(),
+			Removed:    true,
+		})
+
+		if action == RetentionArchive {
+			result.Archived = append(result.Archived, delivery.DeliveryID)
+		} else {
+			result.Purged = append(result.Purged, delivery.DeliveryID)
+		}
+	}
+
+	err = emitEvent(ctx, EventRetentionApplied, map[string]interface{}{
+		"examined":  result.Examined,
+		"archived":  result.Archived,
+		"purged":    result.Purged,
+		"bookmark":  result.Bookmark,
+		"appliedBy": caller.ID,
+		"timestamp": currentTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Event names for recall/return flows
+const (
+	EventRecallRequested    = "RecallRequested"
+	EventRecallAcknowledged = "RecallAcknowledged"
+	EventReturnCompleted    = "ReturnCompleted"
+	EventRecallBroadcast    = "RecallBroadcast"
+)
+
+// maxRecallPageSize bounds the deliveries examined by one AdminBroadcastRecall call
+const maxRecallPageSize = 100
+
+// broadcastRecallStatuses are the in-flight statuses a safety recall pulls back
+// Deliveries mid-handoff are skipped until the handoff is confirmed, disputed or cancelled.
+var broadcastRecallStatuses = map[DeliveryStatus]bool{
+	StatusInTransit:      true,
+	StatusOutForDelivery: true,
+}
+
+// ReturnCondition is the condition a returned package arrived in at the seller
+type ReturnCondition string
+
+const (
+	ReturnConditionGood     ReturnCondition = "GOOD"
+	ReturnConditionDamaged  ReturnCondition = "DAMAGED"
+	ReturnConditionTampered ReturnCondition = "TAMPERED"
+)
+
+// RestockDisposition is what the seller does with a returned package
+type RestockDisposition string
+
+const (
+	DispositionRestock   RestockDisposition = "RESTOCK"
+	DispositionRefurbish RestockDisposition = "REFURBISH"
+	DispositionLiquidate RestockDisposition = "LIQUIDATE"
+	DispositionDispose   RestockDisposition = "DISPOSE"
+)
+
+// RecallInfo tracks a seller-initiated recall of an in-transit package
+type RecallInfo struct {
+	RequestedBy    string `json:"requestedBy"`
+	Reason         string `json:"reason"`
+	RequestedAt    string `json:"requestedAt"`
+	Broadcast      bool   `json:"broadcast,omitempty" metadata:",optional"` // part of an AdminBroadcastRecall
+	AcknowledgedBy string `json:"acknowledgedBy,omitempty" metadata:",optional"`
+	AcknowledgedAt string `json:"acknowledgedAt,omitempty" metadata:",optional"`
+}
+
+// RecallNotice is the per-delivery part of a RecallBroadcast event
+type RecallNotice struct {
+	DeliveryID  string         `json:"deliveryId"`
+	OrderID     string         `json:"orderId"`
+	SellerID    string         `json:"sellerId"`
+	CustodianID string         `json:"custodianId"`
+	OldStatus   DeliveryStatus `json:"oldStatus"`
+}
+
+// BroadcastRecallResult summarizes one AdminBroadcastRecall page
+// Pass Bookmark to the next call; an empty bookmark means every matching delivery was examined
+type BroadcastRecallResult struct {
+	Examined int             `json:"examined"`
+	Recalled []*RecallNotice `json:"recalled"`
+	Skipped  []string        `json:"skipped"`
+	Bookmark string          `json:"bookmark"`
+}
+
+// ReturnReceipt records a returned package's arrival back at the seller
+type ReturnReceipt struct {
+	ReceivedBy   string             `json:"receivedBy"`
+	ReceivedFrom string             `json:"receivedFrom"`
+	Condition    ReturnCondition    `json:"condition"`
+	Disposition  RestockDisposition `json:"disposition,omitempty" metadata:",optional"`
+	ReceivedAt   string             `json:"receivedAt"`
+}
+
+// RecallDelivery flags an in-transit package for return to the seller
+// Only the SELLER of the delivery can recall, and only while IN_TRANSIT or QUARANTINED;
+// an ADMIN can also turn a quarantined delivery into a return
+func (c *DeliveryContract) RecallDelivery(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	reason string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	reason = sanitizeText(reason)
+	if err := validateReason(reason); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - SELLER recalls, ADMIN only returns quarantined goods
+	if err := validateRole(ctx, caller, RoleSeller, RoleAdmin); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	// Verify caller is the seller for this delivery
+	if caller.Role == RoleSeller && delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller can recall this delivery")
+	}
+
+	// Pending handoffs must be cancelled before the package can be recalled
+	if caller.Role == RoleAdmin && delivery.DeliveryStatus != StatusQuarantined {
+		return fmt.Errorf("admins can only recall a quarantined delivery")
+	}
+	if delivery.DeliveryStatus != StatusInTransit && delivery.DeliveryStatus != StatusQuarantined {
+		return fmt.Errorf("can only recall a delivery that is in transit or quarantined")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	oldStatus := delivery.DeliveryStatus
+
+	delivery.Recall = &RecallInfo{
+		RequestedBy: caller.ID,
+		Reason:      reason,
+		RequestedAt: currentTime,
+	}
+	delivery.DeliveryStatus = StatusRecallPending
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	// Fabric keeps one event per transaction, so the recall event carries the status change
+	return emitEvent(ctx, EventRecallRequested, map[string]string{
+		"deliveryId":  deliveryID,
+		"orderId":     delivery.OrderID,
+		"oldStatus":   string(oldStatus),
+		"newStatus":   string(delivery.DeliveryStatus),
+		"requestedBy": caller.ID,
+		"custodianId": delivery.CurrentCustodianID,
+		"reason":      reason,
+		"timestamp":   currentTime,
+	})
+}
+
+// AcknowledgeRecall confirms a recall and starts the return leg
+// Only the current DELIVERY_PERSON custodian can acknowledge
+func (c *DeliveryContract) AcknowledgeRecall(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only DELIVERY_PERSON can acknowledge
+	if err := validateRole(ctx, caller, RoleDeliveryPerson); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	// Must be current custodian
+	if delivery.CurrentCustodianID != caller.ID {
+		return fmt.Errorf("only the current custodian can acknowledge a recall")
+	}
+
+	if delivery.DeliveryStatus != StatusRecallPending || delivery.Recall == nil {
+		return fmt.Errorf("no pending recall for this delivery")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	oldStatus := delivery.DeliveryStatus
+
+	delivery.Recall.AcknowledgedBy = caller.ID
+	delivery.Recall.AcknowledgedAt = currentTime
+	delivery.DeliveryStatus = StatusReturnInTransit
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	// Fabric keeps one event per transaction, so the acknowledgement carries the status change
+	return emitEvent(ctx, EventRecallAcknowledged, map[string]string{
+		"deliveryId":     deliveryID,
+		"orderId":        delivery.OrderID,
+		"oldStatus":      string(oldStatus),
+		"newStatus":      string(delivery.DeliveryStatus),
+		"acknowledgedBy": caller.ID,
+		"timestamp":      currentTime,
+	})
+}
+
+// ConfirmReturnReceipt closes a return once the package is back with the seller
+// Only the SELLER of the delivery can confirm, from RETURN_IN_TRANSIT. The seller records the
+// condition on arrival and, optionally, a restocking disposition; custody moves to the seller
+// and the delivery ends in RETURN_COMPLETED so the orders system can issue the refund.
+func (c *DeliveryContract) ConfirmReturnReceipt(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	condition string,
+	disposition string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	arrivalCondition := ReturnCondition(condition)
+	switch arrivalCondition {
+	case ReturnConditionGood, ReturnConditionDamaged, ReturnConditionTampered:
+	default:
+		return &ValidationError{Field: "condition", Message: "must be GOOD, DAMAGED or TAMPERED"}
+	}
+	restock := RestockDisposition(disposition)
+	switch restock {
+	case "", DispositionRestock, DispositionRefurbish, DispositionLiquidate, DispositionDispose:
+	default:
+		return &ValidationError{Field: "disposition", Message: "must be RESTOCK, REFURBISH, LIQUIDATE, DISPOSE or empty"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER receives returns
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	// Verify caller is the seller for this delivery
+	if delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller can confirm receipt of this return")
+	}
+	if delivery.DeliveryStatus != StatusReturnInTransit {
+		return fmt.Errorf("can only confirm receipt of a delivery that is returning")
+	}
+	if hasPendingReassignment(delivery) {
+		return fmt.Errorf("custody of this delivery is being transferred")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	oldStatus := delivery.DeliveryStatus
+
+	delivery.ReturnReceipt = &ReturnReceipt{
+		ReceivedBy:   caller.ID,
+		ReceivedFrom: delivery.CurrentCustodianID,
+		Condition:    arrivalCondition,
+		Disposition:  restock,
+		ReceivedAt:   currentTime,
+	}
+	delivery.CurrentCustodianID = caller.ID
+	delivery.CurrentCustodianRole = RoleSeller
+	delivery.CustodianMSP = caller.MSP
+	delivery.DeliveryStatus = StatusReturnCompleted
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	// The seller's org now endorses any further changes
+	if err := setDeliveryEndorsementPolicy(ctx, delivery); err != nil {
+		return fmt.Errorf("failed to update endorsement policy: %v", err)
+	}
+
+	// Fabric keeps one event per transaction, so the return event carries the status change
+	return emitEvent(ctx, EventReturnCompleted, map[string]string{
+		"deliveryId":   deliveryID,
+		"orderId":      delivery.OrderID,
+		"oldStatus":    string(oldStatus),
+		"newStatus":    string(delivery.DeliveryStatus),
+		"sellerId":     delivery.SellerID,
+		"customerId":   delivery.CustomerID,
+		"receivedFrom": delivery.ReturnReceipt.ReceivedFrom,
+		"condition":    string(arrivalCondition),
+		"disposition":  string(restock),
+		"timestamp":    currentTime,
+	})
+}
+
+// AdminBroadcastRecall recalls one page of in-flight deliveries for a product safety recall
+// Only ADMIN can broadcast. Exactly one of orderIDPrefix or sellerID selects the deliveries;
+// those IN_TRANSIT or OUT_FOR_DELIVERY move to RECALL_PENDING, and those mid-handoff are
+// returned as skipped. Paginated queries are read-only in Fabric, so pass the returned opaque
+// bookmark to the next call until it is empty. Fabric also keeps one event per transaction,
+// so each page emits a single RecallBroadcast event carrying a notice per recalled delivery.
+func (c *DeliveryContract) AdminBroadcastRecall(
+	ctx contractapi.TransactionContextInterface,
+	orderIDPrefix string,
+	sellerID string,
+	reason string,
+	pageSize int,
+	bookmark string,
+) (*BroadcastRecallResult, error) {
+	// ========== INPUT VALIDATION ==========
+	if (orderIDPrefix == "") == (sellerID == "") {
+		return nil, &ValidationError{Field: "orderIDPrefix", Message: "exactly one of orderIDPrefix or sellerID is required"}
+	}
+	if orderIDPrefix != "" {
+		if err := validateRequiredText(orderIDPrefix, "orderIDPrefix", 50); err != nil {
+			return nil, err
+		}
+	} else if err := validateUserID(sellerID, "sellerID"); err != nil {
+		return nil, err
+	}
+	reason = sanitizeText(reason)
+	if err := validateReason(reason); err != nil {
+		return nil, err
+	}
+	if pageSize <= 0 || pageSize > maxRecallPageSize {
+		return nil, &ValidationError{Field: "pageSize", Message: fmt.Sprintf("must be between 1 and %d", maxRecallPageSize)}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can broadcast recalls
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Order IDs are scanned in key order, so matches for a prefix are contiguous
+	stub := ctx.GetStub()
+	index, keys := IndexOrderDelivery, []string{}
+	if sellerID != "" {
+		index, keys = IndexSellerDelivery, []string{sellerID}
+	}
+	iterator, err := stub.GetStateByPartialCompositeKey(index, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s index: %v", index, err)
+	}
+	result := &BroadcastRecallResult{Recalled: []*RecallNotice{}, Skipped: []string{}}
+	var deliveryIDs []string
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			iterator.Close()
+			return nil, fmt.Errorf("failed to iterate %s index: %v", index, err)
+		}
+		if response.Key < bookmark {
+			continue
+		}
+		_, attrs, err := stub.SplitCompositeKey(response.Key)
+		if err != nil || len(attrs) != 2 {
+			continue
+		}
+		if orderIDPrefix != "" && !strings.HasPrefix(attrs[0], orderIDPrefix) {
+			if attrs[0] > orderIDPrefix {
+				break
+			}
+			continue
+		}
+		if result.Examined == pageSize {
+			result.Bookmark = response.Key
+			break
+		}
+		result.Examined++
+		deliveryIDs = append(deliveryIDs, attrs[1])
+	}
+	iterator.Close()
+
+	for _, deliveryID := range deliveryIDs {
+		delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+		if err != nil {
+			return nil, err
+		}
+		// Regional admins only recall deliveries headed for their region
+		if validateAdminScope(ctx, delivery) != nil {
+			continue
+		}
+		if delivery.PendingHandoff != nil {
+			result.Skipped = append(result.Skipped, deliveryID)
+			continue
+		}
+		if !broadcastRecallStatuses[delivery.DeliveryStatus] {
+			continue
+		}
+
+		notice := &RecallNotice{
+			DeliveryID:  deliveryID,
+			OrderID:     delivery.OrderID,
+			SellerID:    delivery.SellerID,
+			CustodianID: delivery.CurrentCustodianID,
+			OldStatus:   delivery.DeliveryStatus,
+		}
+		delivery.Recall = &RecallInfo{
+			RequestedBy: caller.ID,
+			Reason:      reason,
+			RequestedAt: currentTime,
+			Broadcast:   true,
+		}
+		delivery.DeliveryStatus = StatusRecallPending
+		delivery.UpdatedAt = currentTime
+
+		if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+			return nil, err
+		}
+		result.Recalled = append(result.Recalled, notice)
+	}
+
+	err = emitEvent(ctx, EventRecallBroadcast, map[string]interface{}{
+		"orderIdPrefix": orderIDPrefix,
+		"sellerId":      sellerID,
+		"reason":        reason,
+		"examined":      result.Examined,
+		"deliveries":    result.Recalled,
+		"skipped":       result.Skipped,
+		"bookmark":      result.Bookmark,
+		"requestedBy":   caller.ID,
+		"timestamp":     currentTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
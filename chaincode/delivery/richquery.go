@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxSelectorDepth bounds the nesting of a rich query selector
+const maxSelectorDepth = 8
+
+// richQueryIndexedFields are the fields covered by the CouchDB indexes in META-INF
+// $regex is only accepted on these; on other fields it forces a full database scan.
+var richQueryIndexedFields = map[string]bool{
+	"deliveryStatus":          true,
+	"createdAt":               true,
+	"updatedAt":               true,
+	"pendingHandoff.toUserId": true,
+	"docType":                 true,
+	"deliveryAddress.city":    true,
+	"deliveryAddress.state":   true,
+}
+
+// richQueryAllowedKeys are the top-level query keys callers may set
+// limit, skip and bookmark are controlled by the pagination parameters.
+var richQueryAllowedKeys = map[string]bool{
+	"selector":  true,
+	"sort":      true,
+	"fields":    true,
+	"use_index": true,
+}
+
+// DeliveryQueryPage is one page of a paginated delivery query
+type DeliveryQueryPage struct {
+	Deliveries   []*Delivery `json:"deliveries"`
+	Bookmark     string      `json:"bookmark"`
+	FetchedCount int32       `json:"fetchedCount"`
+}
+
+// validateRichQuery checks the structure of a caller-supplied CouchDB query
+func validateRichQuery(queryString string) error {
+	if queryString == "" {
+		return &ValidationError{Field: "queryString", Message: "cannot be empty"}
+	}
+
+	var query map[string]interface{}
+	if err := json.Unmarshal([]byte(queryString), &query); err != nil {
+		return &ValidationError{Field: "queryString", Message: fmt.Sprintf("must be a JSON object: %v", err)}
+	}
+	for key := range query {
+		if !richQueryAllowedKeys[key] {
+			return &ValidationError{Field: "queryString", Message: fmt.Sprintf("unsupported query key: %s", key)}
+		}
+	}
+
+	selector, ok := query["selector"].(map[string]interface{})
+	if !ok || len(selector) == 0 {
+		return &ValidationError{Field: "selector", Message: "must be a non-empty JSON object"}
+	}
+	return validateSelector(selector, "", 0)
+}
+
+// validateSelector walks a selector, tracking the field path each operator applies to
+func validateSelector(selector map[string]interface{}, field string, depth int) error {
+	if depth > maxSelectorDepth {
+		return &ValidationError{Field: "selector", Message: fmt.Sprintf("exceeds maximum nesting depth of %d", maxSelectorDepth)}
+	}
+
+	for key, value := range selector {
+		switch {
+		case key == "$regex":
+			if !richQueryIndexedFields[field] {
+				return &ValidationError{Field: "selector", Message: fmt.Sprintf("$regex is only allowed on indexed fields, not %q", field)}
+			}
+		case key == "$and" || key == "$or" || key == "$nor":
+			clauses, ok := value.([]interface{})
+			if !ok {
+				return &ValidationError{Field: "selector", Message: fmt.Sprintf("%s must be an array", key)}
+			}
+			for _, clause := range clauses {
+				clauseSelector, ok := clause.(map[string]interface{})
+				if !ok {
+					return &ValidationError{Field: "selector", Message: fmt.Sprintf("%s clauses must be objects", key)}
+				}
+				if err := validateSelector(clauseSelector, field, depth+1); err != nil {
+					return err
+				}
+			}
+		case strings.HasPrefix(key, "$"):
+			// Operators like $not and $elemMatch apply to the enclosing field
+			if nested, ok := value.(map[string]interface{}); ok {
+				if err := validateSelector(nested, field, depth+1); err != nil {
+					return err
+				}
+			}
+		default:
+			path := key
+			if field != "" {
+				path = field + "." + key
+			}
+			if nested, ok := value.(map[string]interface{}); ok {
+				if err := validateSelector(nested, path, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// couchQuery builds a CouchDB Mango query from typed parts
+// Every value is encoded by json.Marshal, so caller input can never change the query structure.
+type couchQuery struct {
+	selector map[string]interface{}
+	sort     []map[string]string
+	useIndex []string
+}
+
+// newCouchQuery starts an empty query
+func newCouchQuery() *couchQuery {
+	return &couchQuery{selector: map[string]interface{}{}}
+}
+
+// Eq matches documents whose field equals value
+func (q *couchQuery) Eq(field string, value interface{}) *couchQuery {
+	q.selector[field] = value
+	return q
+}
+
+// Op adds a comparison operator ($gt, $gte, $lt, $lte, $ne, $exists, ...) on a field
+// Several operators on the same field are combined.
+func (q *couchQuery) Op(field string, operator string, value interface{}) *couchQuery {
+	conditions, ok := q.selector[field].(map[string]interface{})
+	if !ok {
+		conditions = map[string]interface{}{}
+		q.selector[field] = conditions
+	}
+	conditions[operator] = value
+	return q
+}
+
+// Sort orders results by a field ("asc" or "desc")
+func (q *couchQuery) Sort(field string, direction string) *couchQuery {
+	q.sort = append(q.sort, map[string]string{field: direction})
+	return q
+}
+
+// UseIndex pins the query to an index from META-INF
+func (q *couchQuery) UseIndex(designDoc string, indexName string) *couchQuery {
+	q.useIndex = []string{"_design/" + designDoc, indexName}
+	return q
+}
+
+// String encodes the query for GetQueryResult
+func (q *couchQuery) String() (string, error) {
+	query := map[string]interface{}{"selector": q.selector}
+	if len(q.sort) > 0 {
+		query["sort"] = q.sort
+	}
+	if len(q.useIndex) > 0 {
+		query["use_index"] = q.useIndex
+	}
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return "", fmt.Errorf("failed to build query: %v", err)
+	}
+	return string(queryJSON), nil
+}
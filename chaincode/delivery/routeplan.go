@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordRouteDeviation is the composite key prefix for route deviations
+const RecordRouteDeviation = "routeDeviation~deliveryId~txId"
+
+// EventRouteDeviation is emitted when a location update is off the delivery's route plan
+const EventRouteDeviation = "RouteDeviation"
+
+// maxRouteStops bounds the stops of a route plan
+const maxRouteStops = 50
+
+// RouteDeviationAllowance is how far from a planned stop a reported city may be
+// Locations are city-level, so the allowance is an administrative area rather than a distance.
+type RouteDeviationAllowance string
+
+const (
+	// RouteAllowanceNone only accepts the planned cities
+	RouteAllowanceNone RouteDeviationAllowance = "NONE"
+	// RouteAllowanceState accepts any city in the state of a planned stop
+	RouteAllowanceState RouteDeviationAllowance = "STATE"
+	// RouteAllowanceCountry accepts any city in the country of a planned stop
+	RouteAllowanceCountry RouteDeviationAllowance = "COUNTRY"
+)
+
+// RoutePlan is the planned path of a delivery; its destination is always on route
+type RoutePlan struct {
+	Stops      []Location              `json:"stops"`
+	Allowance  RouteDeviationAllowance `json:"allowance"`
+	Deviations int                     `json:"deviations"`
+	PlannedBy  string                  `json:"plannedBy"`
+	PlannedAt  string                  `json:"plannedAt"`
+}
+
+// RouteDeviation is a location update reported off the route plan
+type RouteDeviation struct {
+	DeliveryID string                  `json:"deliveryId"`
+	TxID       string                  `json:"txId"`
+	Location   Location                `json:"location"`
+	Allowance  RouteDeviationAllowance `json:"allowance"`
+	ReportedBy string                  `json:"reportedBy"`
+	ReportedAt string                  `json:"reportedAt"`
+}
+
+// onRoute reports whether a location is within the allowance of a planned stop or the destination
+func (p *RoutePlan) onRoute(delivery *Delivery, location Location) bool {
+	stops := p.Stops
+	if delivery.Destination != nil {
+		stops = append(stops[:len(stops):len(stops)], *delivery.Destination)
+	}
+	for _, stop := range stops {
+		area := ServiceArea{City: stop.City, State: stop.State, Country: stop.Country}
+		switch p.Allowance {
+		case RouteAllowanceState:
+			area.City = ""
+		case RouteAllowanceCountry:
+			area.City, area.State = "", ""
+		}
+		if area.covers(location) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRouteDeviation records a RouteDeviation when a delivery with a route plan is reported
+// off route, returning it (nil when on route or without a plan)
+// The new location is still accepted: the deviation is an alert, not a rejection.
+func checkRouteDeviation(ctx contractapi.TransactionContextInterface, delivery *Delivery, caller *CallerIdentity, currentTime string) (*RouteDeviation, error) {
+	plan := delivery.RoutePlan
+	if plan == nil || plan.onRoute(delivery, delivery.LastLocation) {
+		return nil, nil
+	}
+
+	deviation := &RouteDeviation{
+		DeliveryID: delivery.DeliveryID,
+		TxID:       ctx.GetStub().GetTxID(),
+		Location:   delivery.LastLocation,
+		Allowance:  plan.Allowance,
+		ReportedBy: caller.ID,
+		ReportedAt: currentTime,
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(RecordRouteDeviation, []string{deviation.DeliveryID, deviation.TxID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create route deviation composite key: %v", err)
+	}
+	deviationJSON, err := json.Marshal(deviation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal route deviation: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, deviationJSON); err != nil {
+		return nil, fmt.Errorf("failed to put route deviation: %v", err)
+	}
+
+	plan.Deviations++
+	return deviation, nil
+}
+
+// SetRoutePlan attaches (or replaces) the route plan of a delivery
+// The SELLER can plan before pickup; the current DELIVERY_PERSON or WAREHOUSE custodian can
+// replan in transit. stopsJSON is a JSON array of {city, state, country}; allowance is NONE,
+// STATE or COUNTRY. Replanning keeps the deviation count.
+func (c *DeliveryContract) SetRoutePlan(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	stopsJSON string,
+	allowance string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	var stops []Location
+	if err := json.Unmarshal([]byte(stopsJSON), &stops); err != nil {
+		return fmt.Errorf("failed to parse route stops: %v", err)
+	}
+	if len(stops) == 0 || len(stops) > maxRouteStops {
+		return &ValidationError{Field: "stops", Message: fmt.Sprintf("must list between 1 and %d stops", maxRouteStops)}
+	}
+	for i := range stops {
+		stop := &stops[i]
+		stop.City, stop.State, stop.Country = normalizeLocation(stop.City, stop.State, stop.Country)
+		if err := validateLocation(stop.City, stop.State, stop.Country); err != nil {
+			return err
+		}
+	}
+	routeAllowance := RouteDeviationAllowance(allowance)
+	if routeAllowance == "" {
+		routeAllowance = RouteAllowanceNone
+	}
+	switch routeAllowance {
+	case RouteAllowanceNone, RouteAllowanceState, RouteAllowanceCountry:
+	default:
+		return &ValidationError{Field: "allowance", Message: "must be NONE, STATE or COUNTRY"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - sellers plan, custodians replan
+	if err := validateRole(ctx, caller, RoleSeller, RoleDeliveryPerson, RoleWarehouse); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if caller.Role == RoleSeller {
+		if delivery.SellerID != caller.ID {
+			return fmt.Errorf("only the seller can plan the route of this delivery")
+		}
+		if delivery.DeliveryStatus != StatusPendingPickup {
+			return fmt.Errorf("sellers can only plan a route before pickup")
+		}
+	} else {
+		if delivery.CurrentCustodianID != caller.ID || delivery.CurrentCustodianRole != caller.Role {
+			return fmt.Errorf("only the current custodian can replan the route")
+		}
+		if !locationUpdateStatuses[delivery.DeliveryStatus] {
+			return fmt.Errorf("cannot replan the route in current status: %s", delivery.DeliveryStatus)
+		}
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	plan := &RoutePlan{
+		Stops:     stops,
+		Allowance: routeAllowance,
+		PlannedBy: caller.ID,
+		PlannedAt: currentTime,
+	}
+	if delivery.RoutePlan != nil {
+		plan.Deviations = delivery.RoutePlan.Deviations
+	}
+	delivery.RoutePlan = plan
+	delivery.UpdatedAt = currentTime
+
+	return applyDeliveryUpdate(ctx, delivery)
+}
+
+// GetRouteDeviations returns the location updates a delivery received off its route plan
+func (c *DeliveryContract) GetRouteDeviations(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*RouteDeviation, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordRouteDeviation, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get route deviations: %v", err)
+	}
+	defer iterator.Close()
+
+	deviations := []*RouteDeviation{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate route deviations: %v", err)
+		}
+		var deviation RouteDeviation
+		if err := json.Unmarshal(response.Value, &deviation); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal route deviation: %v", err)
+		}
+		deviations = append(deviations, &deviation)
+	}
+
+	return deviations, nil
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// CollectionSerialization holds the product serialization of pharma deliveries
+// Collection: deliverySerialization (PlatformOrg and SellersOrg only)
+const CollectionSerialization = "deliverySerialization"
+
+// Transient map keys for serialization data
+const (
+	// TransientSerialization carries the seller's SerializationData for SetSerialization
+	TransientSerialization = "serialization"
+	// TransientSerializationScan carries the recipient's scans at a handoff, keyed by delivery ID
+	TransientSerializationScan = "serializationScan"
+)
+
+// RecordSerializationCheck holds the serialization check of each custody change
+// Kept when a delivery is purged, like anchors, so the chain of custody stays verifiable.
+const RecordSerializationCheck = "serialCheck~deliveryId~txId"
+
+var (
+	gtinPattern = regexp.MustCompile(`^(\d{8}|\d{12,14})$`)
+	lotPattern  = regexp.MustCompile(`^[A-Za-z0-9\-./]{1,20}$`)
+)
+
+// SerializationData identifies the serialized unit in a package (GS1 GTIN, lot, expiry)
+// Only a hash of the serial number is kept; the serial itself stays with the seller.
+type SerializationData struct {
+	GTIN       string `json:"gtin"`
+	Lot        string `json:"lot"`
+	Expiry     string `json:"expiry"` // YYYY-MM-DD
+	SerialHash string `json:"serialHash"`
+}
+
+// SerializationCheck is one link of a delivery's serialized chain of custody: the recipient's
+// scan matched the seller's serialization when custody changed
+type SerializationCheck struct {
+	DeliveryID string   `json:"deliveryId"`
+	TxID       string   `json:"txId"`
+	FromUserID string   `json:"fromUserId"`
+	FromRole   UserRole `json:"fromRole"`
+	ToUserID   string   `json:"toUserId"`
+	ToRole     UserRole `json:"toRole"`
+	ScanHash   string   `json:"scanHash"`
+	CheckedAt  string   `json:"checkedAt"`
+}
+
+// ComplianceReport is a regulator's view of a serialized delivery
+type ComplianceReport struct {
+	DeliveryID        string                `json:"deliveryId"`
+	OrderID           string                `json:"orderId"`
+	SellerID          string                `json:"sellerId"`
+	CustomerID        string                `json:"customerId"`
+	DeliveryStatus    DeliveryStatus        `json:"deliveryStatus"`
+	Serialization     *SerializationData    `json:"serialization"`
+	SerializationHash string                `json:"serializationHash"`
+	CustodyChain      []*SerializationCheck `json:"custodyChain"`
+	GeneratedBy       string                `json:"generatedBy"`
+	GeneratedAt       string                `json:"generatedAt"`
+}
+
+// validateGTIN checks a GTIN-8/12/13/14 and its GS1 check digit
+func validateGTIN(gtin string) error {
+	if !gtinPattern.MatchString(gtin) {
+		return &ValidationError{Field: "gtin", Message: "must be 8, 12, 13 or 14 digits"}
+	}
+	sum := 0
+	for i := len(gtin) - 2; i >= 0; i-- {
+		digit := int(gtin[i] - '0')
+		// Weights alternate 3, 1, 3, ... from the digit left of the check digit
+		if (len(gtin)-2-i)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	if (10-sum%10)%10 != int(gtin[len(gtin)-1]-'0') {
+		return &ValidationError{Field: "gtin", Message: "has an invalid check digit"}
+	}
+	return nil
+}
+
+// canonicalSerialization validates serialization data and returns its canonical JSON
+// The seller's record and recipients' scans are hashed in this form, so equal data always
+// gives equal hashes regardless of field order or whitespace in the input.
+func canonicalSerialization(data *SerializationData) ([]byte, error) {
+	if err := validateGTIN(data.GTIN); err != nil {
+		return nil, err
+	}
+	if !lotPattern.MatchString(data.Lot) {
+		return nil, &ValidationError{Field: "lot", Message: "must be 1-20 letters, digits, '-', '.' or '/'"}
+	}
+	if _, err := time.Parse("2006-01-02", data.Expiry); err != nil {
+		return nil, &ValidationError{Field: "expiry", Message: "must be a date in YYYY-MM-DD format"}
+	}
+	if len(data.SerialHash) == 0 || len(data.SerialHash) > 128 {
+		return nil, &ValidationError{Field: "serialHash", Message: "must be between 1 and 128 characters"}
+	}
+	canonical, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal serialization data: %v", err)
+	}
+	return canonical, nil
+}
+
+// verifySerializationScan checks the recipient's scan of a serialized delivery at a handoff and
+// records the check in the delivery's chain of custody
+// Called by confirmHandoffInternal before custody changes; deliveries without serialization
+// are not checked. A mismatching scan rejects the confirmation - the recipient should dispute.
+func verifySerializationScan(ctx contractapi.TransactionContextInterface, delivery *Delivery, currentTime string) error {
+	if delivery.SerializationHash == "" {
+		return nil
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient data: %v", err)
+	}
+	scansJSON, exists := transientMap[TransientSerializationScan]
+	if !exists || len(scansJSON) == 0 {
+		return fmt.Errorf("serialized delivery %s requires a serialization scan (%s)", delivery.DeliveryID, TransientSerializationScan)
+	}
+	var scans map[string]*SerializationData
+	if err := json.Unmarshal(scansJSON, &scans); err != nil {
+		return fmt.Errorf("failed to parse serialization scans: %v", err)
+	}
+	scan, ok := scans[delivery.DeliveryID]
+	if !ok || scan == nil {
+		return fmt.Errorf("serialized delivery %s requires a serialization scan (%s)", delivery.DeliveryID, TransientSerializationScan)
+	}
+	canonical, err := canonicalSerialization(scan)
+	if err != nil {
+		return err
+	}
+	scanHash := fmt.Sprintf("%x", sha256.Sum256(canonical))
+	if scanHash != delivery.SerializationHash {
+		return fmt.Errorf("serialization scan does not match delivery %s", delivery.DeliveryID)
+	}
+
+	handoff := delivery.PendingHandoff
+	check := SerializationCheck{
+		DeliveryID: delivery.DeliveryID,
+		TxID:       ctx.GetStub().GetTxID(),
+		FromUserID: handoff.FromUserID,
+		FromRole:   handoff.FromRole,
+		ToUserID:   handoff.ToUserID,
+		ToRole:     handoff.ToRole,
+		ScanHash:   scanHash,
+		CheckedAt:  currentTime,
+	}
+	checkKey, err := ctx.GetStub().CreateCompositeKey(RecordSerializationCheck, []string{check.DeliveryID, check.TxID})
+	if err != nil {
+		return fmt.Errorf("failed to create serialization check composite key: %v", err)
+	}
+	checkJSON, err := json.Marshal(check)
+	if err != nil {
+		return fmt.Errorf("failed to marshal serialization check: %v", err)
+	}
+	if err := ctx.GetStub().PutState(checkKey, checkJSON); err != nil {
+		return fmt.Errorf("failed to put serialization check: %v", err)
+	}
+	return nil
+}
+
+// SetSerialization attaches pharma serialization data (transient "serialization") to a delivery
+// Only the SELLER can serialize, and only before pickup. The data goes to the restricted
+// deliverySerialization collection; the delivery keeps its hash, and every later custody
+// change must present a matching scan.
+func (c *DeliveryContract) SetSerialization(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient data: %v", err)
+	}
+	dataJSON, exists := transientMap[TransientSerialization]
+	if !exists || len(dataJSON) == 0 {
+		return &ValidationError{Field: TransientSerialization, Message: "serialization data is required in transient data"}
+	}
+	var data SerializationData
+	if err := json.Unmarshal(dataJSON, &data); err != nil {
+		return fmt.Errorf("failed to parse serialization data: %v", err)
+	}
+	canonical, err := canonicalSerialization(&data)
+	if err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER can serialize deliveries
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller can serialize this delivery")
+	}
+	if delivery.DeliveryStatus != StatusPendingPickup {
+		return fmt.Errorf("serialization can only be set before pickup")
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutPrivateData(CollectionSerialization, deliveryID, canonical); err != nil {
+		return fmt.Errorf("failed to store serialization data: %v", err)
+	}
+
+	// Equals the private data hash Fabric records for the collection key
+	delivery.SerializationHash = fmt.Sprintf("%x", sha256.Sum256(canonical))
+	delivery.UpdatedAt = currentTime
+
+	return applyDeliveryUpdate(ctx, delivery)
+}
+
+// ExportComplianceReport returns a serialized delivery's serialization and chain of custody
+// Only ADMIN and AUDITOR (regulators) can export, and the serialization must still match the
+// delivery's commitment. Each custody change in the chain is a verified recipient scan.
+func (c *DeliveryContract) ExportComplianceReport(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) (*ComplianceReport, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - compliance reports are for platform staff and regulators
+	if err := validateRole(ctx, caller, RoleAdmin, RoleAuditor); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.SerializationHash == "" {
+		return nil, fmt.Errorf("delivery %s is not serialized", deliveryID)
+	}
+
+	stub := ctx.GetStub()
+	dataJSON, err := stub.GetPrivateData(CollectionSerialization, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get serialization data: %v", err)
+	}
+	if dataJSON == nil || fmt.Sprintf("%x", sha256.Sum256(dataJSON)) != delivery.SerializationHash {
+		return nil, fmt.Errorf("serialization data does not match the commitment of delivery %s", deliveryID)
+	}
+	var data SerializationData
+	if err := json.Unmarshal(dataJSON, &data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal serialization data: %v", err)
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(RecordSerializationCheck, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get serialization checks: %v", err)
+	}
+	defer iterator.Close()
+
+	chain := []*SerializationCheck{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate serialization checks: %v", err)
+		}
+		var check SerializationCheck
+		if err := json.Unmarshal(response.Value, &check); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal serialization check: %v", err)
+		}
+		chain = append(chain, &check)
+	}
+
+	// Keys are ordered by transaction ID; order the chain by time instead
+	sort.SliceStable(chain, func(i, j int) bool {
+		return chain[i].CheckedAt < chain[j].CheckedAt
+	})
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ComplianceReport{
+		DeliveryID:        delivery.DeliveryID,
+		OrderID:           delivery.OrderID,
+		SellerID:          delivery.SellerID,
+		CustomerID:        delivery.CustomerID,
+		DeliveryStatus:    delivery.DeliveryStatus,
+		Serialization:     &data,
+		SerializationHash: delivery.SerializationHash,
+		CustodyChain:      chain,
+		GeneratedBy:       caller.ID,
+		GeneratedAt:       currentTime,
+	}, nil
+}
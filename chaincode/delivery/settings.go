@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordConfig is the composite key prefix for contract configuration records
+const RecordConfig = "config~name"
+
+// configSettingsName is the config record holding ContractSettings
+const configSettingsName = "settings"
+
+// ContractSettings holds admin-configurable contract parameters
+// Missing fields fall back to the defaults from defaultContractSettings
+type ContractSettings struct {
+	// Hours a customer has to confirm receipt before the delivery can be auto-confirmed
+	DeliveryConfirmationGraceHours int `json:"deliveryConfirmationGraceHours"`
+	// Maximum distance in meters between the courier and the destination geohash at final delivery
+	GeofenceRadiusMeters int `json:"geofenceRadiusMeters"`
+	// Whether out-of-zone final confirmations are flagged or rejected
+	GeofenceMode GeofenceMode `json:"geofenceMode"`
+	// Hours an admin has to resolve a dispute case before it is reported overdue
+	DisputeResolutionHours int `json:"disputeResolutionHours"`
+	// Largest page a rich query may request
+	RichQueryMaxResults int `json:"richQueryMaxResults"`
+	// Incentive points a courier earns for an on-time, dispute-free delivery (0 disables)
+	OnTimeDeliveryPoints int `json:"onTimeDeliveryPoints"`
+	// Incentive points for a late but dispute-free delivery (0 disables)
+	LateDeliveryPoints int `json:"lateDeliveryPoints"`
+	// Penalty points for the user whose handoff dispute was upheld (0 disables)
+	DisputeFaultPenaltyPoints int `json:"disputeFaultPenaltyPoints"`
+	// Percent a re-measured weight may deviate from the recorded one before it counts as a discrepancy
+	WeightDiscrepancyTolerancePercent int `json:"weightDiscrepancyTolerancePercent"`
+	// Number of weight discrepancies from which each new one is penalized
+	WeightDiscrepancyPenaltyThreshold int `json:"weightDiscrepancyPenaltyThreshold"`
+	// Penalty points per weight discrepancy at or above the threshold (0 disables)
+	WeightDiscrepancyPenaltyPoints int `json:"weightDiscrepancyPenaltyPoints"`
+	// Whether handoffs to a courier require the delivery's private details (address) to be set
+	RequirePrivateDetailsForCourier bool `json:"requirePrivateDetailsForCourier"`
+	// Distinct customers sharing one delivery address within the window that raise a fraud signal (0 disables)
+	FraudAddressCustomerThreshold int `json:"fraudAddressCustomerThreshold"`
+	// Hours over which customers sharing an address are counted
+	FraudAddressWindowHours int `json:"fraudAddressWindowHours"`
+	// Cancellations by one customer within the window that raise a fraud signal (0 disables)
+	FraudCancellationThreshold int `json:"fraudCancellationThreshold"`
+	// Hours over which a customer's cancellations are counted
+	FraudCancellationWindowHours int `json:"fraudCancellationWindowHours"`
+	// Hours a handoff confirmation code stays valid before it must be rotated
+	HandoffCodeTTLHours int `json:"handoffCodeTtlHours"`
+}
+
+// defaultContractSettings returns the settings used when none were configured
+func defaultContractSettings() ContractSettings {
+	return ContractSettings{
+		DeliveryConfirmationGraceHours:    72,
+		GeofenceRadiusMeters:              200,
+		GeofenceMode:                      GeofenceModeFlag,
+		DisputeResolutionHours:            120,
+		RichQueryMaxResults:               100,
+		OnTimeDeliveryPoints:              10,
+		LateDeliveryPoints:                0,
+		DisputeFaultPenaltyPoints:         20,
+		WeightDiscrepancyTolerancePercent: 10,
+		WeightDiscrepancyPenaltyThreshold: 3,
+		WeightDiscrepancyPenaltyPoints:    5,
+		RequirePrivateDetailsForCourier:   false,
+		FraudAddressCustomerThreshold:     0,
+		FraudAddressWindowHours:           720,
+		FraudCancellationThreshold:        0,
+		FraudCancellationWindowHours:      24,
+		HandoffCodeTTLHours:               24,
+	}
+}
+
+// validateContractSettings checks that configured values are within sane bounds
+func validateContractSettings(settings *ContractSettings) error {
+	if settings.DeliveryConfirmationGraceHours <= 0 || settings.DeliveryConfirmationGraceHours > 720 {
+		return &ValidationError{Field: "deliveryConfirmationGraceHours", Message: "must be between 1 and 720 hours"}
+	}
+	if settings.GeofenceRadiusMeters <= 0 || settings.GeofenceRadiusMeters > 50000 {
+		return &ValidationError{Field: "geofenceRadiusMeters", Message: "must be between 1 and 50000 meters"}
+	}
+	if settings.GeofenceMode != GeofenceModeFlag && settings.GeofenceMode != GeofenceModeReject {
+		return &ValidationError{Field: "geofenceMode", Message: "must be FLAG or REJECT"}
+	}
+	if settings.DisputeResolutionHours <= 0 || settings.DisputeResolutionHours > 2160 {
+		return &ValidationError{Field: "disputeResolutionHours", Message: "must be between 1 and 2160 hours"}
+	}
+	if settings.RichQueryMaxResults <= 0 || settings.RichQueryMaxResults > 1000 {
+		return &ValidationError{Field: "richQueryMaxResults", Message: "must be between 1 and 1000"}
+	}
+	if settings.OnTimeDeliveryPoints < 0 || settings.OnTimeDeliveryPoints > 1000 {
+		return &ValidationError{Field: "onTimeDeliveryPoints", Message: "must be between 0 and 1000"}
+	}
+	if settings.LateDeliveryPoints < 0 || settings.LateDeliveryPoints > settings.OnTimeDeliveryPoints {
+		return &ValidationError{Field: "lateDeliveryPoints", Message: "must be between 0 and onTimeDeliveryPoints"}
+	}
+	if settings.DisputeFaultPenaltyPoints < 0 || settings.DisputeFaultPenaltyPoints > 1000 {
+		return &ValidationError{Field: "disputeFaultPenaltyPoints", Message: "must be between 0 and 1000"}
+	}
+	if settings.WeightDiscrepancyTolerancePercent <= 0 || settings.WeightDiscrepancyTolerancePercent > 100 {
+		return &ValidationError{Field: "weightDiscrepancyTolerancePercent", Message: "must be between 1 and 100"}
+	}
+	if settings.WeightDiscrepancyPenaltyThreshold <= 0 || settings.WeightDiscrepancyPenaltyThreshold > 100 {
+		return &ValidationError{Field: "weightDiscrepancyPenaltyThreshold", Message: "must be between 1 and 100"}
+	}
+	if settings.WeightDiscrepancyPenaltyPoints < 0 || settings.WeightDiscrepancyPenaltyPoints > 1000 {
+		return &ValidationError{Field: "weightDiscrepancyPenaltyPoints", Message: "must be between 0 and 1000"}
+	}
+	if settings.FraudAddressCustomerThreshold < 0 || settings.FraudAddressCustomerThreshold == 1 || settings.FraudAddressCustomerThreshold > maxAddressSightings {
+		return &ValidationError{Field: "fraudAddressCustomerThreshold", Message: fmt.Sprintf("must be 0 or between 2 and %d", maxAddressSightings)}
+	}
+	if settings.FraudAddressWindowHours <= 0 || settings.FraudAddressWindowHours > 8760 {
+		return &ValidationError{Field: "fraudAddressWindowHours", Message: "must be between 1 and 8760 hours"}
+	}
+	if settings.FraudCancellationThreshold < 0 || settings.FraudCancellationThreshold > maxCancellationsTracked {
+		return &ValidationError{Field: "fraudCancellationThreshold", Message: fmt.Sprintf("must be between 0 and %d", maxCancellationsTracked)}
+	}
+	if settings.FraudCancellationWindowHours <= 0 || settings.FraudCancellationWindowHours > 720 {
+		return &ValidationError{Field: "fraudCancellationWindowHours", Message: "must be between 1 and 720 hours"}
+	}
+	if settings.HandoffCodeTTLHours <= 0 || settings.HandoffCodeTTLHours > 720 {
+		return &ValidationError{Field: "handoffCodeTtlHours", Message: "must be between 1 and 720 hours"}
+	}
+	return nil
+}
+
+// getContractSettings reads the configured settings, merged over the defaults
+func getContractSettings(ctx contractapi.TransactionContextInterface) (*ContractSettings, error) {
+	settings := defaultContractSettings()
+
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configSettingsName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	settingsJSON, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contract settings: %v", err)
+	}
+	if settingsJSON != nil {
+		if err := json.Unmarshal(settingsJSON, &settings); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal contract settings: %v", err)
+		}
+	}
+
+	return &settings, nil
+}
+
+// SetContractSettings updates the contract settings (partial updates are merged)
+// Only ADMIN can change settings
+func (c *DeliveryContract) SetContractSettings(
+	ctx contractapi.TransactionContextInterface,
+	settingsJSON string,
+) error {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can change settings
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	settings, err := getContractSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(settingsJSON), settings); err != nil {
+		return fmt.Errorf("failed to parse settings: %v", err)
+	}
+	if err := validateContractSettings(settings); err != nil {
+		return err
+	}
+
+	configKey, err := ctx.GetStub().CreateCompositeKey(RecordConfig, []string{configSettingsName})
+	if err != nil {
+		return fmt.Errorf("failed to create config composite key: %v", err)
+	}
+	updatedJSON, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal contract settings: %v", err)
+	}
+
+	return ctx.GetStub().PutState(configKey, updatedJSON)
+}
+
+// GetContractSettings returns the effective contract settings
+func (c *DeliveryContract) GetContractSettings(
+	ctx contractapi.TransactionContextInterface,
+) (*ContractSettings, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	return getContractSettings(ctx)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EventDeliveryStalled is emitted when operations mark a delivery stuck in its status
+const EventDeliveryStalled = "DeliveryStalled"
+
+// maxStalledResults bounds the deliveries returned by one QueryStalledDeliveries call
+const maxStalledResults = 200
+
+// maxStallThresholdHours bounds stall thresholds
+const maxStallThresholdHours = 8760
+
+// stallableStatuses are the statuses a delivery is expected to leave, so it can stall in them
+var stallableStatuses = map[DeliveryStatus]bool{
+	StatusPendingPickup:               true,
+	StatusPendingPickupHandoff:        true,
+	StatusDisputedPickupHandoff:       true,
+	StatusInTransit:                   true,
+	StatusPendingTransitHandoff:       true,
+	StatusDisputedTransitHandoff:      true,
+	StatusPendingDeliveryConfirmation: true,
+	StatusDisputedDelivery:            true,
+	StatusRecallPending:               true,
+	StatusReturnInTransit:             true,
+	StatusOffNetworkTransit:           true,
+	StatusOutForDelivery:              true,
+	StatusQuarantined:                 true,
+}
+
+// StalledDelivery is a delivery that has been in its status longer than the threshold
+type StalledDelivery struct {
+	DeliveryID     string         `json:"deliveryId"`
+	OrderID        string         `json:"orderId"`
+	Status         DeliveryStatus `json:"status"`
+	StatusSince    string         `json:"statusSince"`
+	HoursInStatus  float64        `json:"hoursInStatus"`
+	ThresholdHours int            `json:"thresholdHours"`
+	CustodianID    string         `json:"custodianId"`
+	StalledAt      string         `json:"stalledAt,omitempty" metadata:",optional"`
+}
+
+// StalledDeliveries is the result of a stalled delivery query
+type StalledDeliveries struct {
+	Deliveries []*StalledDelivery `json:"deliveries"`
+	// Set when more deliveries were stalled than maxStalledResults
+	Truncated bool `json:"truncated"`
+}
+
+// statusSince returns when a delivery entered its current status
+// Deliveries last written before status times were tracked fall back to their last update.
+func statusSince(delivery *Delivery) string {
+	if delivery.StatusSince != "" {
+		return delivery.StatusSince
+	}
+	return delivery.UpdatedAt
+}
+
+// trackStatusTime stamps when a delivery entered its status and adds the time spent in the
+// status it left to its per-status totals (in seconds)
+// Called by applyDeliveryUpdate before the write; a status change also clears the stall mark.
+func trackStatusTime(previous *Delivery, delivery *Delivery) {
+	if delivery.Tombstone != nil {
+		return
+	}
+	if previous == nil {
+		delivery.StatusSince = delivery.UpdatedAt
+		return
+	}
+	if previous.DeliveryStatus == delivery.DeliveryStatus {
+		return
+	}
+
+	since, errSince := time.Parse(time.RFC3339, statusSince(previous))
+	left, errLeft := time.Parse(time.RFC3339, delivery.UpdatedAt)
+	if errSince == nil && errLeft == nil && left.After(since) {
+		if delivery.StatusDurations == nil {
+			delivery.StatusDurations = map[DeliveryStatus]int64{}
+		}
+		delivery.StatusDurations[previous.DeliveryStatus] += int64(left.Sub(since) / time.Second)
+	}
+	delivery.StatusSince = delivery.UpdatedAt
+	delivery.StalledAt = ""
+}
+
+// stalledFor returns the stall entry of a delivery past the threshold (nil if it isn't)
+func stalledFor(delivery *Delivery, thresholdHours int, now time.Time) *StalledDelivery {
+	sinceText := statusSince(delivery)
+	since, err := time.Parse(time.RFC3339, sinceText)
+	if err != nil {
+		return nil
+	}
+	hours := now.Sub(since).Hours()
+	if hours <= float64(thresholdHours) {
+		return nil
+	}
+	return &StalledDelivery{
+		DeliveryID:     delivery.DeliveryID,
+		OrderID:        delivery.OrderID,
+		Status:         delivery.DeliveryStatus,
+		StatusSince:    sinceText,
+		HoursInStatus:  float64(int64(hours*10)) / 10,
+		ThresholdHours: thresholdHours,
+		CustodianID:    delivery.CurrentCustodianID,
+		StalledAt:      delivery.StalledAt,
+	}
+}
+
+// validateStallThreshold checks a status and its stall threshold
+func validateStallThreshold(status DeliveryStatus, hours int) error {
+	if !stallableStatuses[status] {
+		return &ValidationError{Field: string(status), Message: "deliveries cannot stall in this status"}
+	}
+	if hours <= 0 || hours > maxStallThresholdHours {
+		return &ValidationError{Field: string(status), Message: fmt.Sprintf("threshold must be between 1 and %d hours", maxStallThresholdHours)}
+	}
+	return nil
+}
+
+// QueryStalledDeliveries lists deliveries that have been in a status longer than its threshold
+// statusThresholdsJSON maps statuses to hours, e.g. {"PENDING_PICKUP_HANDOFF": 48}. Only ADMIN
+// (within its region) and SUPPORT can query; results are ordered by status, then delivery ID.
+func (c *DeliveryContract) QueryStalledDeliveries(
+	ctx contractapi.TransactionContextInterface,
+	statusThresholdsJSON string,
+) (*StalledDeliveries, error) {
+	// ========== INPUT VALIDATION ==========
+	var thresholds map[DeliveryStatus]int
+	if err := json.Unmarshal([]byte(statusThresholdsJSON), &thresholds); err != nil {
+		return nil, fmt.Errorf("failed to parse status thresholds: %v", err)
+	}
+	if len(thresholds) == 0 {
+		return nil, &ValidationError{Field: "statusThresholds", Message: "must name at least one status"}
+	}
+	statuses := make([]string, 0, len(thresholds))
+	for status, hours := range thresholds {
+		if err := validateStallThreshold(status, hours); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, string(status))
+	}
+	sort.Strings(statuses)
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - operations staff watch for stuck deliveries
+	if err := validateRole(ctx, caller, RoleAdmin, RoleSupport); err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StalledDeliveries{Deliveries: []*StalledDelivery{}}
+	for _, status := range statuses {
+		iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(IndexStatusDelivery, []string{status})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query deliveries by status: %v", err)
+		}
+		for iterator.HasNext() {
+			response, err := iterator.Next()
+			if err != nil {
+				iterator.Close()
+				return nil, fmt.Errorf("failed to iterate deliveries by status: %v", err)
+			}
+			_, attrs, err := ctx.GetStub().SplitCompositeKey(response.Key)
+			if err != nil || len(attrs) != 2 {
+				continue
+			}
+			delivery, err := c.readDeliveryInternal(ctx, attrs[1])
+			if err != nil || delivery.TenantID != caller.TenantID || string(delivery.DeliveryStatus) != status {
+				continue
+			}
+			if validateAdminScope(ctx, delivery) != nil {
+				continue
+			}
+			stalled := stalledFor(delivery, thresholds[DeliveryStatus(status)], now)
+			if stalled == nil {
+				continue
+			}
+			if len(result.Deliveries) >= maxStalledResults {
+				result.Truncated = true
+				break
+			}
+			result.Deliveries = append(result.Deliveries, stalled)
+		}
+		iterator.Close()
+		if result.Truncated {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// MarkStalled flags a delivery that has been in its status longer than thresholdHours and
+// emits a DeliveryStalled alert for operations
+// Only ADMIN and SUPPORT can mark; a delivery is marked once per status, and the mark clears
+// when its status changes.
+func (c *DeliveryContract) MarkStalled(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	thresholdHours int,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - operations staff watch for stuck deliveries
+	if err := validateRole(ctx, caller, RoleAdmin, RoleSupport); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.TenantID != caller.TenantID {
+		return fmt.Errorf("not authorized to access this delivery")
+	}
+	if err := validateStallThreshold(delivery.DeliveryStatus, thresholdHours); err != nil {
+		return err
+	}
+	if delivery.StalledAt != "" {
+		return fmt.Errorf("delivery %s was already marked stalled in %s at %s", deliveryID, delivery.DeliveryStatus, delivery.StalledAt)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	stalled := stalledFor(delivery, thresholdHours, now)
+	if stalled == nil {
+		return fmt.Errorf("delivery %s has not been in %s for more than %d hours", deliveryID, delivery.DeliveryStatus, thresholdHours)
+	}
+
+	currentTime := now.Format(time.RFC3339)
+	delivery.StalledAt = currentTime
+	delivery.UpdatedAt = currentTime
+
+	if err := applyDeliveryUpdate(ctx, delivery); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventDeliveryStalled, map[string]string{
+		"deliveryId":     deliveryID,
+		"orderId":        delivery.OrderID,
+		"status":         string(delivery.DeliveryStatus),
+		"statusSince":    stalled.StatusSince,
+		"hoursInStatus":  fmt.Sprintf("%.1f", stalled.HoursInStatus),
+		"thresholdHours": fmt.Sprintf("%d", thresholdHours),
+		"custodianId":    delivery.CurrentCustodianID,
+		"sellerId":       delivery.SellerID,
+		"markedBy":       caller.ID,
+		"timestamp":      currentTime,
+	})
+}
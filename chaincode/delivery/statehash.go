@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// DeliveryReadResult is the response of ReadDeliveryIfChanged
+// Delivery is omitted when the caller's copy is still current.
+type DeliveryReadResult struct {
+	Unchanged bool      `json:"unchanged"`
+	StateHash string    `json:"stateHash"`
+	Delivery  *Delivery `json:"delivery,omitempty" metadata:",optional"`
+}
+
+// deliveryStateHash returns the ETag-style hash of a delivery's stored JSON
+// The stored bytes are produced by json.Marshal of the struct, so equal state gives an equal hash.
+func deliveryStateHash(deliveryJSON []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(deliveryJSON))
+}
+
+// ReadDeliveryIfChanged returns a delivery unless it still matches the caller's state hash
+// Same access rules as ReadDelivery. Pass the stateHash of the last response as notModifiedSince;
+// an empty value always returns the delivery.
+func (c *DeliveryContract) ReadDeliveryIfChanged(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	notModifiedSince string,
+) (*DeliveryReadResult, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+	if notModifiedSince != "" && !identityHashPattern.MatchString(notModifiedSince) {
+		return nil, &ValidationError{Field: "notModifiedSince", Message: "must be a stateHash returned by a previous read"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - all roles can read
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	// Access is checked before comparing, so the hash never leaks to uninvolved callers
+	delivery, err := readDeliveryForCaller(ctx, caller, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if delivery.StateHash == notModifiedSince {
+		return &DeliveryReadResult{Unchanged: true, StateHash: delivery.StateHash}, nil
+	}
+	return &DeliveryReadResult{StateHash: delivery.StateHash, Delivery: delivery}, nil
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TelemetryType is the kind of sensor reading reported by an IoT gateway
+type TelemetryType string
+
+const (
+	TelemetryShock    TelemetryType = "SHOCK"
+	TelemetryHumidity TelemetryType = "HUMIDITY"
+	TelemetryDoorOpen TelemetryType = "DOOR_OPEN"
+	// TelemetryTemperature is in °C; exceeding its threshold breaks the cold chain and
+	// quarantines the delivery
+	TelemetryTemperature TelemetryType = "TEMPERATURE"
+)
+
+// Composite key prefixes for telemetry records
+const (
+	RecordTelemetry          = "telemetry~deliveryId~slot"
+	RecordTelemetryCursor    = "telemetryCursor~deliveryId"
+	RecordTelemetryThreshold = "telemetryThreshold~deliveryId"
+)
+
+// EventTelemetryAlert is emitted when a reading exceeds the delivery's threshold
+const EventTelemetryAlert = "TelemetryAlert"
+
+// maxTelemetryReadings bounds the readings kept per delivery; older slots are overwritten
+const maxTelemetryReadings = 200
+
+// TelemetryReading is a single sensor reading attached to a delivery
+type TelemetryReading struct {
+	DeliveryID string        `json:"deliveryId"`
+	Sequence   int           `json:"sequence"`
+	GatewayID  string        `json:"gatewayId"`
+	Type       TelemetryType `json:"type"`
+	Value      float64       `json:"value"`
+	Violation  bool          `json:"violation"`
+	RecordedAt string        `json:"recordedAt"`
+}
+
+// TelemetryThresholds maps a reading type to the maximum allowed value
+// DOOR_OPEN readings carry 1 per opening, so a threshold of 0 alerts on any opening
+type TelemetryThresholds map[TelemetryType]float64
+
+// validateTelemetryType checks if a telemetry type is one of the known values
+func validateTelemetryType(telemetryType TelemetryType) error {
+	switch telemetryType {
+	case TelemetryShock, TelemetryHumidity, TelemetryDoorOpen, TelemetryTemperature:
+		return nil
+	}
+	return &ValidationError{Field: "telemetryType", Message: fmt.Sprintf("unknown telemetry type: %s", telemetryType)}
+}
+
+// getTelemetryThresholds reads the thresholds configured for a delivery (empty if none)
+func getTelemetryThresholds(ctx contractapi.TransactionContextInterface, deliveryID string) (TelemetryThresholds, error) {
+	thresholdKey, err := ctx.GetStub().CreateCompositeKey(RecordTelemetryThreshold, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create telemetry threshold composite key: %v", err)
+	}
+	thresholdJSON, err := ctx.GetStub().GetState(thresholdKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read telemetry thresholds: %v", err)
+	}
+
+	thresholds := TelemetryThresholds{}
+	if thresholdJSON != nil {
+		if err := json.Unmarshal(thresholdJSON, &thresholds); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal telemetry thresholds: %v", err)
+		}
+	}
+	return thresholds, nil
+}
+
+// RegisterSensorGateway registers an IoT gateway identity in the user registry
+// Only ADMIN can register gateways; unregistered SENSOR identities cannot record telemetry.
+// custodianID is the courier or warehouse the gateway is installed with: only its readings on
+// deliveries that custodian holds can quarantine them. Registering again moves the gateway.
+func (c *DeliveryContract) RegisterSensorGateway(
+	ctx contractapi.TransactionContextInterface,
+	gatewayID string,
+	mspID string,
+	custodianID string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateUserID(gatewayID, "gatewayID"); err != nil {
+		return err
+	}
+	if err := validateUserID(custodianID, "custodianID"); err != nil {
+		return err
+	}
+	if mspID != MSPPlatform && mspID != MSPSellers && mspID != MSPLogistics {
+		return &ValidationError{Field: "mspID", Message: fmt.Sprintf("unknown organization: %s", mspID)}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only an ADMIN of the whole marketplace can register gateways
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	existing, err := getUserProfile(ctx, gatewayID)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Role != RoleSensor {
+		return fmt.Errorf("user %s is already registered with role %s", gatewayID, existing.Role)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putUserProfile(ctx, &UserProfile{
+		UserID:      gatewayID,
+		Role:        RoleSensor,
+		MSP:         mspID,
+		CustodianID: custodianID,
+		UpdatedAt:   currentTime,
+	})
+}
+
+// SetTelemetryThresholds configures the alert thresholds for a delivery's sensor readings
+// Only the SELLER of the delivery can set thresholds
+func (c *DeliveryContract) SetTelemetryThresholds(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	thresholdsJSON string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+
+	var thresholds TelemetryThresholds
+	if err := json.Unmarshal([]byte(thresholdsJSON), &thresholds); err != nil {
+		return fmt.Errorf("failed to parse telemetry thresholds: %v", err)
+	}
+	for telemetryType, max := range thresholds {
+		if err := validateTelemetryType(telemetryType); err != nil {
+			return err
+		}
+		if max < 0 && telemetryType != TelemetryTemperature {
+			return &ValidationError{Field: string(telemetryType), Message: "threshold cannot be negative"}
+		}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER can set thresholds
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.SellerID != caller.ID {
+		return fmt.Errorf("only the seller can set telemetry thresholds")
+	}
+
+	thresholdKey, err := ctx.GetStub().CreateCompositeKey(RecordTelemetryThreshold, []string{deliveryID})
+	if err != nil {
+		return fmt.Errorf("failed to create telemetry threshold composite key: %v", err)
+	}
+	thresholdBytes, err := json.Marshal(thresholds)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telemetry thresholds: %v", err)
+	}
+
+	return ctx.GetStub().PutState(thresholdKey, thresholdBytes)
+}
+
+// RecordTelemetry appends a sensor reading to a delivery's bounded telemetry log
+// Only registered SENSOR gateways can record telemetry, and only for active deliveries
+// Readings live under their own keys so sensors never contend with custody updates; only a
+// TEMPERATURE violation writes the delivery itself, to quarantine it, and only when the gateway
+// is registered with the delivery's current custodian. Other violations just raise an alert.
+func (c *DeliveryContract) RecordTelemetry(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	telemetryType string,
+	value float64,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	if err := validateTelemetryType(TelemetryType(telemetryType)); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SENSOR gateways can record telemetry
+	if err := validateRole(ctx, caller, RoleSensor); err != nil {
+		return err
+	}
+
+	profile, err := getUserProfile(ctx, caller.ID)
+	if err != nil {
+		return err
+	}
+	if profile == nil || profile.Role != RoleSensor || profile.MSP != caller.MSP {
+		return fmt.Errorf("gateway %s is not registered", caller.ID)
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	switch delivery.DeliveryStatus {
+	case StatusConfirmedDelivery, StatusCancelled, StatusSplit, StatusMerged, StatusExported, StatusReturnCompleted:
+		return fmt.Errorf("cannot record telemetry in current status: %s", delivery.DeliveryStatus)
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The cursor holds the next sequence number; slots wrap around after maxTelemetryReadings
+	cursorKey, err := ctx.GetStub().CreateCompositeKey(RecordTelemetryCursor, []string{deliveryID})
+	if err != nil {
+		return fmt.Errorf("failed to create telemetry cursor composite key: %v", err)
+	}
+	cursorJSON, err := ctx.GetStub().GetState(cursorKey)
+	if err != nil {
+		return fmt.Errorf("failed to read telemetry cursor: %v", err)
+	}
+	sequence := 0
+	if cursorJSON != nil {
+		if err := json.Unmarshal(cursorJSON, &sequence); err != nil {
+			return fmt.Errorf("failed to unmarshal telemetry cursor: %v", err)
+		}
+	}
+
+	thresholds, err := getTelemetryThresholds(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	max, hasThreshold := thresholds[TelemetryType(telemetryType)]
+
+	reading := TelemetryReading{
+		DeliveryID: deliveryID,
+		Sequence:   sequence,
+		GatewayID:  caller.ID,
+		Type:       TelemetryType(telemetryType),
+		Value:      value,
+		Violation:  hasThreshold && value > max,
+		RecordedAt: currentTime,
+	}
+
+	slot := fmt.Sprintf("%03d", sequence%maxTelemetryReadings)
+	readingKey, err := ctx.GetStub().CreateCompositeKey(RecordTelemetry, []string{deliveryID, slot})
+	if err != nil {
+		return fmt.Errorf("failed to create telemetry composite key: %v", err)
+	}
+	readingJSON, err := json.Marshal(reading)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telemetry reading: %v", err)
+	}
+	if err := ctx.GetStub().PutState(readingKey, readingJSON); err != nil {
+		return fmt.Errorf("failed to put telemetry reading: %v", err)
+	}
+
+	nextJSON, err := json.Marshal(sequence + 1)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telemetry cursor: %v", err)
+	}
+	if err := ctx.GetStub().PutState(cursorKey, nextJSON); err != nil {
+		return fmt.Errorf("failed to put telemetry cursor: %v", err)
+	}
+
+	if !reading.Violation {
+		return nil
+	}
+
+	// A cold-chain breach quarantines the goods before they can reach the customer, as long as
+	// the gateway travels with them; any other gateway's reading is only an alert
+	if reading.Type == TelemetryTemperature && profile.CustodianID == delivery.CurrentCustodianID {
+		oldStatus := delivery.DeliveryStatus
+		quarantined, err := quarantineDelivery(ctx, delivery, &reading, max)
+		if err != nil {
+			return err
+		}
+		if quarantined {
+			return emitEvent(ctx, EventDeliveryQuarantined, map[string]string{
+				"deliveryId":  deliveryID,
+				"orderId":     delivery.OrderID,
+				"sellerId":    delivery.SellerID,
+				"custodianId": delivery.CurrentCustodianID,
+				"gatewayId":   caller.ID,
+				"oldStatus":   string(oldStatus),
+				"temperature": fmt.Sprintf("%g", value),
+				"threshold":   fmt.Sprintf("%g", max),
+				"timestamp":   currentTime,
+			})
+		}
+	}
+
+	return emitEvent(ctx, EventTelemetryAlert, map[string]string{
+		"deliveryId":    deliveryID,
+		"orderId":       delivery.OrderID,
+		"custodianId":   delivery.CurrentCustodianID,
+		"gatewayId":     caller.ID,
+		"telemetryType": telemetryType,
+		"value":         fmt.Sprintf("%g", value),
+		"threshold":     fmt.Sprintf("%g", max),
+		"timestamp":     currentTime,
+	})
+}
+
+// GetTelemetry returns the retained sensor readings of a delivery, oldest first
+func (c *DeliveryContract) GetTelemetry(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*TelemetryReading, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordTelemetry, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get telemetry: %v", err)
+	}
+	defer iterator.Close()
+
+	readings := []*TelemetryReading{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate telemetry: %v", err)
+		}
+
+		var reading TelemetryReading
+		if err := json.Unmarshal(response.Value, &reading); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal telemetry reading: %v", err)
+		}
+		readings = append(readings, &reading)
+	}
+
+	// Slots are returned in key order; restore chronological order after wrap-around
+	sort.Slice(readings, func(i, j int) bool {
+		return readings[i].Sequence < readings[j].Sequence
+	})
+
+	return readings, nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ServiceTier is the shipping speed a delivery was booked with
+type ServiceTier string
+
+const (
+	ServiceTierStandard  ServiceTier = "STANDARD"
+	ServiceTierExpress   ServiceTier = "EXPRESS"
+	ServiceTierOvernight ServiceTier = "OVERNIGHT"
+)
+
+// RecordDeliveryTemplate is the composite key prefix for seller delivery templates
+const RecordDeliveryTemplate = "template~sellerId~templateId"
+
+// DeliveryTemplate holds the package and origin details a seller ships repeatedly
+type DeliveryTemplate struct {
+	TemplateID        string            `json:"templateId"`
+	SellerID          string            `json:"sellerId"`
+	Name              string            `json:"name"`
+	PackageWeight     float64           `json:"packageWeight"`
+	PackageDimensions PackageDimensions `json:"packageDimensions"`
+	Origin            Location          `json:"origin"`
+	ServiceTier       ServiceTier       `json:"serviceTier"`
+	UpdatedAt         string            `json:"updatedAt"`
+}
+
+// validateServiceTier checks if a service tier is one of the known values
+func validateServiceTier(tier ServiceTier) error {
+	switch tier {
+	case ServiceTierStandard, ServiceTierExpress, ServiceTierOvernight:
+		return nil
+	}
+	return &ValidationError{Field: "serviceTier", Message: fmt.Sprintf("unknown service tier: %s", tier)}
+}
+
+// getDeliveryTemplate reads one of a seller's templates (nil if it does not exist)
+func getDeliveryTemplate(ctx contractapi.TransactionContextInterface, sellerID string, templateID string) (*DeliveryTemplate, error) {
+	templateKey, err := ctx.GetStub().CreateCompositeKey(RecordDeliveryTemplate, []string{sellerID, templateID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create template composite key: %v", err)
+	}
+	templateJSON, err := ctx.GetStub().GetState(templateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %v", err)
+	}
+	if templateJSON == nil {
+		return nil, nil
+	}
+
+	var template DeliveryTemplate
+	if err := json.Unmarshal(templateJSON, &template); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal template: %v", err)
+	}
+	return &template, nil
+}
+
+// SaveDeliveryTemplate creates or replaces a delivery template in the caller's namespace
+// Only SELLER can save templates; they are private to the seller that saved them
+func (c *DeliveryContract) SaveDeliveryTemplate(
+	ctx contractapi.TransactionContextInterface,
+	templateID string,
+	name string,
+	packageWeight float64,
+	dimensionLength float64,
+	dimensionWidth float64,
+	dimensionHeight float64,
+	originCity string,
+	originState string,
+	originCountry string,
+	serviceTier string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateUserID(templateID, "templateID"); err != nil {
+		return err
+	}
+	name = sanitizeText(name)
+	if err := validateRequiredText(name, "name", 100); err != nil {
+		return err
+	}
+	units, err := getMeasurementUnits(ctx)
+	if err != nil {
+		return err
+	}
+	packageWeight = units.toKilograms(packageWeight)
+	dimensionLength = units.toCentimeters(dimensionLength)
+	dimensionWidth = units.toCentimeters(dimensionWidth)
+	dimensionHeight = units.toCentimeters(dimensionHeight)
+	if err := validatePackageWeight(packageWeight); err != nil {
+		return err
+	}
+	if err := validateDimension(dimensionLength, "dimensionLength"); err != nil {
+		return err
+	}
+	if err := validateDimension(dimensionWidth, "dimensionWidth"); err != nil {
+		return err
+	}
+	if err := validateDimension(dimensionHeight, "dimensionHeight"); err != nil {
+		return err
+	}
+	originCity, originState, originCountry = normalizeLocation(originCity, originState, originCountry)
+	if err := validateLocation(originCity, originState, originCountry); err != nil {
+		return err
+	}
+	if err := validateServiceTier(ServiceTier(serviceTier)); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER can save templates
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return err
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	template := DeliveryTemplate{
+		TemplateID:    templateID,
+		SellerID:      caller.ID,
+		Name:          name,
+		PackageWeight: packageWeight,
+		PackageDimensions: PackageDimensions{
+			Length: dimensionLength,
+			Width:  dimensionWidth,
+			Height: dimensionHeight,
+		},
+		Origin: Location{
+			City:    originCity,
+			State:   originState,
+			Country: originCountry,
+		},
+		ServiceTier: ServiceTier(serviceTier),
+		UpdatedAt:   currentTime,
+	}
+
+	templateKey, err := ctx.GetStub().CreateCompositeKey(RecordDeliveryTemplate, []string{caller.ID, templateID})
+	if err != nil {
+		return fmt.Errorf("failed to create template composite key: %v", err)
+	}
+	templateJSON, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("failed to marshal template: %v", err)
+	}
+
+	return ctx.GetStub().PutState(templateKey, templateJSON)
+}
+
+// GetDeliveryTemplates returns the caller's delivery templates
+// Only SELLER has templates
+func (c *DeliveryContract) GetDeliveryTemplates(
+	ctx contractapi.TransactionContextInterface,
+) ([]*DeliveryTemplate, error) {
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER has templates
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordDeliveryTemplate, []string{caller.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get templates: %v", err)
+	}
+	defer iterator.Close()
+
+	templates := []*DeliveryTemplate{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate templates: %v", err)
+		}
+
+		var template DeliveryTemplate
+		if err := json.Unmarshal(response.Value, &template); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal template: %v", err)
+		}
+		templates = append(templates, &template)
+	}
+
+	return templates, nil
+}
+
+// CreateDeliveryFromTemplate creates a delivery from one of the caller's templates
+// Only SELLER can create deliveries. The delivery ID is derived from the transaction
+// (DEL-YYYYMMDD-<first 8 hex digits of the tx ID>) and returned to the caller.
+func (c *DeliveryContract) CreateDeliveryFromTemplate(
+	ctx contractapi.TransactionContextInterface,
+	templateID string,
+	orderID string,
+	customerID string,
+) (string, error) {
+	// ========== INPUT VALIDATION ==========
+	var errs ValidationErrors
+	errs.collect(validateUserID(templateID, "templateID"))
+	errs.collect(validateOrderID(orderID))
+	errs.collect(validateUserID(customerID, "customerID"))
+	if err := errs.err(); err != nil {
+		return "", err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only SELLER can create deliveries
+	if err := validateRole(ctx, caller, RoleSeller); err != nil {
+		return "", err
+	}
+
+	template, err := getDeliveryTemplate(ctx, caller.ID, templateID)
+	if err != nil {
+		return "", err
+	}
+	if template == nil {
+		return "", fmt.Errorf("template %s does not exist", templateID)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	currentTime := txTime.Format(time.RFC3339)
+
+	deliveryID := fmt.Sprintf("DEL-%s-%s", txTime.Format("20060102"), strings.ToUpper(ctx.GetStub().GetTxID()[:8]))
+	exists, err := c.DeliveryExists(ctx, deliveryID)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if delivery exists: %v", err)
+	}
+	if exists {
+		return "", fmt.Errorf("delivery %s already exists", deliveryID)
+	}
+
+	delivery := Delivery{
+		TenantID:             caller.TenantID,
+		DeliveryID:           deliveryID,
+		OrderID:              orderID,
+		SellerID:             caller.ID,
+		CustomerID:           customerID,
+		PackageWeight:        template.PackageWeight,
+		PackageDimensions:    template.PackageDimensions,
+		DeliveryStatus:       StatusPendingPickup,
+		LastLocation:         template.Origin,
+		CurrentCustodianID:   caller.ID,
+		CurrentCustodianRole: RoleSeller,
+		CustodianMSP:         caller.MSP,
+		ServiceTier:          template.ServiceTier,
+		TemplateID:           templateID,
+		UpdatedAt:            currentTime,
+	}
+
+	if err := createDeliveryInternal(ctx, &delivery); err != nil {
+		return "", err
+	}
+
+	return deliveryID, nil
+}
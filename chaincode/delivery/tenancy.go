@@ -0,0 +1,366 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// TenantAttribute is the certificate attribute naming the caller's marketplace
+// Callers without it belong to the default tenant, whose keys stay unprefixed
+// so data written before multi-tenancy remains readable.
+const TenantAttribute = "tenant"
+
+// tenantKeyPrefix starts every simple key of a non-default tenant
+const tenantKeyPrefix = "tenant~"
+
+// tenantIDPattern restricts tenant IDs to characters that can't break key namespacing
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// callerTenantID reads the caller's tenant from its certificate ("" for the default tenant)
+// A tenant attribute that is present must be a valid ID; an empty or '~' separated one could
+// reach into another tenant's keys.
+func callerTenantID(clientIdentity cid.ClientIdentity) (string, error) {
+	tenantID, found, err := clientIdentity.GetAttributeValue(TenantAttribute)
+	if err != nil {
+		return "", fmt.Errorf("failed to get tenant attribute: %v", err)
+	}
+	if found && !tenantIDPattern.MatchString(tenantID) {
+		return "", &ValidationError{Field: TenantAttribute, Message: "must be 1-32 characters of letters, digits, '-' or '_'"}
+	}
+	return tenantID, nil
+}
+
+// beforeTransaction runs before every transaction
+// Registered as the contract's BeforeTransaction hook in main.go. It rejects callers with a
+// malformed tenant attribute before anything reads the ledger, then reads the correlation ID.
+func beforeTransaction(ctx contractapi.TransactionContextInterface) error {
+	if identity := ctx.GetClientIdentity(); identity != nil {
+		if _, err := callerTenantID(identity); err != nil {
+			return err
+		}
+	}
+	return readCorrelationID(ctx)
+}
+
+// invalidTenantID namespaces the stub of a caller with a malformed tenant attribute
+// No valid tenant ID contains '~', so nothing of another tenant can be read there.
+const invalidTenantID = "~"
+
+// TenantTransactionContext scopes every ledger access of a transaction to the caller's tenant
+// Registered as the contract's transaction context handler in main.go. It also collects the
+// audience of the transaction's event and the projections of the deliveries it writes, and keeps
+// the request's correlation ID.
+type TenantTransactionContext struct {
+	contractapi.TransactionContext
+	stub     *tenantStub
+	audience *audienceSet
+	// Projections by delivery ID, in the order the deliveries were first written
+	projections     map[string]*DeliveryProjection
+	projectionOrder []string
+	// Last event emitted, re-emitted with the projections after the transaction
+	eventName    string
+	eventPayload []byte
+	// Correlation ID supplied by the caller, read before the transaction runs
+	correlationID string
+	// Set once the permission model lets a caller whose certificate isn't ADMIN act as ADMIN
+	inheritedAdmin bool
+}
+
+// GetStub returns the stub namespaced by the caller's tenant
+// Evaluate transactions and AUDITOR identities get a read-only stub, so a query can't write
+// (its writes would be silently dropped when sent to a single peer) and no function can write
+// on an auditor's behalf.
+func (t *TenantTransactionContext) GetStub() shim.ChaincodeStubInterface {
+	if t.stub == nil {
+		stub := t.TransactionContext.GetStub()
+		tenantID := ""
+		readOnly := ""
+		function, _ := stub.GetFunctionAndParameters()
+		if function = function[strings.LastIndex(function, ":")+1:]; isEvaluateTransaction(function) {
+			readOnly = fmt.Sprintf("%s is an evaluate transaction and cannot write to the ledger", function)
+		}
+		if identity := t.TransactionContext.GetClientIdentity(); identity != nil {
+			value, err := callerTenantID(identity)
+			if err != nil {
+				// beforeTransaction rejects the transaction; until then it can't touch real keys
+				value, readOnly = invalidTenantID, err.Error()
+			}
+			tenantID = value
+			if role, err := callerRole(identity); err == nil && role == RoleAuditor && readOnly == "" {
+				readOnly = fmt.Sprintf("role %s is read-only and cannot submit changes", RoleAuditor)
+			}
+		}
+		t.stub = &tenantStub{ChaincodeStubInterface: stub, tenantID: tenantID, readOnly: readOnly}
+	}
+	return t.stub
+}
+
+// networkStub returns the ledger stub without tenant namespacing, for network-wide records
+// Writes are still refused if the transaction is read-only.
+func networkStub(ctx contractapi.TransactionContextInterface) shim.ChaincodeStubInterface {
+	stub := ctx.GetStub()
+	if scoped, ok := stub.(*tenantStub); ok {
+		return &tenantStub{ChaincodeStubInterface: scoped.ChaincodeStubInterface, readOnly: scoped.readOnly}
+	}
+	return stub
+}
+
+// tenantStub prefixes keys, composite key object types and private data keys with the tenant
+// Range and rich query results are filtered to the tenant and returned with the prefix removed.
+type tenantStub struct {
+	shim.ChaincodeStubInterface
+	tenantID string
+	// Why the transaction may not write, empty if it may
+	readOnly string
+}
+
+// writable rejects writes of read-only transactions
+func (s *tenantStub) writable() error {
+	if s.readOnly != "" {
+		return fmt.Errorf("%s", s.readOnly)
+	}
+	return nil
+}
+
+// prefix returns the namespace of simple keys for the tenant
+func (s *tenantStub) prefix() string {
+	if s.tenantID == "" {
+		return ""
+	}
+	return tenantKeyPrefix + s.tenantID + "~"
+}
+
+// key namespaces a ledger key
+// Composite keys already carry the tenant in their object type. A composite key of another
+// tenant is moved under a prefix nobody else writes, so it can't be read or written from here.
+func (s *tenantStub) key(key string) string {
+	if strings.HasPrefix(key, compositeKeyNamespace) {
+		if s.ownsCompositeKey(key) {
+			return key
+		}
+		return tenantKeyPrefix + s.tenantID + "~" + key
+	}
+	return s.prefix() + key
+}
+
+// ownsCompositeKey reports whether a composite key was created in the tenant's namespace
+// The default tenant owns every composite key outside the tenant namespaces.
+func (s *tenantStub) ownsCompositeKey(key string) bool {
+	if s.tenantID == "" {
+		return !strings.HasPrefix(key, compositeKeyNamespace+tenantKeyPrefix)
+	}
+	return strings.HasPrefix(key, compositeKeyNamespace+s.prefix())
+}
+
+// objectType namespaces a composite key object type
+func (s *tenantStub) objectType(objectType string) string {
+	return s.prefix() + objectType
+}
+
+// visibleKey maps a stored key back to the tenant's view, reporting whether the tenant owns it
+func (s *tenantStub) visibleKey(key string) (string, bool) {
+	if strings.HasPrefix(key, compositeKeyNamespace) {
+		return key, s.ownsCompositeKey(key)
+	}
+	if s.tenantID == "" {
+		return key, !strings.HasPrefix(key, tenantKeyPrefix)
+	}
+	if !strings.HasPrefix(key, s.prefix()) {
+		return "", false
+	}
+	return strings.TrimPrefix(key, s.prefix()), true
+}
+
+// compositeKeyNamespace is the first byte of every Fabric composite key
+const compositeKeyNamespace = "\x00"
+
+func (s *tenantStub) GetState(key string) ([]byte, error) {
+	return s.ChaincodeStubInterface.GetState(s.key(key))
+}
+
+func (s *tenantStub) PutState(key string, value []byte) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	return s.ChaincodeStubInterface.PutState(s.key(key), value)
+}
+
+func (s *tenantStub) DelState(key string) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	return s.ChaincodeStubInterface.DelState(s.key(key))
+}
+
+func (s *tenantStub) SetStateValidationParameter(key string, ep []byte) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	return s.ChaincodeStubInterface.SetStateValidationParameter(s.key(key), ep)
+}
+
+func (s *tenantStub) GetStateValidationParameter(key string) ([]byte, error) {
+	return s.ChaincodeStubInterface.GetStateValidationParameter(s.key(key))
+}
+
+func (s *tenantStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return s.ChaincodeStubInterface.GetHistoryForKey(s.key(key))
+}
+
+func (s *tenantStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return s.ChaincodeStubInterface.CreateCompositeKey(s.objectType(objectType), attributes)
+}
+
+func (s *tenantStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	objectType, attributes, err := s.ChaincodeStubInterface.SplitCompositeKey(compositeKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.TrimPrefix(objectType, s.prefix()), attributes, nil
+}
+
+func (s *tenantStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	iterator, err := s.ChaincodeStubInterface.GetStateByPartialCompositeKey(s.objectType(objectType), keys)
+	if err != nil {
+		return nil, err
+	}
+	return &tenantIterator{StateQueryIteratorInterface: iterator, stub: s}, nil
+}
+
+// A bookmark is a ledger key chosen by the caller, so paginated results are filtered as well
+func (s *tenantStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	iterator, metadata, err := s.ChaincodeStubInterface.GetStateByPartialCompositeKeyWithPagination(s.objectType(objectType), keys, pageSize, bookmark)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &tenantIterator{StateQueryIteratorInterface: iterator, stub: s}, metadata, nil
+}
+
+func (s *tenantStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if s.tenantID != "" {
+		startKey = s.prefix() + startKey
+		if endKey == "" {
+			endKey = s.prefix() + string(utf8.MaxRune)
+		} else {
+			endKey = s.prefix() + endKey
+		}
+	}
+	iterator, err := s.ChaincodeStubInterface.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return &tenantIterator{StateQueryIteratorInterface: iterator, stub: s}, nil
+}
+
+func (s *tenantStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	iterator, err := s.ChaincodeStubInterface.GetQueryResult(query)
+	if err != nil {
+		return nil, err
+	}
+	return &tenantIterator{StateQueryIteratorInterface: iterator, stub: s}, nil
+}
+
+func (s *tenantStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	iterator, metadata, err := s.ChaincodeStubInterface.GetQueryResultWithPagination(query, pageSize, bookmark)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &tenantIterator{StateQueryIteratorInterface: iterator, stub: s}, metadata, nil
+}
+
+func (s *tenantStub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	return s.ChaincodeStubInterface.SetPrivateDataValidationParameter(collection, s.key(key), ep)
+}
+
+func (s *tenantStub) GetPrivateDataValidationParameter(collection, key string) ([]byte, error) {
+	return s.ChaincodeStubInterface.GetPrivateDataValidationParameter(collection, s.key(key))
+}
+
+func (s *tenantStub) GetPrivateData(collection, key string) ([]byte, error) {
+	return s.ChaincodeStubInterface.GetPrivateData(collection, s.key(key))
+}
+
+func (s *tenantStub) GetPrivateDataHash(collection, key string) ([]byte, error) {
+	return s.ChaincodeStubInterface.GetPrivateDataHash(collection, s.key(key))
+}
+
+func (s *tenantStub) PutPrivateData(collection string, key string, value []byte) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	return s.ChaincodeStubInterface.PutPrivateData(collection, s.key(key), value)
+}
+
+func (s *tenantStub) DelPrivateData(collection, key string) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	return s.ChaincodeStubInterface.DelPrivateData(collection, s.key(key))
+}
+
+func (s *tenantStub) PurgePrivateData(collection, key string) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	return s.ChaincodeStubInterface.PurgePrivateData(collection, s.key(key))
+}
+
+func (s *tenantStub) SetEvent(name string, payload []byte) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	return s.ChaincodeStubInterface.SetEvent(name, payload)
+}
+
+// tenantIterator skips results outside the tenant and strips the tenant prefix from keys
+type tenantIterator struct {
+	shim.StateQueryIteratorInterface
+	stub *tenantStub
+	next *queryresult.KV
+	err  error
+}
+
+// advance fetches the next result owned by the tenant
+func (it *tenantIterator) advance() {
+	for it.next == nil && it.err == nil && it.StateQueryIteratorInterface.HasNext() {
+		kv, err := it.StateQueryIteratorInterface.Next()
+		if err != nil {
+			it.err = err
+			return
+		}
+		if key, ok := it.stub.visibleKey(kv.Key); ok {
+			it.next = &queryresult.KV{Namespace: kv.Namespace, Key: key, Value: kv.Value}
+		}
+	}
+}
+
+func (it *tenantIterator) HasNext() bool {
+	it.advance()
+	return it.next != nil || it.err != nil
+}
+
+func (it *tenantIterator) Next() (*queryresult.KV, error) {
+	it.advance()
+	if it.err != nil {
+		err := it.err
+		it.err = nil
+		return nil, err
+	}
+	if it.next == nil {
+		return nil, fmt.Errorf("no more results")
+	}
+	kv := it.next
+	it.next = nil
+	return kv, nil
+}
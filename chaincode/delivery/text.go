@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// bidiControls are the invisible direction overrides used to make text render differently
+// from how it is stored (e.g. spoofed names and reasons)
+var bidiControls = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x200E, Hi: 0x200F, Stride: 1},
+		{Lo: 0x202A, Hi: 0x202E, Stride: 1},
+		{Lo: 0x2066, Hi: 0x2069, Stride: 1},
+	},
+}
+
+// sanitizeText trims free text and normalizes it to NFC
+// Composed and decomposed spellings ("é" vs "é") are stored identically, so
+// equality checks and CouchDB indexes see one value.
+func sanitizeText(value string) string {
+	return strings.TrimSpace(norm.NFC.String(value))
+}
+
+// validateText checks text is valid UTF-8, free of control and bidi override characters,
+// and at most maxRunes characters long
+// Limits count runes, not bytes, so they mean the same for every script; multiline text
+// may contain newlines and tabs.
+func validateText(value string, field string, maxRunes int, multiline bool) error {
+	if !utf8.ValidString(value) {
+		return &ValidationError{Field: field, Message: "must be valid UTF-8"}
+	}
+	if utf8.RuneCountInString(value) > maxRunes {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("exceeds maximum length of %d characters", maxRunes)}
+	}
+	for _, r := range value {
+		if multiline && (r == '\n' || r == '\t') {
+			continue
+		}
+		if unicode.IsControl(r) || unicode.Is(bidiControls, r) {
+			return &ValidationError{Field: field, Message: fmt.Sprintf("must not contain control characters (found %U)", r)}
+		}
+	}
+	return nil
+}
+
+// validateRequiredText is validateText for single-line values that cannot be empty
+func validateRequiredText(value string, field string, maxRunes int) error {
+	if len(value) == 0 {
+		return &ValidationError{Field: field, Message: "cannot be empty"}
+	}
+	return validateText(value, field, maxRunes, false)
+}
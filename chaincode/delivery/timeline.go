@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ProgressMilestone is a customer-facing stage of a delivery's progress
+type ProgressMilestone string
+
+const (
+	ProgressCreated        ProgressMilestone = "CREATED"
+	ProgressPickedUp       ProgressMilestone = "PICKED_UP"
+	ProgressAtHub          ProgressMilestone = "AT_HUB"
+	ProgressOutForDelivery ProgressMilestone = "OUT_FOR_DELIVERY"
+	ProgressDelivered      ProgressMilestone = "DELIVERED"
+)
+
+// Timeline entry states
+const (
+	TimelineOnTime  = "ON_TIME"
+	TimelineLate    = "LATE"
+	TimelinePending = "PENDING"
+	TimelineOverdue = "OVERDUE"
+)
+
+// MilestoneExpectation is one stage of a tier's milestone template
+type MilestoneExpectation struct {
+	Milestone ProgressMilestone `json:"milestone"`
+	// Hours after creation by which the stage is expected
+	Hours int `json:"hours"`
+}
+
+// milestoneTemplates are the expected progress of each service tier, in timeline order
+var milestoneTemplates = map[ServiceTier][]MilestoneExpectation{
+	ServiceTierStandard: {
+		{ProgressPickedUp, 24},
+		{ProgressAtHub, 48},
+		{ProgressOutForDelivery, 96},
+		{ProgressDelivered, 120},
+	},
+	ServiceTierExpress: {
+		{ProgressPickedUp, 12},
+		{ProgressAtHub, 24},
+		{ProgressOutForDelivery, 40},
+		{ProgressDelivered, 48},
+	},
+	ServiceTierOvernight: {
+		{ProgressPickedUp, 4},
+		{ProgressAtHub, 8},
+		{ProgressOutForDelivery, 18},
+		{ProgressDelivered, 24},
+	},
+}
+
+// milestoneTemplate returns the template of a delivery's tier (STANDARD when none was booked)
+func milestoneTemplate(delivery *Delivery) []MilestoneExpectation {
+	if template, ok := milestoneTemplates[delivery.ServiceTier]; ok {
+		return template
+	}
+	return milestoneTemplates[ServiceTierStandard]
+}
+
+// MilestoneActual records when a delivery first reached a progress milestone
+type MilestoneActual struct {
+	Milestone ProgressMilestone `json:"milestone"`
+	ReachedAt string            `json:"reachedAt"`
+}
+
+// reachedMilestones returns the progress milestones a delivery version has reached
+func reachedMilestones(delivery *Delivery) []ProgressMilestone {
+	reached := []ProgressMilestone{ProgressCreated}
+	switch delivery.DeliveryStatus {
+	case StatusInTransit, StatusPendingTransitHandoff, StatusOffNetworkTransit:
+		reached = append(reached, ProgressPickedUp)
+	case StatusOutForDelivery, StatusPendingDeliveryConfirmation:
+		reached = append(reached, ProgressPickedUp, ProgressOutForDelivery)
+	case StatusConfirmedDelivery:
+		reached = append(reached, ProgressPickedUp, ProgressOutForDelivery, ProgressDelivered)
+	}
+	if delivery.CurrentCustodianRole == RoleWarehouse {
+		reached = append(reached, ProgressAtHub)
+	}
+	return reached
+}
+
+// recordMilestoneActuals stamps the progress milestones a delivery reaches for the first time
+// Called by applyDeliveryUpdate before the delivery is written.
+func recordMilestoneActuals(delivery *Delivery) {
+	if delivery.Tombstone != nil {
+		return
+	}
+	recorded := map[ProgressMilestone]bool{}
+	for _, actual := range delivery.MilestoneActuals {
+		recorded[actual.Milestone] = true
+	}
+	for _, milestone := range reachedMilestones(delivery) {
+		if recorded[milestone] {
+			continue
+		}
+		recorded[milestone] = true
+		delivery.MilestoneActuals = append(delivery.MilestoneActuals, MilestoneActual{
+			Milestone: milestone,
+			ReachedAt: delivery.UpdatedAt,
+		})
+	}
+}
+
+// TimelineEntry compares when a milestone was expected with when it was reached
+type TimelineEntry struct {
+	Milestone  ProgressMilestone `json:"milestone"`
+	ExpectedAt string            `json:"expectedAt"`
+	ActualAt   string            `json:"actualAt,omitempty" metadata:",optional"`
+	// Minutes late (negative when early); only set once the milestone is reached
+	VarianceMinutes int64  `json:"varianceMinutes"`
+	State           string `json:"state"`
+}
+
+// MilestoneTimeline is the carrier-style progress view of a delivery
+type MilestoneTimeline struct {
+	DeliveryID  string          `json:"deliveryId"`
+	ServiceTier ServiceTier     `json:"serviceTier"`
+	CreatedAt   string          `json:"createdAt"`
+	Milestones  []TimelineEntry `json:"milestones"`
+}
+
+// deliveryCreatedAt returns when a delivery was created
+// Deliveries stored before milestone actuals were recorded use their first ledger version.
+func deliveryCreatedAt(ctx contractapi.TransactionContextInterface, delivery *Delivery) (time.Time, error) {
+	for _, actual := range delivery.MilestoneActuals {
+		if actual.Milestone == ProgressCreated {
+			return time.Parse(time.RFC3339, actual.ReachedAt)
+		}
+	}
+	entries, err := readHistoryEntries(ctx, delivery.DeliveryID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(entries) == 0 {
+		return time.Time{}, fmt.Errorf("delivery %s has no history", delivery.DeliveryID)
+	}
+	return entries[0].timestamp, nil
+}
+
+// expectedMilestoneAt returns when a delivery is expected to reach a milestone of its tier template
+func expectedMilestoneAt(ctx contractapi.TransactionContextInterface, delivery *Delivery, milestone ProgressMilestone) (time.Time, error) {
+	createdAt, err := deliveryCreatedAt(ctx, delivery)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to determine creation time: %v", err)
+	}
+	for _, expectation := range milestoneTemplate(delivery) {
+		if expectation.Milestone == milestone {
+			return createdAt.Add(time.Duration(expectation.Hours) * time.Hour), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no %s milestone in the %s template", milestone, delivery.ServiceTier)
+}
+
+// GetMilestoneTimeline returns the expected and actual time of each progress milestone
+// Expected times come from the service tier's template; variance is actual minus expected.
+// Anyone who can track the delivery (involved party, admin or grantee) can read it.
+func (c *DeliveryContract) GetMilestoneTimeline(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) (*MilestoneTimeline, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTrackingAccess(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	createdAt, err := deliveryCreatedAt(ctx, delivery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine creation time: %v", err)
+	}
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	actuals := map[ProgressMilestone]string{}
+	for _, actual := range delivery.MilestoneActuals {
+		actuals[actual.Milestone] = actual.ReachedAt
+	}
+
+	tier := delivery.ServiceTier
+	if tier == "" {
+		tier = ServiceTierStandard
+	}
+	timeline := &MilestoneTimeline{
+		DeliveryID:  deliveryID,
+		ServiceTier: tier,
+		CreatedAt:   createdAt.UTC().Format(time.RFC3339),
+		Milestones:  []TimelineEntry{},
+	}
+	for _, expectation := range milestoneTemplate(delivery) {
+		expectedAt := createdAt.Add(time.Duration(expectation.Hours) * time.Hour)
+		entry := TimelineEntry{
+			Milestone:  expectation.Milestone,
+			ExpectedAt: expectedAt.UTC().Format(time.RFC3339),
+			State:      TimelinePending,
+		}
+		if reachedAt, ok := actuals[expectation.Milestone]; ok {
+			actualAt, err := time.Parse(time.RFC3339, reachedAt)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s timestamp: %v", expectation.Milestone, err)
+			}
+			entry.ActualAt = reachedAt
+			entry.VarianceMinutes = int64(actualAt.Sub(expectedAt) / time.Minute)
+			entry.State = TimelineOnTime
+			if actualAt.After(expectedAt) {
+				entry.State = TimelineLate
+			}
+		} else if txTime.After(expectedAt) {
+			entry.State = TimelineOverdue
+		}
+		timeline.Milestones = append(timeline.Milestones, entry)
+	}
+
+	return timeline, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EventDeliveryRedacted is emitted when a delivery is replaced by a tombstone
+const EventDeliveryRedacted = "DeliveryRedacted"
+
+// TombstoneInfo records who removed a delivery's data and why (as a hash of the legal request)
+type TombstoneInfo struct {
+	ReasonHash   string `json:"reasonHash"`
+	DeletedBy    string `json:"deletedBy"`
+	DeletedByMSP string `json:"deletedByMsp"`
+	DeletedAt    string `json:"deletedAt"`
+}
+
+// TombstoneDelivery replaces a delivery with a minimal tombstone after a legal removal request
+// Only ADMIN can tombstone. Indexes, private data, correction snapshots and dispute cases are removed;
+// the delivery ID stays reserved and ReadDelivery reports it as REDACTED. The tombstone's own
+// correction record keeps only the status the delivery had.
+// Earlier versions remain in the ledger's block history, which cannot be rewritten.
+func (c *DeliveryContract) TombstoneDelivery(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	reasonHash string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	if len(reasonHash) == 0 || len(reasonHash) > 128 {
+		return &ValidationError{Field: "reasonHash", Message: "must be between 1 and 128 characters"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only ADMIN can tombstone deliveries
+	if err := validateRole(ctx, caller, RoleAdmin); err != nil {
+		return err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	currentTime, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteDeliveryIndexes(ctx, delivery); err != nil {
+		return err
+	}
+
+	// Off-chain personal data goes for good (purge also drops it from peers' private history)
+	for _, collection := range []string{CollectionDeliveryPrivate, CollectionContentsManifest, CollectionAgeVerification} {
+		if err := ctx.GetStub().PurgePrivateData(collection, deliveryID); err != nil {
+			return fmt.Errorf("failed to purge %s: %v", collection, err)
+		}
+	}
+
+	// Correction records hold full before/after copies of the document
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordCorrection, []string{deliveryID})
+	if err != nil {
+		return fmt.Errorf("failed to get corrections: %v", err)
+	}
+	var correctionKeys []string
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			iterator.Close()
+			return fmt.Errorf("failed to iterate corrections: %v", err)
+		}
+		correctionKeys = append(correctionKeys, response.Key)
+	}
+	iterator.Close()
+	for _, key := range correctionKeys {
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return fmt.Errorf("failed to delete correction record: %v", err)
+		}
+	}
+
+	// Dispute cases carry the parties' free-text reasons
+	if err := deleteDisputeCases(ctx, deliveryID); err != nil {
+		return err
+	}
+
+	tombstone := Delivery{
+		TenantID:       delivery.TenantID,
+		DeliveryID:     deliveryID,
+		DeliveryStatus: StatusRedacted,
+		Tombstone: &TombstoneInfo{
+			ReasonHash:   reasonHash,
+			DeletedBy:    caller.ID,
+			DeletedByMSP: caller.MSP,
+			DeletedAt:    currentTime,
+		},
+		UpdatedAt: currentTime,
+	}
+
+	// A full snapshot would keep the removed data, so the record only shows what was redacted
+	before := Delivery{
+		TenantID:       delivery.TenantID,
+		DeliveryID:     deliveryID,
+		DeliveryStatus: delivery.DeliveryStatus,
+		UpdatedAt:      delivery.UpdatedAt,
+	}
+	if err := recordCorrection(ctx, "TombstoneDelivery", reasonHash, &before, &tombstone, caller); err != nil {
+		return err
+	}
+
+	if err := applyDeliveryUpdate(ctx, &tombstone); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventDeliveryRedacted, map[string]string{
+		"deliveryId": deliveryID,
+		"reasonHash": reasonHash,
+		"deletedBy":  caller.ID,
+		"timestamp":  currentTime,
+	})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testStatuses lists every delivery status
+var testStatuses = []DeliveryStatus{
+	StatusPendingPickup, StatusPendingPickupHandoff, StatusDisputedPickupHandoff, StatusInTransit,
+	StatusPendingTransitHandoff, StatusDisputedTransitHandoff, StatusPendingDeliveryConfirmation,
+	StatusConfirmedDelivery, StatusDisputedDelivery, StatusCancelled, StatusRecallPending,
+	StatusReturnInTransit, StatusSplit, StatusMerged, StatusExported, StatusRedacted,
+	StatusOffNetworkTransit, StatusOutForDelivery, StatusReturnCompleted, StatusQuarantined,
+}
+
+// TestApplyDeliveryUpdateTransitions moves a delivery through every transition of the
+// transition table and checks the indexes applyDeliveryUpdate maintains
+func TestApplyDeliveryUpdateTransitions(t *testing.T) {
+	for _, entry := range transitionTable {
+		for _, transition := range entry.Transitions {
+			entry, transition := entry, transition
+			t.Run(fmt.Sprintf("%s/%s->%s", entry.Function, transition.From, transition.To), func(t *testing.T) {
+				network := newTestNetwork(t)
+				delivery := testDelivery(testDeliveryID, transition.From)
+				if transition.From != "" {
+					network.putDelivery(delivery)
+					delivery = network.getDelivery(testDeliveryID)
+				}
+				delivery.DeliveryStatus = transition.To
+				network.putDelivery(delivery)
+
+				if got := network.getDelivery(testDeliveryID).DeliveryStatus; got != transition.To {
+					t.Fatalf("stored status is %s, want %s", got, transition.To)
+				}
+				if !network.hasIndex(IndexStatusDelivery, string(transition.To), testDeliveryID) {
+					t.Errorf("missing %s status index entry", transition.To)
+				}
+				if transition.From != "" && network.hasIndex(IndexStatusDelivery, string(transition.From), testDeliveryID) {
+					t.Errorf("stale %s status index entry", transition.From)
+				}
+				if held := network.hasIndex(IndexCustodianDelivery, testCourier.id, testDeliveryID); held == closedStatuses[transition.To] {
+					t.Errorf("custodian index entry present = %v in status %s", held, transition.To)
+				}
+				if disputed := network.hasIndex(IndexDisputedDelivery, testDeliveryID); disputed != disputedStatuses[transition.To] {
+					t.Errorf("dispute queue entry present = %v in status %s", disputed, transition.To)
+				}
+				for _, index := range []string{IndexSellerDelivery, IndexCustomerDelivery, IndexOrderDelivery} {
+					value := map[string]string{
+						IndexSellerDelivery:   testSeller.id,
+						IndexCustomerDelivery: testCustomer.id,
+						IndexOrderDelivery:    "order-" + testDeliveryID,
+					}[index]
+					if !network.hasIndex(index, value, testDeliveryID) {
+						t.Errorf("missing %s index entry", index)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestApplyDeliveryUpdateRejectsCorruptDelivery refuses to write over a stored delivery it
+// can't read, since its index entries could not be cleaned up
+func TestApplyDeliveryUpdateRejectsCorruptDelivery(t *testing.T) {
+	network := newTestNetwork(t)
+	network.stub.State[testDeliveryID] = []byte("{not json")
+
+	err := network.transaction(testAdmin, func(ctx *TenantTransactionContext) error {
+		return applyDeliveryUpdate(ctx, testDelivery(testDeliveryID, StatusInTransit))
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to unmarshal stored delivery "+testDeliveryID) {
+		t.Fatalf("expected unmarshal error, got %v", err)
+	}
+	if !bytes.Equal(network.stub.State[testDeliveryID], []byte("{not json")) {
+		t.Errorf("corrupt delivery was overwritten")
+	}
+	if network.hasIndex(IndexStatusDelivery, string(StatusInTransit), testDeliveryID) {
+		t.Errorf("status index entry written for a rejected update")
+	}
+}
+
+// TestTransitionsRejectedFromOtherStatuses calls functions of the transition table on
+// deliveries in every status the table doesn't list as a starting point. A call may fail, which
+// must leave the delivery untouched, or succeed without changing the status (CancelHandoff
+// clears a stray handoff, ResolveDispute leaves a pickup dispute for the seller to re-offer).
+func TestTransitionsRejectedFromOtherStatuses(t *testing.T) {
+	// Each delivery carries whatever else the function looks for, so only its status can
+	// make the call fail
+	pendingHandoff := func(d *Delivery) {
+		d.PendingHandoff = &PendingHandoff{FromUserID: testCourier.id, FromRole: RoleDeliveryPerson, ToUserID: testCustomer.id, ToRole: RoleCustomer, InitiatedAt: "2026-01-01T00:00:00Z"}
+	}
+	tests := []struct {
+		function string
+		caller   testIdentity
+		args     []string
+		prepare  func(d *Delivery)
+	}{
+		{"CancelHandoff", testCourier, nil, pendingHandoff},
+		{"CancelDelivery", testCustomer, []string{"CHANGED_MIND", "ordered by mistake"}, nil},
+		{"AutoConfirmExpired", testAdmin, nil, pendingHandoff},
+		{"RecallDelivery", testSeller, []string{"wrong item"}, nil},
+		{"AcknowledgeRecall", testCourier, nil, func(d *Delivery) {
+			d.Recall = &RecallInfo{RequestedBy: testSeller.id, Reason: "wrong item", RequestedAt: "2026-01-01T00:00:00Z"}
+		}},
+		{"ConfirmReturnReceipt", testSeller, []string{"GOOD", "RESTOCK"}, nil},
+		{"ReofferPickup", testSeller, []string{"courier did not show"}, nil},
+		{"ResolveDispute", testAdmin, []string{string(ResolutionNoAction), "checked with both parties"}, nil},
+		{"RetryDelivery", testAdmin, nil, nil},
+		{"ReleaseQuarantine", testSeller, []string{"temperature back in range"}, func(d *Delivery) {
+			d.Quarantine = &QuarantineInfo{ResumeStatus: StatusInTransit}
+		}},
+	}
+
+	for _, tt := range tests {
+		permission := functionPermission(tt.function)
+		if permission == nil || len(permission.Transitions) == 0 {
+			t.Fatalf("%s has no transitions in the transition table", tt.function)
+		}
+		allowed := map[DeliveryStatus]bool{}
+		for _, transition := range permission.Transitions {
+			allowed[transition.From] = true
+		}
+
+		for _, status := range testStatuses {
+			if allowed[status] {
+				continue
+			}
+			tt, status := tt, status
+			t.Run(fmt.Sprintf("%s/%s", tt.function, status), func(t *testing.T) {
+				network := newTestNetwork(t)
+				delivery := testDelivery(testDeliveryID, status)
+				if tt.prepare != nil {
+					tt.prepare(delivery)
+				}
+				network.putDelivery(delivery)
+				before := network.stub.State[testDeliveryID]
+
+				if _, err := network.invoke(tt.caller, tt.function, append([]string{testDeliveryID}, tt.args...)...); err != nil {
+					if !bytes.Equal(network.stub.State[testDeliveryID], before) {
+						t.Errorf("%s failed from status %s but changed the delivery: %v", tt.function, status, err)
+					}
+					return
+				}
+				if got := network.getDelivery(testDeliveryID).DeliveryStatus; got != status {
+					t.Errorf("%s moved the delivery from %s to %s, which the transition table doesn't list", tt.function, status, got)
+				}
+			})
+		}
+	}
+}
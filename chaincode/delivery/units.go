@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// WeightUnit is the unit a package weight is expressed in
+type WeightUnit string
+
+const (
+	WeightUnitKilogram WeightUnit = "KG"
+	WeightUnitPound    WeightUnit = "LB"
+)
+
+// DimensionUnit is the unit package dimensions are expressed in
+type DimensionUnit string
+
+const (
+	DimensionUnitCentimeter DimensionUnit = "CM"
+	DimensionUnitInch       DimensionUnit = "IN"
+)
+
+// TransientMeasurementUnits is the transient map key for the units of a transaction's
+// weight and dimension arguments ({"weightUnit":"LB","dimensionUnit":"IN"})
+const TransientMeasurementUnits = "measurementUnits"
+
+// Exact conversion factors to the canonical metric units
+const (
+	kilogramsPerPound  = 0.45359237
+	centimetersPerInch = 2.54
+)
+
+// MeasurementUnits are the units weights and dimensions are entered or viewed in
+// The ledger always stores kilograms and centimeters.
+type MeasurementUnits struct {
+	WeightUnit    WeightUnit    `json:"weightUnit"`
+	DimensionUnit DimensionUnit `json:"dimensionUnit"`
+}
+
+// metricUnits are the canonical units stored on the ledger
+var metricUnits = MeasurementUnits{WeightUnit: WeightUnitKilogram, DimensionUnit: DimensionUnitCentimeter}
+
+// newMeasurementUnits validates a pair of units, defaulting empty ones to metric
+func newMeasurementUnits(weightUnit string, dimensionUnit string) (MeasurementUnits, error) {
+	units := metricUnits
+	switch WeightUnit(weightUnit) {
+	case "":
+	case WeightUnitKilogram, WeightUnitPound:
+		units.WeightUnit = WeightUnit(weightUnit)
+	default:
+		return units, &ValidationError{Field: "weightUnit", Message: "must be KG or LB"}
+	}
+	switch DimensionUnit(dimensionUnit) {
+	case "":
+	case DimensionUnitCentimeter, DimensionUnitInch:
+		units.DimensionUnit = DimensionUnit(dimensionUnit)
+	default:
+		return units, &ValidationError{Field: "dimensionUnit", Message: "must be CM or IN"}
+	}
+	return units, nil
+}
+
+// getMeasurementUnits returns the units of the transaction's weight and dimension arguments
+// Transactions without TransientMeasurementUnits use metric units.
+func getMeasurementUnits(ctx contractapi.TransactionContextInterface) (MeasurementUnits, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return metricUnits, fmt.Errorf("failed to get transient data: %v", err)
+	}
+	unitsJSON, exists := transientMap[TransientMeasurementUnits]
+	if !exists || len(unitsJSON) == 0 {
+		return metricUnits, nil
+	}
+	var units MeasurementUnits
+	if err := json.Unmarshal(unitsJSON, &units); err != nil {
+		return metricUnits, fmt.Errorf("failed to parse measurement units: %v", err)
+	}
+	return newMeasurementUnits(string(units.WeightUnit), string(units.DimensionUnit))
+}
+
+// roundMeasurement drops conversion noise below a gram / a hundredth of a millimeter
+func roundMeasurement(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
+
+// toKilograms normalizes a weight in these units to kilograms
+func (u MeasurementUnits) toKilograms(weight float64) float64 {
+	if u.WeightUnit == WeightUnitPound {
+		return roundMeasurement(weight * kilogramsPerPound)
+	}
+	return weight
+}
+
+// toCentimeters normalizes a length in these units to centimeters
+func (u MeasurementUnits) toCentimeters(value float64) float64 {
+	if u.DimensionUnit == DimensionUnitInch {
+		return roundMeasurement(value * centimetersPerInch)
+	}
+	return value
+}
+
+// toMetricDimensions normalizes package dimensions in these units to centimeters
+func (u MeasurementUnits) toMetricDimensions(dimensions PackageDimensions) PackageDimensions {
+	return PackageDimensions{
+		Length: u.toCentimeters(dimensions.Length),
+		Width:  u.toCentimeters(dimensions.Width),
+		Height: u.toCentimeters(dimensions.Height),
+	}
+}
+
+// fromKilograms converts a stored weight to these units
+func (u MeasurementUnits) fromKilograms(weight float64) float64 {
+	if u.WeightUnit == WeightUnitPound {
+		return roundMeasurement(weight / kilogramsPerPound)
+	}
+	return weight
+}
+
+// fromMetricDimensions converts stored package dimensions to these units
+func (u MeasurementUnits) fromMetricDimensions(dimensions PackageDimensions) PackageDimensions {
+	if u.DimensionUnit != DimensionUnitInch {
+		return dimensions
+	}
+	return PackageDimensions{
+		Length: roundMeasurement(dimensions.Length / centimetersPerInch),
+		Width:  roundMeasurement(dimensions.Width / centimetersPerInch),
+		Height: roundMeasurement(dimensions.Height / centimetersPerInch),
+	}
+}
+
+// PackageMeasurements is a delivery's weight and dimensions converted to the requested units
+type PackageMeasurements struct {
+	DeliveryID    string            `json:"deliveryId"`
+	Weight        float64           `json:"weight"`
+	WeightUnit    WeightUnit        `json:"weightUnit"`
+	Dimensions    PackageDimensions `json:"dimensions"`
+	DimensionUnit DimensionUnit     `json:"dimensionUnit"`
+}
+
+// GetPackageMeasurements returns a delivery's weight and dimensions in the requested units
+// Empty units default to KG and CM. Anyone who can read the delivery can read its measurements.
+func (c *DeliveryContract) GetPackageMeasurements(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	weightUnit string,
+	dimensionUnit string,
+) (*PackageMeasurements, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+	units, err := newMeasurementUnits(weightUnit, dimensionUnit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - all roles can read
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := readDeliveryForCaller(ctx, caller, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PackageMeasurements{
+		DeliveryID:    deliveryID,
+		Weight:        units.fromKilograms(delivery.PackageWeight),
+		WeightUnit:    units.WeightUnit,
+		Dimensions:    units.fromMetricDimensions(delivery.PackageDimensions),
+		DimensionUnit: units.DimensionUnit,
+	}, nil
+}
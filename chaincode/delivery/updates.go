@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// IndexUpdatedDelivery lists deliveries by the UTC day they were last modified
+const IndexUpdatedDelivery = "updated~bucket~deliveryId"
+
+// updatedBucketLayout is the time layout of an updated~bucket bucket (one per UTC day)
+const updatedBucketLayout = "2006-01-02"
+
+// maxUpdatedSinceDays bounds how far back QueryDeliveriesUpdatedSince can look
+const maxUpdatedSinceDays = 90
+
+// updatedBucket returns the bucket of an RFC 3339 timestamp ("" if it can't be parsed)
+func updatedBucket(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(updatedBucketLayout)
+}
+
+// syncUpdatedIndex moves a delivery's updated~bucket entry from its previous to its new version
+// Either side may be nil: nil previous on creation, nil delivery on deletion.
+func syncUpdatedIndex(ctx contractapi.TransactionContextInterface, previous *Delivery, delivery *Delivery) error {
+	stub := ctx.GetStub()
+
+	bucket := ""
+	if delivery != nil {
+		bucket = updatedBucket(delivery.UpdatedAt)
+	}
+	if previous != nil {
+		if previousBucket := updatedBucket(previous.UpdatedAt); previousBucket != "" && previousBucket != bucket {
+			previousKey, err := stub.CreateCompositeKey(IndexUpdatedDelivery, []string{previousBucket, previous.DeliveryID})
+			if err != nil {
+				return fmt.Errorf("failed to create updated composite key: %v", err)
+			}
+			if err := stub.DelState(previousKey); err != nil {
+				return fmt.Errorf("failed to delete updated index: %v", err)
+			}
+		}
+	}
+	if bucket == "" {
+		return nil
+	}
+
+	updatedKey, err := stub.CreateCompositeKey(IndexUpdatedDelivery, []string{bucket, delivery.DeliveryID})
+	if err != nil {
+		return fmt.Errorf("failed to create updated composite key: %v", err)
+	}
+	if err := stub.PutState(updatedKey, []byte{0x00}); err != nil {
+		return fmt.Errorf("failed to put updated index: %v", err)
+	}
+	return nil
+}
+
+// QueryDeliveriesUpdatedSince returns the caller's deliveries modified at or after timestamp
+// Walks the updated~bucket index one UTC day at a time, up to 90 days back. Non-admin callers
+// only receive deliveries they are involved with, so a page can hold fewer than pageSize.
+// The bookmark is "<day>|<index bookmark>"; an empty bookmark means the client is up to date.
+func (c *DeliveryContract) QueryDeliveriesUpdatedSince(
+	ctx contractapi.TransactionContextInterface,
+	timestamp string,
+	pageSize int,
+	bookmark string,
+) (*DeliveryQueryPage, error) {
+	// ========== INPUT VALIDATION ==========
+	since, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return nil, &ValidationError{Field: "timestamp", Message: "must be an RFC 3339 timestamp"}
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		return nil, &ValidationError{Field: "pageSize", Message: "must be between 1 and 100"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if since.Before(txTime.AddDate(0, 0, -maxUpdatedSinceDays)) {
+		return nil, &ValidationError{Field: "timestamp", Message: fmt.Sprintf("cannot be more than %d days ago", maxUpdatedSinceDays)}
+	}
+	sinceUTC := since.UTC().Format(time.RFC3339)
+
+	day := since.UTC().Truncate(24 * time.Hour)
+	indexBookmark := ""
+	if bookmark != "" {
+		parts := strings.SplitN(bookmark, "|", 2)
+		bookmarkDay, err := time.Parse(updatedBucketLayout, parts[0])
+		if err != nil || len(parts) != 2 {
+			return nil, &ValidationError{Field: "bookmark", Message: "malformed bookmark"}
+		}
+		day = bookmarkDay
+		indexBookmark = parts[1]
+	}
+
+	stub := ctx.GetStub()
+	page := &DeliveryQueryPage{Deliveries: []*Delivery{}}
+	for !day.After(txTime) {
+		bucket := day.Format(updatedBucketLayout)
+		remaining := int32(pageSize) - page.FetchedCount
+
+		iterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(IndexUpdatedDelivery, []string{bucket}, remaining, indexBookmark)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query updated index: %v", err)
+		}
+		for iterator.HasNext() {
+			response, err := iterator.Next()
+			if err != nil {
+				iterator.Close()
+				return nil, fmt.Errorf("failed to iterate updated index: %v", err)
+			}
+			_, attrs, err := stub.SplitCompositeKey(response.Key)
+			if err != nil || len(attrs) != 2 {
+				continue
+			}
+
+			deliveryJSON, err := stub.GetState(attrs[1])
+			if err != nil || deliveryJSON == nil {
+				continue
+			}
+			var delivery Delivery
+			if err := json.Unmarshal(deliveryJSON, &delivery); err != nil {
+				continue
+			}
+			// Same-day changes before the timestamp were already seen
+			if delivery.UpdatedAt < sinceUTC {
+				continue
+			}
+			if err := validateInvolvement(&delivery, caller); err != nil {
+				continue
+			}
+			delivery.StateHash = deliveryStateHash(deliveryJSON)
+			page.Deliveries = append(page.Deliveries, &delivery)
+		}
+		iterator.Close()
+
+		page.FetchedCount += metadata.FetchedRecordsCount
+		if page.FetchedCount >= int32(pageSize) {
+			page.Bookmark = bucket + "|" + metadata.Bookmark
+			break
+		}
+		day = day.AddDate(0, 0, 1)
+		indexBookmark = ""
+	}
+
+	return page, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// IndexUserStatusDelivery lists each party's deliveries by status
+// A delivery has one entry per involved user: seller, customer and current custodian
+// (closed deliveries have no custodian entry).
+const IndexUserStatusDelivery = "user~status~deliveryId"
+
+// involvedUserIDs returns the distinct users a delivery is listed under in the user~status index
+func involvedUserIDs(delivery *Delivery) []string {
+	custodianID := delivery.CurrentCustodianID
+	if closedStatuses[delivery.DeliveryStatus] {
+		custodianID = ""
+	}
+
+	var userIDs []string
+	seen := map[string]bool{}
+	for _, userID := range []string{delivery.SellerID, delivery.CustomerID, custodianID} {
+		if userID == "" || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs
+}
+
+// syncUserStatusIndex moves a delivery's user~status entries from its previous to its new version
+// Either side may be nil: nil previous on creation, nil delivery on deletion.
+// New entries are always written so deliveries stored before the index existed pick it up.
+func syncUserStatusIndex(ctx contractapi.TransactionContextInterface, previous *Delivery, delivery *Delivery) error {
+	stub := ctx.GetStub()
+
+	current := map[string]bool{}
+	if delivery != nil {
+		for _, userID := range involvedUserIDs(delivery) {
+			key, err := stub.CreateCompositeKey(IndexUserStatusDelivery, []string{userID, string(delivery.DeliveryStatus), delivery.DeliveryID})
+			if err != nil {
+				return fmt.Errorf("failed to create user status composite key: %v", err)
+			}
+			current[key] = true
+			if err := stub.PutState(key, []byte{0x00}); err != nil {
+				return fmt.Errorf("failed to put user status index: %v", err)
+			}
+		}
+	}
+
+	if previous == nil {
+		return nil
+	}
+	for _, userID := range involvedUserIDs(previous) {
+		key, err := stub.CreateCompositeKey(IndexUserStatusDelivery, []string{userID, string(previous.DeliveryStatus), previous.DeliveryID})
+		if err != nil {
+			return fmt.Errorf("failed to create user status composite key: %v", err)
+		}
+		if current[key] {
+			continue
+		}
+		if err := stub.DelState(key); err != nil {
+			return fmt.Errorf("failed to delete user status index: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetDeliveriesByStatusForUser returns one user's deliveries in a given status
+// Any participant can list their own deliveries (as seller, customer or custodian);
+// ADMIN can pass another user's ID. An empty userID means the caller.
+func (c *DeliveryContract) GetDeliveriesByStatusForUser(
+	ctx contractapi.TransactionContextInterface,
+	status string,
+	userID string,
+) ([]*Delivery, error) {
+	// ========== INPUT VALIDATION ==========
+	if status == "" {
+		return nil, &ValidationError{Field: "status", Message: "cannot be empty"}
+	}
+	if userID != "" {
+		if err := validateUserID(userID, "userID"); err != nil {
+			return nil, err
+		}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	if userID == "" {
+		userID = caller.ID
+	}
+	if userID != caller.ID && caller.Role != RoleAdmin {
+		return nil, fmt.Errorf("only ADMIN can list another user's deliveries")
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(IndexUserStatusDelivery, []string{userID, status})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries by user and status: %v", err)
+	}
+	defer iterator.Close()
+
+	deliveries := []*Delivery{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate user status index: %v", err)
+		}
+
+		// Extract deliveryID from composite key
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+		if len(compositeKeyParts) < 3 {
+			continue
+		}
+		deliveryID := compositeKeyParts[2]
+
+		// Fetch the actual delivery
+		deliveryBytes, err := ctx.GetStub().GetState(deliveryID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get delivery %s: %v", deliveryID, err)
+		}
+		if deliveryBytes == nil {
+			continue
+		}
+
+		var delivery Delivery
+		if err := json.Unmarshal(deliveryBytes, &delivery); err != nil {
+			continue
+		}
+		delivery.StateHash = deliveryStateHash(deliveryBytes)
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key prefixes for certified weighing
+const (
+	RecordWeighingStation   = "weighingStation~stationId"
+	RecordWeightMeasurement = "weighing~deliveryId~txId"
+	RecordBillingAdjustment = "billingAdjustment~deliveryId~txId"
+)
+
+// BillingReasonWeightCheck is the reason of adjustments for certified weight discrepancies
+const BillingReasonWeightCheck = "CERTIFIED_WEIGHT_DISCREPANCY"
+
+// Event names for certified weighing
+const (
+	EventCertifiedWeightRecorded = "CertifiedWeightRecorded"
+	EventBillingAdjustment       = "BillingAdjustmentRecorded"
+)
+
+// maxWeightMeasurements bounds the certified measurements kept per delivery
+const maxWeightMeasurements = 50
+
+// WeighingStation is a SENSOR identity certified to weigh packages
+type WeighingStation struct {
+	StationID       string `json:"stationId"`
+	MSP             string `json:"msp"`
+	CertificationID string `json:"certificationId"`
+	CertifiedUntil  string `json:"certifiedUntil"`
+	RegisteredBy    string `json:"registeredBy"`
+	RegisteredAt    string `json:"registeredAt"`
+}
+
+// WeightMeasurement is a weight appended by a certified station, signed by the station's
+// certificate through the transaction that recorded it
+type WeightMeasurement struct {
+	DeliveryID      string  `json:"deliveryId"`
+	TxID            string  `json:"txId"`
+	StationID       string  `json:"stationId"`
+	StationMSP      string  `json:"stationMsp"`
+	CertificationID string  `json:"certificationId"`
+	Weight          float64 `json:"weight"`
+	DeclaredWeight  float64 `json:"declaredWeight"`
+	Discrepancy     bool    `json:"discrepancy"`
+	MeasuredAt      string  `json:"measuredAt"`
+}
+
+// BillingAdjustment asks the fee/settlement subsystem to re-bill the seller for a package that
+// weighed more than the tolerance off its declared weight
+// Adjustments are attached to the delivery's settlement record at final delivery.
+type BillingAdjustment struct {
+	DeliveryID       string  `json:"deliveryId"`
+	OrderID          string  `json:"orderId"`
+	TxID             string  `json:"txId"`
+	Reason           string  `json:"reason"`
+	Payer            string  `json:"payer"`
+	DeclaredWeight   float64 `json:"declaredWeight"`
+	MeasuredWeight   float64 `json:"measuredWeight"`
+	DeviationPercent float64 `json:"deviationPercent"`
+	StationID        string  `json:"stationId"`
+	RecordedAt       string  `json:"recordedAt"`
+}
+
+// declaredWeight returns the weight the seller declared for a delivery
+// Deliveries created before the declared weight was kept fall back to their current weight.
+func declaredWeight(delivery *Delivery) float64 {
+	if delivery.DeclaredWeight > 0 {
+		return delivery.DeclaredWeight
+	}
+	return delivery.PackageWeight
+}
+
+// getWeighingStation reads a weighing station, returning nil if it is not registered
+func getWeighingStation(ctx contractapi.TransactionContextInterface, stationID string) (*WeighingStation, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(RecordWeighingStation, []string{stationID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create weighing station composite key: %v", err)
+	}
+	stationJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read weighing station: %v", err)
+	}
+	if stationJSON == nil {
+		return nil, nil
+	}
+	var station WeighingStation
+	if err := json.Unmarshal(stationJSON, &station); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal weighing station: %v", err)
+	}
+	return &station, nil
+}
+
+// getBillingAdjustments lists the billing adjustments recorded for a delivery
+func getBillingAdjustments(ctx contractapi.TransactionContextInterface, deliveryID string) ([]BillingAdjustment, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordBillingAdjustment, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing adjustments: %v", err)
+	}
+	defer iterator.Close()
+
+	adjustments := []BillingAdjustment{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate billing adjustments: %v", err)
+		}
+		var adjustment BillingAdjustment
+		if err := json.Unmarshal(response.Value, &adjustment); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal billing adjustment: %v", err)
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+	return adjustments, nil
+}
+
+// RegisterWeighingStation registers (or re-certifies) a weighing station identity
+// Only ADMIN can register stations. The station signs its measurements with a SENSOR
+// certificate of mspID; its certification lapses after certifiedUntil (RFC3339).
+func (c *DeliveryContract) RegisterWeighingStation(
+	ctx contractapi.TransactionContextInterface,
+	stationID string,
+	mspID string,
+	certificationID string,
+	certifiedUntil string,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateUserID(stationID, "stationID"); err != nil {
+		return err
+	}
+	if mspID != MSPPlatform && mspID != MSPSellers && mspID != MSPLogistics {
+		return &ValidationError{Field: "mspID", Message: fmt.Sprintf("unknown organization: %s", mspID)}
+	}
+	certificationID = sanitizeText(certificationID)
+	if err := validateText(certificationID, "certificationID", 100, true); err != nil {
+		return err
+	}
+	until, err := time.Parse(time.RFC3339, certifiedUntil)
+	if err != nil {
+		return &ValidationError{Field: "certifiedUntil", Message: "must be an RFC3339 timestamp"}
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - only an ADMIN of the whole marketplace can certify stations
+	if err := validateUnscopedAdmin(ctx, caller); err != nil {
+		return err
+	}
+
+	existing, err := getUserProfile(ctx, stationID)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Role != RoleSensor {
+		return fmt.Errorf("user %s is already registered with role %s", stationID, existing.Role)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if !until.After(now) {
+		return &ValidationError{Field: "certifiedUntil", Message: "must be in the future"}
+	}
+	currentTime := now.Format(time.RFC3339)
+
+	if err := putUserProfile(ctx, &UserProfile{
+		UserID:    stationID,
+		Role:      RoleSensor,
+		MSP:       mspID,
+		UpdatedAt: currentTime,
+	}); err != nil {
+		return err
+	}
+
+	station := WeighingStation{
+		StationID:       stationID,
+		MSP:             mspID,
+		CertificationID: certificationID,
+		CertifiedUntil:  until.UTC().Format(time.RFC3339),
+		RegisteredBy:    caller.ID,
+		RegisteredAt:    currentTime,
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(RecordWeighingStation, []string{stationID})
+	if err != nil {
+		return fmt.Errorf("failed to create weighing station composite key: %v", err)
+	}
+	stationJSON, err := json.Marshal(station)
+	if err != nil {
+		return fmt.Errorf("failed to marshal weighing station: %v", err)
+	}
+	return ctx.GetStub().PutState(key, stationJSON)
+}
+
+// RecordCertifiedWeight appends a certified station's weight measurement to a delivery
+// Only a registered, currently certified station can record, while the delivery is active.
+// A weight more than weightDiscrepancyTolerancePercent off the seller-declared weight also
+// writes a BillingAdjustment for the settlement. Measurements live under their own keys, so
+// weighing never contends with custody updates.
+func (c *DeliveryContract) RecordCertifiedWeight(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+	weight float64,
+) error {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return err
+	}
+	units, err := getMeasurementUnits(ctx)
+	if err != nil {
+		return err
+	}
+	weight = units.toKilograms(weight)
+	if err := validatePackageWeight(weight); err != nil {
+		return err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - stations sign with device (SENSOR) certificates
+	if err := validateRole(ctx, caller, RoleSensor); err != nil {
+		return err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	station, err := getWeighingStation(ctx, caller.ID)
+	if err != nil {
+		return err
+	}
+	if station == nil || station.MSP != caller.MSP {
+		return fmt.Errorf("%s is not a registered weighing station", caller.ID)
+	}
+	certifiedUntil, err := time.Parse(time.RFC3339, station.CertifiedUntil)
+	if err != nil {
+		return fmt.Errorf("invalid certification expiry: %v", err)
+	}
+	if !now.Before(certifiedUntil) {
+		return fmt.Errorf("certification %s of weighing station %s expired at %s", station.CertificationID, caller.ID, station.CertifiedUntil)
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	switch delivery.DeliveryStatus {
+	case StatusConfirmedDelivery, StatusCancelled, StatusSplit, StatusMerged, StatusExported, StatusReturnCompleted:
+		return fmt.Errorf("cannot weigh a delivery in current status: %s", delivery.DeliveryStatus)
+	}
+
+	stub := ctx.GetStub()
+	iterator, err := stub.GetStateByPartialCompositeKey(RecordWeightMeasurement, []string{deliveryID})
+	if err != nil {
+		return fmt.Errorf("failed to get weight measurements: %v", err)
+	}
+	count := 0
+	for iterator.HasNext() {
+		if _, err := iterator.Next(); err != nil {
+			iterator.Close()
+			return fmt.Errorf("failed to iterate weight measurements: %v", err)
+		}
+		count++
+	}
+	iterator.Close()
+	if count >= maxWeightMeasurements {
+		return fmt.Errorf("delivery %s already has %d weight measurements", deliveryID, maxWeightMeasurements)
+	}
+
+	settings, err := getContractSettings(ctx)
+	if err != nil {
+		return err
+	}
+	currentTime := now.Format(time.RFC3339)
+	declared := declaredWeight(delivery)
+	deviation := math.Abs(weight-declared) / declared * 100
+
+	measurement := WeightMeasurement{
+		DeliveryID:      deliveryID,
+		TxID:            stub.GetTxID(),
+		StationID:       caller.ID,
+		StationMSP:      caller.MSP,
+		CertificationID: station.CertificationID,
+		Weight:          weight,
+		DeclaredWeight:  declared,
+		Discrepancy:     deviation > float64(settings.WeightDiscrepancyTolerancePercent),
+		MeasuredAt:      currentTime,
+	}
+	measurementKey, err := stub.CreateCompositeKey(RecordWeightMeasurement, []string{deliveryID, measurement.TxID})
+	if err != nil {
+		return fmt.Errorf("failed to create weight measurement composite key: %v", err)
+	}
+	measurementJSON, err := json.Marshal(measurement)
+	if err != nil {
+		return fmt.Errorf("failed to marshal weight measurement: %v", err)
+	}
+	if err := stub.PutState(measurementKey, measurementJSON); err != nil {
+		return fmt.Errorf("failed to put weight measurement: %v", err)
+	}
+
+	if !measurement.Discrepancy {
+		return emitEvent(ctx, EventCertifiedWeightRecorded, measurement)
+	}
+
+	adjustment := BillingAdjustment{
+		DeliveryID:       deliveryID,
+		OrderID:          delivery.OrderID,
+		TxID:             measurement.TxID,
+		Reason:           BillingReasonWeightCheck,
+		Payer:            delivery.SellerID,
+		DeclaredWeight:   declared,
+		MeasuredWeight:   weight,
+		DeviationPercent: math.Round(deviation*100) / 100,
+		StationID:        caller.ID,
+		RecordedAt:       currentTime,
+	}
+	adjustmentKey, err := stub.CreateCompositeKey(RecordBillingAdjustment, []string{deliveryID, adjustment.TxID})
+	if err != nil {
+		return fmt.Errorf("failed to create billing adjustment composite key: %v", err)
+	}
+	adjustmentJSON, err := json.Marshal(adjustment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal billing adjustment: %v", err)
+	}
+	if err := stub.PutState(adjustmentKey, adjustmentJSON); err != nil {
+		return fmt.Errorf("failed to put billing adjustment: %v", err)
+	}
+
+	return emitEvent(ctx, EventBillingAdjustment, adjustment)
+}
+
+// GetWeightMeasurements returns the certified weight measurements of a delivery
+func (c *DeliveryContract) GetWeightMeasurements(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]*WeightMeasurement, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role
+	if err := validateRole(ctx, caller, readerRoles...); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvolvement(delivery, caller); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordWeightMeasurement, []string{deliveryID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weight measurements: %v", err)
+	}
+	defer iterator.Close()
+
+	measurements := []*WeightMeasurement{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate weight measurements: %v", err)
+		}
+		var measurement WeightMeasurement
+		if err := json.Unmarshal(response.Value, &measurement); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal weight measurement: %v", err)
+		}
+		measurements = append(measurements, &measurement)
+	}
+
+	return measurements, nil
+}
+
+// GetBillingAdjustments returns the billing adjustments recorded for a delivery
+// Only the SELLER of the delivery and ADMIN can read them.
+func (c *DeliveryContract) GetBillingAdjustments(
+	ctx contractapi.TransactionContextInterface,
+	deliveryID string,
+) ([]BillingAdjustment, error) {
+	// ========== INPUT VALIDATION ==========
+	if err := validateDeliveryID(deliveryID); err != nil {
+		return nil, err
+	}
+
+	// Extract caller identity from X.509 certificate
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %v", err)
+	}
+
+	// Validate role - adjustments bill the seller
+	if err := validateRole(ctx, caller, RoleSeller, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	delivery, err := c.readDeliveryInternal(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.TenantID != caller.TenantID || (caller.Role == RoleSeller && delivery.SellerID != caller.ID) {
+		return nil, fmt.Errorf("not authorized to access this delivery")
+	}
+
+	return getBillingAdjustments(ctx, deliveryID)
+}
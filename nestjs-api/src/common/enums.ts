@@ -10,12 +10,15 @@ export enum DeliveryStatus {
   PENDING_PICKUP_HANDOFF = 'PENDING_PICKUP_HANDOFF',
   DISPUTED_PICKUP_HANDOFF = 'DISPUTED_PICKUP_HANDOFF',
   IN_TRANSIT = 'IN_TRANSIT',
+  OUT_FOR_DELIVERY = 'OUT_FOR_DELIVERY',
   PENDING_TRANSIT_HANDOFF = 'PENDING_TRANSIT_HANDOFF',
   DISPUTED_TRANSIT_HANDOFF = 'DISPUTED_TRANSIT_HANDOFF',
   PENDING_DELIVERY_CONFIRMATION = 'PENDING_DELIVERY_CONFIRMATION',
   CONFIRMED_DELIVERY = 'CONFIRMED_DELIVERY',
   DISPUTED_DELIVERY = 'DISPUTED_DELIVERY',
   CANCELLED = 'CANCELLED',
+  RETURN_COMPLETED = 'RETURN_COMPLETED',
+  QUARANTINED = 'QUARANTINED',
 }
 
 export enum OrderStatus {
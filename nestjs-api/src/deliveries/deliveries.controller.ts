@@ -17,6 +17,7 @@ import { UpdateLocationDto } from './dto/update-location.dto';
 import { InitiateHandoffDto } from './dto/initiate-handoff.dto';
 import { ConfirmHandoffDto } from './dto/confirm-handoff.dto';
 import { DisputeHandoffDto } from './dto/dispute-handoff.dto';
+import { CancelDeliveryDto } from './dto/cancel-delivery.dto';
 import { RolesGuard } from '../auth/guards/roles.guard';
 import { Roles } from '../auth/decorators/roles.decorator';
 import { CurrentUser, CurrentUserData } from '../auth/decorators/current-user.decorator';
@@ -71,6 +72,21 @@ export class DeliveriesController {
     };
   }
 
+  @Get('my/status/:status')
+  @Roles(UserRole.SELLER, UserRole.CUSTOMER, UserRole.DELIVERY_PERSON, UserRole.ADMIN)
+  async getMyByStatus(
+    @CurrentUser() user: CurrentUserData,
+    @Param('status') status: DeliveryStatus,
+  ) {
+    const deliveries = await this.deliveriesService.getMyDeliveriesByStatus(user.id, status);
+
+    return {
+      success: true,
+      count: deliveries.length,
+      data: deliveries,
+    };
+  }
+
   @Get('status/:status')
   @Roles(UserRole.SELLER, UserRole.CUSTOMER, UserRole.DELIVERY_PERSON, UserRole.ADMIN)
   async getByStatus(
@@ -91,7 +107,28 @@ export class DeliveriesController {
   async getDelivery(
     @CurrentUser() user: CurrentUserData,
     @Param('id') id: string,
+    @Query('notModifiedSince') notModifiedSince?: string,
   ) {
+    if (notModifiedSince) {
+      const result = await this.deliveriesService.getDeliveryIfChanged(
+        user.id,
+        id,
+        notModifiedSince,
+      );
+
+      if (result.unchanged) {
+        return {
+          success: true,
+          unchanged: true,
+          stateHash: result.stateHash,
+        };
+      }
+      return {
+        success: true,
+        data: result.delivery,
+      };
+    }
+
     const delivery = await this.deliveriesService.getDelivery(user.id, id);
 
     return {
@@ -137,6 +174,20 @@ export class DeliveriesController {
     };
   }
 
+  @Get(':id/timeline')
+  @Roles(UserRole.SELLER, UserRole.CUSTOMER, UserRole.DELIVERY_PERSON, UserRole.ADMIN)
+  async getMilestoneTimeline(
+    @CurrentUser() user: CurrentUserData,
+    @Param('id') id: string,
+  ) {
+    const timeline = await this.deliveriesService.getMilestoneTimeline(user.id, id);
+
+    return {
+      success: true,
+      data: timeline,
+    };
+  }
+
   @Put(':id/location')
   @Roles(UserRole.DELIVERY_PERSON)
   async updateLocation(
@@ -152,13 +203,28 @@ export class DeliveriesController {
     };
   }
 
+  @Put(':id/out-for-delivery')
+  @Roles(UserRole.DELIVERY_PERSON)
+  async declareOutForDelivery(
+    @CurrentUser() user: CurrentUserData,
+    @Param('id') id: string,
+  ) {
+    await this.deliveriesService.declareOutForDelivery(user.id, id);
+
+    return {
+      success: true,
+      message: 'Delivery is out for delivery',
+    };
+  }
+
   @Put(':id/cancel')
   @Roles(UserRole.CUSTOMER)
   async cancelDelivery(
     @CurrentUser() user: CurrentUserData,
     @Param('id') id: string,
+    @Body() dto: CancelDeliveryDto,
   ) {
-    await this.deliveriesService.cancelDelivery(user.id, id);
+    await this.deliveriesService.cancelDelivery(user.id, id, dto);
 
     return {
       success: true,
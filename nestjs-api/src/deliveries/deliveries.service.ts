@@ -1,15 +1,27 @@
-import { Injectable, Logger, NotFoundException, BadRequestException } from '@nestjs/common';
+import {
+  Injectable,
+  Logger,
+  NotFoundException,
+  BadRequestException,
+  UnprocessableEntityException,
+} from '@nestjs/common';
 import { randomBytes } from 'crypto';
 
 import { FabricGatewayService } from '../fabric/fabric-gateway.service';
 import { WalletService } from '../fabric/wallet.service';
 import { UsersService } from '../users/users.service';
 import { CrossOrgVerificationService } from '../auth/cross-org-verification.service';
-import { Delivery, DeliveryHistoryRecord } from './types/delivery.types';
+import {
+  Delivery,
+  DeliveryHistoryRecord,
+  DeliveryReadResult,
+  MilestoneTimeline,
+} from './types/delivery.types';
 import { UpdateLocationDto } from './dto/update-location.dto';
 import { InitiateHandoffDto } from './dto/initiate-handoff.dto';
 import { ConfirmHandoffDto } from './dto/confirm-handoff.dto';
 import { DisputeHandoffDto } from './dto/dispute-handoff.dto';
+import { CancelDeliveryDto } from './dto/cancel-delivery.dto';
 import { DeliveryStatus, UserRole } from '../common/enums';
 
 @Injectable()
@@ -42,6 +54,22 @@ export class DeliveriesService {
     }
   }
 
+  /**
+   * Extract the field errors of a chaincode VALIDATION_FAILED error
+   * The chaincode reports every invalid field at once as a JSON array after the prefix
+   */
+  private parseValidationErrors(message: string): { field: string; message: string }[] | null {
+    const match = message?.match(/VALIDATION_FAILED: (\[.*\])/);
+    if (!match) {
+      return null;
+    }
+    try {
+      return JSON.parse(match[1]);
+    } catch {
+      return null;
+    }
+  }
+
   /**
    * Create a new delivery on the blockchain
    * Called when seller confirms an order
@@ -85,6 +113,15 @@ export class DeliveriesService {
       return deliveryId;
     } catch (error: any) {
       this.logger.error(`Failed to create delivery: ${error.message}`);
+      if (error.message?.includes('UNSERVICEABLE_DESTINATION')) {
+        throw new UnprocessableEntityException(
+          `Destination ${city}, ${state}, ${country} is outside the service coverage`,
+        );
+      }
+      const fieldErrors = this.parseValidationErrors(error.message);
+      if (fieldErrors) {
+        throw new BadRequestException({ message: 'Invalid delivery input', errors: fieldErrors });
+      }
       throw new BadRequestException(`Failed to create delivery: ${error.message}`);
     }
   }
@@ -116,6 +153,39 @@ export class DeliveriesService {
     }
   }
 
+  /**
+   * Read a delivery unless it still matches the state hash the client holds
+   */
+  async getDeliveryIfChanged(
+    userId: string,
+    deliveryId: string,
+    notModifiedSince: string,
+  ): Promise<DeliveryReadResult> {
+    await this.ensureIdentity(userId);
+
+    try {
+      const result = await this.fabricGatewayService.evaluateTransaction(
+        userId,
+        'ReadDeliveryIfChanged',
+        deliveryId,
+        notModifiedSince,
+      );
+
+      return JSON.parse(new TextDecoder().decode(result)) as DeliveryReadResult;
+    } catch (error: any) {
+      if (error.message?.includes('does not exist')) {
+        throw new NotFoundException(`Delivery ${deliveryId} not found`);
+      }
+      if (error.message?.includes('not authorized')) {
+        throw new BadRequestException('Not authorized to view this delivery');
+      }
+      if (error.message?.includes('notModifiedSince')) {
+        throw new BadRequestException(error.message);
+      }
+      throw error;
+    }
+  }
+
   /**
    * Update delivery location (delivery person only)
    */
@@ -143,6 +213,26 @@ export class DeliveriesService {
     }
   }
 
+  /**
+   * Start the last-mile run of a delivery (courier custodian only)
+   */
+  async declareOutForDelivery(userId: string, deliveryId: string): Promise<void> {
+    await this.ensureIdentity(userId);
+
+    try {
+      await this.fabricGatewayService.submitTransaction(
+        userId,
+        'DeclareOutForDelivery',
+        deliveryId,
+      );
+
+      this.logger.log(`Delivery ${deliveryId} is out for delivery`);
+    } catch (error: any) {
+      this.logger.error(`Failed to declare out for delivery: ${error.message}`);
+      throw new BadRequestException(`Failed to declare out for delivery: ${error.message}`);
+    }
+  }
+
   /**
    * Initiate a handoff to another user
    */
@@ -250,7 +340,8 @@ export class DeliveriesService {
         userId,
         'DisputeHandoff',
         deliveryId,
-        dto.reason,
+        dto.reasonCode,
+        dto.reason ?? '',
       );
 
       this.logger.log(`Disputed handoff for delivery ${deliveryId}`);
@@ -283,7 +374,11 @@ export class DeliveriesService {
   /**
    * Cancel a delivery (customer only, before pickup)
    */
-  async cancelDelivery(userId: string, deliveryId: string): Promise<void> {
+  async cancelDelivery(
+    userId: string,
+    deliveryId: string,
+    dto: CancelDeliveryDto,
+  ): Promise<void> {
     await this.ensureIdentity(userId);
 
     try {
@@ -291,6 +386,8 @@ export class DeliveriesService {
         userId,
         'CancelDelivery',
         deliveryId,
+        dto.reasonCode,
+        dto.reason ?? '',
       );
 
       this.logger.log(`Cancelled delivery ${deliveryId}`);
@@ -340,6 +437,27 @@ export class DeliveriesService {
     }
   }
 
+  /**
+   * Query the current user's deliveries in one status
+   */
+  async getMyDeliveriesByStatus(userId: string, status: DeliveryStatus): Promise<Delivery[]> {
+    await this.ensureIdentity(userId);
+
+    try {
+      const result = await this.fabricGatewayService.evaluateTransaction(
+        userId,
+        'GetDeliveriesByStatusForUser',
+        status,
+        '',
+      );
+
+      return JSON.parse(new TextDecoder().decode(result)) as Delivery[];
+    } catch (error: any) {
+      this.logger.error(`Failed to query deliveries by status: ${error.message}`);
+      return [];
+    }
+  }
+
   /**
    * Get delivery history from blockchain
    */
@@ -365,6 +483,31 @@ export class DeliveriesService {
     }
   }
 
+  /**
+   * Get expected vs actual progress milestones from blockchain
+   */
+  async getMilestoneTimeline(userId: string, deliveryId: string): Promise<MilestoneTimeline> {
+    await this.ensureIdentity(userId);
+
+    try {
+      const result = await this.fabricGatewayService.evaluateTransaction(
+        userId,
+        'GetMilestoneTimeline',
+        deliveryId,
+      );
+
+      return JSON.parse(new TextDecoder().decode(result)) as MilestoneTimeline;
+    } catch (error: any) {
+      if (error.message?.includes('does not exist')) {
+        throw new NotFoundException(`Delivery ${deliveryId} not found`);
+      }
+      if (error.message?.includes('not authorized')) {
+        throw new BadRequestException('Not authorized to view this delivery');
+      }
+      throw error;
+    }
+  }
+
   /**
    * Get customer delivery address (for delivery persons)
    */
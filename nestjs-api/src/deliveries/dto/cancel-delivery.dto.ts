@@ -0,0 +1,13 @@
+import { IsString, MinLength, MaxLength, IsOptional } from 'class-validator';
+
+export class CancelDeliveryDto {
+  @IsString()
+  @MinLength(1)
+  @MaxLength(50)
+  reasonCode: string;
+
+  @IsOptional()
+  @IsString()
+  @MaxLength(500)
+  reason?: string;
+}
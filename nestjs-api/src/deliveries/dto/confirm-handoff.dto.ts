@@ -1,4 +1,4 @@
-import { IsString, IsNumber, Min, Max, MinLength, MaxLength, IsOptional } from 'class-validator';
+import { IsString, IsNumber, Min, Max, MinLength, MaxLength, IsOptional, Matches } from 'class-validator';
 
 export class ConfirmHandoffDto {
   @IsString()
@@ -12,8 +12,7 @@ export class ConfirmHandoffDto {
   state: string;
 
   @IsString()
-  @MinLength(1)
-  @MaxLength(100)
+  @Matches(/^[A-Za-z]{2}$/, { message: 'country must be an ISO 3166-1 alpha-2 code' })
   country: string;
 
   @IsOptional()
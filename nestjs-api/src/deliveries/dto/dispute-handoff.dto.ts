@@ -1,8 +1,13 @@
-import { IsString, MinLength, MaxLength } from 'class-validator';
+import { IsString, MinLength, MaxLength, IsOptional } from 'class-validator';
 
 export class DisputeHandoffDto {
   @IsString()
   @MinLength(1)
+  @MaxLength(50)
+  reasonCode: string;
+
+  @IsOptional()
+  @IsString()
   @MaxLength(500)
-  reason: string;
+  reason?: string;
 }
@@ -1,4 +1,4 @@
-import { IsString, MinLength, MaxLength } from 'class-validator';
+import { IsString, MinLength, MaxLength, Matches } from 'class-validator';
 
 export class UpdateLocationDto {
   @IsString()
@@ -12,7 +12,6 @@ export class UpdateLocationDto {
   state: string;
 
   @IsString()
-  @MinLength(1)
-  @MaxLength(100)
+  @Matches(/^[A-Za-z]{2}$/, { message: 'country must be an ISO 3166-1 alpha-2 code' })
   country: string;
 }
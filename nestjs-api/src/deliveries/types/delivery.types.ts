@@ -29,15 +29,38 @@ export interface Delivery {
   packageDimensions: PackageDimensions;
   deliveryStatus: DeliveryStatus;
   lastLocation: Location;
+  destination?: Location;
   currentCustodianId: string;
   currentCustodianRole: UserRole;
   pendingHandoff?: PendingHandoff;
+  stateHash?: string;
   updatedAt: string;
 }
 
+export interface DeliveryReadResult {
+  unchanged: boolean;
+  stateHash: string;
+  delivery?: Delivery;
+}
+
 export interface DeliveryHistoryRecord {
   txId: string;
   timestamp: any;
   isDelete: boolean;
   delivery: Delivery;
 }
+
+export interface MilestoneTimelineEntry {
+  milestone: 'PICKED_UP' | 'AT_HUB' | 'OUT_FOR_DELIVERY' | 'DELIVERED';
+  expectedAt: string;
+  actualAt?: string;
+  varianceMinutes: number;
+  state: 'ON_TIME' | 'LATE' | 'PENDING' | 'OVERDUE';
+}
+
+export interface MilestoneTimeline {
+  deliveryId: string;
+  serviceTier: string;
+  createdAt: string;
+  milestones: MilestoneTimelineEntry[];
+}
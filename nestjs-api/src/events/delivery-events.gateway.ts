@@ -13,6 +13,7 @@ import { Logger, UseGuards } from '@nestjs/common';
 import { OnEvent } from '@nestjs/event-emitter';
 import { JwtService } from '@nestjs/jwt';
 import { ConfigService } from '@nestjs/config';
+import { EventAudience } from '../fabric/chaincode-events.service';
 
 interface AuthenticatedSocket extends Socket {
   userId?: string;
@@ -191,6 +192,18 @@ export class DeliveryEventsGateway
     return { success: true, message: `Subscribed to user ${userId}` };
   }
 
+  /**
+   * Rooms to notify for a delivery event: the delivery room plus every user in the
+   * event's audience (socket.io delivers once per socket even if it is in several rooms)
+   */
+  private deliveryRooms(deliveryId: string, audience?: EventAudience): string[] {
+    const rooms = [`delivery:${deliveryId}`];
+    for (const userId of audience?.userIds ?? []) {
+      rooms.push(`user:${userId}`);
+    }
+    return rooms;
+  }
+
   // =====================================================
   // Chaincode Event Handlers
   // =====================================================
@@ -198,14 +211,14 @@ export class DeliveryEventsGateway
   @OnEvent('chaincode.delivery.created')
   handleDeliveryCreated(event: {
     type: string;
-    payload: { deliveryId: string; orderId: string; newStatus: string; timestamp: string };
+    payload: { deliveryId: string; orderId: string; newStatus: string; timestamp: string; audience?: EventAudience };
     transactionId: string;
     blockNumber: bigint;
   }) {
-    const { deliveryId } = event.payload;
+    const { deliveryId, audience } = event.payload;
 
-    // Emit to delivery room
-    this.server.to(`delivery:${deliveryId}`).emit('delivery:created', {
+    // Emit to delivery room and the delivery's parties
+    this.server.to(this.deliveryRooms(deliveryId, audience)).emit('delivery:created', {
       ...event.payload,
       transactionId: event.transactionId,
       blockNumber: event.blockNumber.toString(),
@@ -223,14 +236,15 @@ export class DeliveryEventsGateway
       oldStatus: string;
       newStatus: string;
       timestamp: string;
+      audience?: EventAudience;
     };
     transactionId: string;
     blockNumber: bigint;
   }) {
-    const { deliveryId } = event.payload;
+    const { deliveryId, audience } = event.payload;
 
-    // Emit to delivery room
-    this.server.to(`delivery:${deliveryId}`).emit('delivery:statusChanged', {
+    // Emit to delivery room and the delivery's parties
+    this.server.to(this.deliveryRooms(deliveryId, audience)).emit('delivery:statusChanged', {
       ...event.payload,
       transactionId: event.transactionId,
       blockNumber: event.blockNumber.toString(),
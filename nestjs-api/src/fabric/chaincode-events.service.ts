@@ -13,12 +13,20 @@ import * as path from 'path';
 import { WalletService } from './wallet.service';
 import { createIdentity, createSigner } from './fabric.types';
 
+// Who an event concerns, attached by the chaincode to events of transactions that wrote deliveries
+export interface EventAudience {
+  tenantId?: string;
+  userIds: string[];
+  msps: string[];
+}
+
 // Event types emitted by the chaincode
 export interface DeliveryCreatedEvent {
   deliveryId: string;
   orderId: string;
   newStatus: string;
   timestamp: string;
+  audience?: EventAudience;
 }
 
 export interface DeliveryStatusChangedEvent {
@@ -27,6 +35,7 @@ export interface DeliveryStatusChangedEvent {
   oldStatus: string;
   newStatus: string;
   timestamp: string;
+  audience?: EventAudience;
 }
 
 export interface HandoffInitiatedEvent {
@@ -35,6 +44,7 @@ export interface HandoffInitiatedEvent {
   fromUserId: string;
   toUserId: string;
   timestamp: string;
+  audience?: EventAudience;
 }
 
 export interface HandoffConfirmedEvent {
@@ -42,6 +52,7 @@ export interface HandoffConfirmedEvent {
   orderId: string;
   newCustodianId: string;
   timestamp: string;
+  audience?: EventAudience;
 }
 
 export interface HandoffDisputedEvent {
@@ -49,6 +60,20 @@ export interface HandoffDisputedEvent {
   orderId: string;
   disputedBy: string;
   timestamp: string;
+  audience?: EventAudience;
+}
+
+// Emitted when the seller confirms a returned package arrived; drives refunds in the orders system
+export interface ReturnCompletedEvent {
+  deliveryId: string;
+  orderId: string;
+  sellerId: string;
+  customerId: string;
+  receivedFrom: string;
+  condition: 'GOOD' | 'DAMAGED' | 'TAMPERED';
+  disposition: '' | 'RESTOCK' | 'REFURBISH' | 'LIQUIDATE' | 'DISPOSE';
+  timestamp: string;
+  audience?: EventAudience;
 }
 
 // Union type for all chaincode events
@@ -57,7 +82,8 @@ export type ChaincodeEventPayload =
   | { type: 'DeliveryStatusChanged'; payload: DeliveryStatusChangedEvent }
   | { type: 'HandoffInitiated'; payload: HandoffInitiatedEvent }
   | { type: 'HandoffConfirmed'; payload: HandoffConfirmedEvent }
-  | { type: 'HandoffDisputed'; payload: HandoffDisputedEvent };
+  | { type: 'HandoffDisputed'; payload: HandoffDisputedEvent }
+  | { type: 'ReturnCompleted'; payload: ReturnCompletedEvent };
 
 @Injectable()
 export class ChaincodeEventsService implements OnModuleInit, OnModuleDestroy {
@@ -362,6 +388,15 @@ export class ChaincodeEventsService implements OnModuleInit, OnModuleDestroy {
           });
           break;
 
+        case 'ReturnCompleted':
+          this.eventEmitter.emit('chaincode.return.completed', {
+            type: 'ReturnCompleted',
+            payload: payload as ReturnCompletedEvent,
+            transactionId: event.transactionId,
+            blockNumber: event.blockNumber,
+          });
+          break;
+
         default:
           this.logger.warn(`Unknown chaincode event: ${eventName}`);
           this.eventEmitter.emit('chaincode.unknown', {
@@ -1,4 +1,4 @@
-import { IsString, IsNumber, Min, Max, MinLength, MaxLength } from 'class-validator';
+import { IsString, IsNumber, Min, Max, MinLength, MaxLength, Matches } from 'class-validator';
 
 export class ConfirmOrderDto {
   @IsNumber()
@@ -32,7 +32,6 @@ export class ConfirmOrderDto {
   state: string;
 
   @IsString()
-  @MinLength(1)
-  @MaxLength(100)
+  @Matches(/^[A-Za-z]{2}$/, { message: 'country must be an ISO 3166-1 alpha-2 code' })
   country: string;
 }